@@ -0,0 +1,142 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrUnexpectedRowsAffected is an error that is returned when the number of the rows affected by
+// an update or a delete statement does not match its affectedRows attribute.
+// The check runs after the statement is executed, so outside a transaction the write is already
+// done when the error is returned, run the statement in a transaction to roll it back on the error.
+var ErrUnexpectedRowsAffected = errors.New("unexpected rows affected")
+
+// UnexpectedRowsAffectedError is the error that is returned when the number of the rows affected by
+// an update or a delete statement does not match its affectedRows attribute, it matches ErrUnexpectedRowsAffected.
+// Like ErrUnexpectedRowsAffected, the write is already done outside a transaction.
+type UnexpectedRowsAffectedError struct {
+	// Statement is the name of the statement.
+	Statement string
+
+	// Want is the affectedRows attribute of the statement, like 1 or 1-10.
+	Want string
+
+	// Got is the number of the rows affected.
+	Got int64
+}
+
+// Error implements error.
+func (e *UnexpectedRowsAffectedError) Error() string {
+	return fmt.Sprintf("%s: statement %s affected %d rows, want %s", ErrUnexpectedRowsAffected, e.Statement, e.Got, e.Want)
+}
+
+// Is reports whether the target is ErrUnexpectedRowsAffected.
+func (e *UnexpectedRowsAffectedError) Is(target error) bool {
+	return target == ErrUnexpectedRowsAffected
+}
+
+// skipAffectedRowsCheckKey is the context key which skips the affectedRows check.
+type skipAffectedRowsCheckKey struct{}
+
+// SkipAffectedRowsCheck returns a new context which skips the affectedRows check of the statements
+// executed with it.
+func SkipAffectedRowsCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipAffectedRowsCheckKey{}, true)
+}
+
+// isAffectedRowsCheckSkipped reports whether the affectedRows check is skipped by the context.
+func isAffectedRowsCheckSkipped(ctx context.Context) bool {
+	skipped, _ := ctx.Value(skipAffectedRowsCheckKey{}).(bool)
+	return skipped
+}
+
+// parseAffectedRows parses the affectedRows attribute, which is either an exact number like 1,
+// a range like 1-10, or a lower bound like 1-.
+func parseAffectedRows(value string) (minRows, maxRows int64, err error) {
+	lower, upper, isRange := strings.Cut(value, "-")
+	if minRows, err = strconv.ParseInt(strings.TrimSpace(lower), 10, 64); err != nil || minRows < 0 {
+		return 0, 0, fmt.Errorf("invalid affectedRows %q", value)
+	}
+	if !isRange {
+		return minRows, minRows, nil
+	}
+	if upper = strings.TrimSpace(upper); upper == "" {
+		return minRows, math.MaxInt64, nil
+	}
+	if maxRows, err = strconv.ParseInt(upper, 10, 64); err != nil || maxRows < minRows {
+		return 0, 0, fmt.Errorf("invalid affectedRows %q", value)
+	}
+	return minRows, maxRows, nil
+}
+
+// ensure affectedRowsMiddleware implements Middleware
+var _ Middleware = (*affectedRowsMiddleware)(nil) // compile time check
+
+// affectedRowsMiddleware is a middleware that checks the number of the rows affected by the
+// update and delete statements with the affectedRows attribute.
+//
+//	<delete id="DeleteUser" affectedRows="1">DELETE FROM user WHERE id = #{id}</delete>
+//
+// The affectedRows of the xml statements is validated when the mappers are parsed.
+// The check is skipped for the executions with the context returned by SkipAffectedRowsCheck.
+type affectedRowsMiddleware struct{}
+
+// QueryContext implements Middleware.
+// return the result directly and do nothing.
+func (m *affectedRowsMiddleware) QueryContext(_ Statement, next QueryHandler) QueryHandler {
+	return next
+}
+
+// ExecContext implements Middleware.
+// ExecContext will check the rows affected by the statement.
+func (m *affectedRowsMiddleware) ExecContext(stmt Statement, next ExecHandler) ExecHandler {
+	if action := stmt.Action(); action != Update && action != Delete {
+		return next
+	}
+	want := stmt.Attribute("affectedRows")
+	if want == "" {
+		return next
+	}
+	minRows, maxRows, err := parseAffectedRows(want)
+	return func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+		if isAffectedRowsCheckSkipped(ctx) {
+			return next(ctx, query, args...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("statement %s: %w", stmt.Name(), err)
+		}
+		result, err := next(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		got, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if got < minRows || got > maxRows {
+			return result, &UnexpectedRowsAffectedError{Statement: stmt.Name(), Want: want, Got: got}
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAffectedRowsMiddleware(t *testing.T) {
+	db := newFakeDB(t)
+	var affected int64
+	db.exec = func(string, []any) (sqldriver.Result, error) {
+		return sqldriver.RowsAffected(affected), nil
+	}
+	engine := db.Engine(t, "main", `<delete id="delete" affectedRows="1">delete from users where id = #{id}</delete>
+<update id="update" affectedRows="1-">update users set name = #{name}</update>`)
+
+	ctx := context.Background()
+	affected = 1
+	if _, err := engine.Object("main.delete").ExecContext(ctx, H{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	affected = 0
+	_, err := engine.Object("main.delete").ExecContext(ctx, H{"id": 1})
+	var rowsErr *UnexpectedRowsAffectedError
+	if !errors.As(err, &rowsErr) || !errors.Is(err, ErrUnexpectedRowsAffected) {
+		t.Fatalf("expected UnexpectedRowsAffectedError, got %v", err)
+	}
+	if rowsErr.Statement != "main.delete" || rowsErr.Want != "1" || rowsErr.Got != 0 {
+		t.Errorf("unexpected error: %+v", rowsErr)
+		return
+	}
+
+	// the check is skippable per call.
+	if _, err = engine.Object("main.delete").ExecContext(SkipAffectedRowsCheck(ctx), H{"id": 1}); err != nil {
+		t.Errorf("expected the check to be skipped, got %v", err)
+		return
+	}
+
+	affected = 5
+	if _, err = engine.Object("main.update").ExecContext(ctx, H{"name": "a"}); err != nil {
+		t.Errorf("expected at least one row to pass, got %v", err)
+		return
+	}
+	affected = 0
+	if _, err = engine.Object("main.update").ExecContext(ctx, H{"name": "a"}); !errors.Is(err, ErrUnexpectedRowsAffected) {
+		t.Errorf("expected ErrUnexpectedRowsAffected, got %v", err)
+	}
+}
+
+func TestParser_AffectedRows(t *testing.T) {
+	for _, statement := range []string{
+		`<update id="invalid" affectedRows="2-1">update users set name = #{name}</update>`,
+		`<delete id="invalid" affectedRows="one">delete from users</delete>`,
+		`<select id="invalid" affectedRows="1">select * from users</select>`,
+	} {
+		files := newTestMapperFS("unused", "main", statement)
+		if _, err := NewXMLConfigurationWithFS(files, "juice.xml"); err == nil || !strings.Contains(err.Error(), "affectedRows") {
+			t.Errorf("%s: expected the affectedRows error, got %v", statement, err)
+		}
+	}
+}
+
+func TestParseAffectedRows(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max int64
+		wantErr  bool
+	}{
+		{value: "1", min: 1, max: 1},
+		{value: "0-10", min: 0, max: 10},
+		{value: "2-", min: 2, max: 1<<63 - 1},
+		{value: "a", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "3-2", wantErr: true},
+	}
+	for _, tt := range tests {
+		minRows, maxRows, err := parseAffectedRows(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAffectedRows(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (minRows != tt.min || maxRows != tt.max) {
+			t.Errorf("parseAffectedRows(%q) = %d, %d, want %d, %d", tt.value, minRows, maxRows, tt.min, tt.max)
+		}
+	}
+}
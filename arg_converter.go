@@ -0,0 +1,166 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// RegisterArgConverter registers a converter into the default TypeHandlers, which converts the values
+// of type T bound by the #{} placeholders of all the configurations into the arguments passed to the driver,
+// registering a converter of the same type again overrides it.
+// The time.Duration values are passed as they are by default, since the columns may store them in
+// different units or as the strings, so their conversion is opt-in:
+//
+//	juice.RegisterArgConverter(func(d time.Duration) (any, error) { return int64(d), nil })
+//	juice.RegisterArgConverter(func(d time.Duration) (any, error) { return d.String(), nil })
+//
+// The converter only encodes the arguments, it is not used to decode the results.
+// It is not safe for concurrent use, converters should be registered at init time.
+//
+// Deprecated: register a TypeHandler by the type on the TypeHandlers of the configuration instead,
+// which takes precedence over the default ones for its mappers.
+func RegisterArgConverter[T any](converter func(T) (any, error)) {
+	if converter == nil {
+		panic("juice: arg converter is nil")
+	}
+	registerDefaultArgConverter(converter)
+}
+
+// registerDefaultArgConverter registers the converter of type T into the default TypeHandlers.
+func registerDefaultArgConverter[T any](converter func(T) (any, error)) {
+	defaultTypeHandlers.byType[reflect.TypeFor[T]()] = argOnlyTypeHandler{
+		ArgTypeHandler: ArgTypeHandlerFunc(func(value any) (any, error) {
+			return converter(value.(T))
+		}),
+	}
+}
+
+// durationType is the reflect.Type of time.Duration.
+var durationType = reflect.TypeFor[time.Duration]()
+
+// valuerType is the reflect.Type of driver.Valuer.
+var valuerType = reflect.TypeFor[driver.Valuer]()
+
+// basicTypes is the builtin types of the basic kinds, which the named types are converted to.
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:    reflect.TypeFor[bool](),
+	reflect.Int:     reflect.TypeFor[int](),
+	reflect.Int8:    reflect.TypeFor[int8](),
+	reflect.Int16:   reflect.TypeFor[int16](),
+	reflect.Int32:   reflect.TypeFor[int32](),
+	reflect.Int64:   reflect.TypeFor[int64](),
+	reflect.Uint:    reflect.TypeFor[uint](),
+	reflect.Uint8:   reflect.TypeFor[uint8](),
+	reflect.Uint16:  reflect.TypeFor[uint16](),
+	reflect.Uint32:  reflect.TypeFor[uint32](),
+	reflect.Uint64:  reflect.TypeFor[uint64](),
+	reflect.Float32: reflect.TypeFor[float32](),
+	reflect.Float64: reflect.TypeFor[float64](),
+	reflect.String:  reflect.TypeFor[string](),
+}
+
+// convertArg converts the value bound by a placeholder into the argument passed to the driver.
+// The handler of the type registered on the given handlers, or on the default ones, is used first.
+// Otherwise, a driver.Valuer is passed as is so the driver calls its Value method, a value whose pointer
+// implements it is passed by the pointer, and the named types of the basic kinds, like type Status int,
+// are converted to their underlying types since some drivers can not handle them, except time.Duration,
+// which is passed as is unless a handler of it is registered.
+func convertArg(handlers *TypeHandlers, name string, value reflect.Value) (any, error) {
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if handler, ok := handlers.LookupType(value.Type()); ok {
+		return encodeArg(handler, value.Type().String(), name, value)
+	}
+	if value.Type().Implements(valuerType) {
+		return value.Interface(), nil
+	}
+	// the Value method of a pointer receiver is kept by passing a pointer to the value,
+	// otherwise the named basic types would be converted and lose it.
+	if reflect.PointerTo(value.Type()).Implements(valuerType) {
+		if value.CanAddr() {
+			return value.Addr().Interface(), nil
+		}
+		ptr := reflect.New(value.Type())
+		ptr.Elem().Set(value)
+		return ptr.Interface(), nil
+	}
+	if basic, ok := basicTypes[value.Kind()]; ok && value.Type() != basic && value.Type() != durationType {
+		value = value.Convert(basic)
+		// the handlers of the basic types apply to the named types too.
+		if _, ok = handlers.LookupType(basic); ok {
+			return convertArg(handlers, name, value)
+		}
+		return value.Interface(), nil
+	}
+	return value.Interface(), nil
+}
+
+// BoolArgMode is the mode of binding the bool values, since some databases and drivers
+// expect the booleans as 0/1 or TRUE/FALSE rather than the Go bools.
+type BoolArgMode int
+
+const (
+	// BoolArgNative binds the bool values as they are, which is the default.
+	BoolArgNative BoolArgMode = iota
+
+	// BoolArgInt binds true as 1 and false as 0.
+	BoolArgInt
+
+	// BoolArgString binds true as "TRUE" and false as "FALSE".
+	BoolArgString
+)
+
+// encode encodes the bool value by the mode.
+func (m BoolArgMode) encode(b bool) any {
+	switch m {
+	case BoolArgInt:
+		if b {
+			return 1
+		}
+		return 0
+	case BoolArgString:
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return b
+	}
+}
+
+// SetBoolArgMode sets how the bool values bound by the #{} placeholders are passed to the driver globally,
+// including the named types of bool. It can be overridden by the placeholders with the type handlers
+// bool, boolInt and boolString, like #{active,typeHandler=boolInt}.
+// It is not safe for concurrent use, it should be set at init time.
+func SetBoolArgMode(mode BoolArgMode) {
+	switch mode {
+	case BoolArgNative:
+		delete(defaultTypeHandlers.byType, reflect.TypeFor[bool]())
+	case BoolArgInt, BoolArgString:
+		registerDefaultArgConverter(func(b bool) (any, error) { return mode.encode(b), nil })
+	default:
+		panic(fmt.Sprintf("juice: invalid bool arg mode %d", mode))
+	}
+}
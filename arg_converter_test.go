@@ -0,0 +1,178 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+type testArgStatus int
+
+type testArgValuer int
+
+func (v testArgValuer) Value() (sqldriver.Value, error) { return "valuer", nil }
+
+type testArgPoint struct{ X, Y int }
+
+func TestConvertArg(t *testing.T) {
+	errInvalid := errors.New("invalid point")
+	RegisterArgConverter(func(p testArgPoint) (any, error) {
+		if p.X < 0 {
+			return nil, errInvalid
+		}
+		return p.X*10 + p.Y, nil
+	})
+	t.Cleanup(func() { delete(defaultTypeHandlers.byType, reflect.TypeFor[testArgPoint]()) })
+
+	var status any = testArgStatus(2)
+	cases := []struct {
+		value    reflect.Value
+		expected any
+	}{
+		{reflect.ValueOf(time.Second), time.Second},
+		{reflect.ValueOf(testArgStatus(1)), 1},
+		{reflect.ValueOf(&status).Elem(), 2},
+		{reflect.ValueOf(testArgValuer(1)), testArgValuer(1)},
+		{reflect.ValueOf(testArgPoint{X: 1, Y: 2}), 12},
+		{reflect.ValueOf("a"), "a"},
+		{reflect.ValueOf([]byte("a")), []byte("a")},
+		{reflect.ValueOf((*any)(nil)).Elem(), nil},
+	}
+	for _, c := range cases {
+		arg, err := convertArg(nil, "arg", c.value)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !reflect.DeepEqual(arg, c.expected) {
+			t.Errorf("expected %#v, got %#v", c.expected, arg)
+		}
+	}
+	if _, err := convertArg(nil, "point", reflect.ValueOf(testArgPoint{X: -1})); !errors.Is(err, errInvalid) {
+		t.Errorf("expected errInvalid, got %v", err)
+	}
+}
+
+func TestTextNode_ArgConverter(t *testing.T) {
+	RegisterArgConverter(func(d time.Duration) (any, error) { return d.String(), nil })
+	t.Cleanup(func() { delete(defaultTypeHandlers.byType, reflect.TypeFor[time.Duration]()) })
+
+	node := NewTextNode("select * from jobs where timeout = #{timeout} and status = #{status}")
+	_, args, err := node.Accept(driver.MySQLDriver{}.Translator(), newGenericParam(H{"timeout": time.Minute, "status": testArgStatus(3)}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != "1m0s" || args[1] != 3 {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+type testArgEnum int
+
+func (e testArgEnum) Value() (sqldriver.Value, error) {
+	return [...]string{"draft", "published"}[e], nil
+}
+
+type testArgPointerEnum int
+
+func (e *testArgPointerEnum) Value() (sqldriver.Value, error) {
+	return [...]string{"low", "high"}[*e], nil
+}
+
+func TestValuerArg(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="list">
+    select * from posts where status = #{status} and priority = #{priority}
+</select>`)
+
+	type Filter struct {
+		Status   testArgEnum        `param:"status"`
+		Priority testArgPointerEnum `param:"priority"`
+	}
+	for _, param := range []any{
+		H{"status": testArgEnum(1), "priority": testArgPointerEnum(1)},
+		Filter{Status: 1, Priority: 1},
+		&Filter{Status: 1, Priority: 1},
+	} {
+		rows, err := engine.Object("main.list").QueryContext(context.Background(), param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rows.Close()
+	}
+	// the fake driver observes the arguments as they are, which the real drivers resolve by Value.
+	calls := db.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 calls, got %v", calls)
+	}
+	for _, call := range calls {
+		var values []sqldriver.Value
+		for _, arg := range call.args {
+			valuer, ok := arg.(sqldriver.Valuer)
+			if !ok {
+				t.Fatalf("expected a driver.Valuer, got %#v", arg)
+			}
+			value, err := valuer.Value()
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, value)
+		}
+		if len(values) != 2 || values[0] != "published" || values[1] != "high" {
+			t.Errorf("expected the values of the Valuers, got %v", values)
+		}
+	}
+}
+
+type testArgFlag bool
+
+func TestSetBoolArgMode(t *testing.T) {
+	t.Cleanup(func() { SetBoolArgMode(BoolArgNative) })
+
+	translator := driver.MySQLDriver{}.Translator()
+	node := NewTextNode("#{active} #{flag} #{active,typeHandler=bool} #{active,typeHandler=boolInt} #{flag,typeHandler=boolString}")
+	param := newGenericParam(H{"active": true, "flag": testArgFlag(false)}, "")
+	cases := []struct {
+		mode     BoolArgMode
+		expected []any
+	}{
+		{BoolArgNative, []any{true, false, true, 1, "FALSE"}},
+		{BoolArgInt, []any{1, 0, true, 1, "FALSE"}},
+		{BoolArgString, []any{"TRUE", "FALSE", true, 1, "FALSE"}},
+	}
+	for _, c := range cases {
+		SetBoolArgMode(c.mode)
+		_, args, err := node.Accept(translator, param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(args, c.expected) {
+			t.Errorf("mode %d: expected %v, got %v", c.mode, c.expected, args)
+		}
+	}
+
+	if _, _, err := NewTextNode("#{name,typeHandler=boolInt}").Accept(translator, newGenericParam(H{"name": "a"}, "")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-juicedev/juice/internal/reflectlite"
+)
+
+// ErrTypeHandlerNotFound is an error that is returned when the typeHandler of a placeholder is not registered.
+var ErrTypeHandlerNotFound = errors.New("type handler not found")
+
+// ArgTypeHandler encodes the values bound by the placeholders which are flagged with its name,
+// like #{settings,typeHandler=json}, into the arguments passed to the driver.
+// The handlers registered by types are not applied to the flagged placeholders.
+type ArgTypeHandler interface {
+	// EncodeArg encodes the value into the argument.
+	EncodeArg(value any) (any, error)
+}
+
+// ArgTypeHandlerFunc is a function type of ArgTypeHandler.
+type ArgTypeHandlerFunc func(value any) (any, error)
+
+// EncodeArg implements ArgTypeHandler.
+func (f ArgTypeHandlerFunc) EncodeArg(value any) (any, error) {
+	return f(value)
+}
+
+// RegisterArgTypeHandler registers the handler with the name into the default TypeHandlers, which is
+// referenced by the typeHandler of the placeholders of all the configurations.
+// Registering a handler with the same name again overrides it.
+// The handler only encodes the arguments, it is not used to decode the results.
+// It is not safe for concurrent use, handlers should be registered at init time.
+//
+// Deprecated: register the handler on the TypeHandlers of the configuration instead,
+// which takes precedence over the default ones for its mappers.
+func RegisterArgTypeHandler(name string, handler ArgTypeHandler) {
+	if name == "" {
+		panic("name is empty")
+	}
+	if handler == nil {
+		panic("juice: arg type handler is nil")
+	}
+	registerDefaultArgTypeHandler(name, handler)
+}
+
+// registerDefaultArgTypeHandler registers the handler with the name into the default TypeHandlers.
+func registerDefaultArgTypeHandler(name string, handler ArgTypeHandler) {
+	defaultTypeHandlers.byName[name] = argOnlyTypeHandler{ArgTypeHandler: handler}
+}
+
+// The builtin handlers of the default TypeHandlers, json and jsonb both marshal the value into JSON:
+//
+//	UPDATE user SET settings = #{settings,typeHandler=json} WHERE id = #{id}
+//
+// json passes the encoded bytes, and jsonb passes them as a string, since some Postgres drivers send
+// the bytes in the binary format, which is not accepted by the jsonb columns.
+// The nil values are bound as NULL by both of them.
+// The bool, boolInt and boolString handlers bind the bools by the BoolArgMode of their names.
+func init() {
+	registerDefaultArgTypeHandler("json", ArgTypeHandlerFunc(func(value any) (any, error) {
+		if isNilArg(value) {
+			return nil, nil
+		}
+		return json.Marshal(value)
+	}))
+	registerDefaultArgTypeHandler("jsonb", ArgTypeHandlerFunc(func(value any) (any, error) {
+		if isNilArg(value) {
+			return nil, nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}))
+	registerDefaultArgTypeHandler("bool", boolArgTypeHandler(BoolArgNative))
+	registerDefaultArgTypeHandler("boolInt", boolArgTypeHandler(BoolArgInt))
+	registerDefaultArgTypeHandler("boolString", boolArgTypeHandler(BoolArgString))
+}
+
+// boolArgTypeHandler returns the handler which encodes the bool values by the mode, ignoring the global one.
+func boolArgTypeHandler(mode BoolArgMode) ArgTypeHandler {
+	return ArgTypeHandlerFunc(func(value any) (any, error) {
+		if isNilArg(value) {
+			return nil, nil
+		}
+		rv := reflectlite.Unwrap(reflect.ValueOf(value))
+		if rv.Kind() != reflect.Bool {
+			return nil, fmt.Errorf("%w: expected bool, got %T", ErrUnsupportedType, value)
+		}
+		return mode.encode(rv.Bool()), nil
+	})
+}
+
+// isNilArg reports whether the value is nil or a nil pointer, map, slice or interface.
+func isNilArg(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// argOnlyTypeHandler is a TypeHandler registered by the deprecated global functions,
+// which only encodes the arguments and is not used to decode the results.
+type argOnlyTypeHandler struct {
+	ArgTypeHandler
+}
+
+// DecodeResult implements TypeHandler.
+func (argOnlyTypeHandler) DecodeResult(any, any) error {
+	return errors.New("juice: the arg type handler does not decode results")
+}
+
+// encodeArg encodes the value bound by the placeholder of the name with the handler of the typeHandler.
+func encodeArg(handler ArgTypeHandler, typeHandler, name string, value reflect.Value) (any, error) {
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	var v any
+	if value.IsValid() {
+		v = value.Interface()
+	}
+	arg, err := handler.EncodeArg(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode argument %s with type handler %s: %w", name, typeHandler, err)
+	}
+	return arg, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+type testSettings struct {
+	Theme string `json:"theme"`
+}
+
+func TestTextNode_TypeHandler(t *testing.T) {
+	translator := driver.MySQLDriver{}.Translator()
+	node := NewTextNode("update user set settings = #{settings, typeHandler=json}, raw = #{ settings }, doc = #{settings,typeHandler=jsonb} where id = #{id}")
+	param := newGenericParam(H{"settings": testSettings{Theme: "dark"}, "id": 1}, "")
+	query, args, err := node.Accept(translator, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "update user set settings = ?, raw = ?, doc = ? where id = ?" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if data, ok := args[0].([]byte); !ok || !bytes.Equal(data, []byte(`{"theme":"dark"}`)) {
+		t.Errorf("unexpected json arg: %v", args[0])
+	}
+	if _, ok := args[1].(testSettings); !ok {
+		t.Errorf("unexpected raw arg: %v", args[1])
+	}
+	if args[2] != `{"theme":"dark"}` {
+		t.Errorf("unexpected jsonb arg: %v", args[2])
+	}
+
+	// nil values are bound as NULL.
+	_, args, err = NewTextNode("#{settings,typeHandler=json}").Accept(translator, newGenericParam(H{"settings": (*testSettings)(nil)}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != nil {
+		t.Errorf("expected nil arg, got %v", args[0])
+	}
+
+	_, _, err = NewTextNode("#{settings,typeHandler=json}").Accept(translator, newGenericParam(H{"settings": func() {}}, ""))
+	if err == nil || !strings.Contains(err.Error(), "settings") {
+		t.Errorf("expected marshal error with the placeholder name, got %v", err)
+	}
+
+	_, _, err = NewTextNode("#{settings,typeHandler=yaml}").Accept(translator, param)
+	if !errors.Is(err, ErrTypeHandlerNotFound) {
+		t.Errorf("expected ErrTypeHandlerNotFound, got %v", err)
+	}
+}
+
+func TestRegisterArgTypeHandler(t *testing.T) {
+	RegisterArgTypeHandler("upper", ArgTypeHandlerFunc(func(value any) (any, error) {
+		return strings.ToUpper(value.(string)), nil
+	}))
+	t.Cleanup(func() { delete(defaultTypeHandlers.byName, "upper") })
+
+	_, args, err := NewTextNode("#{name,typeHandler=upper}").Accept(driver.MySQLDriver{}.Translator(), newGenericParam(H{"name": "juice"}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != "JUICE" {
+		t.Errorf("expected JUICE, got %v", args[0])
+	}
+
+	// the handlers are applied to the placeholders of the statements, like the items of a foreach.
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <insert id="create">
+                insert into users (id, name) values
+                <foreach collection="users" item="user" separator=",">(#{user.ID}, #{user.Name, typeHandler=upper})</foreach>
+            </insert>
+            <select id="unknown">select * from users where name = #{name,typeHandler=lower}</select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type User struct {
+		ID   int
+		Name string
+	}
+	query, args, err := statement.Build(driver.MySQLDriver{}.Translator(), H{"users": []User{{1, "a"}, {2, "b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "insert into users (id, name) values (?, ?),(?, ?)" || len(args) != 4 || args[0] != 1 || args[1] != "A" || args[3] != "B" {
+		t.Errorf("unexpected statement: %s %v", query, args)
+	}
+	if statement, err = cfg.GetStatement("users.unknown"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = statement.Build(driver.MySQLDriver{}.Translator(), H{"name": "a"}); !errors.Is(err, ErrTypeHandlerNotFound) {
+		t.Errorf("expected ErrTypeHandlerNotFound, got %v", err)
+	}
+}
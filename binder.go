@@ -17,9 +17,13 @@ limitations under the License.
 package juice
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"iter"
 	"reflect"
+	"slices"
 	"time"
 )
 
@@ -187,6 +191,10 @@ func List2[T any](rows *sql.Rows) ([]*T, error) {
 // for closing the rows when iteration is complete. This design allows for more
 // flexible resource management, especially when using the iterator in different
 // contexts or when early termination is needed.
+//
+// If the iteration stops because of an error reported by the rows, such as the
+// cancellation of the query context, the error is yielded as the last element.
+// The connection is released as soon as the rows are closed.
 func Iter[T any](rows *sql.Rows) (iter.Seq2[T, error], error) {
 	columns, err := rows.Columns()
 	if err != nil {
@@ -229,6 +237,9 @@ func Iter[T any](rows *sql.Rows) (iter.Seq2[T, error], error) {
 			if err := rows.Scan(dest...); err != nil {
 				return t, err
 			}
+			if err := columnDest.scanProbes(rows, v.Elem()); err != nil {
+				return t, err
+			}
 			return t, nil
 		}
 
@@ -237,5 +248,183 @@ func Iter[T any](rows *sql.Rows) (iter.Seq2[T, error], error) {
 				return
 			}
 		}
+
+		// rows.Next returns false when the rows are exhausted or an error occurred,
+		// for example, the context of the query has been canceled.
+		if err := rows.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
 	}, nil
 }
+
+// ColumnMeta describes a column of the result set.
+type ColumnMeta struct {
+	// Name is the name or the alias of the column.
+	Name string
+
+	// DatabaseType is the database system name of the column type, like "VARCHAR" or "INT".
+	// It is empty if the driver does not report it.
+	DatabaseType string
+
+	// Nullable reports whether the column may be null,
+	// it is meaningful only if NullableKnown is true.
+	Nullable bool
+
+	// NullableKnown reports whether the driver reports the nullability of the column.
+	NullableKnown bool
+}
+
+// Columns returns the metadata of the columns of the given rows.
+func Columns(rows *sql.Rows) ([]ColumnMeta, error) {
+	if rows == nil {
+		return nil, ErrNilRows
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+	columns := make([]ColumnMeta, len(columnTypes))
+	for i, columnType := range columnTypes {
+		nullable, ok := columnType.Nullable()
+		columns[i] = ColumnMeta{
+			Name:          columnType.Name(),
+			DatabaseType:  columnType.DatabaseTypeName(),
+			Nullable:      nullable,
+			NullableKnown: ok,
+		}
+	}
+	return columns, nil
+}
+
+// ListWithColumns converts sql.Rows to a slice of the given entity type like List,
+// and returns the metadata of the columns alongside, which suits the generic tooling like exporters.
+// The entity can be a struct, a map or a scalar.
+// rows won't be closed when the function returns.
+func ListWithColumns[T any](rows *sql.Rows) ([]T, []ColumnMeta, error) {
+	columns, err := Columns(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := List[T](rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, columns, nil
+}
+
+// QueryWithColumns executes the query of the given executor like its QueryContext, so the result is bound
+// with the result map options of the statement and the result map chosen by WithResultMap,
+// and returns the metadata of the columns alongside.
+// The executor must be a GenericExecutor, the others are invalid.
+//
+//	rows, columns, err := QueryWithColumns(ctx, NewGenericManager[[]map[string]any](engine).Object("main.Export"), nil)
+func QueryWithColumns[T any](ctx context.Context, executor Executor[[]T], param Param) ([]T, []ColumnMeta, error) {
+	exe, ok := executor.(*GenericExecutor[[]T])
+	if !ok {
+		return nil, nil, errors.Join(ErrInvalidExecutor, fmt.Errorf("QueryWithColumns requires a GenericExecutor, got %T", executor))
+	}
+	var columns []ColumnMeta
+	capturing := *exe
+	capturing.columns = &columns
+	result, err := capturing.QueryContext(ctx, param)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, columns, nil
+}
+
+// ErrDuplicateKey is an error that is returned by BindMap when more than one row has the same key.
+var ErrDuplicateKey = errors.New("juice: duplicate key in result set")
+
+// bindMapOption is a configuration of BindMap.
+type bindMapOption struct {
+	lastWins bool
+}
+
+// BindMapOptionFunc is a function to set the option of BindMap.
+type BindMapOptionFunc func(*bindMapOption)
+
+// BindMapWithLastWins makes the later rows overwrite the earlier rows with the same key,
+// instead of returning ErrDuplicateKey.
+func BindMapWithLastWins() BindMapOptionFunc {
+	return func(option *bindMapOption) {
+		option.lastWins = true
+	}
+}
+
+// BindMap binds the sql.Rows of two columns to a map, whose keys are the values of the keyColumn
+// and whose values are the values of the other column.
+// ErrDuplicateKey is returned if more than one row has the same key, unless BindMapWithLastWins is given.
+// rows won't be closed when the function returns.
+//
+//	rows, err := db.Query("SELECT id, name FROM users")
+//	...
+//	names, err := BindMap[int64, string](rows, "id")
+func BindMap[K comparable, V any](rows *sql.Rows, keyColumn string, opts ...BindMapOptionFunc) (map[K]V, error) {
+	if rows == nil {
+		return nil, ErrNilRows
+	}
+	var option bindMapOption
+	for _, opt := range opts {
+		opt(&option)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	keyIndex := slices.Index(columns, keyColumn)
+	if len(columns) != 2 || keyIndex == -1 {
+		return nil, fmt.Errorf("expected two columns including %q, got %v", keyColumn, columns)
+	}
+	result := make(map[K]V)
+	for rows.Next() {
+		var (
+			key   K
+			value V
+		)
+		dest := []any{&value, &value}
+		dest[keyIndex] = &key
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if _, exists := result[key]; exists && !option.lastWins {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateKey, key)
+		}
+		result[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred while iterating rows: %w", err)
+	}
+	return result, nil
+}
+
+// QueryMap executes the query of the given executor and binds its result to a map by BindMap.
+//
+//	names, err := QueryMap[int64, string](ctx, engine.Object("main.UserNames"), nil, "id")
+func QueryMap[K comparable, V any](ctx context.Context, executor SQLRowsExecutor, param Param, keyColumn string, opts ...BindMapOptionFunc) (map[K]V, error) {
+	rows, err := executor.QueryContext(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	return BindMap[K, V](rows, keyColumn, opts...)
+}
+
+// QueryOptional executes the query of the given executor and returns its single result,
+// found is false with no error if the query returns no rows instead of sql.ErrNoRows.
+// It suits the RETURNING executions which may skip the row, like an upsert on a conflict:
+//
+//	// INSERT INTO users (name) VALUES (#{name}) ON CONFLICT DO NOTHING RETURNING id
+//	id, found, err := QueryOptional(ctx, NewGenericManager[int64](engine).Object("main.CreateUser"), user)
+func QueryOptional[T any](ctx context.Context, executor Executor[T], param Param) (result T, found bool, err error) {
+	result, err = executor.QueryContext(ctx, param)
+	if errors.Is(err, sql.ErrNoRows) {
+		var zero T
+		return zero, false, nil
+	}
+	if err != nil {
+		return result, false, err
+	}
+	return result, true, nil
+}
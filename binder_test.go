@@ -0,0 +1,533 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCancelingFakeDB returns a fake database with many rows which cancels the
+// given context function while the rows are being read.
+func newCancelingFakeDB(t *testing.T, cancel context.CancelFunc) *fakeDB {
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		rows := make([][]sqldriver.Value, 1000)
+		for i := range rows {
+			rows[i] = []sqldriver.Value{int64(i)}
+		}
+		return []string{"id"}, rows, nil
+	}
+	db.onNext = func(i int) {
+		if i == 10 {
+			cancel()
+			// give database/sql the chance to observe the cancellation.
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	return db
+}
+
+func TestGenericExecutor_QueryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := newCancelingFakeDB(t, cancel)
+	engine := db.Engine(t, "main", `<select id="ids">select id from t</select>`)
+
+	executor := &GenericExecutor[[]int64]{SQLRowsExecutor: engine.Object("main.ids")}
+	_, err := executor.QueryContext(ctx, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+		return
+	}
+	if inUse := engine.DB().Stats().InUse; inUse != 0 {
+		t.Errorf("expected no connection in use, got %d", inUse)
+	}
+}
+
+func TestIter_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := newCancelingFakeDB(t, cancel)
+	engine := db.Engine(t, "main", `<select id="ids">select id from t</select>`)
+
+	rows, err := engine.Object("main.ids").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iterator, err := Iter[int64](rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		count   int
+		iterErr error
+	)
+	for _, err := range iterator {
+		if err != nil {
+			iterErr = err
+			break
+		}
+		count++
+	}
+	_ = rows.Close()
+
+	if !errors.Is(iterErr, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", iterErr)
+		return
+	}
+	if count >= 1000 {
+		t.Error("expected iteration to stop early")
+		return
+	}
+	if inUse := engine.DB().Stats().InUse; inUse != 0 {
+		t.Errorf("expected no connection in use, got %d", inUse)
+	}
+}
+
+func TestBind_EmbeddedStructPointer(t *testing.T) {
+	type Profile struct {
+		Bio  string `column:"bio"`
+		Site string `column:"site"`
+	}
+	type Member struct {
+		ID int64 `column:"id"`
+		*Profile
+	}
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		// the result of a LEFT JOIN, the second user has no profile.
+		return []string{"id", "bio", "site"}, [][]sqldriver.Value{
+			{int64(1), "gopher", nil},
+			{int64(2), nil, nil},
+		}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="members">select id, bio, site from users left join profiles</select>`)
+
+	rows, err := engine.Object("main.members").QueryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rows.Close() }()
+	members, err := List[Member](rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Fatalf("expected 2 members, got %d", len(members))
+	}
+	if members[0].Profile == nil || members[0].Bio != "gopher" || members[0].Site != "" {
+		t.Errorf("expected the profile to be allocated and filled, got %+v", members[0].Profile)
+	}
+	if members[1].Profile != nil {
+		t.Errorf("expected the profile to be nil, got %+v", members[1].Profile)
+	}
+}
+
+func TestBind_NestedEmbeddedStructPointer(t *testing.T) {
+	type Site struct {
+		URL string `column:"url"`
+	}
+	type Profile struct {
+		Bio string `column:"bio"`
+		*Site
+	}
+	type Member struct {
+		ID int64 `column:"id"`
+		*Profile
+	}
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		// the result of two LEFT JOINs, the pointers are checked at each level.
+		return []string{"id", "bio", "url"}, [][]sqldriver.Value{
+			{int64(1), "gopher", "go.dev"},
+			{int64(2), "gopher", nil},
+			{int64(3), nil, "go.dev"},
+			{int64(4), nil, nil},
+		}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="members">select id, bio, url from users left join profiles left join sites</select>`)
+
+	members, err := NewGenericManager[[]Member](engine).Object("main.members").QueryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 4 {
+		t.Fatalf("expected 4 members, got %d", len(members))
+	}
+	if members[0].Profile == nil || members[0].Site == nil || members[0].Bio != "gopher" || members[0].URL != "go.dev" {
+		t.Errorf("expected the profile and the site to be filled, got %+v", members[0].Profile)
+	}
+	if members[1].Profile == nil || members[1].Bio != "gopher" || members[1].Site != nil {
+		t.Errorf("expected the profile to be filled and the site to be nil, got %+v", members[1].Profile)
+	}
+	if members[2].Profile == nil || members[2].Bio != "" || members[2].Site == nil || members[2].URL != "go.dev" {
+		t.Errorf("expected the profile to be allocated for the site, got %+v", members[2].Profile)
+	}
+	if members[3].Profile != nil {
+		t.Errorf("expected the profile to be nil, got %+v", members[3].Profile)
+	}
+}
+
+func TestGenericExecutor_NullAsZero(t *testing.T) {
+	type User struct {
+		Name     string         `column:"name"`
+		Nickname *string        `column:"nickname"`
+		Email    sql.NullString `column:"email"`
+	}
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"name", "nickname", "email"}, [][]sqldriver.Value{{nil, nil, nil}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="user" nullAsZero="true">select name, nickname, email from users</select>
+<select id="users" nullAsZero="true">select name, nickname, email from users</select>
+<select id="strict">select name, nickname, email from users</select>`)
+
+	ctx := context.Background()
+	user, err := NewGenericManager[User](engine).Object("main.user").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pointer and sql.Null* fields still reflect the NULL.
+	if user.Name != "" || user.Nickname != nil || user.Email.Valid {
+		t.Errorf("unexpected user: %+v", user)
+		return
+	}
+	users, err := NewGenericManager[[]User](engine).Object("main.users").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "" {
+		t.Errorf("unexpected users: %+v", users)
+		return
+	}
+	if _, err = NewGenericManager[User](engine).Object("main.strict").QueryContext(ctx, nil); err == nil {
+		t.Error("expected error for scanning NULL into a string field")
+	}
+}
+
+func TestGenericExecutor_ColumnMapping(t *testing.T) {
+	type User struct {
+		ID        int64 `column:"id"`
+		UserName  string
+		CreatedAt string
+		Nickname  string `column:"user_name"`
+	}
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"ID", "USER_NAME", "CREATED_AT"}, [][]sqldriver.Value{{int64(1), "a", "today"}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="strict">select id, user_name, created_at from users</select>
+<select id="insensitive" columnCase="insensitive">select id, user_name, created_at from users</select>
+<select id="camel" columnCase="insensitive" mapUnderscoreToCamelCase="true">select id, user_name, created_at from users</select>`)
+
+	ctx := context.Background()
+	user, err := NewGenericManager[User](engine).Object("main.strict").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{}) {
+		t.Errorf("expected nothing matched in the strict mode, got %+v", user)
+		return
+	}
+	user, err = NewGenericManager[User](engine).Object("main.insensitive").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{ID: 1, Nickname: "a"}) {
+		t.Errorf("unexpected user: %+v", user)
+		return
+	}
+	// the column tag takes precedence over the field name.
+	users, err := NewGenericManager[[]User](engine).Object("main.camel").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0] != (User{ID: 1, CreatedAt: "today", Nickname: "a"}) {
+		t.Errorf("unexpected users: %+v", users)
+	}
+}
+
+func TestGenericExecutor_MaxRows(t *testing.T) {
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id"}, [][]sqldriver.Value{{int64(1)}, {int64(2)}, {int64(3)}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="error" maxRows="2">select id from users</select>
+<select id="truncate" maxRows="2" maxRowsPolicy="truncate">select id from users</select>
+<select id="enough" maxRows="3">select id from users</select>`)
+
+	type User struct {
+		ID int64 `column:"id"`
+	}
+	ctx := context.Background()
+	if _, err := NewGenericManager[[]User](engine).Object("main.error").QueryContext(ctx, nil); !errors.Is(err, ErrMaxRowsExceeded) {
+		t.Errorf("expected ErrMaxRowsExceeded, got %v", err)
+		return
+	}
+	users, err := NewGenericManager[[]*User](engine).Object("main.truncate").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1].ID != 2 {
+		t.Errorf("expected the rows to be truncated to 2, got %d", len(users))
+		return
+	}
+	ids, err := NewGenericManager[[]int64](engine).Object("main.enough").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Errorf("expected 3 rows, got %d", len(ids))
+		return
+	}
+
+	// the truncation is reported by the flag.
+	var truncated bool
+	if _, err = WithTruncatedRows(NewGenericManager[[]User](engine).Object("main.enough"), &truncated).QueryContext(ctx, nil); err != nil || truncated {
+		t.Errorf("expected the complete rows, got %v %v", truncated, err)
+		return
+	}
+	if _, err = WithTruncatedRows(NewGenericManager[[]User](engine).Object("main.truncate"), &truncated).QueryContext(ctx, nil); err != nil || !truncated {
+		t.Errorf("expected the truncated rows, got %v %v", truncated, err)
+		return
+	}
+	if _, err = WithTruncatedRows[[]User](otherExecutor[[]User]{}, &truncated).QueryContext(ctx, nil); !errors.Is(err, ErrInvalidExecutor) {
+		t.Errorf("expected ErrInvalidExecutor, got %v", err)
+		return
+	}
+
+	// the result maps chosen at call time are limited too.
+	RegisterResultMap("main.ids", ColumnsResultMap{Columns: []string{"id"}})
+	RegisterResultMap("main.all", allRowsResultMap{})
+	t.Cleanup(func() {
+		delete(resultMaps, "main.ids")
+		delete(resultMaps, "main.all")
+	})
+	for _, id := range []string{"ids", "all"} {
+		if _, err = WithResultMap(NewGenericManager[[]User](engine).Object("main.error"), id).QueryContext(ctx, nil); !errors.Is(err, ErrMaxRowsExceeded) {
+			t.Errorf("%s: expected ErrMaxRowsExceeded, got %v", id, err)
+			return
+		}
+		truncated = false
+		users, err = WithTruncatedRows(WithResultMap(NewGenericManager[[]*User](engine).Object("main.truncate"), id), &truncated).QueryContext(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 2 || users[1].ID != 2 || !truncated {
+			t.Errorf("%s: expected the rows to be truncated to 2, got %d", id, len(users))
+			return
+		}
+	}
+}
+
+// allRowsResultMap maps all the rows whatever the limit is.
+type allRowsResultMap struct{}
+
+func (allRowsResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
+	return MultiRowsResultMap{}.MapTo(rv, rows)
+}
+
+func TestToSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"Name":       "name",
+		"CreatedAt":  "created_at",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+		"Address2":   "address2",
+		"V2Name":     "v2_name",
+	}
+	for name, expected := range cases {
+		if got := toSnakeCase(name); got != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, got)
+		}
+	}
+}
+
+func TestQueryMap(t *testing.T) {
+	db := newFakeDB(t)
+	db.query = func(query string, _ []any) ([]string, [][]sqldriver.Value, error) {
+		switch query {
+		case "select name, id from users":
+			return []string{"name", "id"}, [][]sqldriver.Value{{"a", int64(1)}, {"b", int64(2)}}, nil
+		case "select id from users":
+			return []string{"id"}, [][]sqldriver.Value{{int64(1)}}, nil
+		default:
+			return []string{"id", "name"}, [][]sqldriver.Value{{int64(1), "a"}, {int64(1), "b"}}, nil
+		}
+	}
+	engine := db.Engine(t, "main", `<select id="names">select name, id from users</select>
+<select id="ids">select id from users</select>
+<select id="duplicates">select id, name from users</select>`)
+
+	ctx := context.Background()
+	names, err := QueryMap[int64, string](ctx, engine.Object("main.names"), nil, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[1] != "a" || names[2] != "b" {
+		t.Errorf("unexpected map: %v", names)
+		return
+	}
+	if _, err = QueryMap[int64, string](ctx, engine.Object("main.ids"), nil, "id"); err == nil {
+		t.Error("expected error for the missing value column")
+		return
+	}
+	if _, err = QueryMap[int64, string](ctx, engine.Object("main.duplicates"), nil, "id"); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("expected ErrDuplicateKey, got %v", err)
+		return
+	}
+	names, err = QueryMap[int64, string](ctx, engine.Object("main.duplicates"), nil, "id", BindMapWithLastWins())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[1] != "b" {
+		t.Errorf("unexpected map: %v", names)
+	}
+}
+
+func TestQueryOptional(t *testing.T) {
+	db := newFakeDB(t)
+	db.query = func(_ string, args []any) ([]string, [][]sqldriver.Value, error) {
+		if args[0] == "taken" {
+			return []string{"id"}, nil, nil
+		}
+		return []string{"id"}, [][]sqldriver.Value{{int64(7)}}, nil
+	}
+	engine := db.Engine(t, "main", `<insert id="create">
+    insert into users (name) values (#{name}) on conflict do nothing returning id
+</insert>`)
+	executor := NewGenericManager[int64](engine).Object("main.create")
+
+	ctx := context.Background()
+	id, found, err := QueryOptional(ctx, executor, H{"name": "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || id != 7 {
+		t.Errorf("expected the returned id, got %d, %v", id, found)
+		return
+	}
+
+	// the skipped conflict is not an error.
+	id, found, err = QueryOptional(ctx, executor, H{"name": "taken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found || id != 0 {
+		t.Errorf("expected no result, got %d, %v", id, found)
+		return
+	}
+
+	if _, err = executor.QueryContext(ctx, H{"name": "taken"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestQueryWithColumns(t *testing.T) {
+	db := newFakeDB(t)
+	db.query = func(query string, _ []any) ([]string, [][]sqldriver.Value, error) {
+		if strings.HasPrefix(query, "select count") {
+			return []string{"count"}, [][]sqldriver.Value{{int64(2)}}, nil
+		}
+		return []string{"id", "name"}, [][]sqldriver.Value{{int64(1), "a"}, {int64(2), nil}}, nil
+	}
+	db.columnType = func(column string) (string, bool) {
+		if column == "id" {
+			return "BIGINT", false
+		}
+		return "VARCHAR", true
+	}
+	engine := db.Engine(t, "main", `<select id="list">
+    select id, name from users
+</select>
+<select id="count">
+    select count(*) as count from users
+</select>
+<select id="limited" maxRows="1" namingStrategy="snakeCase">
+    select id, name from users
+</select>`)
+	ctx := context.Background()
+
+	type User struct {
+		ID   int64          `column:"id"`
+		Name sql.NullString `column:"name"`
+	}
+	users, columns, err := QueryWithColumns(ctx, NewGenericManager[[]User](engine).Object("main.list"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ColumnMeta{
+		{Name: "id", DatabaseType: "BIGINT", Nullable: false, NullableKnown: true},
+		{Name: "name", DatabaseType: "VARCHAR", Nullable: true, NullableKnown: true},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("unexpected columns: %+v", columns)
+		return
+	}
+	if len(users) != 2 || users[0].ID != 1 || users[0].Name.String != "a" || users[1].Name.Valid {
+		t.Errorf("unexpected users: %+v", users)
+		return
+	}
+
+	rows, columns, err := QueryWithColumns(ctx, NewGenericManager[[]map[string]any](engine).Object("main.list"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["id"] != int64(1) || rows[0]["name"] != "a" || rows[1]["name"] != nil || len(columns) != 2 {
+		t.Errorf("unexpected rows: %v, %+v", rows, columns)
+		return
+	}
+
+	counts, columns, err := QueryWithColumns(ctx, NewGenericManager[[]int64](engine).Object("main.count"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts[0] != 2 || len(columns) != 1 || columns[0].Name != "count" {
+		t.Errorf("unexpected counts: %v, %+v", counts, columns)
+		return
+	}
+
+	// the options of the statement and the chosen result map apply.
+	type Named struct {
+		ID   int64
+		Name sql.NullString
+	}
+	if _, _, err = QueryWithColumns(ctx, NewGenericManager[[]Named](engine).Object("main.limited"), nil); !errors.Is(err, ErrMaxRowsExceeded) {
+		t.Errorf("expected ErrMaxRowsExceeded, got %v", err)
+		return
+	}
+	RegisterResultMap("main.named", ColumnsResultMap{Columns: []string{"id", "name"}, ResultMap: MultiRowsResultMap{ColumnMapping: ColumnMapping{NamingStrategy: DefaultNamingStrategy{SnakeCase: true}}}})
+	t.Cleanup(func() { delete(resultMaps, "main.named") })
+	named, columns, err := QueryWithColumns(ctx, WithResultMap(NewGenericManager[[]Named](engine).Object("main.list"), "named"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(named) != 2 || named[0].ID != 1 || named[0].Name.String != "a" || len(columns) != 2 {
+		t.Errorf("unexpected rows: %+v, %+v", named, columns)
+	}
+}
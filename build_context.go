@@ -0,0 +1,81 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"github.com/go-juicedev/juice/eval"
+)
+
+// buildContext carries the state of a build of a statement to its nodes and expressions.
+// It is created once per build and carried by the buildParameter.
+type buildContext struct {
+	// budget limits the evaluation of the expressions of the build.
+	budget *eval.Budget
+
+	// typeHandlers are the type handlers of the configuration, nil if there is none.
+	typeHandlers *TypeHandlers
+
+	// namingStrategy is the naming strategy of the statement, nil if it is not set.
+	namingStrategy NamingStrategy
+
+	// trusted holds the values which are allowed to be used in the text substitutions
+	// in the strictTextSubstitution mode, nil if the mode is disabled.
+	trusted Parameter
+
+	// prepare is true if the statement is built by Engine.Prepare,
+	// whose placeholders are recorded as the preparedPlaceholder instead of being bound.
+	prepare bool
+}
+
+// emptyBuildContext is the buildContext of the parameters which carry none, which configures nothing.
+var emptyBuildContext = &buildContext{}
+
+// buildParameter is a Parameter which carries the buildContext of a build.
+// It is always the outermost Parameter given to the nodes, the nodes scoping their own values,
+// like the items of a foreach, wrap them inside of it by scopeParameter,
+// so the context is found by a type assertion instead of walking the parameters.
+type buildParameter struct {
+	Parameter
+	ctx *buildContext
+}
+
+// Budget implements eval.BudgetCarrier.
+func (b *buildParameter) Budget() *eval.Budget {
+	return b.ctx.budget
+}
+
+// withBuildContext returns the Parameter which carries the given buildContext.
+func withBuildContext(p Parameter, ctx *buildContext) Parameter {
+	return &buildParameter{Parameter: p, ctx: ctx}
+}
+
+// contextOf returns the buildContext carried by the given Parameter, or the empty one if there is none.
+func contextOf(p Parameter) *buildContext {
+	if b, ok := p.(*buildParameter); ok {
+		return b.ctx
+	}
+	return emptyBuildContext
+}
+
+// scopeParameter returns the Parameter which looks up the scope first and then the given Parameter,
+// keeping the buildContext of the given Parameter outermost.
+func scopeParameter(p Parameter, scope Parameter) Parameter {
+	if b, ok := p.(*buildParameter); ok {
+		return &buildParameter{Parameter: eval.ParamGroup{scope, b.Parameter}, ctx: b.ctx}
+	}
+	return eval.ParamGroup{scope, p}
+}
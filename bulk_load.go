@@ -0,0 +1,250 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-juicedev/juice/driver"
+	"github.com/go-juicedev/juice/session"
+)
+
+// ErrInvalidBulkLoad is an error that is returned when the table, the columns or a row of a bulk load is invalid.
+var ErrInvalidBulkLoad = errors.New("invalid bulk load")
+
+// ErrBulkLoadUnsupported is an error that is returned when the driver supports neither the native bulk load
+// nor the multi-row inserts of the fallback.
+var ErrBulkLoadUnsupported = errors.New("bulk load unsupported")
+
+// bulkLoadBatchRows is the maximum number of the rows inserted by a statement of the fallback bulk load.
+const bulkLoadBatchRows = 1000
+
+// BulkLoad loads the rows into the columns of the table and returns the number of the rows loaded,
+// each row holds the values of the columns in order.
+// It uses the native bulk mechanism of the database if the driver implements driver.BulkLoader,
+// which none of the builtin drivers does, otherwise it falls back to the chunked multi-row inserts, like:
+//
+//	INSERT INTO users (id, name) VALUES (?, ?), (?, ?), ...
+//
+// The fallback is only used with the drivers implementing driver.PlaceholderLimiter, which bounds the
+// placeholders of a chunk, like MySQL, PostgreSQL and SQLite. The other drivers, like Oracle, which does not
+// accept the multi-row inserts, get ErrBulkLoadUnsupported.
+// The fallback inserts are executed through the middlewares of the engine, within the transaction
+// carried by the context if any, like the one of Transaction, otherwise without a transaction,
+// so the rows inserted by the succeeded chunks are kept if a chunk fails.
+// The table, which may be qualified by a schema, and the columns must be plain identifiers,
+// since they are spliced into the inserts.
+func (e *Engine) BulkLoad(ctx context.Context, table string, columns []string, rows iter.Seq[[]any]) (int64, error) {
+	if err := checkBulkLoadIdentifiers(table, columns); err != nil {
+		return 0, err
+	}
+	sess := e.bulkLoadSession(ctx)
+	// the statements of a transaction are tracked by the transaction itself.
+	if _, ok := sess.(*sql.Tx); !ok {
+		end, err := e.manager.begin(e.using)
+		if err != nil {
+			return 0, err
+		}
+		defer end()
+	}
+	if loader, ok := e.Driver().(driver.BulkLoader); ok {
+		return loader.BulkLoad(ctx, e.DB(), table, columns, rows)
+	}
+	limiter, ok := e.Driver().(driver.PlaceholderLimiter)
+	if !ok {
+		return 0, fmt.Errorf("%w: driver %s", ErrBulkLoadUnsupported, e.Driver())
+	}
+	batchRows := min(bulkLoadBatchRows, limiter.MaxPlaceholders()/len(columns))
+	if batchRows == 0 {
+		return 0, fmt.Errorf("%w: %d columns exceed the %d placeholders of a statement", ErrInvalidBulkLoad, len(columns), limiter.MaxPlaceholders())
+	}
+	args := make([]any, 0, batchRows*len(columns))
+
+	var loaded int64
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		translator := e.Driver().Translator()
+		query := bulkInsertQuery(translator, table, columns, len(args)/len(columns))
+		// the arguments are passed as the sql.NamedArg if the placeholders are named.
+		query, named, err := namedArgs(translator, query, args)
+		if err != nil {
+			return err
+		}
+		handler := &CompiledStatementHandler{
+			query:       query,
+			args:        named,
+			middlewares: e.middlewares,
+			driver:      e.Driver(),
+			session:     sess,
+		}
+		result, err := handler.ExecContext(ctx, NewRawSQLStatement(query, e.GetConfiguration(), Insert), nil)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		loaded += affected
+		args = args[:0]
+		return nil
+	}
+	for row := range rows {
+		if len(row) != len(columns) {
+			return loaded, fmt.Errorf("%w: expected %d values, got %d", ErrInvalidBulkLoad, len(columns), len(row))
+		}
+		args = append(args, row...)
+		if len(args) == batchRows*len(columns) {
+			if err := flush(); err != nil {
+				return loaded, err
+			}
+		}
+	}
+	return loaded, flush()
+}
+
+// bulkLoadSession returns the session of the context, or the transaction of the BasicTxManager
+// in the context, like the one given to the handler of Transaction, otherwise the database.
+func (e *Engine) bulkLoadSession(ctx context.Context) session.Session {
+	if sess, err := session.FromContext(ctx); err == nil {
+		return sess
+	}
+	if manager, ok := managerFromContext(ctx); ok {
+		if tx, ok := manager.(*BasicTxManager); ok && tx.tx != nil {
+			return tx.tx
+		}
+	}
+	return e.DB()
+}
+
+// bulkLoadIdentifierRegexp matches the plain identifiers, which may be qualified by dots.
+var bulkLoadIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+
+// checkBulkLoadIdentifiers checks that the table and the columns of a bulk load are plain identifiers,
+// the columns must not be qualified.
+func checkBulkLoadIdentifiers(table string, columns []string) error {
+	if table == "" || len(columns) == 0 {
+		return fmt.Errorf("%w: table and columns are required", ErrInvalidBulkLoad)
+	}
+	if !bulkLoadIdentifierRegexp.MatchString(table) {
+		return fmt.Errorf("%w: invalid table %q", ErrInvalidBulkLoad, table)
+	}
+	for _, column := range columns {
+		if !bulkLoadIdentifierRegexp.MatchString(column) || strings.Contains(column, ".") {
+			return fmt.Errorf("%w: invalid column %q", ErrInvalidBulkLoad, column)
+		}
+	}
+	return nil
+}
+
+// bulkInsertQuery returns the multi-row insert query of the given number of rows.
+func bulkInsertQuery(translator driver.Translator, table string, columns []string, rows int) string {
+	builder := getStringBuilder()
+	defer putStringBuilder(builder)
+	builder.WriteString("INSERT INTO ")
+	builder.WriteString(table)
+	builder.WriteString(" (")
+	for i, column := range columns {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(column)
+	}
+	builder.WriteString(") VALUES ")
+	for i := range rows {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString("(")
+		for j, column := range columns {
+			if j > 0 {
+				builder.WriteString(", ")
+			}
+			builder.WriteString(translator.Translate(column))
+		}
+		builder.WriteString(")")
+	}
+	return builder.String()
+}
+
+// BulkLoadStructs loads the struct rows into the table by BulkLoad, the table is named from the name of T
+// by the naming strategy chosen by the namingStrategy setting, like OrderItem to order_item by snakeCase,
+// and the names are kept as is without the setting.
+// The columns are the exported fields of T named by their column tags, or by the naming strategy if untagged.
+// The fields tagged with "-" are skipped, and the untagged embedded structs are walked into.
+func BulkLoadStructs[T any](ctx context.Context, engine *Engine, rows iter.Seq[T]) (int64, error) {
+	tp := reflect.TypeFor[T]()
+	if tp.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("%w: expected struct rows, got %s", ErrInvalidBulkLoad, tp)
+	}
+	strategy, err := lookupNamingStrategy(engine.GetConfiguration().Settings().Get(namingStrategyKey))
+	if err != nil {
+		return 0, err
+	}
+	if strategy == nil {
+		strategy = DefaultNamingStrategy{}
+	}
+	columns, indexes := structColumns(tp, strategy)
+	values := func(yield func([]any) bool) {
+		for item := range rows {
+			row := make([]any, len(columns))
+			value := reflect.ValueOf(item)
+			for i, index := range indexes {
+				row[i] = value.FieldByIndex(index).Interface()
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	}
+	return engine.BulkLoad(ctx, strategy.TableName(tp.Name()), columns, values)
+}
+
+// structColumns returns the columns of the exported fields of the struct type and the indexes of the fields.
+func structColumns(tp reflect.Type, strategy NamingStrategy) (columns []string, indexes [][]int) {
+	var walk func(tp reflect.Type, walked []int)
+	walk = func(tp reflect.Type, walked []int) {
+		for i := 0; i < tp.NumField(); i++ {
+			field := tp.Field(i)
+			column := field.Tag.Get("column")
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && column == "" {
+				walk(field.Type, append(slices.Clone(walked), i))
+				continue
+			}
+			if column == "-" || !field.IsExported() {
+				continue
+			}
+			if column == "" {
+				column = strategy.ColumnName(field.Name)
+			}
+			columns = append(columns, column)
+			indexes = append(indexes, append(slices.Clone(walked), i))
+		}
+	}
+	walk(tp, nil)
+	return columns, indexes
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+func TestEngine_BulkLoad(t *testing.T) {
+	db := newFakeDB(t)
+	db.exec = func(_ string, args []any) (sqldriver.Result, error) {
+		return sqldriver.RowsAffected(len(args) / 2), nil
+	}
+	engine := db.Engine(t, "main", `<select id="users">select id, name from users</select>`)
+
+	rows := func(n int) iter.Seq[[]any] {
+		return func(yield func([]any) bool) {
+			row := make([]any, 2)
+			for i := range n {
+				// the row is reused, which must be copied.
+				row[0], row[1] = i, "name"
+				if !yield(row) {
+					return
+				}
+			}
+		}
+	}
+	loaded, err := engine.BulkLoad(context.Background(), "users", []string{"id", "name"}, rows(bulkLoadBatchRows+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != bulkLoadBatchRows+1 {
+		t.Errorf("expected %d rows loaded, got %d", bulkLoadBatchRows+1, loaded)
+		return
+	}
+	calls := db.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(calls))
+	}
+	if !strings.HasPrefix(calls[0].query, "INSERT INTO users (id, name) VALUES (?, ?), (?, ?)") || len(calls[0].args) != bulkLoadBatchRows*2 {
+		t.Errorf("unexpected first chunk: %.60s with %d args", calls[0].query, len(calls[0].args))
+		return
+	}
+	if calls[1].query != "INSERT INTO users (id, name) VALUES (?, ?)" || calls[1].args[0] != bulkLoadBatchRows {
+		t.Errorf("unexpected last chunk: %s %v", calls[1].query, calls[1].args)
+		return
+	}
+
+	invalid := slices.Values([][]any{{1}})
+	if _, err = engine.BulkLoad(context.Background(), "users", []string{"id", "name"}, invalid); !errors.Is(err, ErrInvalidBulkLoad) {
+		t.Errorf("expected ErrInvalidBulkLoad, got %v", err)
+	}
+}
+
+func TestEngine_BulkLoadMiddlewaresAndTransaction(t *testing.T) {
+	db := newFakeDB(t)
+	db.exec = func(_ string, args []any) (sqldriver.Result, error) {
+		return sqldriver.RowsAffected(len(args)), nil
+	}
+	engine := db.Engine(t, "main", `<select id="users">select id from users</select>`)
+	engine.UseQueryRewriter(func(_ context.Context, _ string, query string) string {
+		return "/* bulk */ " + query
+	})
+
+	errRollback := errors.New("rollback")
+	ctx := ContextWithManager(context.Background(), engine)
+	err := Transaction(ctx, func(ctx context.Context) error {
+		if _, err := engine.BulkLoad(ctx, "users", []string{"id"}, slices.Values([][]any{{1}, {2}})); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("expected the rollback error, got %v", err)
+	}
+	calls := db.Calls()
+	if len(calls) != 1 || calls[0].query != "/* bulk */ INSERT INTO users (id) VALUES (?), (?)" {
+		t.Errorf("expected the insert to run through the middlewares, got %v", calls)
+		return
+	}
+	if db.rollbacks != 1 || db.commits != 0 {
+		t.Errorf("expected the insert to run within the transaction, got %d rollbacks and %d commits", db.rollbacks, db.commits)
+	}
+}
+
+func TestEngine_BulkLoadIdentifiers(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="users">select id from users</select>`)
+	for _, tc := range []struct {
+		table   string
+		columns []string
+	}{
+		{"users; drop table users", []string{"id"}},
+		{"users", []string{"id) values (1); --"}},
+		{"users", []string{"u.id"}},
+		{"", []string{"id"}},
+		{"users", nil},
+	} {
+		if _, err := engine.BulkLoad(context.Background(), tc.table, tc.columns, slices.Values([][]any{{1}})); !errors.Is(err, ErrInvalidBulkLoad) {
+			t.Errorf("%q %q: expected ErrInvalidBulkLoad, got %v", tc.table, tc.columns, err)
+		}
+	}
+	if len(db.Calls()) != 0 {
+		t.Errorf("expected nothing executed, got %v", db.Calls())
+	}
+	db.exec = func(_ string, args []any) (sqldriver.Result, error) {
+		return sqldriver.RowsAffected(len(args)), nil
+	}
+	if _, err := engine.BulkLoad(context.Background(), "app.users", []string{"id"}, slices.Values([][]any{{1}})); err != nil {
+		t.Errorf("expected the qualified table to be accepted, got %v", err)
+	}
+}
+
+// testBulkLoaderDriver is a driver which loads the rows with a native bulk mechanism.
+type testBulkLoaderDriver struct {
+	driver.MySQLDriver
+	rows [][]any
+}
+
+func (d *testBulkLoaderDriver) BulkLoad(_ context.Context, _ *sql.DB, _ string, _ []string, rows iter.Seq[[]any]) (int64, error) {
+	for row := range rows {
+		d.rows = append(d.rows, slices.Clone(row))
+	}
+	return int64(len(d.rows)), nil
+}
+
+func TestEngine_BulkLoadNative(t *testing.T) {
+	loader := &testBulkLoaderDriver{}
+	driver.Register(fakeDriverName, loader)
+	t.Cleanup(func() { driver.Register(fakeDriverName, driver.MySQLDriver{}) })
+
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="users">select id, name from users</select>`)
+	loaded, err := engine.BulkLoad(context.Background(), "users", []string{"id", "name"}, slices.Values([][]any{{1, "a"}, {2, "b"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 2 || len(loader.rows) != 2 || len(db.Calls()) != 0 {
+		t.Errorf("expected the native bulk load to be used, got %d rows and %d calls", loaded, len(db.Calls()))
+	}
+}
+
+// testNamedSQLiteDriver is a SQLite driver whose placeholders are named.
+type testNamedSQLiteDriver struct {
+	driver.SQLiteDriver
+}
+
+func (testNamedSQLiteDriver) Translator() driver.Translator {
+	return driver.NewNamedTranslator()
+}
+
+func TestEngine_BulkLoadFallbackDrivers(t *testing.T) {
+	t.Cleanup(func() { driver.Register(fakeDriverName, driver.MySQLDriver{}) })
+
+	// the chunks are bounded by the placeholders of the driver.
+	driver.Register(fakeDriverName, driver.SQLiteDriver{})
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="users">select id from users</select>`)
+	columns := make([]string, 40)
+	row := make([]any, len(columns))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	rows := slices.Repeat([][]any{row}, 1000)
+	if _, err := engine.BulkLoad(context.Background(), "wide", columns, slices.Values(rows)); err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if len(calls) != 2 || len(calls[0].args) != 32766/40*40 || len(calls[1].args) != (1000-32766/40)*40 {
+		t.Errorf("unexpected chunks: %d", len(calls))
+		return
+	}
+
+	// the named placeholders get the named arguments.
+	driver.Register(fakeDriverName, testNamedSQLiteDriver{})
+	db = newFakeDB(t)
+	engine = db.Engine(t, "main", `<select id="users">select id from users</select>`)
+	if _, err := engine.BulkLoad(context.Background(), "users", []string{"id", "name"}, slices.Values([][]any{{1, "a"}, {2, "b"}})); err != nil {
+		t.Fatal(err)
+	}
+	calls = db.Calls()
+	if len(calls) != 1 || calls[0].query != "INSERT INTO users (id, name) VALUES (:id, :name), (:id_2, :name_2)" {
+		t.Errorf("unexpected calls: %v", calls)
+		return
+	}
+	if arg, ok := calls[0].args[2].(sql.NamedArg); !ok || arg.Name != "id_2" || arg.Value != 2 {
+		t.Errorf("unexpected args: %v", calls[0].args)
+		return
+	}
+
+	// the drivers without the multi-row inserts are rejected.
+	driver.Register(fakeDriverName, driver.OracleDriver{})
+	engine = newFakeDB(t).Engine(t, "main", `<select id="users">select id from users</select>`)
+	if _, err := engine.BulkLoad(context.Background(), "users", []string{"id"}, slices.Values([][]any{{1}})); !errors.Is(err, ErrBulkLoadUnsupported) {
+		t.Errorf("expected ErrBulkLoadUnsupported, got %v", err)
+	}
+}
+
+func TestBulkLoadStructs(t *testing.T) {
+	type Base struct {
+		CreatedAt int
+	}
+	type OrderItem struct {
+		ID int64 `column:"id"`
+		Base
+		ItemName string
+		Note     string `column:"-"`
+		secret   string
+	}
+	db := newFakeDB(t)
+	files := newTestMapperFS(db.dsn, "main", `<select id="users">select id from users</select>`)
+	config := strings.Replace(string(files["juice.xml"].Data), "<mappers>",
+		`<settings><setting name="namingStrategy" value="snakeCase"/></settings>
+    <mappers>`, 1)
+	files["juice.xml"] = &fstest.MapFile{Data: []byte(config)}
+	cfg, err := NewXMLConfigurationWithFS(files, "juice.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+
+	items := []OrderItem{{ID: 1, Base: Base{CreatedAt: 2}, ItemName: "a", Note: "n", secret: "s"}, {ID: 3, ItemName: "b"}}
+	if _, err = BulkLoadStructs(context.Background(), engine, slices.Values(items)); err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if len(calls) != 1 || calls[0].query != "INSERT INTO order_item (id, created_at, item_name) VALUES (?, ?, ?), (?, ?, ?)" {
+		t.Errorf("unexpected calls: %v", calls)
+		return
+	}
+	if args := calls[0].args; len(args) != 6 || args[0] != int64(1) || args[1] != 2 || args[5] != "b" {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// the names are kept as is without the setting.
+	db = newFakeDB(t)
+	engine = db.Engine(t, "main", `<select id="users">select id from users</select>`)
+	if _, err = BulkLoadStructs(context.Background(), engine, slices.Values(items)); err != nil {
+		t.Fatal(err)
+	}
+	if calls = db.Calls(); len(calls) != 1 || calls[0].query != "INSERT INTO OrderItem (id, CreatedAt, ItemName) VALUES (?, ?, ?), (?, ?, ?)" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
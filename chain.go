@@ -0,0 +1,141 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Chain executes the statements sequentially within one transaction of the engine,
+// and threads the result of each statement into the parameter of the next one.
+// The transaction is committed if all the statements succeed, otherwise it is rolled back.
+// For example:
+//
+//	results, err := engine.Chain(ctx).
+//		Exec("users.create", user).
+//		Then("roles.grant", func(prev sql.Result) (juice.Param, error) {
+//			affected, err := prev.RowsAffected()
+//			if err != nil {
+//				return nil, err
+//			}
+//			if affected == 0 {
+//				return nil, errors.New("user not created")
+//			}
+//			return juice.H{"userId": user.ID, "role": "member"}, nil
+//		}).
+//		Run()
+//
+// The statements appended by Exec and Then are executed by ExecContext, and their sql.Result is threaded
+// through the chain. The queries which return rows are appended by ThenQuery, which binds the rows into
+// a value passed to its callback, like a select whose result the later statements depend on:
+//
+//	var user User
+//	chain := engine.Chain(ctx).Exec("users.create", param)
+//	juice.ThenQuery(chain, "users.getByName", func(sql.Result) (juice.Param, error) {
+//		return param, nil
+//	}, func(result User) error {
+//		user = result
+//		return nil
+//	})
+//	results, err := chain.Then("roles.grant", func(sql.Result) (juice.Param, error) {
+//		return juice.H{"userId": user.ID, "role": "member"}, nil
+//	}).Run()
+//
+// Note that LastInsertId of the result is not supported by every driver, like the Postgres ones,
+// query the key by ThenQuery instead, like an insert with a returning clause.
+//
+// It is not safe for concurrent use.
+type Chain struct {
+	ctx    context.Context
+	engine *Engine
+	opts   []TransactionOptionFunc
+	steps  []chainStep
+}
+
+// chainStep is a statement of the Chain with the function to compute its parameter.
+type chainStep struct {
+	id    any
+	param func(prev sql.Result) (Param, error)
+
+	// query runs the statement as a query appended by ThenQuery, nil means ExecContext is used.
+	query func(ctx context.Context, tx Manager, id any, param Param) error
+}
+
+// Chain returns a new Chain of the engine, the options set the transaction which the chain runs in.
+func (e *Engine) Chain(ctx context.Context, opts ...TransactionOptionFunc) *Chain {
+	return &Chain{ctx: ctx, engine: e, opts: opts}
+}
+
+// Exec appends the statement of the given id executed with the given parameter.
+func (c *Chain) Exec(id any, param Param) *Chain {
+	return c.Then(id, func(sql.Result) (Param, error) { return param, nil })
+}
+
+// Then appends the statement of the given id executed with the parameter computed by fn from the result
+// of the previous statement, which is nil for the first statement. An error of fn stops the chain.
+func (c *Chain) Then(id any, fn func(prev sql.Result) (Param, error)) *Chain {
+	c.steps = append(c.steps, chainStep{id: id, param: fn})
+	return c
+}
+
+// ThenQuery appends the query of the given id to the chain, executed with the parameter computed by fn
+// like Then, whose rows are bound into T and passed to then, an error of fn or then stops the chain.
+// The query has no sql.Result, so its result in the ones of Run is nil, and the next statement
+// receives the result of the last statement executed by ExecContext.
+func ThenQuery[T any](c *Chain, id any, fn func(prev sql.Result) (Param, error), then func(result T) error) *Chain {
+	c.steps = append(c.steps, chainStep{id: id, param: fn, query: func(ctx context.Context, tx Manager, id any, param Param) error {
+		result, err := NewGenericManager[T](tx).Object(id).QueryContext(ctx, param)
+		if err != nil {
+			return err
+		}
+		return then(result)
+	}})
+	return c
+}
+
+// Run executes the statements of the chain and returns their results in order.
+// The error is wrapped with the position of the failed statement in the chain.
+func (c *Chain) Run() ([]sql.Result, error) {
+	results := make([]sql.Result, 0, len(c.steps))
+	err := c.engine.InTx(c.ctx, func(tx Manager) error {
+		var prev sql.Result
+		for i, step := range c.steps {
+			param, err := step.param(prev)
+			if err != nil {
+				return fmt.Errorf("chain step %d: %w", i, err)
+			}
+			if step.query != nil {
+				if err = step.query(c.ctx, tx, step.id, param); err != nil {
+					return fmt.Errorf("chain step %d: %w", i, err)
+				}
+				results = append(results, nil)
+				continue
+			}
+			if prev, err = tx.Object(step.id).ExecContext(c.ctx, param); err != nil {
+				return fmt.Errorf("chain step %d: %w", i, err)
+			}
+			results = append(results, prev)
+		}
+		return nil
+	}, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
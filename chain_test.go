@@ -0,0 +1,126 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestEngine_Chain(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<insert id="createUser">insert into users (name) values (#{name})</insert>
+<insert id="grant">insert into roles (user_id, role) values (#{userId}, #{role})</insert>`)
+	ctx := context.Background()
+
+	results, err := engine.Chain(ctx).
+		Exec("main.createUser", H{"name": "a"}).
+		Then("main.grant", func(prev sql.Result) (Param, error) {
+			affected, err := prev.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			return H{"userId": affected, "role": "member"}, nil
+		}).
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if len(results) != 2 || len(calls) != 2 || calls[1].args[0] != int64(1) || calls[1].args[1] != "member" {
+		t.Errorf("unexpected calls: %v", calls)
+		return
+	}
+	if db.commits != 1 || db.rollbacks != 0 {
+		t.Errorf("expected the chain to be committed, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+		return
+	}
+
+	errStop := errors.New("stop")
+	_, err = engine.Chain(ctx).
+		Exec("main.createUser", H{"name": "b"}).
+		Then("main.grant", func(sql.Result) (Param, error) { return nil, errStop }).
+		Run()
+	if !errors.Is(err, errStop) || err.Error() != "chain step 1: stop" {
+		t.Errorf("expected errStop, got %v", err)
+		return
+	}
+	if db.commits != 1 || db.rollbacks != 1 || len(db.Calls()) != 3 {
+		t.Errorf("expected the chain to be rolled back, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+	}
+}
+
+func TestThenQuery(t *testing.T) {
+	type User struct {
+		ID int64 `column:"id"`
+	}
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id"}, [][]sqldriver.Value{{int64(7)}}, nil
+	}
+	engine := db.Engine(t, "main", `<insert id="createUser">insert into users (name) values (#{name})</insert>
+<select id="getUser">select id from users where name = #{name}</select>
+<insert id="grant">insert into roles (user_id, role) values (#{userId}, #{role})</insert>`)
+	ctx := context.Background()
+
+	var user User
+	chain := engine.Chain(ctx).Exec("main.createUser", H{"name": "a"})
+	ThenQuery(chain, "main.getUser", func(prev sql.Result) (Param, error) {
+		if prev == nil {
+			return nil, errors.New("expected the result of the insert")
+		}
+		return H{"name": "a"}, nil
+	}, func(result User) error {
+		user = result
+		return nil
+	})
+	results, err := chain.Then("main.grant", func(prev sql.Result) (Param, error) {
+		// the result of the insert is passed over the query.
+		if prev == nil {
+			return nil, errors.New("expected the result of the insert")
+		}
+		return H{"userId": user.ID, "role": "member"}, nil
+	}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if len(results) != 3 || results[1] != nil || len(calls) != 3 || calls[2].args[0] != int64(7) {
+		t.Errorf("unexpected calls: %v", calls)
+		return
+	}
+	if db.commits != 1 || db.rollbacks != 0 {
+		t.Errorf("expected the chain to be committed, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+		return
+	}
+
+	// an error of the callback stops the chain.
+	errNotFound := errors.New("not found")
+	chain = ThenQuery(engine.Chain(ctx), "main.getUser", func(sql.Result) (Param, error) {
+		return H{"name": "b"}, nil
+	}, func(User) error { return errNotFound })
+	if _, err = chain.Exec("main.grant", H{"userId": 0, "role": "member"}).Run(); !errors.Is(err, errNotFound) || err.Error() != "chain step 0: not found" {
+		t.Errorf("expected errNotFound, got %v", err)
+		return
+	}
+	if db.rollbacks != 1 || len(db.Calls()) != 4 {
+		t.Errorf("expected the chain to be rolled back, got %d rollbacks and %d calls", db.rollbacks, len(db.Calls()))
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the node group, which can be modified without affecting the original.
+// See cloneNode for how each node is copied.
+func (g NodeGroup) Clone() NodeGroup {
+	if g == nil {
+		return nil
+	}
+	cloned := make(NodeGroup, len(g))
+	for i, node := range g {
+		cloned[i] = cloneNode(node)
+	}
+	return cloned
+}
+
+// cloneNodes returns a deep copy of the nodes.
+func cloneNodes(nodes []Node) []Node {
+	if nodes == nil {
+		return nil
+	}
+	return NodeGroup(nodes).Clone()
+}
+
+// cloneNode returns a deep copy of the node.
+// The children and the mutable slices are copied, while the compiled expressions and
+// the parsed texts are shared, since they are never modified after parsing.
+// The fragment referenced by an IncludeNode is shared too, which is owned by its mapper.
+// The nodes of unknown types are returned as is.
+func cloneNode(node Node) Node {
+	switch n := node.(type) {
+	case pureTextNode:
+		return n
+	case *TextNode:
+		cloned := *n
+		return &cloned
+	case *ConditionNode:
+		cloned := *n
+		cloned.Nodes = n.Nodes.Clone()
+		return &cloned
+	case *WhereNode:
+		return &WhereNode{Nodes: n.Nodes.Clone(), PrefixOverrides: slices.Clone(n.PrefixOverrides), Default: n.Default}
+	case *TrimNode:
+		cloned := *n
+		cloned.Nodes = n.Nodes.Clone()
+		cloned.PrefixOverrides = slices.Clone(n.PrefixOverrides)
+		cloned.SuffixOverrides = slices.Clone(n.SuffixOverrides)
+		return &cloned
+	case *ForeachNode:
+		cloned := *n
+		cloned.Nodes = cloneNodes(n.Nodes)
+		return &cloned
+	case *SetNode:
+		cloned := *n
+		cloned.Nodes = n.Nodes.Clone()
+		return &cloned
+	case *ChooseNode:
+		cloned := &ChooseNode{WhenNodes: cloneNodes(n.WhenNodes)}
+		if n.OtherwiseNode != nil {
+			cloned.OtherwiseNode = cloneNode(n.OtherwiseNode)
+		}
+		return cloned
+	case *OtherwiseNode:
+		return &OtherwiseNode{Nodes: n.Nodes.Clone()}
+	case *BlockNode:
+		cloned := *n
+		cloned.Nodes = n.Nodes.Clone()
+		return &cloned
+	case *BindNode:
+		cloned := *n
+		cloned.Nodes = n.Nodes.Clone()
+		return &cloned
+	case *SQLNode:
+		return &SQLNode{id: n.id, nodes: n.nodes.Clone()}
+	case *IncludeNode:
+		cloned := *n
+		return &cloned
+	case ValuesNode:
+		cloned := make(ValuesNode, len(n))
+		for i, item := range n {
+			value := *item
+			cloned[i] = &value
+		}
+		return cloned
+	case *ParamValuesNode:
+		cloned := *n
+		cloned.Values = cloneNode(n.Values).(ValuesNode)
+		return &cloned
+	case *CaseNode:
+		cloned := *n
+		cloned.Columns = slices.Clone(n.Columns)
+		return &cloned
+	case *ColumnsNode:
+		cloned := *n
+		cloned.Allowed = slices.Clone(n.Allowed)
+		return &cloned
+	case *AggregateNode:
+		cloned := *n
+		cloned.Allowed = slices.Clone(n.Allowed)
+		return &cloned
+	case SelectFieldAliasNode:
+		cloned := make(SelectFieldAliasNode, len(n))
+		for i, item := range n {
+			alias := *item
+			cloned[i] = &alias
+		}
+		return cloned
+	case NodeGroup:
+		return n.Clone()
+	default:
+		return node
+	}
+}
+
+// Clone implements Statement.
+// The nodes and the attributes are copied, and the mapper is shared.
+func (s *xmlSQLStatement) Clone() Statement {
+	return s.clone()
+}
+
+// clone returns a deep copy of the xmlSQLStatement.
+func (s *xmlSQLStatement) clone() *xmlSQLStatement {
+	cloned := *s
+	cloned.Nodes = s.Nodes.Clone()
+	cloned.attrs = maps.Clone(s.attrs)
+	cloned.defaults = maps.Clone(s.defaults)
+	return &cloned
+}
+
+// Clone implements Statement.
+func (s *databaseIDStatement) Clone() Statement {
+	return &databaseIDStatement{xmlSQLStatement: s.xmlSQLStatement.clone(), databaseID: s.databaseID}
+}
+
+// Clone implements Statement.
+// rawSQLStatement is immutable, so it returns itself.
+func (s rawSQLStatement) Clone() Statement {
+	return s
+}
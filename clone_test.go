@@ -0,0 +1,92 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+func TestStatement_Clone(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list" timeout="10">
+                select id, name from users
+                <where>
+                    <if test="name != ''">and name = #{name}</if>
+                    <foreach collection="ids" item="id" open="and id in (" separator="," close=")">#{id}</foreach>
+                </where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	translator := driver.MySQLDriver{}.Translator()
+	param := H{"name": "a", "ids": []int{1, 2}}
+	expected, _, err := statement.Build(translator, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cloned := statement.Clone()
+	if cloned.Name() != statement.Name() || cloned.Attribute("timeout") != "10" {
+		t.Errorf("expected the clone to keep the name and attributes")
+		return
+	}
+	query, _, err := cloned.Build(translator, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != expected {
+		t.Errorf("expected %s, got %s", expected, query)
+		return
+	}
+
+	// mutate the clone, the source must not be affected.
+	xmlStatement := cloned.(*xmlSQLStatement)
+	xmlStatement.setAttribute("timeout", "20")
+	xmlStatement.Nodes = append(xmlStatement.Nodes, pureTextNode("limit 1"))
+	for _, node := range xmlStatement.Nodes {
+		where, ok := node.(*WhereNode)
+		if !ok {
+			continue
+		}
+		where.Nodes[0].(*IfNode).Nodes[0] = pureTextNode("and name is null")
+		where.Nodes[1].(*ForeachNode).Separator = " or "
+	}
+	if statement.Attribute("timeout") != "10" {
+		t.Errorf("expected the source attribute to be kept, got %s", statement.Attribute("timeout"))
+		return
+	}
+	query, _, err = statement.Build(translator, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != expected {
+		t.Errorf("expected the source to be kept as %s, got %s", expected, query)
+		return
+	}
+	query, _, _ = cloned.Build(translator, param)
+	if query == expected {
+		t.Errorf("expected the clone to be modified, got %s", query)
+	}
+}
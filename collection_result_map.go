@@ -0,0 +1,174 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CollectionResultMap is a ResultMap which maps the rows of a one-to-many join into a slice of parents,
+// grouping the rows by the Key column and aggregating the scalar Column of each row into the
+// slice field Property of its parent, like the user to tags:
+//
+//	type User struct {
+//	    ID   int64    `column:"id"`
+//	    Name string   `column:"name"`
+//	    Tags []string
+//	}
+//
+//	// SELECT u.id, u.name, t.tag FROM user u LEFT JOIN user_tag t ON t.user_id = u.id ORDER BY u.id
+//	juice.RegisterResultMap("users.withTags", juice.CollectionResultMap{Key: "id", Property: "Tags", Column: "tag"})
+//
+// The other columns are mapped to the parent from the first row of its group.
+// The NULL values of the Column are skipped, so a parent without any child has an empty slice.
+// The destination must be a pointer to a slice of structs or struct pointers.
+type CollectionResultMap struct {
+	// Key is the column which identifies the parent, like "id".
+	Key string
+
+	// Property is the name of the slice field of the parent which the Column is aggregated into.
+	// The element type of the field is the type of the Column, like string for []string.
+	Property string
+
+	// Column is the scalar column aggregated into the Property.
+	Column string
+
+	ColumnMapping
+}
+
+// MapTo implements ResultMap.
+func (m CollectionResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
+	if err := (MultiRowsResultMap{}).validateInput(rv); err != nil {
+		return err
+	}
+	elementType := rv.Elem().Type().Elem()
+	isPointer := elementType.Kind() == reflect.Ptr
+	parentType := elementType
+	if isPointer {
+		parentType = parentType.Elem()
+	}
+	if parentType.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: expected slice of struct, got %s", ErrUnsupportedType, elementType)
+	}
+	property, ok := parentType.FieldByName(m.Property)
+	if !ok || !property.IsExported() || property.Type.Kind() != reflect.Slice {
+		return fmt.Errorf("%w: %s has no slice field %s", ErrUnsupportedType, parentType, m.Property)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+	keyIndex, columnIndex := m.indexOf(columns, m.Key), m.indexOf(columns, m.Column)
+	var missing []string
+	if keyIndex < 0 {
+		missing = append(missing, m.Key)
+	}
+	if columnIndex < 0 {
+		missing = append(missing, m.Column)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+
+	// the aggregated column is not mapped to the parent.
+	parentColumns := make([]string, len(columns))
+	copy(parentColumns, columns)
+	parentColumns[columnIndex] = ""
+	parentDest := &rowDestination{ColumnMapping: m.ColumnMapping}
+
+	var (
+		discard any
+		key     any
+		value   any
+		parents []reflect.Value
+		groups  = map[any]reflect.Value{}
+	)
+	probes := make([]any, len(columns))
+	for i := range probes {
+		probes[i] = &discard
+	}
+	probes[keyIndex], probes[columnIndex] = &key, &value
+
+	for rows.Next() {
+		key, value = nil, nil
+		if err = rows.Scan(probes...); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		// the bytes are not comparable, which can not be the keys of the groups.
+		if data, ok := key.([]byte); ok {
+			key = string(data)
+		}
+		parent, exists := groups[key]
+		if !exists {
+			parent = reflect.New(parentType)
+			dest, err := parentDest.Destination(parent.Elem(), parentColumns)
+			if err != nil {
+				return fmt.Errorf("failed to get destination: %w", err)
+			}
+			if err = rows.Scan(dest...); err != nil {
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			if err = parentDest.scanProbes(rows, parent.Elem()); err != nil {
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			groups[key] = parent
+			parents = append(parents, parent)
+		}
+		if value == nil {
+			continue
+		}
+		field := parent.Elem().FieldByIndex(property.Index)
+		item := reflect.New(property.Type.Elem())
+		dest := make([]any, len(columns))
+		for i := range dest {
+			dest[i] = &discard
+		}
+		dest[columnIndex] = item.Interface()
+		if err = rows.Scan(dest...); err != nil {
+			return fmt.Errorf("failed to scan %s into %s: %w", m.Column, m.Property, err)
+		}
+		field.Set(reflect.Append(field, item.Elem()))
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error occurred while iterating rows: %w", err)
+	}
+
+	target := rv.Elem()
+	target.Grow(len(parents))
+	for _, parent := range parents {
+		if isPointer {
+			target.Set(reflect.Append(target, parent))
+		} else {
+			target.Set(reflect.Append(target, parent.Elem()))
+		}
+	}
+	return nil
+}
+
+// indexOf returns the index of the column in the columns, or -1 if it is absent.
+func (m CollectionResultMap) indexOf(columns []string, column string) int {
+	for i, c := range columns {
+		if c == column || m.CaseInsensitive && strings.EqualFold(c, column) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCollectionResultMap(t *testing.T) {
+	RegisterResultMap("main.withTags", CollectionResultMap{Key: "id", Property: "Tags", Column: "tag"})
+	RegisterResultMap("main.missing", CollectionResultMap{Key: "id", Property: "Tags", Column: "label"})
+	t.Cleanup(func() {
+		delete(resultMaps, "main.withTags")
+		delete(resultMaps, "main.missing")
+	})
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id", "name", "tag"}, [][]sqldriver.Value{
+			{int64(1), "a", "go"},
+			{int64(1), "a", "sql"},
+			{int64(2), "b", nil},
+			{int64(3), "c", "xml"},
+		}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="users">
+    select u.id, u.name, t.tag from users u left join user_tags t on t.user_id = u.id order by u.id
+</select>`)
+
+	type User struct {
+		ID   int64  `column:"id"`
+		Name string `column:"name"`
+		Tags []string
+	}
+	users, err := WithResultMap(NewGenericManager[[]User](engine).Object("main.users"), "withTags").QueryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []User{
+		{ID: 1, Name: "a", Tags: []string{"go", "sql"}},
+		{ID: 2, Name: "b"},
+		{ID: 3, Name: "c", Tags: []string{"xml"}},
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %+v, got %+v", expected, users)
+		return
+	}
+
+	pointers, err := WithResultMap(NewGenericManager[[]*User](engine).Object("main.users"), "withTags").QueryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pointers) != 3 || !reflect.DeepEqual(*pointers[0], expected[0]) {
+		t.Errorf("unexpected users: %+v", pointers)
+		return
+	}
+
+	_, err = WithResultMap(NewGenericManager[[]User](engine).Object("main.users"), "missing").QueryContext(context.Background(), nil)
+	if !errors.Is(err, ErrMissingColumns) {
+		t.Errorf("expected ErrMissingColumns, got %v", err)
+	}
+}
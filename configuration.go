@@ -17,9 +17,13 @@ limitations under the License.
 package juice
 
 import (
+	"database/sql"
+	"fmt"
 	"io/fs"
 	"path"
 	"path/filepath"
+
+	"github.com/go-juicedev/juice/internal/container"
 )
 
 // IConfiguration is the interface of configuration.
@@ -32,6 +36,9 @@ type IConfiguration interface {
 
 	// GetStatement returns the xmlSQLStatement of the given value.
 	GetStatement(v any) (Statement, error)
+
+	// TypeHandlers returns the registry of the type handlers.
+	TypeHandlers() *TypeHandlers
 }
 
 // Configuration is a configuration of juice.
@@ -44,6 +51,13 @@ type Configuration struct {
 
 	// settings is a map of settings.
 	settings keyValueSettingProvider
+
+	// databaseIDs maps the driver names to the databaseIds,
+	// which is declared by the databaseIdProvider element.
+	databaseIDs map[string]string
+
+	// typeHandlers is the registry of the type handlers, which is shared by the copies of the configuration.
+	typeHandlers *TypeHandlers
 }
 
 // Environments returns the environments.
@@ -56,11 +70,63 @@ func (c Configuration) Settings() SettingProvider {
 	return &c.settings
 }
 
+// TypeHandlers returns the registry of the type handlers of the configuration,
+// which the mappers of it use to convert the arguments and the results.
+//
+//	cfg.TypeHandlers().Register("money", moneyHandler{})
+//	cfg.TypeHandlers().RegisterType(reflect.TypeFor[Status](), statusHandler{})
+func (c Configuration) TypeHandlers() *TypeHandlers {
+	return c.typeHandlers
+}
+
 // GetStatement returns the xmlSQLStatement of the given value.
 func (c Configuration) GetStatement(v any) (Statement, error) {
 	return c.mappers.GetStatement(v)
 }
 
+// DatabaseID returns the databaseId of the given environment.
+// It is resolved from the driver of the environment by the databaseIdProvider,
+// and is empty if the driver is not listed in it.
+// If the databaseIdProvider is not declared, the driver name is used as the databaseId,
+// which is one of "mysql", "postgres", "sqlite3" and "oracle" for the builtin drivers.
+func (c Configuration) DatabaseID(env *Environment) string {
+	if env == nil {
+		return ""
+	}
+	if c.databaseIDs == nil {
+		return env.Driver
+	}
+	return c.databaseIDs[env.Driver]
+}
+
+// SetDB sets the already opened database of the environment with the given id,
+// which is used instead of opening one from its dataSource, see Environment.SetDB.
+//
+//	db, mock, err := sqlmock.New()
+//	...
+//	err = cfg.(*juice.Configuration).SetDB("test", db)
+//	engine, err := juice.New(cfg)
+func (c *Configuration) SetDB(envID string, db *sql.DB) error {
+	if db == nil {
+		return fmt.Errorf("juice: db of environment %s is nil", envID)
+	}
+	env, err := c.environments.Use(envID)
+	if err != nil {
+		return err
+	}
+	env.SetDB(db)
+	return nil
+}
+
+// GetStatementByDatabaseID returns the xmlSQLStatement of the given value for the given databaseId.
+func (c Configuration) GetStatementByDatabaseID(v any, databaseID string) (Statement, error) {
+	id, err := statementIDOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.mappers.GetStatementByDatabaseID(id, databaseID)
+}
+
 func NewXMLConfiguration(filename string) (IConfiguration, error) {
 	return newLocalXMLConfiguration(filename, false)
 }
@@ -92,6 +158,102 @@ func newXMLConfigurationParser(fs fs.FS, filename string, ignoreEnv bool) (IConf
 		&XMLEnvironmentsElementParser{},
 		&XMLMappersElementParser{},
 		&XMLSettingsElementParser{},
+		&XMLDatabaseIDProviderElementParser{},
 	)
 	return parser.Parse(file)
 }
+
+// NewXMLConfigurationFromFiles creates a new Configuration by parsing each of the given
+// XML files and merging their environments, settings and mappers together.
+// The resources referenced by a file are resolved relative to the file itself.
+// It returns an error if an environment id or a setting name is defined more than once,
+// if the files declare different default environments, or if a mapper namespace is not
+// unique across the files.
+func NewXMLConfigurationFromFiles(fs fs.FS, filenames ...string) (IConfiguration, error) {
+	merged := &Configuration{typeHandlers: &TypeHandlers{}}
+	for _, filename := range filenames {
+		cfg, err := NewXMLConfigurationWithFS(fs, filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		if err = merged.merge(cfg.(*Configuration)); err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", filename, err)
+		}
+	}
+	if merged.mappers != nil {
+		merged.mappers.cfg = merged
+	}
+	return merged, nil
+}
+
+// merge merges the environments, settings and mappers of the other configuration into c.
+func (c *Configuration) merge(other *Configuration) error {
+	if err := c.mergeEnvironments(other.environments); err != nil {
+		return err
+	}
+	for name, value := range other.settings {
+		if _, exists := c.settings[name]; exists {
+			return fmt.Errorf("duplicate setting name: %s", name)
+		}
+		if c.settings == nil {
+			c.settings = make(keyValueSettingProvider)
+		}
+		c.settings[name] = value
+	}
+	for name, value := range other.databaseIDs {
+		if _, exists := c.databaseIDs[name]; exists {
+			return fmt.Errorf("duplicate databaseIdProvider property: %s", name)
+		}
+		if c.databaseIDs == nil {
+			c.databaseIDs = make(map[string]string)
+		}
+		c.databaseIDs[name] = value
+	}
+	return c.mergeMappers(other.mappers)
+}
+
+func (c *Configuration) mergeEnvironments(other *environments) error {
+	if other == nil {
+		return nil
+	}
+	if c.environments == nil {
+		c.environments = &environments{}
+	}
+	for key, value := range other.attr {
+		if current := c.environments.Attribute(key); current != "" && current != value {
+			return fmt.Errorf("conflicting environments attribute %s: %s and %s", key, current, value)
+		}
+		c.environments.setAttr(key, value)
+	}
+	for id, env := range other.envs {
+		if _, exists := c.environments.envs[id]; exists {
+			return fmt.Errorf("duplicate environment id: %s", id)
+		}
+		if c.environments.envs == nil {
+			c.environments.envs = make(map[string]*Environment)
+		}
+		c.environments.envs[id] = env
+	}
+	return nil
+}
+
+func (c *Configuration) mergeMappers(other *Mappers) error {
+	if other == nil || other.mappers == nil {
+		return nil
+	}
+	if c.mappers == nil {
+		c.mappers = &Mappers{attrs: other.attrs}
+	}
+	if c.mappers.mappers == nil {
+		c.mappers.mappers = container.NewTrie[*Mapper]()
+	}
+	// the keys have already been prefixed by the mappers they come from.
+	for _, item := range other.mappers.All() {
+		if _, exists := c.mappers.mappers.Get(item.Key); exists {
+			return fmt.Errorf("mapper %s already exists", item.Key)
+		}
+		item.Value.mappers = c.mappers
+		c.mappers.mappers.Insert(item.Key, item.Value)
+	}
+	return nil
+}
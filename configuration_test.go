@@ -2,7 +2,9 @@ package juice
 
 import (
 	"embed"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 //go:embed testdata/configuration
@@ -21,3 +23,104 @@ func TestNewXMLConfiguration(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestNewXMLConfigurationFromFiles(t *testing.T) {
+	files := fstest.MapFS{
+		"env.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <environments default="prod">
+        <environment id="prod">
+            <dataSource>fake</dataSource>
+            <driver>fake</driver>
+        </environment>
+    </environments>
+    <settings>
+        <setting name="debug" value="false"/>
+    </settings>
+</configuration>`)},
+		"users/juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper resource="users.xml"/>
+    </mappers>
+</configuration>`)},
+		"users/users.xml": &fstest.MapFile{Data: []byte(`<mapper namespace="main.users">
+    <select id="list">select * from users</select>
+</mapper>`)},
+		"orders.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper namespace="main.orders">
+            <select id="list">select * from orders</select>
+        </mapper>
+    </mappers>
+</configuration>`)},
+	}
+	cfg, err := NewXMLConfigurationFromFiles(files, "env.xml", "users/juice.xml", "orders.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cfg.Environments().Use("prod"); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, id := range []string{"main.users.list", "main.orders.list"} {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if statement.Configuration().Settings().Get("debug") != "false" {
+			t.Errorf("expected settings of %s to be merged", id)
+			return
+		}
+	}
+
+	if _, err = NewXMLConfigurationFromFiles(files, "env.xml", "env.xml"); err == nil {
+		t.Error("expected duplicate environment error")
+		return
+	}
+	if _, err = NewXMLConfigurationFromFiles(files, "orders.xml", "orders.xml"); err == nil {
+		t.Error("expected duplicate mapper error")
+		return
+	}
+}
+
+func TestMappersRoot(t *testing.T) {
+	mapper := func(namespace string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`<mapper namespace="` + namespace + `"><select id="list">select 1</select></mapper>`)}
+	}
+	files := fstest.MapFS{
+		"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers root="mappers" pattern="orders/*.xml">
+        <mapper resource="users.xml"/>
+        <mapper url="file://roles.xml"/>
+        <mapper url="file:///admin/admins.xml"/>
+    </mappers>
+</configuration>`)},
+		"mappers/users.xml":         mapper("users"),
+		"mappers/roles.xml":         mapper("roles"),
+		"mappers/admin/admins.xml":  mapper("admins"),
+		"mappers/orders/orders.xml": mapper("orders"),
+	}
+	cfg, err := NewXMLConfigurationWithFS(files, "juice.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"users.list", "roles.list", "admins.list", "orders.list"} {
+		if _, err = cfg.GetStatement(id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+
+	// the root of the parser is joined with the root attribute.
+	files = fstest.MapFS{
+		"app/mappers/users.xml": mapper("users"),
+	}
+	parser := &XMLParser{FS: files, MapperRoot: "app", ignoreEnv: true}
+	parser.AddXMLElementParser(&XMLMappersElementParser{})
+	cfg, err = parser.Parse(strings.NewReader(`<configuration><mappers root="mappers"><mapper resource="users.xml"/></mappers></configuration>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cfg.GetStatement("users.list"); err != nil {
+		t.Error(err)
+	}
+}
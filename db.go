@@ -19,13 +19,17 @@ limitations under the License.
 package juice
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/go-juicedev/juice/driver"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/go-juicedev/juice/driver"
 )
 
 // Source encapsulates all configuration parameters needed for establishing
@@ -38,6 +42,14 @@ type Source struct {
 	MaxOpenConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+
+	// OnNewConn is called with each new connection before it is used, which is optional.
+	OnNewConn driver.OnNewConnFunc
+
+	// DB is the already opened database used instead of opening one from the DSN, which is optional.
+	// The Driver is still used to translate the statements, and the pool settings are not applied.
+	// It is owned by the caller, so it is not closed by the manager.
+	DB *sql.DB
 }
 
 // conn represents an active database connection along with its associated driver.
@@ -46,6 +58,9 @@ type conn struct {
 	db   *sql.DB
 	drv  driver.Driver
 	once sync.Once
+
+	// borrowed reports whether the db is given by the Source, which is not closed by the manager.
+	borrowed bool
 }
 
 // DBManager implements a thread-safe connection manager for multiple database instances.
@@ -55,7 +70,12 @@ type DBManager struct {
 	sources map[string]Source // connection sources
 	mu      sync.RWMutex      // protects sources map
 	closed  atomic.Bool       // manager state
+	drain   atomic.Bool       // whether the manager is shutting down
 	names   []string          // sorted list of registered sources
+
+	inflight sync.Map      // in-flight executions of each source, name -> *atomic.Int64
+	idle     chan struct{} // signalled when an execution ends while draining
+	idleOnce sync.Once
 }
 
 var (
@@ -67,6 +87,10 @@ var (
 
 	// ErrSourceNotFound is returned when attempting to access a non-existent source
 	ErrSourceNotFound = errors.New("source not found")
+
+	// ErrShutdownTimeout is returned when some sources still have in-flight
+	// executions when the context of Close is done.
+	ErrShutdownTimeout = errors.New("shutdown timed out")
 )
 
 // Get retrieves an existing database connection or creates a new one if it doesn't exist.
@@ -114,6 +138,10 @@ func (m *DBManager) connect(name string, source Source) (db *sql.DB, drv driver.
 			err = fmt.Errorf("failed to get driver: %w", err)
 			return
 		}
+		if source.DB != nil {
+			c.db, c.drv, c.borrowed = source.DB, drv, true
+			return
+		}
 		db, err = driver.Connect(
 			source.Driver,
 			source.DSN,
@@ -121,6 +149,7 @@ func (m *DBManager) connect(name string, source Source) (db *sql.DB, drv driver.
 			driver.ConnectWithMaxIdleConnNum(source.MaxIdleConns),
 			driver.ConnectWithMaxConnLifetime(source.ConnMaxLifetime),
 			driver.ConnectWithMaxIdleConnLifetime(source.ConnMaxIdleTime),
+			driver.ConnectWithOnNewConn(source.OnNewConn),
 		)
 		if err != nil {
 			err = fmt.Errorf("failed to create connection: %w", err)
@@ -171,9 +200,36 @@ func (m *DBManager) Registered() []string {
 }
 
 // Close gracefully shuts down all managed database connections.
-// It ensures that all resources are properly released and prevents new connections
-// from being established. This method is idempotent and thread-safe.
-func (m *DBManager) Close() error {
+// It stops accepting new executions, waits until the in-flight executions of each
+// source are finished or the context is done, and then closes all the connections.
+// An execution is in-flight from the start of its statement handler until it returns,
+// and a transaction from its beginning until it is committed or rolled back.
+// The rows already returned are still readable, since the database closes their
+// connections when they are released.
+// If some sources didn't drain in time, an error wrapping ErrShutdownTimeout
+// which lists their names is returned, and the connections are closed anyway.
+// This method is idempotent and thread-safe.
+func (m *DBManager) Close(ctx context.Context) error {
+	m.drain.Store(true)
+
+	for {
+		busy := m.busy()
+		if len(busy) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("%w: %s", ErrShutdownTimeout, strings.Join(busy, ", "))
+			return errors.Join(err, ctx.Err(), m.closeConns())
+		case <-m.idleChan():
+		}
+	}
+	return m.closeConns()
+}
+
+// closeConns closes all managed database connections without waiting for the
+// in-flight executions, and prevents new connections from being established.
+func (m *DBManager) closeConns() error {
 
 	if m.closed.Load() {
 		return nil
@@ -189,6 +245,9 @@ func (m *DBManager) Close() error {
 	var errs []error
 	m.conns.Range(func(key, value interface{}) bool {
 		c := value.(*conn)
+		if c.borrowed {
+			return true
+		}
 		if err := c.db.Close(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to close %v: %w", key, err))
 		}
@@ -203,6 +262,53 @@ func (m *DBManager) Close() error {
 	return nil
 }
 
+// draining reports whether the manager is shutting down or closed.
+func (m *DBManager) draining() bool {
+	return m.drain.Load() || m.closed.Load()
+}
+
+// begin marks the start of an execution on the named source, which is ended by
+// calling the returned function once.
+// The execution is counted before checking whether the manager is draining,
+// so that Close never misses an execution which passed the check.
+func (m *DBManager) begin(name string) (func(), error) {
+	value, _ := m.inflight.LoadOrStore(name, new(atomic.Int64))
+	counter := value.(*atomic.Int64)
+	counter.Add(1)
+	end := func() {
+		if counter.Add(-1) == 0 && m.drain.Load() {
+			select {
+			case m.idleChan() <- struct{}{}:
+			default:
+			}
+		}
+	}
+	if m.draining() {
+		end()
+		return nil, ErrDBManagerClosed
+	}
+	return end, nil
+}
+
+// idleChan returns the channel signalled when an execution ends while draining.
+func (m *DBManager) idleChan() chan struct{} {
+	m.idleOnce.Do(func() { m.idle = make(chan struct{}, 1) })
+	return m.idle
+}
+
+// busy returns the sorted names of the sources which still have in-flight executions.
+func (m *DBManager) busy() []string {
+	var names []string
+	m.inflight.Range(func(key, value any) bool {
+		if value.(*atomic.Int64).Load() > 0 {
+			names = append(names, key.(string))
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
 // newDBManagerFromConfiguration creates a new DBManager instance using the provided
 // configuration. It initializes all configured database sources and validates their
 // parameters before adding them to the manager.
@@ -219,6 +325,8 @@ func newDBManagerFromConfiguration(cfg IConfiguration) (*DBManager, error) {
 			MaxIdleConns:    env.MaxIdleConnNum,
 			ConnMaxLifetime: time.Duration(env.MaxConnLifetime) * time.Second,
 			ConnMaxIdleTime: time.Duration(env.MaxIdleConnLifetime) * time.Second,
+			OnNewConn:       onNewConnHooks[name],
+			DB:              env.db,
 		}); err != nil {
 			return nil, fmt.Errorf("failed to add source %s: %w", name, err)
 		}
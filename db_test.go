@@ -0,0 +1,216 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_CloseContext(t *testing.T) {
+	db := newFakeDB(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		close(started)
+		<-release
+		return []string{"id"}, [][]sqldriver.Value{{int64(1)}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="ids">select id from t</select>`)
+	// an executor got before closing must not execute after it.
+	executor := engine.Object("main.ids")
+
+	queried := make(chan error, 1)
+	go func() {
+		rows, err := engine.Object("main.ids").QueryContext(context.Background(), nil)
+		if err == nil {
+			_, err = List[int64](rows)
+		}
+		queried <- err
+	}()
+	<-started
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		done <- engine.CloseContext(ctx)
+	}()
+
+	// wait for the engine to stop accepting new executions.
+	for !engine.manager.draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := engine.Object("main.ids").QueryContext(context.Background(), nil); !errors.Is(err, ErrDBManagerClosed) {
+		t.Errorf("expected ErrDBManagerClosed, got %v", err)
+	}
+	if _, err := executor.QueryContext(context.Background(), nil); !errors.Is(err, ErrDBManagerClosed) {
+		t.Errorf("expected ErrDBManagerClosed for the executor got before closing, got %v", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("expected close to wait for the in-flight query, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-queried; err != nil {
+		t.Errorf("unexpected query error: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+	if !engine.manager.closed.Load() {
+		t.Error("expected manager to be closed")
+	}
+}
+
+func TestEngine_CloseContextTimeout(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<update id="touch">update t set a = 1</update>`)
+
+	// an open transaction is in-flight until it is committed or rolled back.
+	tx := engine.Tx()
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := engine.CloseContext(ctx)
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("expected ErrShutdownTimeout, got %v", err)
+		return
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "test") {
+		t.Errorf("expected the undrained environment to be listed, got %v", err)
+		return
+	}
+	if !engine.manager.closed.Load() {
+		t.Error("expected manager to be closed")
+	}
+}
+
+func TestEngine_CloseContextTx(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<update id="touch">update t set a = 1</update>`)
+
+	tx := engine.Tx()
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- engine.CloseContext(context.Background()) }()
+	for !engine.manager.draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the statements of the begun transaction are still executed while closing.
+	if _, err := tx.Object("main.touch").ExecContext(context.Background(), nil); err != nil {
+		t.Errorf("unexpected exec error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+}
+
+func TestRegisterOnNewConn(t *testing.T) {
+	var hooked int
+	RegisterOnNewConn("test", func(ctx context.Context, conn *sql.Conn) error {
+		hooked++
+		_, err := conn.ExecContext(ctx, "SET app = ?", "juice")
+		return err
+	})
+	t.Cleanup(func() { delete(onNewConnHooks, "test") })
+
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<update id="touch">update t set a = 1</update>`)
+	for range 2 {
+		if _, err := engine.Object("main.touch").ExecContext(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calls := db.Calls()
+	if hooked != 1 || len(calls) != 3 || calls[0].query != "SET app = ?" || calls[0].args[0] != "juice" {
+		t.Errorf("expected the hook called once on the new connection, got %d %v", hooked, calls)
+		return
+	}
+
+	errHook := errors.New("hook failed")
+	RegisterOnNewConn("test", func(context.Context, *sql.Conn) error { return errHook })
+	engine = newFakeDB(t).Engine(t, "main", `<update id="touch">update t set a = 1</update>`)
+	_, err := engine.Object("main.touch").ExecContext(context.Background(), nil)
+	if !errors.Is(err, errHook) || !strings.Contains(err.Error(), "on new conn") {
+		t.Errorf("expected the hook error, got %v", err)
+	}
+}
+
+func TestConfiguration_SetDB(t *testing.T) {
+	fake := newFakeDB(t)
+	db := fake.Open(t)
+	cfg := newTestConfiguration(t, `<configuration>
+    <environments default="test">
+        <environment id="test">
+            <dataSource>unused</dataSource>
+            <driver>mysql</driver>
+        </environment>
+    </environments>
+    <mappers>
+        <mapper namespace="main">
+            <update id="touch">update t set a = #{a}</update>
+        </mapper>
+    </mappers>
+</configuration>`)
+	configuration := cfg.(*Configuration)
+	if err := configuration.SetDB("missing", db); err == nil {
+		t.Error("expected error for the unknown environment")
+	}
+	if err := configuration.SetDB("test", db); err != nil {
+		t.Fatal(err)
+	}
+
+	// the mysql database/sql driver is not registered, so the db must not be opened from the dataSource.
+	engine, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine.DB() != db {
+		t.Fatal("expected the given db to be used")
+	}
+	if _, err = engine.Object("main.touch").ExecContext(context.Background(), H{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].query != "update t set a = ?" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+
+	// the given db is owned by the caller.
+	if err = engine.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.PingContext(context.Background()); err != nil {
+		t.Errorf("expected the given db to be kept open, got %v", err)
+	}
+}
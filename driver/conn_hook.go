@@ -0,0 +1,179 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// OnNewConnFunc is called with each new connection before it is used by the pool,
+// for the instrumentation or the session setup which can not be expressed as SQL.
+// The connection must not be closed by the function, and an error fails the acquisition of the connection.
+type OnNewConnFunc func(ctx context.Context, conn *sql.Conn) error
+
+// hookConnector is a sqldriver.Connector which calls the OnNewConnFunc with each new connection.
+type hookConnector struct {
+	connector sqldriver.Connector
+	onNewConn OnNewConnFunc
+}
+
+// Connect implements sqldriver.Connector.
+func (c *hookConnector) Connect(ctx context.Context) (sqldriver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.hook(ctx, conn); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("on new conn: %w", err)
+	}
+	return conn, nil
+}
+
+// hook calls the OnNewConnFunc with the *sql.Conn of the new connection,
+// which is borrowed from a pool holding nothing but it.
+func (c *hookConnector) hook(ctx context.Context, conn sqldriver.Conn) (err error) {
+	db := sql.OpenDB(&singleConnector{conn: conn, driver: c.Driver()})
+	defer func() { err = errors.Join(err, db.Close()) }()
+	db.SetMaxOpenConns(1)
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, sqlConn.Close()) }()
+	return c.onNewConn(ctx, sqlConn)
+}
+
+// Driver implements sqldriver.Connector.
+func (c *hookConnector) Driver() sqldriver.Driver {
+	return c.connector.Driver()
+}
+
+// dsnConnector is a sqldriver.Connector of the drivers which do not implement sqldriver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver sqldriver.Driver
+}
+
+// Connect implements sqldriver.Connector.
+func (c dsnConnector) Connect(_ context.Context) (sqldriver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements sqldriver.Connector.
+func (c dsnConnector) Driver() sqldriver.Driver {
+	return c.driver
+}
+
+// singleConnector is a sqldriver.Connector which connects to the given connection only once.
+type singleConnector struct {
+	conn   sqldriver.Conn
+	driver sqldriver.Driver
+	used   bool
+}
+
+// Connect implements sqldriver.Connector.
+func (c *singleConnector) Connect(_ context.Context) (sqldriver.Conn, error) {
+	if c.used {
+		return nil, errors.New("connection is already used")
+	}
+	c.used = true
+	return borrowedConn{Conn: c.conn}, nil
+}
+
+// Driver implements sqldriver.Connector.
+func (c *singleConnector) Driver() sqldriver.Driver {
+	return c.driver
+}
+
+// borrowedConn is a sqldriver.Conn which is not closed by the pool it is borrowed by,
+// the optional interfaces of the connection are forwarded to it.
+type borrowedConn struct {
+	sqldriver.Conn
+}
+
+// Close implements sqldriver.Conn, the connection is closed by its owner.
+func (c borrowedConn) Close() error {
+	return nil
+}
+
+// PrepareContext implements sqldriver.ConnPrepareContext.
+func (c borrowedConn) PrepareContext(ctx context.Context, query string) (sqldriver.Stmt, error) {
+	if preparer, ok := c.Conn.(sqldriver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx implements sqldriver.ConnBeginTx.
+func (c borrowedConn) BeginTx(ctx context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	if beginner, ok := c.Conn.(sqldriver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("driver does not support non-default transaction options")
+	}
+	return c.Conn.Begin() // nolint:staticcheck
+}
+
+// ExecContext implements sqldriver.ExecerContext.
+func (c borrowedConn) ExecContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	if execer, ok := c.Conn.(sqldriver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, sqldriver.ErrSkip
+}
+
+// QueryContext implements sqldriver.QueryerContext.
+func (c borrowedConn) QueryContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	if queryer, ok := c.Conn.(sqldriver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, sqldriver.ErrSkip
+}
+
+// CheckNamedValue implements sqldriver.NamedValueChecker.
+func (c borrowedConn) CheckNamedValue(value *sqldriver.NamedValue) error {
+	if checker, ok := c.Conn.(sqldriver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return sqldriver.ErrSkip
+}
+
+// openWithOnNewConn opens a *sql.DB whose new connections are passed to the onNewConn.
+func openWithOnNewConn(driverName, datasource string, onNewConn OnNewConnFunc) (*sql.DB, error) {
+	// the *sql.DB opened by the name gives the driver, it connects to nothing.
+	db, err := sql.Open(driverName, datasource)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+	var connector sqldriver.Connector = dsnConnector{dsn: datasource, driver: drv}
+	if driverContext, ok := drv.(sqldriver.DriverContext); ok {
+		if connector, err = driverContext.OpenConnector(datasource); err != nil {
+			return nil, err
+		}
+	}
+	return sql.OpenDB(&hookConnector{connector: connector, onNewConn: onNewConn}), nil
+}
@@ -27,6 +27,7 @@ type connectOption struct {
 	MaxOpenConnNum      int
 	MaxConnLifetime     time.Duration
 	MaxIdleConnLifetime time.Duration
+	OnNewConn           OnNewConnFunc
 }
 
 // ConnectOptionFunc is a function to set the connection option.
@@ -60,13 +61,26 @@ func ConnectWithMaxIdleConnLifetime(d time.Duration) ConnectOptionFunc {
 	}
 }
 
+// ConnectWithOnNewConn sets the function called with each new connection.
+func ConnectWithOnNewConn(fn OnNewConnFunc) ConnectOptionFunc {
+	return func(option *connectOption) {
+		option.OnNewConn = fn
+	}
+}
+
 // Connect connects to the database.
 func Connect(driver string, datasource string, opts ...ConnectOptionFunc) (*sql.DB, error) {
 	var option connectOption
 	for _, opt := range opts {
 		opt(&option)
 	}
-	db, err := sql.Open(driver, datasource)
+	var db *sql.DB
+	var err error
+	if option.OnNewConn != nil {
+		db, err = openWithOnNewConn(driver, datasource, option.OnNewConn)
+	} else {
+		db, err = sql.Open(driver, datasource)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -17,8 +17,12 @@ limitations under the License.
 package driver
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"iter"
 	"sort"
+	"strconv"
 	"sync"
 )
 
@@ -28,6 +32,36 @@ type Driver interface {
 	Translator() Translator
 }
 
+// Limiter is an optional interface of Driver which limits the number of the rows returned by a query
+// in the dialect of the database.
+type Limiter interface {
+	// Limit returns the query which returns at most n rows.
+	Limit(query string, n int) string
+}
+
+// RowValueComparer is an optional interface of Driver which reports that the database supports
+// the row value comparisons, like (created_at, id) > (?, ?).
+type RowValueComparer interface {
+	// RowValueComparison reports whether the row value comparisons are supported.
+	RowValueComparison() bool
+}
+
+// PlaceholderLimiter is an optional interface of Driver which reports the maximum number of the placeholders,
+// which are the bind parameters, in a statement of the database.
+type PlaceholderLimiter interface {
+	// MaxPlaceholders returns the maximum number of the placeholders in a statement.
+	MaxPlaceholders() int
+}
+
+// BulkLoader is an optional interface of Driver which loads the rows into the table with the native
+// bulk mechanism of the database, like COPY FROM of PostgreSQL and LOAD DATA LOCAL INFILE of MySQL.
+// The builtin drivers do not implement it, since the mechanisms depend on the database clients,
+// it can be implemented by wrapping a builtin driver with the bulk API of the client.
+type BulkLoader interface {
+	// BulkLoad loads the rows into the columns of the table and returns the number of the rows loaded.
+	BulkLoad(ctx context.Context, db *sql.DB, table string, columns []string, rows iter.Seq[[]any]) (int64, error)
+}
+
 var (
 	// registeredDrivers is a map of registered drivers.
 	// The key is a name of driver, it is used to get a driver.
@@ -73,3 +107,8 @@ func Drivers() []string {
 	sort.Strings(drivers)
 	return drivers
 }
+
+// limitClause appends the LIMIT clause to the query, which is supported by MySQL, PostgreSQL and SQLite.
+func limitClause(query string, n int) string {
+	return query + " LIMIT " + strconv.Itoa(n)
+}
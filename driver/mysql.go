@@ -28,6 +28,21 @@ func (d MySQLDriver) String() string {
 	return "mysql"
 }
 
+// Limit implements Limiter.
+func (d MySQLDriver) Limit(query string, n int) string {
+	return limitClause(query, n)
+}
+
+// RowValueComparison implements RowValueComparer.
+func (d MySQLDriver) RowValueComparison() bool {
+	return true
+}
+
+// MaxPlaceholders implements PlaceholderLimiter.
+func (d MySQLDriver) MaxPlaceholders() int {
+	return 65535
+}
+
 func init() {
 	Register("mysql", &MySQLDriver{})
 }
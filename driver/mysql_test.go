@@ -9,3 +9,25 @@ func TestMySQLDriver(t *testing.T) {
 		t.Fatal("failed to translate")
 	}
 }
+
+func TestMySQLDriver_Limit(t *testing.T) {
+	var driver Driver = MySQLDriver{}
+	limiter, ok := driver.(Limiter)
+	if !ok {
+		t.Fatal("expected MySQLDriver to implement Limiter")
+	}
+	if query := limiter.Limit("SELECT * FROM users", 1); query != "SELECT * FROM users LIMIT 1" {
+		t.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestMySQLDriver_MaxPlaceholders(t *testing.T) {
+	var driver Driver = MySQLDriver{}
+	limiter, ok := driver.(PlaceholderLimiter)
+	if !ok {
+		t.Fatal("expected MySQLDriver to implement PlaceholderLimiter")
+	}
+	if limit := limiter.MaxPlaceholders(); limit != 65535 {
+		t.Errorf("unexpected limit: %d", limit)
+	}
+}
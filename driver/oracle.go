@@ -34,6 +34,12 @@ func (o OracleDriver) String() string {
 	return "oracle"
 }
 
+// Limit implements Limiter.
+// The FETCH FIRST clause is supported since Oracle 12c.
+func (o OracleDriver) Limit(query string, n int) string {
+	return query + " FETCH FIRST " + strconv.Itoa(n) + " ROWS ONLY"
+}
+
 func init() {
 	Register("oracle", &OracleDriver{})
 }
@@ -20,3 +20,14 @@ func TestOracleDriver(t *testing.T) {
 		}
 	}
 }
+
+func TestOracleDriver_Limit(t *testing.T) {
+	var driver Driver = OracleDriver{}
+	limiter, ok := driver.(Limiter)
+	if !ok {
+		t.Fatal("expected OracleDriver to implement Limiter")
+	}
+	if query := limiter.Limit("SELECT * FROM users", 1); query != "SELECT * FROM users FETCH FIRST 1 ROWS ONLY" {
+		t.Errorf("unexpected query: %s", query)
+	}
+}
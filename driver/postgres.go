@@ -21,7 +21,10 @@ import "strconv"
 // PostgresDriver is a driver of PostgreSQL.
 type PostgresDriver struct{}
 
-// Translator is a function to translate a matched string.
+// Translator returns a translator of SQL, which translates the placeholders to the numbered ones
+// like $1, $2 in the order they are translated, a repeated parameter gets a new number as well.
+// The translator is stateful, so a new one must be used to build each statement,
+// which is why every build of the statements calls Translator again.
 func (d PostgresDriver) Translator() Translator {
 	var i int
 	return TranslateFunc(func(matched string) string {
@@ -34,6 +37,21 @@ func (d PostgresDriver) String() string {
 	return "postgres"
 }
 
+// Limit implements Limiter.
+func (d PostgresDriver) Limit(query string, n int) string {
+	return limitClause(query, n)
+}
+
+// RowValueComparison implements RowValueComparer.
+func (d PostgresDriver) RowValueComparison() bool {
+	return true
+}
+
+// MaxPlaceholders implements PlaceholderLimiter.
+func (d PostgresDriver) MaxPlaceholders() int {
+	return 65535
+}
+
 func init() {
 	Register("postgres", &PostgresDriver{})
 }
@@ -28,6 +28,21 @@ func (d SQLiteDriver) String() string {
 	return "sqlite3"
 }
 
+// Limit implements Limiter.
+func (d SQLiteDriver) Limit(query string, n int) string {
+	return limitClause(query, n)
+}
+
+// RowValueComparison implements RowValueComparer.
+func (d SQLiteDriver) RowValueComparison() bool {
+	return true
+}
+
+// MaxPlaceholders implements PlaceholderLimiter, which is the default SQLITE_MAX_VARIABLE_NUMBER since SQLite 3.32.0.
+func (d SQLiteDriver) MaxPlaceholders() int {
+	return 32766
+}
+
 func init() {
 	Register("sqlite3", &SQLiteDriver{})
 }
@@ -16,6 +16,11 @@ limitations under the License.
 
 package driver
 
+import (
+	"strconv"
+	"unicode"
+)
+
 // Translator is an interface for translating the matched string.
 type Translator interface {
 	Translate(matched string) string
@@ -28,3 +33,74 @@ type TranslateFunc func(matched string) string
 func (f TranslateFunc) Translate(matched string) string {
 	return f(matched)
 }
+
+// NamedArgTranslator is an optional interface of Translator which translates the placeholders to the named ones,
+// the arguments of the built statement are passed as the sql.NamedArg by the returned names.
+type NamedArgTranslator interface {
+	Translator
+
+	// Names returns the names of the placeholders translated so far in order,
+	// each of which names the argument at the same position.
+	Names() []string
+}
+
+// NamedTranslator is a Translator which translates the placeholders to the named ones like :name,
+// which is preferred by some drivers. The names are made valid identifiers by replacing the other
+// characters with the underscores, like :user_name of #{user.name}, and a repeated name gets its
+// occurrence as the suffix, like :id_2, since it may hold a different value, like the item of a foreach.
+// It is stateful, so a new one must be used to build each statement, which can be plugged into
+// a driver by its Translator method:
+//
+//	type NamedMySQLDriver struct{ driver.MySQLDriver }
+//
+//	func (NamedMySQLDriver) Translator() driver.Translator { return driver.NewNamedTranslator() }
+type NamedTranslator struct {
+	names []string
+
+	// occurrences is the last occurrence of each name, used holds all the names translated.
+	occurrences map[string]int
+	used        map[string]struct{}
+}
+
+// NewNamedTranslator returns a new NamedTranslator.
+func NewNamedTranslator() *NamedTranslator {
+	return &NamedTranslator{occurrences: make(map[string]int), used: make(map[string]struct{})}
+}
+
+// Translate implements Translator.
+func (t *NamedTranslator) Translate(matched string) string {
+	base := placeholderName(matched)
+	name, n := base, t.occurrences[base]+1
+	if n > 1 {
+		name = base + "_" + strconv.Itoa(n)
+	}
+	// the suffixed name may be taken by another parameter, like id_2.
+	for _, exists := t.used[name]; exists; _, exists = t.used[name] {
+		n++
+		name = base + "_" + strconv.Itoa(n)
+	}
+	t.occurrences[base] = n
+	t.used[name] = struct{}{}
+	t.names = append(t.names, name)
+	return ":" + name
+}
+
+// Names implements NamedArgTranslator.
+func (t *NamedTranslator) Names() []string {
+	return t.names
+}
+
+// placeholderName returns the identifier of the placeholder of the given parameter name,
+// which starts with a letter and contains only the letters, the digits and the underscores.
+func placeholderName(name string) string {
+	runes := []rune(name)
+	for i, r := range runes {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			runes[i] = '_'
+		}
+	}
+	if len(runes) == 0 || !unicode.IsLetter(runes[0]) {
+		runes = append([]rune("p"), runes...)
+	}
+	return string(runes)
+}
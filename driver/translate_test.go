@@ -0,0 +1,37 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamedTranslator(t *testing.T) {
+	translator := NewNamedTranslator()
+	var placeholders []string
+	for _, name := range []string{"id", "user.name", "id", "id_2", "?", "id"} {
+		placeholders = append(placeholders, translator.Translate(name))
+	}
+	expected := []string{":id", ":user_name", ":id_2", ":id_2_2", ":p_", ":id_3"}
+	if !reflect.DeepEqual(placeholders, expected) {
+		t.Errorf("unexpected placeholders: %v", placeholders)
+	}
+	if names := translator.Names(); len(names) != len(expected) || names[1] != "user_name" {
+		t.Errorf("unexpected names: %v", names)
+	}
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package juice
 
 import (
+	"database/sql"
 	"fmt"
 	"iter"
 	"os"
+
+	"github.com/go-juicedev/juice/driver"
 )
 
 // Environment defines a environment.
@@ -45,6 +48,17 @@ type Environment struct {
 
 	// attrs is a map of attributes.
 	attrs map[string]string
+
+	// db is the database set by SetDB, which is used instead of opening one from the DataSource.
+	db *sql.DB
+}
+
+// SetDB sets the already opened database of the environment, which is used instead of opening one
+// from the DataSource, like a shared pool or a mock database for the tests.
+// The Driver is still required to translate the statements. The database is owned by the caller,
+// so it is not closed by the engine. It must be set before the engine is created.
+func (e *Environment) SetDB(db *sql.DB) {
+	e.db = db
 }
 
 // setAttr sets a value of the attribute.
@@ -182,6 +196,29 @@ func GetEnvValueProvider(key string) EnvValueProvider {
 	return defaultEnvValueProvider
 }
 
+// onNewConnHooks is a map of the registered functions called with the new connections by the environment ids.
+var onNewConnHooks = map[string]driver.OnNewConnFunc{}
+
+// RegisterOnNewConn registers the function called with each new connection of the environment with the given id,
+// after the connection is established and before it is used, like:
+//
+//	juice.RegisterOnNewConn("prod", func(ctx context.Context, conn *sql.Conn) error {
+//	    _, err := conn.ExecContext(ctx, "SET TIME ZONE 'UTC'")
+//	    return err
+//	})
+//
+// An error fails the acquisition of the connection, which is wrapped with the context of the hook.
+// It must be registered before the engine is created.
+func RegisterOnNewConn(envID string, fn driver.OnNewConnFunc) {
+	if len(envID) == 0 {
+		panic("name is empty")
+	}
+	if fn == nil {
+		panic("juice: on new conn function is nil")
+	}
+	onNewConnHooks[envID] = fn
+}
+
 func init() {
 	// Register the default environment value provider.
 	RegisterEnvValueProvider("env", &OsEnvValueProvider{})
@@ -19,6 +19,7 @@ package juice
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -46,6 +47,29 @@ var (
 
 	// ErrNoStatementFound is an error that is returned when the statement is not found.
 	ErrNoStatementFound = errors.New("no statement found")
+
+	// ErrParamNotFound is an error that is returned when the parameter of a placeholder
+	// or a text substitution is not found.
+	ErrParamNotFound = errors.New("parameter not found")
+
+	// ErrCollectionNotFound is an error that is returned when the collection of a foreach node is not found.
+	ErrCollectionNotFound = errors.New("collection not found")
+
+	// ErrUnsupportedType is an error that is returned when the type of value is not supported by the node,
+	// like a foreach collection which is neither a slice nor a map.
+	ErrUnsupportedType = errors.New("unsupported type")
+
+	// ErrAmbiguousColumn is an error that is returned in the strict mode of the mappers
+	// when more than one field of an alias node select the same column name.
+	ErrAmbiguousColumn = errors.New("ambiguous column")
+
+	// ErrNothingToUpdate is an error that is returned when a set node generated from a struct
+	// has no column to update, since all the fields are zero.
+	ErrNothingToUpdate = errors.New("nothing to update")
+
+	// ErrNothingToInsert is an error that is returned when a values node generated from a struct
+	// has no column to insert.
+	ErrNothingToInsert = errors.New("nothing to insert")
 )
 
 // nodeUnclosedError is an error that is returned when the node is not closed.
@@ -81,6 +105,61 @@ func (e *nodeAttributeConflictError) Error() string {
 	return fmt.Sprintf("node %s has conflicting attribute %s", e.nodeName, e.attrName)
 }
 
+// nodeAttributeUnknownError is an error that is returned when the node has unknown attributes in the strict mode.
+type nodeAttributeUnknownError struct {
+	nodeName  string
+	attrNames []string
+}
+
+// Error returns the error message.
+func (e *nodeAttributeUnknownError) Error() string {
+	return fmt.Sprintf("node %s has unknown attribute %s", e.nodeName, strings.Join(e.attrNames, ", "))
+}
+
+// XMLParseError is an error that is returned when a mapper fails to parse, which tells where the error is.
+type XMLParseError struct {
+	// Resource is the resource or the url of the mapper file,
+	// it is empty for the mappers declared in the configuration file.
+	Resource string
+
+	// Namespace is the namespace of the mapper.
+	Namespace string
+
+	// Element is the element of the mapper in which the error occurs, like select id="GetUser".
+	Element string
+
+	// Line and Column are the position of the decoder when the error occurs, both are 1-based.
+	Line, Column int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the error message.
+func (e *XMLParseError) Error() string {
+	var builder strings.Builder
+	builder.WriteString("parse mapper")
+	if e.Resource != "" {
+		builder.WriteString(" " + e.Resource)
+	}
+	if e.Namespace != "" {
+		builder.WriteString(" (namespace " + e.Namespace + ")")
+	}
+	if e.Line > 0 {
+		builder.WriteString(fmt.Sprintf(" at line %d, column %d", e.Line, e.Column))
+	}
+	if e.Element != "" {
+		builder.WriteString(" in <" + e.Element + ">")
+	}
+	builder.WriteString(": " + e.Err.Error())
+	return builder.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *XMLParseError) Unwrap() error {
+	return e.Err
+}
+
 // unreachable is a function that is used to mark unreachable code.
 // nolint:deadcode,unused
 func unreachable() error {
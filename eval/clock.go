@@ -0,0 +1,62 @@
+/*
+Copyright 2025 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eval
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Clock returns the current time.
+type Clock func() time.Time
+
+// clock is the clock used by the now and utcNow functions.
+var clock atomic.Pointer[Clock]
+
+// SetClock sets the clock used by the now and utcNow functions,
+// which allows tests to inject a fixed time.
+// If c is nil, time.Now is used.
+func SetClock(c Clock) {
+	if c == nil {
+		clock.Store(nil)
+		return
+	}
+	clock.Store(&c)
+}
+
+// currentTime returns the current time of the clock.
+func currentTime() time.Time {
+	if c := clock.Load(); c != nil {
+		return (*c)()
+	}
+	return time.Now()
+}
+
+// now returns the current local time.
+func now() (time.Time, error) {
+	return currentTime(), nil
+}
+
+// utcNow returns the current time in UTC.
+func utcNow() (time.Time, error) {
+	return currentTime().UTC(), nil
+}
+
+// formatDate formats the time by the given layout of time.Format, like formatDate(day, '200601').
+func formatDate(t time.Time, layout string) (string, error) {
+	return t.Format(layout), nil
+}
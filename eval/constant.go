@@ -0,0 +1,78 @@
+/*
+Copyright 2025 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// constants is the registry of the named constants, the dotted names are grouped into nested maps.
+var constants = map[string]any{}
+
+// RegisterConstant registers a named constant which can be used in expressions,
+// a dotted name groups the constants like an enum.
+//
+//	RegisterConstant("Status.Active", 1)
+//	RegisterConstant("Status.Inactive", 0)
+//
+//	<if test="status == Status.Active">...</if>
+//
+// The constants are consulted only when an identifier is not found in the parameter,
+// so a parameter field with the same name as a constant always takes precedence,
+// for example, if the parameter has a field named Status, Status.Active is resolved from that field.
+// It panics if the name is empty or collides with a registered constant or group.
+// It is not safe for concurrent use, constants should be registered at init time.
+func RegisterConstant(name string, value any) {
+	if name == "" {
+		panic("eval: constant name is empty")
+	}
+	group := constants
+	for {
+		key, rest, nested := strings.Cut(name, ".")
+		if key == "" {
+			panic(fmt.Sprintf("eval: invalid constant name %q", name))
+		}
+		if !nested {
+			if _, exists := group[key]; exists {
+				panic(fmt.Sprintf("eval: constant %q already registered", name))
+			}
+			group[key] = value
+			return
+		}
+		child, exists := group[key]
+		if !exists {
+			child = map[string]any{}
+			group[key] = child
+		}
+		next, ok := child.(map[string]any)
+		if !ok {
+			panic(fmt.Sprintf("eval: constant %q collides with constant %q", name, key))
+		}
+		group, name = next, rest
+	}
+}
+
+// lookupConstant returns the registered constant or constant group of the given identifier.
+func lookupConstant(name string) (reflect.Value, bool) {
+	value, ok := constants[name]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return reflect.ValueOf(value), true
+}
@@ -97,6 +97,7 @@ type goExpression struct {
 }
 
 // Execute evaluates the expression and returns the value.
+// The evaluation is limited by the Budget of the params if they implement BudgetCarrier.
 func (e *goExpression) Execute(params Parameter) (Value, error) {
 	return eval(e.Expr, params)
 }
@@ -115,6 +116,12 @@ func Eval(expr string, params Parameter) (Value, error) {
 }
 
 func eval(exp ast.Expr, params Parameter) (reflect.Value, error) {
+	if b := budgetOf(params); b != nil {
+		if err := b.enter(); err != nil {
+			return reflect.Value{}, err
+		}
+		defer b.leave()
+	}
 	switch exp := exp.(type) {
 	case *ast.BinaryExpr:
 		return evalBinaryExpr(exp, params)
@@ -191,6 +198,10 @@ func evalUnaryExpr(exp *ast.UnaryExpr, params Parameter) (reflect.Value, error)
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	// the values of the maps like H are held by interfaces.
+	for value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
 	switch exp.Op {
 	case token.SUB:
 		return reflect.ValueOf(-value.Int()), nil
@@ -367,6 +378,8 @@ func evalSelectorExpr(exp *ast.SelectorExpr, params Parameter) (reflect.Value, e
 		result = unwarned.MapIndex(reflect.ValueOf(fieldOrTagOrMethodName))
 		// select expression does not support get default value from map
 		// it might be ambiguous with calling a method
+	case reflect.Slice, reflect.Array:
+		// only the methods can be selected from the collections.
 	default:
 		return reflect.Value{}, fmt.Errorf("invalid selector expression: %s", fieldOrTagOrMethodName)
 	}
@@ -377,6 +390,11 @@ func evalSelectorExpr(exp *ast.SelectorExpr, params Parameter) (reflect.Value, e
 		result = x.MethodByName(fieldOrTagOrMethodName)
 	}
 
+	// try to find the builtin method of the collections, like ids.contains(1).
+	if !result.IsValid() {
+		result = collectionMethod(unwarned, fieldOrTagOrMethodName)
+	}
+
 	// we failed to find the field
 	// it means you wrote a wrong expression
 	if !result.IsValid() {
@@ -391,10 +409,14 @@ func evalIdent(exp *ast.Ident, params Parameter) (reflect.Value, error) {
 		return fn, nil
 	}
 	value, ok := params.Get(exp.Name)
-	if !ok {
-		return reflect.Value{}, fmt.Errorf("undefined identifier: %s", exp.Name)
+	if ok {
+		return value, nil
+	}
+	// the parameter takes precedence over the constants.
+	if value, ok = lookupConstant(exp.Name); ok {
+		return value, nil
 	}
-	return value, nil
+	return reflect.Value{}, fmt.Errorf("undefined identifier: %s", exp.Name)
 }
 
 var errUnsupportedBasicLiteral = errors.New("unsupported basic literal")
@@ -87,7 +87,10 @@ func strJoin(v any, sep string) (string, error) {
 	return "", errors.New("join: invalid argument type")
 }
 
-// contains returns true if the value is in the array or string.
+// contains returns true if the value is in the array, the set or the string.
+// A map is treated as a set of its keys, like map[string]struct{} or map[string]bool,
+// and the keys of a map[K]bool are only in the set if their values are true.
+// The numbers are compared by their values, so contains(ids, 1) works for []int32 too.
 func contains(s any, v any) (bool, error) {
 	switch t := s.(type) {
 	case string:
@@ -99,19 +102,98 @@ func contains(s any, v any) (bool, error) {
 	default:
 		rv := reflect.Indirect(reflect.ValueOf(s))
 		switch rv.Kind() {
-		case reflect.Array, reflect.Slice, reflect.Map:
+		case reflect.Array, reflect.Slice:
 			for i := 0; i < rv.Len(); i++ {
-				if rv.Index(i).Interface() == v {
+				if equalElem(rv.Index(i), v) {
 					return true, nil
 				}
 			}
 			return false, nil
+		case reflect.Map:
+			key, ok := convertElem(reflect.ValueOf(v), rv.Type().Key())
+			if !ok {
+				return false, nil
+			}
+			value := rv.MapIndex(key)
+			if !value.IsValid() {
+				return false, nil
+			}
+			if value.Kind() == reflect.Bool {
+				return value.Bool(), nil
+			}
+			return true, nil
 		default:
 		}
 	}
 	return false, errors.New("contains: invalid argument type")
 }
 
+// in returns true if the value is in the collection, which is contains with the arguments swapped,
+// like in(status, statuses).
+func in(v any, collection any) (bool, error) {
+	return contains(collection, v)
+}
+
+// convertElem converts the value to the element type of a collection,
+// only the values of the same type and the numbers can be converted.
+func convertElem(value reflect.Value, elemType reflect.Type) (reflect.Value, bool) {
+	if !value.IsValid() {
+		return reflect.Value{}, false
+	}
+	if value.Type() == elemType {
+		return value, true
+	}
+	if elemType.Kind() == reflect.Interface && value.Type().Implements(elemType) {
+		return value, true
+	}
+	if isNumberKind(value.Kind()) && isNumberKind(elemType.Kind()) {
+		// the negative numbers are never unsigned integers.
+		if value.CanInt() && value.Int() < 0 && elemType.Kind() >= reflect.Uint && elemType.Kind() <= reflect.Uintptr {
+			return reflect.Value{}, false
+		}
+		converted := value.Convert(elemType)
+		// the value is changed by the conversion, like 1.5 to int.
+		if !converted.Convert(value.Type()).Equal(value) {
+			return reflect.Value{}, false
+		}
+		return converted, true
+	}
+	return reflect.Value{}, false
+}
+
+// equalElem reports whether the element of a collection equals to the value.
+func equalElem(elem reflect.Value, v any) bool {
+	if elem.Kind() == reflect.Interface {
+		elem = elem.Elem()
+	}
+	if !elem.IsValid() {
+		return v == nil
+	}
+	value, ok := convertElem(reflect.ValueOf(v), elem.Type())
+	return ok && value.Comparable() && elem.Comparable() && value.Equal(elem)
+}
+
+// isNumberKind reports whether the kind is an integer or a float.
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+// collectionMethod returns the builtin method of the collection by the given name, like ids.contains(1),
+// or an invalid value if there is no such method.
+func collectionMethod(collection reflect.Value, name string) reflect.Value {
+	switch collection.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map:
+	default:
+		return reflect.Value{}
+	}
+	switch name {
+	case "contains":
+		return reflect.ValueOf(func(v any) (bool, error) { return contains(collection.Interface(), v) })
+	default:
+		return reflect.Value{}
+	}
+}
+
 // slice returns a slice of the array or string.
 func slice(v any, start, count int) ([]any, error) {
 	rv := reflect.Indirect(reflect.ValueOf(v))
@@ -235,6 +317,7 @@ func init() {
 	MustRegisterEvalFunc("substr", strSub)
 	MustRegisterEvalFunc("join", strJoin)
 	MustRegisterEvalFunc("contains", contains)
+	MustRegisterEvalFunc("in", in)
 	MustRegisterEvalFunc("slice", slice)
 	MustRegisterEvalFunc("lower", lower)
 	MustRegisterEvalFunc("upper", upper)
@@ -246,4 +329,8 @@ func init() {
 	MustRegisterEvalFunc("split", split)
 	MustRegisterEvalFunc("splitN", splitN)
 	MustRegisterEvalFunc("splitAfter", splitAfter)
+	MustRegisterEvalFunc("now", now)
+	MustRegisterEvalFunc("utcNow", utcNow)
+	MustRegisterEvalFunc("formatDate", formatDate)
+	MustRegisterEvalFunc("flag", Flag)
 }
@@ -1,9 +1,12 @@
 package eval
 
 import (
+	"errors"
 	"go/parser"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func testEval(expr string, v any) (result reflect.Value, err error) {
@@ -286,6 +289,196 @@ func TestComment(t *testing.T) {
 	}
 }
 
+func TestSingleQuotedString(t *testing.T) {
+	param := H{"_databaseId": "mysql", "name": "it's"}
+	cases := []string{
+		`_databaseId == 'mysql'`,
+		`_databaseId != 'postgres'`,
+		`name == 'it\'s'`,
+		`'a' == "a"`,
+	}
+	for _, expr := range cases {
+		result, err := testEval(expr, param)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			return
+		}
+		if !result.Bool() {
+			t.Errorf("%s: eval error", expr)
+			return
+		}
+	}
+}
+
+func TestFlag(t *testing.T) {
+	SetFlagProvider(FlagMap{"new_ranking": true, "old_ranking": false})
+	t.Cleanup(func() {
+		SetFlagProvider(nil)
+		SetStrictFlag(false)
+	})
+
+	cases := map[string]bool{
+		`flag('new_ranking')`:  true,
+		`flag('old_ranking')`:  false,
+		`flag('missing_flag')`: false,
+	}
+	for expr, expected := range cases {
+		result, err := Eval(expr, nil)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if result.Bool() != expected {
+			t.Errorf("%s: expected %v, got %v", expr, expected, result.Bool())
+		}
+	}
+
+	SetStrictFlag(true)
+	if _, err := Eval(`flag('missing_flag')`, nil); !errors.Is(err, ErrUnknownFlag) {
+		t.Errorf("expected ErrUnknownFlag, got %v", err)
+	}
+
+	SetFlagProvider(FlagFunc(func(name string) bool { return name == "beta" }))
+	result, err := Eval(`flag('beta') && !flag('alpha')`, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !result.Bool() {
+		t.Error("expected true")
+	}
+}
+
+func TestConstant(t *testing.T) {
+	RegisterConstant("Status.Active", 1)
+	RegisterConstant("Status.Inactive", 0)
+	RegisterConstant("MaxPageSize", 100)
+	t.Cleanup(func() {
+		delete(constants, "Status")
+		delete(constants, "MaxPageSize")
+	})
+
+	cases := []struct {
+		expr     string
+		param    H
+		expected bool
+	}{
+		{`status == Status.Active`, H{"status": 1}, true},
+		{`status == Status.Inactive`, H{"status": 1}, false},
+		{`size <= MaxPageSize`, H{"size": 20}, true},
+		// the parameter takes precedence over the constants.
+		{`MaxPageSize == 10`, H{"MaxPageSize": 10}, true},
+		{`status == Status.Active`, H{"status": 1, "Status": H{"Active": 2}}, false},
+	}
+	for _, c := range cases {
+		result, err := Eval(c.expr, NewGenericParam(c.param, ""))
+		if err != nil {
+			t.Errorf("%s: %v", c.expr, err)
+			continue
+		}
+		if result.Bool() != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.expr, c.expected, result.Bool())
+		}
+	}
+
+	if _, err := Eval(`status == Status.Deleted`, NewGenericParam(H{"status": 1}, "")); err == nil {
+		t.Error("expected error for the unknown constant")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for the collided constant")
+		}
+	}()
+	RegisterConstant("MaxPageSize.Default", 10)
+}
+
+func TestCollectionContains(t *testing.T) {
+	param := NewGenericParam(H{
+		"fields":   []string{"id", "profile"},
+		"ids":      []int32{1, 2},
+		"set":      map[string]struct{}{"profile": {}},
+		"flags":    map[string]bool{"profile": true, "orders": false},
+		"statuses": []any{"active", 1},
+		"status":   "active",
+	}, "")
+	cases := map[string]bool{
+		`fields.contains('profile')`:     true,
+		`fields.contains('orders')`:      false,
+		`ids.contains(2)`:                true,
+		`ids.contains(-1)`:               false,
+		`contains(ids, 1)`:               true,
+		`set.contains('profile')`:        true,
+		`set.contains('orders')`:         false,
+		`flags.contains('profile')`:      true,
+		`flags.contains('orders')`:       false,
+		`in(status, statuses)`:           true,
+		`in(1, statuses)`:                true,
+		`in('deleted', statuses)`:        false,
+		`in(3, ids) || in('id', fields)`: true,
+	}
+	for expr, expected := range cases {
+		result, err := Eval(expr, param)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if result.Bool() != expected {
+			t.Errorf("%s: expected %v, got %v", expr, expected, result.Bool())
+		}
+	}
+	if _, err := Eval(`fields.missing('id')`, param); err == nil {
+		t.Error("expected error for the unknown collection method")
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	day := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	result, err := Eval(`'events_' + formatDate(day, '200601')`, NewGenericParam(H{"day": day}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "events_202401" {
+		t.Errorf("expected events_202401, got %s", result.String())
+	}
+}
+
+func TestNow(t *testing.T) {
+	fixed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	SetClock(func() time.Time { return fixed })
+	t.Cleanup(func() { SetClock(nil) })
+
+	result, err := Eval(`now()`, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, ok := result.Interface().(time.Time); !ok || !got.Equal(fixed) || got.Location() != fixed.Location() {
+		t.Errorf("expected %v, got %v", fixed, result.Interface())
+		return
+	}
+
+	result, err = Eval(`utcNow()`, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got, ok := result.Interface().(time.Time); !ok || !got.Equal(fixed) || got.Location() != time.UTC {
+		t.Errorf("expected %v, got %v", fixed.UTC(), result.Interface())
+		return
+	}
+
+	SetClock(nil)
+	result, err = Eval(`now()`, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := result.Interface().(time.Time); time.Since(got) > time.Minute {
+		t.Errorf("expected the current time, got %v", got)
+	}
+}
+
 func TestUnaryExpr(t *testing.T) {
 	result, err := Eval(`-2`, nil)
 	if err != nil {
@@ -974,6 +1167,10 @@ func TestLexer_Tokenize(t *testing.T) {
 		// Spacing variations
 		{"extra_spaces", "true  and  false  or  true", true},
 		{"minimal_spaces", "true and false or true", true},
+
+		// null is nil
+		{"null", "null == nil", true},
+		{"not_null", "not (null != nil)", true},
 	}
 
 	for _, tt := range tests {
@@ -991,3 +1188,154 @@ func TestLexer_Tokenize(t *testing.T) {
 		})
 	}
 }
+
+func TestShortCircuitKeywords(t *testing.T) {
+	type Profile struct {
+		Age int
+	}
+	type User struct {
+		Profile *Profile
+	}
+	// the right operands fail to evaluate if they are evaluated at all.
+	if _, err := Eval("user.Profile.Age > 1", NewGenericParam(map[string]any{"user": User{}}, "")); err == nil {
+		t.Fatal("expected error for the nil pointer")
+	}
+	tests := []struct {
+		expr     string
+		user     any
+		expected bool
+	}{
+		{"user.Profile != null and user.Profile.Age > 1", User{}, false},
+		{"user.Profile != nil && user.Profile.Age > 1", User{}, false},
+		{"user.Profile == null or user.Profile.Age > 1", User{}, true},
+		{"user.Profile == nil || user.Profile.Age > 1", User{}, true},
+		{"not (user.Profile == null) and user.Profile.Age > 1", User{}, false},
+		{"user.Profile != null and user.Profile.Age > 1", User{Profile: &Profile{Age: 2}}, true},
+		{"user.Profile == null or user.Profile.Age > 1", User{Profile: &Profile{Age: 1}}, false},
+		{"user != null and user.Profile != null", nil, false},
+	}
+	for _, tt := range tests {
+		result, err := Eval(tt.expr, NewGenericParam(map[string]any{"user": tt.user}, ""))
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if result.Bool() != tt.expected {
+			t.Errorf("%s: got %v, want %v", tt.expr, result, tt.expected)
+		}
+	}
+}
+
+func TestAnyMapParameter(t *testing.T) {
+	type User struct {
+		Name string
+	}
+	param := NewParameter(map[string]any{
+		"id":      1,
+		"nil":     nil,
+		"user":    H{"name": "eatmoreapple", "tags": map[string]any{"a": 1}},
+		"entity":  User{Name: "juice"},
+		"numbers": []int{1, 2, 3},
+	})
+	if _, ok := param.(anyMapParameter); !ok {
+		t.Fatalf("expected anyMapParameter, got %T", param)
+	}
+	cases := map[string]any{
+		"id":          1,
+		"user.name":   "eatmoreapple",
+		"user.tags.a": 1,
+		"entity.Name": "juice",
+		"numbers.1":   2,
+	}
+	for name, expected := range cases {
+		value, ok := param.Get(name)
+		if !ok {
+			t.Errorf("%s: not found", name)
+			continue
+		}
+		if value.Interface() != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, value.Interface())
+		}
+	}
+	if value, ok := param.Get("nil"); !ok || value.Kind() != reflect.Interface || !value.IsNil() {
+		t.Errorf("expected a nil interface value, got %v", value)
+	}
+	for _, name := range []string{"missing", "user.missing", "nil.a", "id.a"} {
+		if _, ok := param.Get(name); ok {
+			t.Errorf("%s: expected not found", name)
+		}
+	}
+}
+
+func benchmarkParameterMap() map[string]any {
+	param := make(map[string]any, 10)
+	for i := 0; i < 9; i++ {
+		param["key"+strconv.Itoa(i)] = i
+	}
+	param["user"] = map[string]any{"name": "eatmoreapple"}
+	return param
+}
+
+func BenchmarkAnyMapParameter_Get(b *testing.B) {
+	param := benchmarkParameterMap()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := anyMapParameter(param)
+		_, _ = p.Get("key3")
+		_, _ = p.Get("key7")
+		_, _ = p.Get("user.name")
+	}
+	// BenchmarkAnyMapParameter_Get   	 4823659	       242.9 ns/op	      64 B/op	       4 allocs/op
+}
+
+func BenchmarkGenericParameter_Get(b *testing.B) {
+	param := benchmarkParameterMap()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := &GenericParameter{Value: reflect.ValueOf(param)}
+		_, _ = p.Get("key3")
+		_, _ = p.Get("key7")
+		_, _ = p.Get("user.name")
+	}
+	// BenchmarkGenericParameter_Get 	 1214359	       844.7 ns/op	     688 B/op	      17 allocs/op
+}
+
+func TestWithLimits(t *testing.T) {
+	param := NewGenericParam(H{"id": 1, "ids": []int{1, 2}}, "")
+
+	// the binary expressions are left associative, so the depth grows with the conditions.
+	expression, err := Compile(`id > 0 && id > 0 && id > 0 && id > 0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		limits Limits
+		failed bool
+	}{
+		{Limits{}, false},
+		{DefaultLimits, false},
+		{Limits{MaxDepth: 3}, true},
+		{Limits{MaxDepth: 5}, false},
+		{Limits{MaxSteps: 10}, true},
+		{Limits{MaxSteps: 15}, false},
+	}
+	for _, c := range cases {
+		limited := WithLimits(param, c.limits)
+		// the limits apply to the groups wrapped by the carrier, and the steps are counted per execution.
+		grouped := &limitedParameter{Parameter: ParamGroup{H{"x": 1}.AsParam(), param}, budget: budgetOf(limited)}
+		for _, p := range []Parameter{limited, grouped} {
+			for range 2 {
+				value, err := expression.Execute(p)
+				if failed := errors.Is(err, ErrLimitExceeded); failed != c.failed {
+					t.Errorf("%+v: expected failed %v, got %v", c.limits, c.failed, err)
+				}
+				if !c.failed && (err != nil || !value.Bool()) {
+					t.Errorf("%+v: unexpected result %v, %v", c.limits, value, err)
+				}
+			}
+		}
+		if b := budgetOf(limited); b.depth != 0 {
+			t.Errorf("%+v: expected the depth to be restored, got %d", c.limits, b.depth)
+		}
+	}
+}
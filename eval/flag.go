@@ -0,0 +1,90 @@
+/*
+Copyright 2025 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrUnknownFlag is an error that is returned by the flag function in the strict mode
+// when the feature flag is not known by the FlagProvider.
+var ErrUnknownFlag = errors.New("unknown flag")
+
+// FlagProvider provides the feature flags used by the flag function and the flag attribute of the statements,
+// which allows to toggle the branches or the whole statements at runtime.
+//
+//	<if test="flag('new_ranking')">ORDER BY score DESC</if>
+//	<select id="ListByScore" flag="new_ranking">...</select>
+type FlagProvider interface {
+	// Flag returns whether the feature flag with the given name is enabled,
+	// and whether the flag is known by the provider.
+	Flag(name string) (enabled, exists bool)
+}
+
+// FlagFunc is a function type of FlagProvider, which knows all the flags.
+type FlagFunc func(name string) bool
+
+// Flag implements FlagProvider.
+func (f FlagFunc) Flag(name string) (enabled, exists bool) {
+	return f(name), true
+}
+
+// FlagMap is a FlagProvider which provides the flags from a map.
+type FlagMap map[string]bool
+
+// Flag implements FlagProvider.
+func (m FlagMap) Flag(name string) (enabled, exists bool) {
+	enabled, exists = m[name]
+	return enabled, exists
+}
+
+// flagProvider is the FlagProvider used by the flag function.
+var flagProvider atomic.Pointer[FlagProvider]
+
+// strictFlag reports whether the unknown flags are errors.
+var strictFlag atomic.Bool
+
+// SetFlagProvider sets the FlagProvider used by the flag function.
+// If p is nil, all the flags are unknown.
+func SetFlagProvider(p FlagProvider) {
+	if p == nil {
+		flagProvider.Store(nil)
+		return
+	}
+	flagProvider.Store(&p)
+}
+
+// SetStrictFlag sets whether the flag function returns ErrUnknownFlag for the unknown flags.
+// By default, the unknown flags are disabled.
+func SetStrictFlag(strict bool) {
+	strictFlag.Store(strict)
+}
+
+// Flag returns whether the feature flag with the given name is enabled by the FlagProvider,
+// it reports the unknown flags like the flag function.
+func Flag(name string) (bool, error) {
+	var enabled, exists bool
+	if p := flagProvider.Load(); p != nil {
+		enabled, exists = (*p).Flag(name)
+	}
+	if !exists && strictFlag.Load() {
+		return false, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	return enabled, nil
+}
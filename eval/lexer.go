@@ -15,12 +15,14 @@ limitations under the License.
 */
 
 // Package eval provides a simple lexical analyzer for processing logical expressions.
-// It converts human-readable logical operators (and, or, not) to their Go equivalents (&&, ||, !).
+// It converts human-readable logical operators (and, or, not) to their Go equivalents (&&, ||, !),
+// null to nil for the compatibility with MyBatis, and single-quoted strings like 'mysql' to double-quoted ones.
 package eval
 
 import (
 	"go/scanner"
 	"go/token"
+	"strconv"
 	"strings"
 )
 
@@ -29,6 +31,7 @@ import (
 //   - "and" to "&&"
 //   - "or" to "||"
 //   - "not" to "!"
+//   - "null" to "nil"
 //
 // Any other identifiers are returned unchanged.
 func identReplacer(s string) string {
@@ -39,11 +42,28 @@ func identReplacer(s string) string {
 		return "||"
 	case "not":
 		return "!"
+	case "null":
+		return "nil"
 	default:
 		return s
 	}
 }
 
+// charReplacer converts a single-quoted string which is not a valid rune literal,
+// like 'mysql', to a double-quoted string, since it is common to quote strings with
+// single quotes in xml attributes. Valid rune literals are returned unchanged.
+func charReplacer(s string) string {
+	if len(s) < 2 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return s
+	}
+	inner := s[1 : len(s)-1]
+	if _, _, tail, err := strconv.UnquoteChar(inner, '\''); err == nil && tail == "" {
+		return s
+	}
+	inner = strings.ReplaceAll(inner, `\'`, `'`)
+	return `"` + strings.ReplaceAll(inner, `"`, `\"`) + `"`
+}
+
 // Lexer performs lexical analysis on input strings.
 // It uses Go's standard scanner to tokenize the input and processes
 // specific identifiers for logical operations.
@@ -66,6 +86,8 @@ func (l *Lexer) Tokenize() string {
 		case token.IDENT:
 			replacement := identReplacer(lit)
 			tokens = append(tokens, replacement)
+		case token.CHAR:
+			tokens = append(tokens, charReplacer(lit))
 		default:
 			if lit != "" {
 				tokens = append(tokens, lit)
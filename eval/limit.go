@@ -0,0 +1,106 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eval
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned when the evaluation of an expression exceeds its Limits.
+var ErrLimitExceeded = errors.New("eval: evaluation limit exceeded")
+
+// Limits limits the evaluation of an expression, which protects the build of the statements
+// from the pathological expressions. Zero means no limit.
+type Limits struct {
+	// MaxDepth is the maximum depth of the nested sub expressions being evaluated.
+	MaxDepth int
+
+	// MaxSteps is the maximum number of the sub expressions evaluated by an execution of an expression.
+	MaxSteps int
+}
+
+// DefaultLimits are the generous limits which no reasonable expression reaches.
+var DefaultLimits = Limits{MaxDepth: 128, MaxSteps: 10000}
+
+// Budget counts the evaluation of the expressions against their Limits.
+// It is shared by all the expressions executed with the Parameter carrying it,
+// like the ones of a build of a statement, and it is not safe for concurrent use.
+type Budget struct {
+	limits Limits
+	depth  int
+	steps  int
+}
+
+// NewBudget returns the Budget of the given Limits.
+func NewBudget(limits Limits) *Budget {
+	return &Budget{limits: limits}
+}
+
+// BudgetCarrier is implemented by the Parameters which carry the Budget limiting the expressions executed with them.
+// The Budget is only found on the Parameter given to Execute itself, so the carrier must wrap the others,
+// like the ParamGroup of a foreach item, instead of being wrapped by them.
+type BudgetCarrier interface {
+	Budget() *Budget
+}
+
+// WithLimits returns the Parameter which limits the evaluation of the expressions executed with it.
+func WithLimits(params Parameter, limits Limits) Parameter {
+	return &limitedParameter{Parameter: params, budget: NewBudget(limits)}
+}
+
+// enter is called before a sub expression is evaluated, leave must be called after it if no error is returned.
+func (b *Budget) enter() error {
+	// a new execution of an expression starts.
+	if b.depth == 0 {
+		b.steps = 0
+	}
+	b.depth++
+	b.steps++
+	if b.limits.MaxDepth > 0 && b.depth > b.limits.MaxDepth {
+		b.depth--
+		return fmt.Errorf("%w: depth exceeds %d", ErrLimitExceeded, b.limits.MaxDepth)
+	}
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		b.depth--
+		return fmt.Errorf("%w: steps exceed %d", ErrLimitExceeded, b.limits.MaxSteps)
+	}
+	return nil
+}
+
+func (b *Budget) leave() {
+	b.depth--
+}
+
+// limitedParameter is a Parameter which carries the Budget of the evaluation.
+type limitedParameter struct {
+	Parameter
+	budget *Budget
+}
+
+// Budget implements BudgetCarrier.
+func (l *limitedParameter) Budget() *Budget {
+	return l.budget
+}
+
+// budgetOf returns the Budget carried by the given Parameter, or nil if there is none.
+func budgetOf(params Parameter) *Budget {
+	if carrier, ok := params.(BudgetCarrier); ok {
+		return carrier.Budget()
+	}
+	return nil
+}
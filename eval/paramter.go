@@ -180,6 +180,52 @@ func (p sliceParameter) Get(name string) (reflect.Value, bool) {
 	return value, true
 }
 
+// make sure that anyMapParameter implements Parameter.
+var _ Parameter = (anyMapParameter)(nil)
+
+// anyMapParameter is a parameter that wraps a map[string]any.
+// It looks up the values of the map directly without reflection,
+// and traverses the nested maps of the dotted names in the same way.
+// It only falls back to the GenericParameter when the value of the path is not a map[string]any.
+type anyMapParameter map[string]any
+
+// Get implements Parameter.
+func (p anyMapParameter) Get(name string) (reflect.Value, bool) {
+	current := map[string]any(p)
+	for {
+		item, rest, nested := strings.Cut(name, ".")
+		value, exists := current[item]
+		if !exists {
+			return reflect.Value{}, false
+		}
+		if !nested {
+			// keep the kind of the value as reflect.Interface, which is the same as the reflect.Value.MapIndex returns.
+			return reflect.ValueOf(&value).Elem(), true
+		}
+		next, ok := asAnyMap(value)
+		if !ok {
+			if value == nil {
+				return reflect.Value{}, false
+			}
+			// fall back to the reflection for the values which are not map[string]any.
+			return (&GenericParameter{Value: reflect.ValueOf(value)}).get(rest)
+		}
+		current, name = next, rest
+	}
+}
+
+// asAnyMap returns the map[string]any of the given value without reflection.
+func asAnyMap(v any) (map[string]any, bool) {
+	switch value := v.(type) {
+	case map[string]any:
+		return value, true
+	case H:
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
 // GenericParameter is a parameter that wraps a generic value.
 type GenericParameter struct {
 	// Value is the wrapped value
@@ -288,6 +334,10 @@ func NewGenericParam(v any, wrapKey string) Parameter {
 	if v == nil {
 		return noOPParameter
 	}
+	// the map[string]any is the most common parameter, which is looked up without reflection.
+	if m, ok := asAnyMap(v); ok {
+		return anyMapParameter(m)
+	}
 	value := reflect.ValueOf(v)
 
 	tp := reflectlite.IndirectType(value.Type())
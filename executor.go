@@ -20,8 +20,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"reflect"
 
 	"github.com/go-juicedev/juice/driver"
+	"github.com/go-juicedev/juice/internal/reflectlite"
 )
 
 // ErrInvalidExecutor is a custom error type that is used when an invalid executor is found.
@@ -125,6 +128,18 @@ var _ SQLRowsExecutor = (*sqlRowsExecutor)(nil)
 // GenericExecutor is a generic sqlRowsExecutor.
 type GenericExecutor[T any] struct {
 	SQLRowsExecutor
+
+	// resultMap is the id of the result map chosen by WithResultMap.
+	resultMap string
+
+	// columns receives the metadata of the columns for QueryWithColumns.
+	columns *[]ColumnMeta
+
+	// truncated reports the truncation of the rows for WithTruncatedRows.
+	truncated *bool
+
+	// single makes the interface results bound from a single row for WithSingleResult.
+	single bool
 }
 
 // QueryContext executes the query and returns the scanner.
@@ -135,6 +150,13 @@ func (e *GenericExecutor[T]) QueryContext(ctx context.Context, p Param) (result
 	}
 	statement := e.Statement()
 
+	// validate the result type against the resultType declared by the statement,
+	// which also chooses the destination of the interface results.
+	destType, err := resolveResultType(statement, reflect.TypeFor[T](), e.single)
+	if err != nil {
+		return result, err
+	}
+
 	retMap, err := statement.ResultMap()
 
 	// ErrResultMapNotSet means the result map is not set, use the default result map.
@@ -144,6 +166,31 @@ func (e *GenericExecutor[T]) QueryContext(ctx context.Context, p Param) (result
 		}
 	}
 
+	// the result map chosen at call time by WithResultMap takes precedence.
+	if e.resultMap != "" {
+		if retMap, err = lookupResultMap(e.resultMap, statement); err != nil {
+			return result, err
+		}
+	}
+
+	// mark the query as a single result query, which may be limited by the SingleResultLimitMiddleware.
+	if retMap == nil && isSingleResultType(destType) {
+		ctx = context.WithValue(ctx, singleResultKey{}, true)
+	}
+
+	// use the default result map with the options of the statement if any.
+	if retMap == nil {
+		if retMap, err = statementResultMap(statement, destType); err != nil {
+			return result, err
+		}
+	}
+
+	// limit the slice results by the maxRows of the statement, whichever result map binds them.
+	if reflectlite.IndirectType(destType).Kind() == reflect.Slice {
+		retMap = limitRows(retMap, int(statementOption(statement, maxRowsKey).Int64()),
+			statementOption(statement, maxRowsPolicyKey) == "truncate", e.truncated)
+	}
+
 	// try to query the database.
 	rows, err := e.SQLRowsExecutor.QueryContext(ctx, p)
 	if err != nil {
@@ -151,6 +198,18 @@ func (e *GenericExecutor[T]) QueryContext(ctx context.Context, p Param) (result
 	}
 	defer func() { _ = rows.Close() }()
 
+	if e.columns != nil {
+		if *e.columns, err = Columns(rows); err != nil {
+			return result, err
+		}
+	}
+	if destType != reflect.TypeFor[T]() {
+		dest := reflect.New(destType)
+		if err = bindWithResultMap(rows, dest.Interface(), retMap); err != nil {
+			return result, err
+		}
+		return dest.Elem().Interface().(T), nil
+	}
 	return BindWithResultMap[T](rows, retMap)
 }
 
@@ -165,3 +224,137 @@ func (e *GenericExecutor[_]) ExecContext(ctx context.Context, p Param) (result s
 
 // ensure GenericExecutor implements Executor.
 var _ Executor[any] = (*GenericExecutor[any])(nil)
+
+// singleResultKey is the context key which marks the query only scans the first row of its result.
+type singleResultKey struct{}
+
+// isSingleResultQuery reports whether the query of the context only scans the first row of its result.
+func isSingleResultQuery(ctx context.Context) bool {
+	single, _ := ctx.Value(singleResultKey{}).(bool)
+	return single
+}
+
+// isSingleResultType reports whether the given result type is bound from a single row.
+// The interfaces and the types which implement RowScanner are not, since they may scan any rows.
+func isSingleResultType(resultType reflect.Type) bool {
+	if resultType.Kind() == reflect.Interface {
+		return false
+	}
+	if resultType.Implements(rowScannerType) || reflect.PointerTo(resultType).Implements(rowScannerType) {
+		return false
+	}
+	kind := reflectlite.IndirectType(resultType).Kind()
+	return kind != reflect.Slice && kind != reflect.Array
+}
+
+// The names of the attributes and the settings which configure the default result map,
+// the statement attribute takes precedence over the global setting.
+//
+//	<select id="GetUser" nullAsZero="true" columnCase="insensitive" mapUnderscoreToCamelCase="true">...</select>
+//	<select id="GetUser" namingStrategy="snakeCase">...</select>
+//	<select id="ListUsers" maxRows="10000" maxRowsPolicy="truncate">...</select>
+const (
+	nullAsZeroKey               = "nullAsZero"
+	columnCaseKey               = "columnCase"
+	mapUnderscoreToCamelCaseKey = "mapUnderscoreToCamelCase"
+	maxRowsKey                  = "maxRows"
+	maxRowsPolicyKey            = "maxRowsPolicy"
+)
+
+// statementOption returns the value of the attribute of the statement, or the setting if the attribute is not set.
+func statementOption(statement Statement, key string) StringValue {
+	if value := statement.Attribute(key); value != "" {
+		return StringValue(value)
+	}
+	return statement.Configuration().Settings().Get(key)
+}
+
+// statementResultMap returns the default result map of the given result type with the options
+// of the statement, or nil if the statement uses none of them.
+// The columnCase is either sensitive, which is the default, or insensitive.
+// The namingStrategy is the name of a strategy registered by RegisterNamingStrategy.
+func statementResultMap(statement Statement, resultType reflect.Type) (ResultMap, error) {
+	strategy, err := statementNamingStrategy(statement)
+	if err != nil {
+		return nil, err
+	}
+	mapping := ColumnMapping{
+		NullAsZero:               statementOption(statement, nullAsZeroKey).Bool(),
+		CaseInsensitive:          statementOption(statement, columnCaseKey) == "insensitive",
+		MapUnderscoreToCamelCase: statementOption(statement, mapUnderscoreToCamelCaseKey).Bool(),
+		NamingStrategy:           strategy,
+		TypeHandlers:             typeHandlersOf(statement.Configuration()),
+	}
+	if mapping.isDefault() {
+		return nil, nil
+	}
+	if reflectlite.IndirectType(resultType).Kind() == reflect.Slice {
+		return MultiRowsResultMap{ColumnMapping: mapping}, nil
+	}
+	return SingleRowResultMap{ColumnMapping: mapping}, nil
+}
+
+// WithTruncatedRows returns a copy of the executor which sets the truncated to true when the rows
+// of its slice result are truncated by the maxRows of the statement, or by the MaxRows of the
+// MultiRowsResultMap, so the caller can tell a truncated result from a complete one.
+// Like WithResultMap, the flag belongs to the returned executor only.
+// The executor must be a GenericExecutor, the others are invalid.
+//
+//	var truncated bool
+//	users, err := juice.WithTruncatedRows(executor, &truncated).QueryContext(ctx, nil)
+func WithTruncatedRows[T any](executor Executor[T], truncated *bool) Executor[T] {
+	exe, ok := executor.(*GenericExecutor[T])
+	if !ok {
+		return &GenericExecutor[T]{SQLRowsExecutor: inValidExecutor(fmt.Errorf("WithTruncatedRows requires a GenericExecutor, got %T", executor))}
+	}
+	reporting := *exe
+	reporting.truncated = truncated
+	return &reporting
+}
+
+// WithSingleResult returns a copy of the executor which binds its interface result, like any, from a single row
+// into the resultType declared by the statement, instead of a slice of it, which is the default.
+// It makes no difference to the other results, whose types already tell a single row from many.
+// The executor must be a GenericExecutor, the others are invalid.
+//
+//	user, err := juice.WithSingleResult(juice.NewGenericManager[any](engine).Object("main.GetUserByID")).QueryContext(ctx, param)
+func WithSingleResult[T any](executor Executor[T]) Executor[T] {
+	exe, ok := executor.(*GenericExecutor[T])
+	if !ok {
+		return &GenericExecutor[T]{SQLRowsExecutor: inValidExecutor(fmt.Errorf("WithSingleResult requires a GenericExecutor, got %T", executor))}
+	}
+	single := *exe
+	single.single = true
+	return &single
+}
+
+// limitRows returns the result map which limits the rows of a slice result to the maxRows of the statement,
+// the rows beyond it are dropped if the maxRowsPolicy is truncate, or ErrMaxRowsExceeded is returned by default.
+// The MultiRowsResultMap stops at the maxRows while the others are limited after the mapping,
+// and the MaxRows of the MultiRowsResultMap takes precedence.
+func limitRows(resultMap ResultMap, maxRows int, truncate bool, truncated *bool) ResultMap {
+	switch m := resultMap.(type) {
+	case MultiRowsResultMap:
+		if m.MaxRows <= 0 {
+			m.MaxRows, m.TruncateRows = maxRows, truncate
+		}
+		if m.Truncated == nil {
+			m.Truncated = truncated
+		}
+		return m
+	case *MultiRowsResultMap:
+		return limitRows(*m, maxRows, truncate, truncated)
+	case ColumnsResultMap:
+		if maxRows > 0 || m.ResultMap != nil {
+			m.ResultMap = limitRows(m.ResultMap, maxRows, truncate, truncated)
+		}
+		return m
+	}
+	if maxRows <= 0 {
+		return resultMap
+	}
+	if resultMap == nil {
+		return MultiRowsResultMap{MaxRows: maxRows, TruncateRows: truncate, Truncated: truncated}
+	}
+	return maxRowsResultMap{ResultMap: resultMap, MaxRows: maxRows, TruncateRows: truncate, Truncated: truncated}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// resolveExtends merges the blocks of the statements which extend other statements
+// into the nodes of their base statements, so that they are built as the normal statements.
+// The base statement must be declared in the same mapper without databaseId.
+func (m *Mapper) resolveExtends() error {
+	var statements []*xmlSQLStatement
+	for _, id := range slices.Sorted(maps.Keys(m.statements)) {
+		statements = append(statements, m.statements[id])
+	}
+	for _, id := range slices.Sorted(maps.Keys(m.databaseIDStatements)) {
+		variants := m.databaseIDStatements[id]
+		for _, databaseID := range slices.Sorted(maps.Keys(variants)) {
+			statements = append(statements, variants[databaseID])
+		}
+	}
+	resolved := make(map[*xmlSQLStatement]bool)
+	resolving := make(map[*xmlSQLStatement]bool)
+	var resolve func(stmt *xmlSQLStatement) error
+	resolve = func(stmt *xmlSQLStatement) error {
+		baseID := stmt.Attribute("extends")
+		if baseID == "" || resolved[stmt] {
+			return nil
+		}
+		if resolving[stmt] {
+			return fmt.Errorf("statement %s: circular extends of %s", stmt.id, baseID)
+		}
+		resolving[stmt] = true
+		base, exists := m.statements[baseID]
+		if !exists {
+			return fmt.Errorf("statement %s extends unknown statement %s", stmt.id, baseID)
+		}
+		if err := resolve(base); err != nil {
+			return err
+		}
+		if err := stmt.extend(base); err != nil {
+			return fmt.Errorf("statement %s: %w", stmt.id, err)
+		}
+		resolved[stmt] = true
+		return nil
+	}
+	for _, stmt := range statements {
+		if err := resolve(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extend replaces the nodes of the statement with a copy of the nodes of the base statement,
+// whose blocks are overridden by the blocks of the statement.
+// The statement inherits the attributes and the defaults it does not declare from the base.
+func (s *xmlSQLStatement) extend(base *xmlSQLStatement) error {
+	if s.action != base.action {
+		return fmt.Errorf("%s statement can not extend %s statement %s", s.action, base.action, base.id)
+	}
+	blocks := make(map[string]*BlockNode)
+	for _, node := range s.Nodes {
+		block, ok := node.(*BlockNode)
+		if !ok {
+			return fmt.Errorf("only block elements are allowed in a statement which extends %s", base.id)
+		}
+		if _, exists := blocks[block.Name]; exists {
+			return fmt.Errorf("duplicate block %s", block.Name)
+		}
+		blocks[block.Name] = block
+	}
+	nodes := base.Nodes.Clone()
+	overridden := make(map[string]bool, len(blocks))
+	overrideBlocks(nodes, blocks, overridden)
+	for _, name := range slices.Sorted(maps.Keys(blocks)) {
+		if !overridden[name] {
+			return fmt.Errorf("unknown block %s of statement %s", name, base.id)
+		}
+	}
+	s.Nodes = nodes
+
+	for key, value := range base.attrs {
+		switch key {
+		case "id", "databaseId", "extends":
+			continue
+		}
+		if _, exists := s.attrs[key]; !exists {
+			s.setAttribute(key, value)
+		}
+	}
+	if len(base.defaults) > 0 {
+		defaults := maps.Clone(base.defaults)
+		maps.Copy(defaults, s.defaults)
+		s.defaults = defaults
+	}
+	return nil
+}
+
+// overrideBlocks overrides the blocks found in the nodes and their children by the blocks
+// of the same names, and records the names of the overridden blocks.
+// The nodes must be owned by the caller, since they are modified in place.
+func overrideBlocks(nodes []Node, blocks map[string]*BlockNode, overridden map[string]bool) {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *BlockNode:
+			// the nested blocks can be overridden too, unless the block is replaced.
+			overrideBlocks(n.Nodes, blocks, overridden)
+			block, exists := blocks[n.Name]
+			if !exists {
+				continue
+			}
+			overridden[n.Name] = true
+			switch block.Mode {
+			case BlockModeAppend:
+				n.Nodes = slices.Concat(n.Nodes, block.Nodes)
+			case BlockModePrepend:
+				n.Nodes = slices.Concat(block.Nodes, n.Nodes)
+			default:
+				n.Nodes = block.Nodes
+			}
+		case NodeGroup:
+			overrideBlocks(n, blocks, overridden)
+		case *ConditionNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *WhereNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *TrimNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *SetNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *OtherwiseNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *BindNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *ForeachNode:
+			overrideBlocks(n.Nodes, blocks, overridden)
+		case *ChooseNode:
+			overrideBlocks(n.WhenNodes, blocks, overridden)
+			if n.OtherwiseNode != nil {
+				overrideBlocks([]Node{n.OtherwiseNode}, blocks, overridden)
+			}
+		}
+	}
+}
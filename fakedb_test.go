@@ -0,0 +1,323 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+// fakeDriverName is the name which the fake database driver is registered with,
+// both in database/sql and in the juice driver registry.
+const fakeDriverName = "juice-fake"
+
+func init() {
+	sql.Register(fakeDriverName, fakeSQLDriver{})
+	driver.Register(fakeDriverName, driver.MySQLDriver{})
+}
+
+// fakeDBs holds the fake databases by their data source name.
+var fakeDBs sync.Map
+
+var fakeDBSeq atomic.Int64
+
+// fakeCall records a query or exec call received by the fake database.
+type fakeCall struct {
+	query string
+	args  []any
+}
+
+// fakeDB is an in-memory database used by tests to observe what juice sends
+// to the database/sql layer.
+type fakeDB struct {
+	dsn string
+
+	// query returns the columns and rows for the given query.
+	query func(query string, args []any) ([]string, [][]sqldriver.Value, error)
+
+	// exec returns the result for the given statement.
+	exec func(query string, args []any) (sqldriver.Result, error)
+
+	// prepare returns the error for preparing the given query.
+	prepare func(query string) error
+
+	// onNext is called before the row with the given index is returned.
+	onNext func(i int)
+
+	// columnType returns the database type name and the nullability of the given column.
+	columnType func(column string) (typeName string, nullable bool)
+
+	mu        sync.Mutex
+	calls     []fakeCall
+	txOptions []sqldriver.TxOptions
+	commits   int
+	rollbacks int
+}
+
+// newFakeDB creates a fake database which is removed when the test finishes.
+func newFakeDB(t testing.TB) *fakeDB {
+	t.Helper()
+	db := &fakeDB{dsn: fmt.Sprintf("fake-%d", fakeDBSeq.Add(1))}
+	fakeDBs.Store(db.dsn, db)
+	t.Cleanup(func() { fakeDBs.Delete(db.dsn) })
+	return db
+}
+
+// Calls returns the recorded query and exec calls.
+func (d *fakeDB) Calls() []fakeCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]fakeCall(nil), d.calls...)
+}
+
+func (d *fakeDB) record(query string, args []sqldriver.NamedValue) []any {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			values[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			values[i] = arg.Value
+		}
+	}
+	d.mu.Lock()
+	d.calls = append(d.calls, fakeCall{query: query, args: values})
+	d.mu.Unlock()
+	return values
+}
+
+// Open opens a *sql.DB connected to the fake database.
+func (d *fakeDB) Open(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(fakeDriverName, d.dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// Engine creates an engine whose default environment is the fake database and
+// whose only mapper is built from the given statements.
+func (d *fakeDB) Engine(t testing.TB, namespace, statements string) *Engine {
+	t.Helper()
+	engine, err := New(d.Configuration(t, namespace, statements))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+	return engine
+}
+
+// Configuration creates a configuration whose default environment is the fake
+// database and whose only mapper is built from the given statements.
+func (d *fakeDB) Configuration(t testing.TB, namespace, statements string) IConfiguration {
+	t.Helper()
+	cfg, err := NewXMLConfigurationWithFS(newTestMapperFS(d.dsn, namespace, statements), "juice.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newTestMapperFS returns a file system which contains a configuration file
+// named juice.xml and a mapper file with the given statements.
+func newTestMapperFS(dsn, namespace, statements string) fstest.MapFS {
+	config := `<?xml version="1.0" encoding="UTF-8"?>
+<configuration>
+    <environments default="test">
+        <environment id="test">
+            <dataSource>` + dsn + `</dataSource>
+            <driver>` + fakeDriverName + `</driver>
+        </environment>
+    </environments>
+    <mappers>
+        <mapper resource="mapper.xml"/>
+    </mappers>
+</configuration>`
+	mapper := `<?xml version="1.0" encoding="UTF-8"?>
+<mapper namespace="` + namespace + `">
+` + statements + `
+</mapper>`
+	return fstest.MapFS{
+		"juice.xml":  &fstest.MapFile{Data: []byte(config)},
+		"mapper.xml": &fstest.MapFile{Data: []byte(mapper)},
+	}
+}
+
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(dsn string) (sqldriver.Conn, error) {
+	db, ok := fakeDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("fake database %q not found", dsn)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (sqldriver.Stmt, error) {
+	if c.db.prepare != nil {
+		if err := c.db.prepare(query); err != nil {
+			return nil, err
+		}
+	}
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (sqldriver.Tx, error) {
+	return c.BeginTx(context.Background(), sqldriver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(_ context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	c.db.mu.Lock()
+	c.db.txOptions = append(c.db.txOptions, opts)
+	c.db.mu.Unlock()
+	return &fakeTx{db: c.db}, nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	values := c.db.record(query, args)
+	if c.db.query == nil {
+		return &fakeRows{db: c.db}, nil
+	}
+	columns, rows, err := c.db.query(query, values)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{db: c.db, columns: columns, rows: rows}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	values := c.db.record(query, args)
+	if c.db.exec == nil {
+		return sqldriver.RowsAffected(1), nil
+	}
+	return c.db.exec(query, values)
+}
+
+// CheckNamedValue accepts every argument as is, so that tests can observe the
+// values juice passes to the driver.
+func (c *fakeConn) CheckNamedValue(*sqldriver.NamedValue) error { return nil }
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	return s.ExecContext(context.Background(), toNamedValues(args))
+}
+
+func (s *fakeStmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return s.QueryContext(context.Background(), toNamedValues(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func toNamedValues(args []sqldriver.Value) []sqldriver.NamedValue {
+	named := make([]sqldriver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = sqldriver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (t *fakeTx) Commit() error {
+	t.db.mu.Lock()
+	t.db.commits++
+	t.db.mu.Unlock()
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.db.mu.Lock()
+	t.db.rollbacks++
+	t.db.mu.Unlock()
+	return nil
+}
+
+type fakeRows struct {
+	db      *fakeDB
+	columns []string
+	rows    [][]sqldriver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string {
+	if r.db.columnType == nil {
+		return ""
+	}
+	typeName, _ := r.db.columnType(r.columns[index])
+	return typeName
+}
+
+func (r *fakeRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if r.db.columnType == nil {
+		return false, false
+	}
+	_, nullable = r.db.columnType(r.columns[index])
+	return nullable, true
+}
+
+func (r *fakeRows) Next(dest []sqldriver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	if r.db.onNext != nil {
+		r.db.onNext(r.pos)
+	}
+	row := r.rows[r.pos]
+	if len(row) != len(dest) {
+		return errors.New("fake rows: column count mismatch")
+	}
+	copy(dest, row)
+	r.pos++
+	return nil
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctxreducer
+
+import (
+	"context"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+// driverWithContextReducer is a ContextReducer that adds a Driver to the context.
+type driverWithContextReducer struct {
+	driver driver.Driver
+}
+
+// The Reduce method uses an external function WithContext to add the Driver to the context.
+func (r driverWithContextReducer) Reduce(ctx context.Context) context.Context {
+	return driver.WithContext(ctx, r.driver)
+}
+
+// NewDriverContextReducer returns a new instance of the driverWithContextReducer.
+func NewDriverContextReducer(driver driver.Driver) ContextReducer {
+	return driverWithContextReducer{driver: driver}
+}
@@ -39,6 +39,10 @@ type Engine struct {
 	// current using of environment id
 	using string
 
+	// databaseID is the databaseId of the current using environment,
+	// which is used to choose the statement variants.
+	databaseID string
+
 	manager *DBManager
 
 	// rw is the read write lock
@@ -56,16 +60,74 @@ type Engine struct {
 
 // sqlRowsExecutor represents a mapper sqlRowsExecutor with the given parameters
 func (e *Engine) executor(v any) (SQLRowsExecutor, error) {
-	statement, err := e.GetConfiguration().GetStatement(v)
+	statement, err := e.getStatement(v)
 	if err != nil {
 		return nil, err
 	}
-	statementHandler := NewBatchStatementHandler(e.Driver(), e.DB(), e.middlewares...)
+	statementHandler := e.trackStatementHandler(NewBatchStatementHandler(e.Driver(), e.DB(), e.middlewares...))
 	return NewSQLRowsExecutor(statement, statementHandler, e.Driver()), nil
 }
 
+// databaseIDStatementGetter is implemented by the configurations which support
+// choosing statements by the databaseId of the environment.
+type databaseIDStatementGetter interface {
+	DatabaseID(env *Environment) string
+	GetStatementByDatabaseID(v any, databaseID string) (Statement, error)
+}
+
+// getStatement returns the statement of the given value,
+// choosing the variant which matches the databaseId of the current environment.
+// The databaseId is exposed to the nodes of the statement as the _databaseId variable.
+func (e *Engine) getStatement(v any) (Statement, error) {
+	return statementOfDatabaseID(e.GetConfiguration(), v, e.databaseID)
+}
+
+// statementOfDatabaseID returns the statement of the given value from the configuration,
+// preferring the variant which declares the given databaseId.
+func statementOfDatabaseID(cfg IConfiguration, v any, databaseID string) (Statement, error) {
+	getter, ok := cfg.(databaseIDStatementGetter)
+	if !ok {
+		return cfg.GetStatement(v)
+	}
+	statement, err := getter.GetStatementByDatabaseID(v, databaseID)
+	if err != nil {
+		return nil, err
+	}
+	if stmt, ok := statement.(*xmlSQLStatement); ok && databaseID != "" {
+		statement = &databaseIDStatement{xmlSQLStatement: stmt, databaseID: databaseID}
+	}
+	return statement, nil
+}
+
+// resolveDatabaseID resolves the databaseId of the current using environment.
+func (e *Engine) resolveDatabaseID() {
+	e.databaseID = databaseIDOf(e.configuration, e.using)
+}
+
+// databaseIDOf returns the databaseId of the environment with the given id,
+// which is empty if the configuration does not support databaseId.
+func databaseIDOf(cfg IConfiguration, envID string) string {
+	getter, ok := cfg.(databaseIDStatementGetter)
+	if !ok {
+		return ""
+	}
+	env, err := cfg.Environments().Use(envID)
+	if err != nil {
+		return ""
+	}
+	return getter.DatabaseID(env)
+}
+
+// DatabaseID returns the databaseId of the currently active database environment.
+func (e *Engine) DatabaseID() string {
+	return e.databaseID
+}
+
 // Object implements the Manager interface
 func (e *Engine) Object(v any) SQLRowsExecutor {
+	if e.manager.draining() {
+		return inValidExecutor(ErrDBManagerClosed)
+	}
 	exe, err := e.executor(v)
 	if err != nil {
 		return inValidExecutor(err)
@@ -106,6 +168,14 @@ func (e *Engine) Use(middleware Middleware) {
 	e.middlewares = append(e.middlewares, middleware)
 }
 
+// UseQueryRewriter adds a QueryRewriter to the engine, which rewrites the rendered
+// query of every statement before it is executed.
+//
+//	engine.UseQueryRewriter(juice.StatementIDCommentRewriter)
+func (e *Engine) UseQueryRewriter(rewriter QueryRewriter) {
+	e.Use(&QueryRewriteMiddleware{Rewriter: rewriter})
+}
+
 func (e *Engine) clone() *Engine {
 	return &Engine{
 		configuration: e.configuration,
@@ -130,6 +200,7 @@ func (e *Engine) With(name string) (*Engine, error) {
 	engine := e.clone()
 	engine.db, engine.driver = db, drv
 	engine.using = name
+	engine.resolveDatabaseID()
 	return engine, nil
 }
 
@@ -151,7 +222,17 @@ func (e *Engine) Driver() driver.Driver {
 // Close gracefully shuts down all managed database connections
 // all cloned engines share the same DBManager
 func (e *Engine) Close() error {
-	return e.manager.Close()
+	return e.manager.closeConns()
+}
+
+// CloseContext stops accepting new executions, waits for the in-flight ones to
+// finish until the context is done, and then closes all managed database connections,
+// see DBManager.Close for the details.
+// Statements of transactions which have already begun are still executed while
+// closing, so that they can be committed or rolled back.
+// All cloned engines share the same DBManager.
+func (e *Engine) CloseContext(ctx context.Context) error {
+	return e.manager.Close(ctx)
 }
 
 // SetLocker sets the locker of the engine
@@ -171,10 +252,17 @@ func (e *Engine) init() (err error) {
 	}
 	e.using = e.configuration.Environments().Attribute("default")
 	e.db, e.driver, err = e.manager.Get(e.using)
-	return err
+	if err != nil {
+		return err
+	}
+	e.resolveDatabaseID()
+	return nil
 }
 
 func (e *Engine) Raw(query string) Runner {
+	if e.manager.draining() {
+		return NewErrorRunner(ErrDBManagerClosed)
+	}
 	return NewRunner(query, e, e.DB())
 }
 
@@ -189,6 +277,9 @@ func New(configuration IConfiguration) (*Engine, error) {
 	}
 	// add the default middlewares
 	engine.Use(&useGeneratedKeysMiddleware{})
+	engine.Use(&lastSQLMiddleware{})
+	engine.Use(&affectedRowsMiddleware{})
+	engine.Use(&sqlValidationMiddleware{})
 	return engine, nil
 }
 
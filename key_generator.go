@@ -0,0 +1,245 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"crypto/rand"
+	sqldriver "database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-juicedev/juice/internal/reflectlite"
+)
+
+// ErrKeyGeneratorNotFound is an error that is returned when the keyGenerator of a statement is not registered.
+var ErrKeyGeneratorNotFound = errors.New("key generator not found")
+
+// errKeyGeneratorParamRequired is an error that the param is not a struct pointer or a slice array type.
+var errKeyGeneratorParamRequired = errors.New(
+	"keyGenerator is set, but the param is not a struct pointer or a slice array type",
+)
+
+// KeyGenerator generates the primary keys on the application side.
+// The keys are generated before the insert statement is built, and are set to
+// the keyProperty of the param, so the caller knows the keys after the insert.
+//
+//	<insert id="CreateUser" keyGenerator="uuid" keyProperty="ID">
+//	    INSERT INTO user (id, name) VALUES (#{ID}, #{Name})
+//	</insert>
+type KeyGenerator interface {
+	// GenerateKey returns a new key.
+	GenerateKey() (any, error)
+}
+
+// KeyGeneratorFunc is a function type of KeyGenerator.
+type KeyGeneratorFunc func() (any, error)
+
+// GenerateKey implements KeyGenerator.
+func (f KeyGeneratorFunc) GenerateKey() (any, error) {
+	return f()
+}
+
+// UUID is a universally unique identifier defined in RFC 9562.
+// It can be set to a string field or to a field whose underlying type is [16]byte.
+type UUID [16]byte
+
+// String returns the canonical form of the UUID, like "f47ac10b-58cc-4372-a567-0e02b2c3d479".
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// Value implements driver.Valuer, the UUID is stored in its canonical form.
+func (u UUID) Value() (sqldriver.Value, error) {
+	return u.String(), nil
+}
+
+// Scan implements sql.Scanner, it accepts the canonical or the hex form of the UUID,
+// and the raw 16 bytes.
+func (u *UUID) Scan(src any) error {
+	switch src := src.(type) {
+	case string:
+		return u.parse(src)
+	case []byte:
+		if len(src) == len(u) {
+			copy(u[:], src)
+			return nil
+		}
+		return u.parse(string(src))
+	default:
+		return fmt.Errorf("juice: can not scan %T into UUID", src)
+	}
+}
+
+// parse parses the canonical or the hex form of the UUID.
+func (u *UUID) parse(s string) error {
+	if len(s) == 36 && s[8] == '-' && s[13] == '-' && s[18] == '-' && s[23] == '-' {
+		s = s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(s) != 32 {
+		return fmt.Errorf("juice: invalid UUID %q", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return fmt.Errorf("juice: invalid UUID %q: %w", s, err)
+	}
+	return nil
+}
+
+// UUIDKeyGenerator generates random UUIDs of version 4.
+type UUIDKeyGenerator struct{}
+
+// GenerateKey implements KeyGenerator.
+func (UUIDKeyGenerator) GenerateKey() (any, error) {
+	var uuid UUID
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return nil, err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40 // version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // variant 10
+	return uuid, nil
+}
+
+// keyGeneratorLibraries is a map of key generators.
+var keyGeneratorLibraries = map[string]KeyGenerator{}
+
+// RegisterKeyGenerator registers a key generator,
+// which can be used by the keyGenerator attribute of the insert statements.
+// It allows to override the builtin generators, like ULID or snowflake generators can be registered.
+func RegisterKeyGenerator(name string, generator KeyGenerator) {
+	if len(name) == 0 {
+		panic("name is empty")
+	}
+	if generator == nil {
+		panic("juice: key generator is nil")
+	}
+	keyGeneratorLibraries[name] = generator
+}
+
+// GetKeyGenerator returns the key generator registered by the given name.
+func GetKeyGenerator(name string) (KeyGenerator, error) {
+	generator, exists := keyGeneratorLibraries[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrKeyGeneratorNotFound, name)
+	}
+	return generator, nil
+}
+
+func init() {
+	// Register the default key generators.
+	RegisterKeyGenerator("uuid", UUIDKeyGenerator{})
+}
+
+// generateKeys generates the keys by the keyGenerator of the statement,
+// and sets them to the keyProperty of the param whose key is not set yet.
+// It does nothing if the keyGenerator of the statement is not set.
+func generateKeys(statement Statement, param Param) error {
+	name := statement.Attribute("keyGenerator")
+	if len(name) == 0 {
+		return nil
+	}
+	generator, err := GetKeyGenerator(name)
+	if err != nil {
+		return err
+	}
+	keyProperty := statement.Attribute("keyProperty")
+	if len(keyProperty) == 0 {
+		return &nodeAttributeRequiredError{nodeName: statement.Action().String(), attrName: "keyProperty"}
+	}
+
+	rv := reflect.ValueOf(param)
+
+	// the param wrapped in a map is supported like the useGeneratedKeys does.
+	if rv.Kind() == reflect.Map {
+		if rv.Len() != 1 {
+			return fmt.Errorf("keyGenerator is set, map must contain exactly one key-value pair, got %d", rv.Len())
+		}
+		rv = rv.MapIndex(rv.MapKeys()[0])
+	}
+	rv = reflectlite.Unpack(rv)
+
+	keyProperties := strings.Split(keyProperty, ".")
+
+	switch {
+	case rv.Kind() == reflect.Ptr && reflectlite.Unwrap(rv).Kind() == reflect.Struct:
+		return generateKeyTo(reflectlite.Unwrap(rv), generator, keyProperties)
+	case reflectlite.Unwrap(rv).Kind() == reflect.Slice, reflectlite.Unwrap(rv).Kind() == reflect.Array:
+		rv = reflectlite.Unwrap(rv)
+		for i := 0; i < rv.Len(); i++ {
+			elem := reflectlite.Unwrap(rv.Index(i))
+			if elem.Kind() != reflect.Struct {
+				return errors.New("the element of the slice or array is not a struct")
+			}
+			if err = generateKeyTo(elem, generator, keyProperties); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errKeyGeneratorParamRequired
+	}
+}
+
+// generateKeyTo sets a generated key to the field of the given struct value found by the key properties.
+// The field which already has a key is left as is.
+func generateKeyTo(v reflect.Value, generator KeyGenerator, keyProperties []string) error {
+	indexes, ok := findFieldIndexesFromProperties(v.Type(), keyProperties...)
+	if !ok {
+		return fmt.Errorf("invalid keyProperty %s", strings.Join(keyProperties, "."))
+	}
+	field := v.FieldByIndex(indexes)
+	if !field.CanSet() {
+		return fmt.Errorf("the keyProperty %s can not be set", strings.Join(keyProperties, "."))
+	}
+	if !field.IsZero() {
+		return nil
+	}
+	key, err := generator.GenerateKey()
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(key)
+	if !value.IsValid() {
+		return errors.New("the generated key is nil")
+	}
+	switch {
+	case value.Type().AssignableTo(field.Type()):
+		field.Set(value)
+	// converting an integer to a string yields a rune, which is not what we want.
+	case value.Type().ConvertibleTo(field.Type()) && !(field.Kind() == reflect.String && (value.CanInt() || value.CanUint())):
+		field.Set(value.Convert(field.Type()))
+	default:
+		stringer, isStringer := key.(fmt.Stringer)
+		if !isStringer || field.Kind() != reflect.String {
+			return fmt.Errorf("can not set key of type %s to the keyProperty %s of type %s",
+				value.Type(), strings.Join(keyProperties, "."), field.Type())
+		}
+		field.SetString(stringer.String())
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestUUIDKeyGenerator(t *testing.T) {
+	key, err := UUIDKeyGenerator{}.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid, ok := key.(UUID)
+	if !ok {
+		t.Fatalf("expected UUID, got %T", key)
+	}
+	if !uuidRegexp.MatchString(uuid.String()) {
+		t.Errorf("invalid uuid: %s", uuid)
+	}
+}
+
+func TestEngine_KeyGenerator(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<insert id="create" keyGenerator="uuid" keyProperty="ID">
+    insert into users (id, name) values (#{ID}, #{Name})
+</insert>
+<insert id="createAll" keyGenerator="uuid" keyProperty="id">
+    insert into users (id, name) values
+    <foreach collection="users" item="user" separator=",">(#{user.ID}, #{user.Name})</foreach>
+</insert>
+<insert id="missing" keyGenerator="ulid" keyProperty="ID">
+    insert into users (id, name) values (#{ID}, #{Name})
+</insert>`)
+
+	type User struct {
+		ID   string `column:"id"`
+		Name string
+	}
+
+	user := &User{Name: "eatmoreapple"}
+	if _, err := engine.Object("main.create").ExecContext(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	if !uuidRegexp.MatchString(user.ID) {
+		t.Errorf("invalid uuid: %s", user.ID)
+		return
+	}
+	calls := db.Calls()
+	if len(calls) != 1 || calls[0].args[0] != user.ID {
+		t.Errorf("expected the generated key to be bound, got %v", calls)
+		return
+	}
+
+	// the keys already set are kept.
+	users := []User{{Name: "a"}, {ID: "fixed", Name: "b"}}
+	if _, err := engine.Object("main.createAll").ExecContext(context.Background(), H{"users": users}); err != nil {
+		t.Fatal(err)
+	}
+	if !uuidRegexp.MatchString(users[0].ID) || users[1].ID != "fixed" {
+		t.Errorf("unexpected keys: %s, %s", users[0].ID, users[1].ID)
+		return
+	}
+
+	_, err := engine.Object("main.missing").ExecContext(context.Background(), &User{})
+	if !errors.Is(err, ErrKeyGeneratorNotFound) {
+		t.Errorf("expected ErrKeyGeneratorNotFound, got %v", err)
+	}
+}
+
+func TestEngine_KeyGeneratorUUIDField(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<insert id="create" keyGenerator="uuid" keyProperty="ID">
+    insert into users (id, name) values (#{ID}, #{Name})
+</insert>`)
+
+	type User struct {
+		ID   UUID
+		Name string
+	}
+
+	user := &User{Name: "a"}
+	if _, err := engine.Object("main.create").ExecContext(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	// the keys are generated on the prepared statements as well.
+	stmt, err := engine.Prepare(context.Background(), "main.create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stmt.Close() }()
+	prepared := &User{Name: "b"}
+	if _, err = stmt.ExecContext(context.Background(), prepared); err != nil {
+		t.Fatal(err)
+	}
+	if prepared.ID == (UUID{}) || prepared.ID == user.ID {
+		t.Errorf("expected a new key for the prepared statement, got %s", prepared.ID)
+		return
+	}
+
+	calls := db.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %v", calls)
+	}
+	for i, expected := range []UUID{user.ID, prepared.ID} {
+		value, err := sqldriver.DefaultParameterConverter.ConvertValue(calls[i].args[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected.String() {
+			t.Errorf("expected the uuid %s to be bound as its canonical form, got %v", expected, value)
+			return
+		}
+	}
+}
+
+func TestUUID_Scan(t *testing.T) {
+	key, err := UUIDKeyGenerator{}.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := key.(UUID)
+	value, err := expected.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []any{value, []byte(value.(string)), expected[:]} {
+		var uuid UUID
+		if err = uuid.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if uuid != expected {
+			t.Errorf("expected %s, got %s", expected, uuid)
+		}
+	}
+	var uuid UUID
+	for _, src := range []any{"not-a-uuid", 1} {
+		if err = uuid.Scan(src); err == nil {
+			t.Errorf("expected error for %v", src)
+		}
+	}
+}
+
+func TestRegisterKeyGenerator(t *testing.T) {
+	var seq int64
+	RegisterKeyGenerator("test-seq", KeyGeneratorFunc(func() (any, error) {
+		seq++
+		return seq, nil
+	}))
+	t.Cleanup(func() { delete(keyGeneratorLibraries, "test-seq") })
+
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<insert id="create" keyGenerator="test-seq" keyProperty="ID">
+    insert into users (id) values (#{ID})
+</insert>
+<insert id="createString" keyGenerator="test-seq" keyProperty="ID">
+    insert into users (id) values (#{ID})
+</insert>`)
+
+	type User struct {
+		ID int32
+	}
+	user := &User{}
+	if _, err := engine.Object("main.create").ExecContext(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 {
+		t.Errorf("expected key 1, got %d", user.ID)
+		return
+	}
+
+	// an integer key is not converted to a string field.
+	type Item struct {
+		ID string
+	}
+	if _, err := engine.Object("main.createString").ExecContext(context.Background(), &Item{}); err == nil {
+		t.Error("expected error for mismatched key type")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+)
+
+// captureLastSQLKey is the name of the setting which enables capturing the last executed sql.
+//
+//	<setting name="captureLastSQL" value="true"/>
+const captureLastSQLKey = "captureLastSQL"
+
+// lastSQLKey is the context key of the lastSQLRecorder.
+type lastSQLKey struct{}
+
+// lastSQLRecorder holds the most recent sql executed with its context.
+type lastSQLRecorder struct {
+	mu    sync.Mutex
+	query string
+	args  []any
+}
+
+// record replaces the recorded sql with the given one.
+func (r *lastSQLRecorder) record(query string, args []any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.query, r.args = query, slices.Clone(args)
+}
+
+// WithLastSQL returns a context which captures the last sql executed with it, which can be retrieved by LastSQL.
+// Only the most recent execution is kept, which bounds the memory.
+// The capturing is opt-in, it works only when the captureLastSQL setting is true.
+func WithLastSQL(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(lastSQLKey{}).(*lastSQLRecorder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, lastSQLKey{}, &lastSQLRecorder{})
+}
+
+// LastSQL returns the query and the arguments of the last sql executed with the context returned by WithLastSQL,
+// which helps to find out the failing query in an error handler.
+// The ok is false if the context does not capture any sql or nothing is executed yet.
+func LastSQL(ctx context.Context) (query string, args []any, ok bool) {
+	recorder, exists := ctx.Value(lastSQLKey{}).(*lastSQLRecorder)
+	if !exists {
+		return "", nil, false
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.query, recorder.args, recorder.query != ""
+}
+
+// ensure lastSQLMiddleware implements Middleware.
+var _ Middleware = (*lastSQLMiddleware)(nil) // compile time check
+
+// lastSQLMiddleware is a middleware that records the executed sql to the context returned by WithLastSQL.
+// It is added by default and does nothing unless the captureLastSQL setting is true.
+type lastSQLMiddleware struct{}
+
+// QueryContext implements Middleware.
+func (m *lastSQLMiddleware) QueryContext(stmt Statement, next QueryHandler) QueryHandler {
+	if !m.enabled(stmt) {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		m.record(ctx, query, args)
+		return next(ctx, query, args...)
+	}
+}
+
+// ExecContext implements Middleware.
+func (m *lastSQLMiddleware) ExecContext(stmt Statement, next ExecHandler) ExecHandler {
+	if !m.enabled(stmt) {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+		m.record(ctx, query, args)
+		return next(ctx, query, args...)
+	}
+}
+
+// enabled reports whether the captureLastSQL setting is true.
+func (m *lastSQLMiddleware) enabled(stmt Statement) bool {
+	return stmt.Configuration().Settings().Get(captureLastSQLKey).Bool()
+}
+
+// record records the sql to the context if it captures the sql.
+func (m *lastSQLMiddleware) record(ctx context.Context, query string, args []any) {
+	if recorder, ok := ctx.Value(lastSQLKey{}).(*lastSQLRecorder); ok {
+		recorder.record(query, args)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLastSQL(t *testing.T) {
+	db := newFakeDB(t)
+	cfg := db.Configuration(t, "main", `<update id="update">update users set name = #{name} where id = #{id}</update>`)
+	cfg.(*Configuration).settings = keyValueSettingProvider{captureLastSQLKey: "true"}
+	engine, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+
+	if _, _, ok := LastSQL(context.Background()); ok {
+		t.Error("expected nothing captured without WithLastSQL")
+		return
+	}
+	ctx := WithLastSQL(context.Background())
+	if _, _, ok := LastSQL(ctx); ok {
+		t.Error("expected nothing captured before executing")
+		return
+	}
+	if _, err = engine.Object("main.update").ExecContext(ctx, H{"name": "a", "id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.Object("main.update").ExecContext(ctx, H{"name": "b", "id": 2}); err != nil {
+		t.Fatal(err)
+	}
+	query, args, ok := LastSQL(ctx)
+	if !ok || query != "update users set name = ? where id = ?" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	// only the most recent execution is kept.
+	if len(args) != 2 || args[0] != "b" || args[1] != 2 {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// the capturing is disabled without the setting.
+	cfg.(*Configuration).settings = nil
+	ctx = WithLastSQL(context.Background())
+	if _, err = engine.Object("main.update").ExecContext(ctx, H{"name": "a", "id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok = LastSQL(ctx); ok {
+		t.Error("expected nothing captured without the setting")
+	}
+}
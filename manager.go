@@ -19,6 +19,8 @@ package juice
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sync/atomic"
 
 	"github.com/go-juicedev/juice/session"
 )
@@ -80,6 +82,9 @@ type BasicTxManager struct {
 	// It's nil if no transaction is active
 	tx  session.TransactionSession
 	ctx context.Context
+
+	// end ends the tracking of the transaction as an in-flight execution of the engine
+	end func()
 }
 
 // Object implements the Manager interface
@@ -87,10 +92,13 @@ func (t *BasicTxManager) Object(v any) SQLRowsExecutor {
 	if t.tx == nil {
 		return inValidExecutor(session.ErrTransactionNotBegun)
 	}
-	statement, err := t.engine.GetConfiguration().GetStatement(v)
+	statement, err := t.engine.getStatement(v)
 	if err != nil {
 		return inValidExecutor(err)
 	}
+	if t.txOptions != nil && t.txOptions.ReadOnly && statement.Action().ForWrite() {
+		return inValidExecutor(fmt.Errorf("%w: %s", ErrReadOnlyTransaction, statement.Name()))
+	}
 	drv := t.engine.Driver()
 	statementHandler := NewBatchStatementHandler(drv, t.tx, t.engine.middlewares...)
 	return NewSQLRowsExecutor(statement, statementHandler, drv)
@@ -102,20 +110,36 @@ func (t *BasicTxManager) Begin() error {
 	if t.tx != nil {
 		return session.ErrTransactionAlreadyBegun
 	}
+	// the transaction is in-flight until it is committed or rolled back,
+	// so that closing the engine waits for it.
+	end, err := t.engine.manager.begin(t.engine.using)
+	if err != nil {
+		return err
+	}
 	tx, err := t.engine.DB().BeginTx(t.ctx, t.txOptions)
 	if err != nil {
+		end()
 		return err
 	}
-	t.tx = tx
+	t.tx, t.end = tx, end
 	return nil
 }
 
+// finish ends the tracking of the transaction once.
+func (t *BasicTxManager) finish() {
+	if t.end != nil {
+		t.end()
+		t.end = nil
+	}
+}
+
 // Commit commits the transaction
 func (t *BasicTxManager) Commit() error {
 	// If the transaction is not begun, return an error directly.
 	if t.tx == nil {
 		return session.ErrTransactionNotBegun
 	}
+	defer t.finish()
 	return t.tx.Commit()
 }
 
@@ -125,16 +149,28 @@ func (t *BasicTxManager) Rollback() error {
 	if t.tx == nil {
 		return session.ErrTransactionNotBegun
 	}
+	defer t.finish()
 	return t.tx.Rollback()
 }
 
+// Raw returns a Runner which executes the raw query within the transaction.
+// Like Object, the Insert, Update and Delete of the Runner fail with ErrReadOnlyTransaction
+// in a read-only transaction, the Select is not checked, since the query itself is not parsed.
 func (t *BasicTxManager) Raw(query string) Runner {
 	if t.tx == nil {
 		return NewErrorRunner(session.ErrTransactionNotBegun)
 	}
-	return NewRunner(query, t.engine, t.tx)
+	return &SQLRunner{
+		query:    query,
+		engine:   t.engine,
+		session:  t.tx,
+		readOnly: t.txOptions != nil && t.txOptions.ReadOnly,
+	}
 }
 
+// managerKey is the context key of the Manager.
+// It is unexported to avoid collisions with the keys defined in other packages,
+// use ContextWithManager and ManagerFromContext to access the Manager.
 type managerKey struct{}
 
 // managerFromContext returns the Manager from the context.
@@ -144,9 +180,41 @@ func managerFromContext(ctx context.Context) (Manager, bool) {
 }
 
 // ManagerFromContext returns the Manager from the context.
+// It returns the default Manager set by SetDefaultManager if the context carries no Manager,
+// or nil if there is neither.
 func ManagerFromContext(ctx context.Context) Manager {
-	manager, _ := managerFromContext(ctx)
-	return manager
+	if manager, ok := managerFromContext(ctx); ok {
+		return manager
+	}
+	if holder := defaultManager.Load(); holder != nil {
+		return holder.manager
+	}
+	return nil
+}
+
+// managerHolder holds the default Manager, since atomic.Pointer needs a concrete type.
+type managerHolder struct {
+	manager Manager
+}
+
+// defaultManager is the default Manager set by SetDefaultManager.
+var defaultManager atomic.Pointer[managerHolder]
+
+// SetDefaultManager sets the package level Manager which ManagerFromContext falls back to
+// when the context carries no Manager, so the simple apps and scripts can use the generated code
+// without putting the manager into every context. Setting nil removes it.
+// It is not set by default, and a Manager in the context always takes precedence over it.
+//
+// Note that it is a global state shared by the whole process: the tests which set it affect
+// each other and must not run in parallel, and an app which serves many tenants or databases
+// should keep passing the Manager by the context, since a query may silently use the default
+// one when the context misses it.
+func SetDefaultManager(manager Manager) {
+	if manager == nil {
+		defaultManager.Store(nil)
+		return
+	}
+	defaultManager.Store(&managerHolder{manager: manager})
 }
 
 // ContextWithManager returns a new context with the given Manager.
@@ -154,6 +222,11 @@ func ContextWithManager(ctx context.Context, manager Manager) context.Context {
 	return context.WithValue(ctx, managerKey{}, manager)
 }
 
+// WithManager is an alias of ContextWithManager, which is the setter of ManagerFromContext.
+func WithManager(ctx context.Context, manager Manager) context.Context {
+	return ContextWithManager(ctx, manager)
+}
+
 // IsTxManager returns true if the manager is a TxManager.
 func IsTxManager(manager Manager) bool {
 	_, ok := manager.(TxManager)
@@ -0,0 +1,57 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithManager(t *testing.T) {
+	if manager := ManagerFromContext(context.Background()); manager != nil {
+		t.Errorf("expected nil manager, got %v", manager)
+		return
+	}
+	engine := newFakeDB(t).Engine(t, "main", `<select id="ids">select id from t</select>`)
+	ctx := WithManager(context.Background(), engine)
+	if manager := ManagerFromContext(ctx); manager != engine {
+		t.Errorf("expected the engine, got %v", manager)
+	}
+}
+
+func TestSetDefaultManager(t *testing.T) {
+	engine := newFakeDB(t).Engine(t, "main", `<select id="ids">select id from t</select>`)
+	SetDefaultManager(engine)
+	t.Cleanup(func() { SetDefaultManager(nil) })
+
+	if manager := ManagerFromContext(context.Background()); manager != engine {
+		t.Errorf("expected the default manager, got %v", manager)
+		return
+	}
+
+	// the manager of the context takes precedence.
+	tx := engine.Tx()
+	if manager := ManagerFromContext(ContextWithManager(context.Background(), tx)); manager != tx {
+		t.Errorf("expected the manager of the context, got %v", manager)
+		return
+	}
+
+	SetDefaultManager(nil)
+	if manager := ManagerFromContext(context.Background()); manager != nil {
+		t.Errorf("expected nil manager after removing the default, got %v", manager)
+	}
+}
@@ -14,8 +14,11 @@ type Mapper struct {
 	namespace  string
 	mappers    *Mappers
 	statements map[string]*xmlSQLStatement
-	sqlNodes   map[string]*SQLNode
-	attrs      map[string]string
+	// databaseIDStatements stores the statements which declare a databaseId,
+	// keyed by the statement id and then the databaseId.
+	databaseIDStatements map[string]map[string]*xmlSQLStatement
+	sqlNodes             map[string]*SQLNode
+	attrs                map[string]string
 }
 
 // Namespace returns the namespace of the mapper.
@@ -46,6 +49,49 @@ func (m *Mapper) setSqlNode(node *SQLNode) error {
 	return nil
 }
 
+// setStatement registers the statement into the mapper.
+// Statements are allowed to share the same id if they declare different databaseIds.
+func (m *Mapper) setStatement(stmt *xmlSQLStatement) error {
+	key := stmt.ID()
+	databaseID := stmt.DatabaseID()
+	if databaseID == "" {
+		if m.statements == nil {
+			m.statements = make(map[string]*xmlSQLStatement)
+		}
+		if _, exists := m.statements[key]; exists {
+			return fmt.Errorf("duplicate xmlSQLStatement id: %s", key)
+		}
+		m.statements[key] = stmt
+		return nil
+	}
+	if m.databaseIDStatements == nil {
+		m.databaseIDStatements = make(map[string]map[string]*xmlSQLStatement)
+	}
+	variants, ok := m.databaseIDStatements[key]
+	if !ok {
+		variants = make(map[string]*xmlSQLStatement)
+		m.databaseIDStatements[key] = variants
+	}
+	if _, exists := variants[databaseID]; exists {
+		return fmt.Errorf("duplicate xmlSQLStatement id: %s with databaseId: %s", key, databaseID)
+	}
+	variants[databaseID] = stmt
+	return nil
+}
+
+// getStatement returns the statement by the key and the databaseId.
+// The statement which declares the given databaseId is preferred,
+// otherwise the one without databaseId is returned.
+func (m *Mapper) getStatement(key, databaseID string) (*xmlSQLStatement, bool) {
+	if databaseID != "" {
+		if stmt, exists := m.databaseIDStatements[key][databaseID]; exists {
+			return stmt, true
+		}
+	}
+	stmt, exists := m.statements[key]
+	return stmt, exists
+}
+
 // Attribute returns the attribute value by key.
 func (m *Mapper) Attribute(key string) string {
 	return m.attrs[key]
@@ -56,6 +102,9 @@ func (m *Mapper) Prefix() string {
 	return m.Attribute("prefix")
 }
 
+// GetSQLNodeByID returns the SQL node by id.
+// A bare id like "columns" is looked up in the current mapper, while a namespace-qualified
+// id like "common.columns" is looked up in the mapper of that namespace.
 func (m *Mapper) GetSQLNodeByID(id string) (Node, error) {
 	// if the id is not cross-namespace
 	isCrossNamespace := strings.Contains(id, ".")
@@ -65,9 +114,13 @@ func (m *Mapper) GetSQLNodeByID(id string) (Node, error) {
 			return nil, &ErrSQLNodeNotFound{NodeName: id, MapperName: m.namespace}
 		}
 		return node, nil
-	} else {
-		return m.mappers.GetSQLNodeByID(id)
 	}
+	// the mapper has not been registered into the mappers yet, which happens while parsing.
+	// the caller should resolve it later.
+	if m.mappers == nil {
+		return nil, &ErrSQLNodeNotFound{NodeName: id, MapperName: m.namespace}
+	}
+	return m.mappers.GetSQLNodeByID(id)
 }
 
 // ErrSQLNodeNotFound indicates that the SQL node was not found in the mapper
@@ -130,6 +183,13 @@ func (m *Mappers) getMapperAndKey(id string) (mapper *Mapper, key string, err er
 // The id should be in the format of "namespace.statementName"
 // For example: "main.UserMapper.SelectUser"
 func (m *Mappers) GetStatementByID(id string) (Statement, error) {
+	return m.GetStatementByDatabaseID(id, "")
+}
+
+// GetStatementByDatabaseID returns a Statement by id for the given databaseId.
+// The statement which declares the given databaseId is preferred over the one without it,
+// and the statements which declare other databaseIds are ignored.
+func (m *Mappers) GetStatementByDatabaseID(id, databaseID string) (Statement, error) {
 	if m == nil {
 		return nil, fmt.Errorf("%w: statement '%s' not found in mapper configuration", ErrNoStatementFound, id)
 	}
@@ -138,17 +198,26 @@ func (m *Mappers) GetStatementByID(id string) (Statement, error) {
 		return nil, err
 	}
 
-	stmt, exists := mapper.statements[key]
+	stmt, exists := mapper.getStatement(key, databaseID)
 	if !exists {
 		return nil, &ErrStatementNotFound{StatementName: key, MapperName: mapper.namespace}
 	}
 	return stmt, nil
 }
 
+// GetSQLNodeByID returns the SQL node by the namespace-qualified id.
+// The namespace can be written either with or without the prefix of the Mappers.
 func (m *Mappers) GetSQLNodeByID(id string) (Node, error) {
 	mapper, key, err := m.getMapperAndKey(id)
 	if err != nil {
-		return nil, err
+		prefix := m.Prefix()
+		if prefix == "" {
+			return nil, err
+		}
+		var prefixedErr error
+		if mapper, key, prefixedErr = m.getMapperAndKey(prefix + "." + id); prefixedErr != nil {
+			return nil, err
+		}
 	}
 
 	node, exists := mapper.sqlNodes[key]
@@ -160,6 +229,15 @@ func (m *Mappers) GetSQLNodeByID(id string) (Node, error) {
 
 // GetStatement try to one the xmlSQLStatement from the Mappers with the given interface
 func (m *Mappers) GetStatement(v any) (Statement, error) {
+	id, err := statementIDOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return m.GetStatementByID(id)
+}
+
+// statementIDOf returns the statement id of the given interface.
+func statementIDOf(v any) (string, error) {
 	var id string
 	// if the interface is StatementIDGetter, use the StatementID() method to get the id
 	// or if the interface is a string type, use the string as the id
@@ -178,13 +256,13 @@ func (m *Mappers) GetStatement(v any) (Statement, error) {
 		case reflect.Struct:
 			id = rv.Type().PkgPath() + "." + rv.Type().Name()
 		default:
-			return nil, errors.New("invalid type of xmlSQLStatement id")
+			return "", errors.New("invalid type of xmlSQLStatement id")
 		}
 	}
 	if len(id) == 0 {
-		return nil, errors.New("can not get the xmlSQLStatement id from the given interface")
+		return "", errors.New("can not get the xmlSQLStatement id from the given interface")
 	}
-	return m.GetStatementByID(id)
+	return id, nil
 }
 
 // Configuration represents a configuration of juice.
@@ -0,0 +1,693 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+// newTestConfiguration parses the given configuration content.
+func newTestConfiguration(t *testing.T, content string) IConfiguration {
+	t.Helper()
+	files := fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(content)}}
+	cfg, err := NewXMLConfigurationWithFS(files, "juice.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestMapper_IncludeCrossNamespace(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers prefix="app">
+        <mapper namespace="users">
+            <sql id="columns">id, name</sql>
+            <select id="list">select <include refid="common.columns"/> from users</select>
+            <select id="local">select <include refid="columns"/> from users</select>
+        </mapper>
+        <mapper namespace="common">
+            <sql id="columns">id, name, created_at</sql>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	cases := map[string]string{
+		"app.users.list":  "select id, name, created_at from users",
+		"app.users.local": "select id, name from users",
+	}
+	for id, expected := range cases {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if query != expected {
+			t.Errorf("expected %q, got %q", expected, query)
+			return
+		}
+	}
+}
+
+func TestMapper_IncludeCrossNamespaceNotFound(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">select <include refid="common.columns"/> from users</select>
+        </mapper>
+        <mapper namespace="common">
+            <sql id="fields">id</sql>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = statement.Build(driver.MySQLDriver{}.Translator(), nil)
+	var notFound *ErrSQLNodeNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected ErrSQLNodeNotFound, got %v", err)
+	}
+}
+
+func TestEngine_DatabaseID(t *testing.T) {
+	db := newFakeDB(t)
+	files := fstest.MapFS{
+		"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <environments default="test">
+        <environment id="test">
+            <dataSource>` + db.dsn + `</dataSource>
+            <driver>` + fakeDriverName + `</driver>
+        </environment>
+    </environments>
+    <mappers>
+        <mapper namespace="main">
+            <select id="now" databaseId="pg">select now()</select>
+            <select id="now" databaseId="mysql">select current_timestamp()</select>
+            <select id="now">select current_timestamp</select>
+            <select id="other" databaseId="pg">select 1</select>
+        </mapper>
+    </mappers>
+    <databaseIdProvider>
+        <property name="` + fakeDriverName + `" value="mysql"/>
+    </databaseIdProvider>
+</configuration>`)},
+	}
+	cfg, err := NewXMLConfigurationWithFS(files, "juice.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = engine.Close() }()
+
+	if engine.DatabaseID() != "mysql" {
+		t.Errorf("expected databaseId mysql, got %s", engine.DatabaseID())
+		return
+	}
+	statement := engine.Object("main.now").Statement()
+	query, _, err := statement.Build(engine.Driver().Translator(), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "select current_timestamp()" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+
+	// the statements for other databases are ignored.
+	var notFound *ErrStatementNotFound
+	if _, err = engine.Object("main.other").QueryContext(context.Background(), nil); !errors.As(err, &notFound) {
+		t.Errorf("expected ErrStatementNotFound, got %v", err)
+		return
+	}
+
+	// the statement without databaseId is used when no variant matches.
+	statement, err = cfg.(*Configuration).GetStatementByDatabaseID("main.now", "sqlite")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query, _, _ = statement.Build(engine.Driver().Translator(), nil); query != "select current_timestamp" {
+		t.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestEngine_DatabaseIDVariable(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<insert id="create">
+    insert into users (name) values (#{name})
+    <if test="_databaseId == 'postgres'">returning id</if>
+    <if test="_databaseId == '`+fakeDriverName+`'">/* fake */</if>
+</insert>`)
+
+	// without databaseIdProvider, the driver name is used as the databaseId.
+	if engine.DatabaseID() != fakeDriverName {
+		t.Errorf("expected databaseId %s, got %s", fakeDriverName, engine.DatabaseID())
+		return
+	}
+	statement := engine.Object("main.create").Statement()
+	query, _, err := statement.Build(engine.Driver().Translator(), H{"name": "eatmoreapple"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "insert into users (name) values (?) /* fake */" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+
+	// the parameter has a higher priority than the _databaseId variable.
+	query, _, err = statement.Build(engine.Driver().Translator(), H{"name": "eatmoreapple", "_databaseId": "postgres"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "insert into users (name) values (?) returning id" {
+		t.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestMapper_TrimCompact(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="name">
+                select
+                <trim prefix="concat(" suffix=")" suffixOverrides="," compact="true">
+                    <if test='first != ""'>#{first},</if>
+                    <if test='last != ""'>#{last},</if>
+                </trim>
+                from users
+            </select>
+            <select id="loose">
+                select * from users
+                <trim prefix="where" prefixOverrides="and">
+                    and id = #{id}
+                    <if test='first != ""'>and first = #{first}</if>
+                </trim>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	cases := []struct {
+		id       string
+		param    H
+		expected string
+	}{
+		{"users.name", H{"first": "a", "last": "b"}, "select concat(?,?) from users"},
+		{"users.name", H{"first": "a", "last": ""}, "select concat(?) from users"},
+		{"users.loose", H{"id": 1, "first": "a"}, "select * from users where id = ? and first = ?"},
+	}
+	for _, c := range cases {
+		statement, err := cfg.GetStatement(c.id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), c.param)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if query != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, query)
+			return
+		}
+	}
+}
+
+func TestMapper_ExecutableComment(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select /*! STRAIGHT_JOIN */ u.id from users u /*!50100 PARTITION (p0) */ join orders o on o.user_id = u.id
+                <where>
+                    <if test="id > 0">/*!80000 AND */ u.id = #{id}</if>
+                    <if test='name != ""'>AND u.name = #{name} /*!50100 COLLATE utf8mb4_bin */</if>
+                </where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		param    H
+		expected string
+	}{
+		{H{"id": 1, "name": ""}, "select /*! STRAIGHT_JOIN */ u.id from users u /*!50100 PARTITION (p0) */ join orders o on o.user_id = u.id WHERE /*!80000 AND */ u.id = ?"},
+		{H{"id": 0, "name": "a"}, "select /*! STRAIGHT_JOIN */ u.id from users u /*!50100 PARTITION (p0) */ join orders o on o.user_id = u.id WHERE u.name = ? /*!50100 COLLATE utf8mb4_bin */"},
+	}
+	for _, c := range cases {
+		query, args, err := statement.Build(driver.MySQLDriver{}.Translator(), c.param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, query)
+		}
+		if len(args) != 1 {
+			t.Errorf("expected one argument, got %v", args)
+		}
+	}
+}
+
+func TestMapper_StrictTextSubstitution(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <settings>
+        <setting name="strictTextSubstitution" value="true"/>
+        <setting name="tablePrefix" value="t_"/>
+    </settings>
+    <mappers>
+        <mapper namespace="users">
+            <select id="trusted">select * from ${tablePrefix}users where id = #{id}</select>
+            <select id="untrusted">select * from users order by ${column}</select>
+            <select id="foreach">
+                select * from users where
+                <foreach collection="names" item="name" separator="and">${name} = 1</foreach>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	build := func(id string, param H) (string, error) {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			return "", err
+		}
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), param)
+		return query, err
+	}
+
+	query, err := build("users.trusted", H{"id": 1, "tablePrefix": "x_"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "select * from t_users where id = ?" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+
+	for _, id := range []string{"users.untrusted", "users.foreach"} {
+		_, err = build(id, H{"column": "name", "names": []string{"name"}})
+		if !errors.Is(err, ErrUntrustedTextSubstitution) {
+			t.Errorf("%s: expected ErrUntrustedTextSubstitution, got %v", id, err)
+		}
+	}
+}
+
+func TestMapper_StrictAttributes(t *testing.T) {
+	const statements = `<mapper namespace="users">
+            <select id="list">
+                select * from users where id in
+                <foreach colletion="ids" item="id" open="(" close=")" seperator=",">#{id}</foreach>
+            </select>
+        </mapper>`
+	files := fstest.MapFS{
+		"loose.xml":  &fstest.MapFile{Data: []byte(`<configuration><mappers>` + statements + `</mappers></configuration>`)},
+		"strict.xml": &fstest.MapFile{Data: []byte(`<configuration><mappers strict="true">` + statements + `</mappers></configuration>`)},
+	}
+	if _, err := NewXMLConfigurationWithFS(files, "loose.xml"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	_, err := NewXMLConfigurationWithFS(files, "strict.xml")
+	var unknownErr *nodeAttributeUnknownError
+	if !errors.As(err, &unknownErr) {
+		t.Errorf("expected nodeAttributeUnknownError, got %v", err)
+		return
+	}
+	if err.Error() != `parse mapper (namespace users) at line 4, column 85 in <select id="list">: node foreach has unknown attribute colletion, seperator` {
+		t.Errorf("unexpected error message: %s", err)
+	}
+}
+
+func TestMapper_StrictAliasColumns(t *testing.T) {
+	const statements = `<mapper namespace="users">
+            <select id="list">
+                select <alias><field name="id"/><field name="user_id" alias="id"/></alias> from users
+            </select>
+        </mapper>`
+	files := fstest.MapFS{
+		"loose.xml":  &fstest.MapFile{Data: []byte(`<configuration><mappers>` + statements + `</mappers></configuration>`)},
+		"strict.xml": &fstest.MapFile{Data: []byte(`<configuration><mappers strict="true">` + statements + `</mappers></configuration>`)},
+	}
+	if _, err := NewXMLConfigurationWithFS(files, "loose.xml"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if _, err := NewXMLConfigurationWithFS(files, "strict.xml"); !errors.Is(err, ErrAmbiguousColumn) {
+		t.Errorf("expected ErrAmbiguousColumn, got %v", err)
+	}
+}
+
+func TestParseChoose(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users
+                <where>
+                    <choose>
+                        <when test="id > 0">id = #{id}</when>
+                        <when test='name != ""'>name = #{name}</when>
+                        <otherwise>status = 1</otherwise>
+                    </choose>
+                </where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		param    H
+		expected string
+		args     int
+	}{
+		// the first matched when wins even if the later ones match too.
+		{H{"id": 1, "name": "a"}, "select * from users WHERE id = ?", 1},
+		{H{"id": 0, "name": "a"}, "select * from users WHERE name = ?", 1},
+		{H{"id": 0, "name": ""}, "select * from users WHERE status = 1", 0},
+	}
+	for _, c := range cases {
+		query, args, err := statement.Build(driver.MySQLDriver{}.Translator(), c.param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected || len(args) != c.args {
+			t.Errorf("%v: expected %q, got %q %v", c.param, c.expected, query, args)
+		}
+	}
+}
+
+func TestMapper_UpdateSetWhere(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <update id="update">
+                update users
+                <set>
+                    <if test='name != ""'>
+                        name = #{name},
+                    </if>
+                    <if test="age > 0">
+                        age = #{age},
+                    </if>
+                    updated_at = now()
+                </set>
+                <where>
+                    <if test="id > 0">
+                        and id = #{id}
+                    </if>
+                    <if test="status > 0">
+                        and status = #{status}
+                    </if>
+                </where>
+            </update>
+            <update id="inline">update users<set><if test='name != ""'>name = #{name},</if></set><where><if test="id > 0">and id = #{id}</if></where></update>
+            <delete id="delete">
+                delete from users
+                <where>
+                    <if test="id > 0">and id = #{id}</if>
+                    <if test="status > 0">and status = #{status}</if>
+                </where>
+                limit 1
+            </delete>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	cases := []struct {
+		id       string
+		param    H
+		expected string
+		args     int
+	}{
+		{"users.update", H{"name": "a", "age": 1, "id": 1, "status": 2}, "update users SET name = ?, age = ?, updated_at = now() WHERE id = ? and status = ?", 4},
+		{"users.update", H{"name": "", "age": 1, "id": 0, "status": 2}, "update users SET age = ?, updated_at = now() WHERE status = ?", 2},
+		{"users.update", H{"name": "", "age": 0, "id": 1, "status": 0}, "update users SET updated_at = now() WHERE id = ?", 1},
+		{"users.update", H{"name": "a", "age": 0, "id": 0, "status": 0}, "update users SET name = ?, updated_at = now()", 1},
+		{"users.inline", H{"name": "a", "id": 1}, "update users SET name = ? WHERE id = ?", 2},
+		{"users.delete", H{"id": 1, "status": 0}, "delete from users WHERE id = ? limit 1", 1},
+		{"users.delete", H{"id": 0, "status": 0}, "delete from users limit 1", 0},
+	}
+	for _, c := range cases {
+		statement, err := cfg.GetStatement(c.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, args, err := statement.Build(driver.MySQLDriver{}.Translator(), c.param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected || len(args) != c.args {
+			t.Errorf("%s %v: expected %q, got %q %v", c.id, c.param, c.expected, query, args)
+		}
+	}
+}
+
+func TestParseChoose_Malformed(t *testing.T) {
+	cases := map[string]string{
+		"multiple otherwise elements in choose": `<choose>
+    <when test="id > 0">id = #{id}</when>
+    <otherwise>id = 0</otherwise>
+    <otherwise>id = 1</otherwise>
+</choose>`,
+		"when element after otherwise in choose": `<choose>
+    <otherwise>id = 0</otherwise>
+    <when test="id > 0">id = #{id}</when>
+</choose>`,
+		"unknown element if in choose": `<choose>
+    <when test="id > 0">id = #{id}</when>
+    <if test="status > 0">status = #{status}</if>
+</choose>`,
+		"otherwise element outside choose": `<otherwise>id = 0</otherwise>`,
+		"when element outside choose":      `<where><when test="id > 0">id = #{id}</when></where>`,
+	}
+	for expected, body := range cases {
+		files := fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="get">select * from users where ` + body + `</select>
+        </mapper>
+    </mappers>
+</configuration>`)}}
+		_, err := NewXMLConfigurationWithFS(files, "juice.xml")
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestXMLParseError(t *testing.T) {
+	files := fstest.MapFS{
+		"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper resource="users.xml"/>
+    </mappers>
+</configuration>`)},
+		"users.xml": &fstest.MapFile{Data: []byte(`<mapper namespace="users">
+    <select id="list">select * from users</select>
+    <select id="get">
+        select * from users <where><if>id = #{id}</if></where>
+    </select>
+</mapper>`)},
+	}
+	_, err := NewXMLConfigurationWithFS(files, "juice.xml")
+	var parseErr *XMLParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected XMLParseError, got %v", err)
+	}
+	if parseErr.Resource != "users.xml" || parseErr.Namespace != "users" || parseErr.Element != `select id="get"` || parseErr.Line != 4 {
+		t.Errorf("unexpected error location: %+v", parseErr)
+		return
+	}
+	var requiredErr *nodeAttributeRequiredError
+	if !errors.As(err, &requiredErr) {
+		t.Errorf("expected the underlying nodeAttributeRequiredError, got %v", err)
+		return
+	}
+
+	// the syntax errors are located too.
+	files["users.xml"] = &fstest.MapFile{Data: []byte(`<mapper namespace="users">
+    <select id="list">select * from users</selec>
+</mapper>`)}
+	_, err = NewXMLConfigurationWithFS(files, "juice.xml")
+	if !errors.As(err, &parseErr) || parseErr.Resource != "users.xml" || parseErr.Line != 2 {
+		t.Errorf("expected the syntax error located in users.xml, got %v", err)
+	}
+}
+
+func TestStatement_Defaults(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="events">
+            <select id="list">
+                <defaults>
+                    <default name="source" value="api"/>
+                    <default name="level" value="info"/>
+                </defaults>
+                select * from events where source = #{source}
+                <if test="level != 'info'">and level = #{level}</if>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("events.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	translator := driver.MySQLDriver{}.Translator()
+
+	query, args, err := statement.Build(translator, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from events where source = ?" || len(args) != 1 || args[0] != "api" {
+		t.Errorf("unexpected query: %s %v", query, args)
+		return
+	}
+
+	// the caller supplied values take precedence.
+	query, args, err = statement.Build(translator, H{"source": "web", "level": "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from events where source = ? and level = ?" || len(args) != 2 || args[0] != "web" || args[1] != "warn" {
+		t.Errorf("unexpected query: %s %v", query, args)
+		return
+	}
+
+	type Filter struct {
+		Level string `param:"level"`
+	}
+	_, args, err = statement.Build(translator, Filter{Level: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != "api" || args[1] != "error" {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestMapper_Extends(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="base" resultType="user">
+                select <block name="columns">id, name</block> from users
+                <where>
+                    <block name="where">status = 1</block>
+                </where>
+                <block name="order">order by id</block>
+            </select>
+            <select id="byRole" extends="base">
+                <block name="where" mode="append">and role = #{role}</block>
+                <block name="order"/>
+            </select>
+            <select id="names" extends="byRole">
+                <block name="columns">name</block>
+                <block name="where" mode="prepend">deleted = 0 and</block>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	cases := map[string]string{
+		"users.base":   "select id, name from users WHERE status = 1 order by id",
+		"users.byRole": "select id, name from users WHERE status = 1 and role = ?",
+		"users.names":  "select name from users WHERE deleted = 0 and status = 1 and role = ?",
+	}
+	for id, expected := range cases {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), H{"role": "admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != expected {
+			t.Errorf("%s: expected %q, got %q", id, expected, query)
+		}
+		if statement.Attribute("resultType") != "user" {
+			t.Errorf("%s: expected the inherited resultType, got %q", id, statement.Attribute("resultType"))
+		}
+	}
+}
+
+func TestMapper_ExtendsError(t *testing.T) {
+	cases := map[string]string{
+		"unknown block": `<select id="base">select * from users <block name="where"/></select>
+            <select id="child" extends="base"><block name="order">order by id</block></select>`,
+		"unknown base": `<select id="child" extends="base"><block name="where"/></select>`,
+		"circular": `<select id="a" extends="b"><block name="where"/></select>
+            <select id="b" extends="a"><block name="where"/></select>`,
+		"text": `<select id="base">select * from users <block name="where"/></select>
+            <select id="child" extends="base">select 1</select>`,
+		"action": `<select id="base">select * from users <block name="where"/></select>
+            <delete id="child" extends="base"><block name="where"/></delete>`,
+	}
+	for name, statements := range cases {
+		files := fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper namespace="users">` + statements + `</mapper>
+    </mappers>
+</configuration>`)}}
+		_, err := NewXMLConfigurationWithFS(files, "juice.xml")
+		var parseErr *XMLParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: expected XMLParseError, got %v", name, err)
+		}
+	}
+	files := fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="base">select * from users <block name="where"/></select>
+            <select id="child" extends="base"><block name="order">order by id</block></select>
+        </mapper>
+    </mappers>
+</configuration>`)}}
+	_, err := NewXMLConfigurationWithFS(files, "juice.xml")
+	if err == nil || !strings.Contains(err.Error(), "unknown block order of statement base") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MetricsRecorder records the metrics of the executed statements, like the execution count,
+// the error count and the latency.
+// It must be safe for concurrent use.
+type MetricsRecorder interface {
+	// RecordStatement records an execution of the statement with the given name and action,
+	// which took the elapsed time and failed with err if it is not nil.
+	RecordStatement(ctx context.Context, statement string, action Action, elapsed time.Duration, err error)
+}
+
+// ensure NoopMetricsRecorder implements MetricsRecorder
+var _ MetricsRecorder = NoopMetricsRecorder{} // compile time check
+
+// NoopMetricsRecorder is a MetricsRecorder which records nothing, it is used when no recorder is given.
+type NoopMetricsRecorder struct{}
+
+// RecordStatement implements MetricsRecorder.
+func (NoopMetricsRecorder) RecordStatement(context.Context, string, Action, time.Duration, error) {}
+
+// rawStatementMetricName is the name of the raw statements in the metrics, which have no ids,
+// so they share one name to keep the cardinality of the metrics bounded.
+const rawStatementMetricName = "raw"
+
+// ensure MetricsMiddleware implements Middleware
+var _ Middleware = (*MetricsMiddleware)(nil) // compile time check
+
+// MetricsMiddleware is a middleware that records every statement execution by the Recorder.
+// The statements are named by their ids instead of the rendered queries, and all the raw
+// statements are named "raw", so the cardinality of the metrics is bounded.
+//
+//	engine.Use(&juice.MetricsMiddleware{Recorder: recorder})
+//
+// The latency of a query covers the execution of the query, but not the scanning of its rows.
+type MetricsMiddleware struct {
+	Recorder MetricsRecorder
+}
+
+// QueryContext implements Middleware.
+func (m *MetricsMiddleware) QueryContext(stmt Statement, next QueryHandler) QueryHandler {
+	recorder := m.recorder()
+	name := statementMetricName(stmt)
+	return func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		start := time.Now()
+		rows, err := next(ctx, query, args...)
+		recorder.RecordStatement(ctx, name, stmt.Action(), time.Since(start), err)
+		return rows, err
+	}
+}
+
+// ExecContext implements Middleware.
+func (m *MetricsMiddleware) ExecContext(stmt Statement, next ExecHandler) ExecHandler {
+	recorder := m.recorder()
+	name := statementMetricName(stmt)
+	return func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+		start := time.Now()
+		result, err := next(ctx, query, args...)
+		recorder.RecordStatement(ctx, name, stmt.Action(), time.Since(start), err)
+		return result, err
+	}
+}
+
+// recorder returns the Recorder, or NoopMetricsRecorder if it is not set.
+func (m *MetricsMiddleware) recorder() MetricsRecorder {
+	if m.Recorder == nil {
+		return NoopMetricsRecorder{}
+	}
+	return m.Recorder
+}
+
+// statementMetricName returns the name of the statement used by the metrics.
+func statementMetricName(stmt Statement) string {
+	switch stmt.(type) {
+	case rawSQLStatement, *rawSQLStatement:
+		return rawStatementMetricName
+	}
+	return stmt.Name()
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package promtext provides a juice.MetricsRecorder which exports the metrics of the statements
+// in the Prometheus text exposition format.
+//
+// It is a standalone exporter without any dependency: the Recorder serves its own scrape endpoint,
+// it is not a prometheus.Collector of the client_golang and can not be registered to a prometheus.Registry.
+// The applications which already use the client_golang should implement the juice.MetricsRecorder with
+// its collectors instead.
+//
+//	recorder := promtext.NewRecorder()
+//	engine.Use(&juice.MetricsMiddleware{Recorder: recorder})
+//	http.Handle("/metrics", recorder)
+//
+// The metrics are labeled by the statement id and the action:
+//
+//	juice_statement_executions_total{statement="main.GetUser",action="select"} 42
+//	juice_statement_errors_total{statement="main.GetUser",action="select"} 1
+//	juice_statement_duration_seconds_bucket{statement="main.GetUser",action="select",le="0.005"} 40
+package promtext
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-juicedev/juice"
+)
+
+// DefaultBuckets is the default upper bounds of the latency histogram in seconds.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ensure Recorder implements juice.MetricsRecorder
+var _ juice.MetricsRecorder = (*Recorder)(nil) // compile time check
+
+// seriesKey is the labels of a series.
+type seriesKey struct {
+	statement string
+	action    juice.Action
+}
+
+// series is the metrics of a statement.
+type series struct {
+	executions uint64
+	errors     uint64
+	// buckets is the count of the executions per bucket, which is not cumulative.
+	buckets []uint64
+	sum     float64
+}
+
+// Recorder records the executions of the statements, it is safe for concurrent use.
+// It serves the recorded metrics over http.
+type Recorder struct {
+	namespace string
+	buckets   []float64
+	mu        sync.Mutex
+	series    map[seriesKey]*series
+}
+
+// Option configures the Recorder.
+type Option func(*Recorder)
+
+// WithNamespace sets the prefix of the metric names, which is juice by default.
+func WithNamespace(namespace string) Option {
+	return func(r *Recorder) {
+		r.namespace = namespace
+	}
+}
+
+// WithBuckets sets the upper bounds of the latency histogram in seconds, which is DefaultBuckets by default.
+func WithBuckets(buckets ...float64) Option {
+	return func(r *Recorder) {
+		r.buckets = buckets
+	}
+}
+
+// NewRecorder returns a new Recorder with the given options.
+func NewRecorder(opts ...Option) *Recorder {
+	recorder := &Recorder{
+		namespace: "juice",
+		buckets:   DefaultBuckets,
+		series:    make(map[seriesKey]*series),
+	}
+	for _, opt := range opts {
+		opt(recorder)
+	}
+	recorder.buckets = slices.Clone(recorder.buckets)
+	slices.Sort(recorder.buckets)
+	recorder.buckets = slices.Compact(recorder.buckets)
+	return recorder
+}
+
+// RecordStatement implements juice.MetricsRecorder.
+func (r *Recorder) RecordStatement(_ context.Context, statement string, action juice.Action, elapsed time.Duration, err error) {
+	seconds := elapsed.Seconds()
+	key := seriesKey{statement: statement, action: action}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, exists := r.series[key]
+	if !exists {
+		s = &series{buckets: make([]uint64, len(r.buckets))}
+		r.series[key] = s
+	}
+	s.executions++
+	if err != nil {
+		s.errors++
+	}
+	s.sum += seconds
+	if i, _ := slices.BinarySearch(r.buckets, seconds); i < len(r.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// WriteTo writes the recorded metrics to the writer in the Prometheus text exposition format.
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	keys := make([]seriesKey, 0, len(r.series))
+	snapshot := make(map[seriesKey]series, len(r.series))
+	for key, s := range r.series {
+		keys = append(keys, key)
+		snapshot[key] = series{executions: s.executions, errors: s.errors, buckets: slices.Clone(s.buckets), sum: s.sum}
+	}
+	r.mu.Unlock()
+	slices.SortFunc(keys, func(a, b seriesKey) int {
+		if c := strings.Compare(a.statement, b.statement); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.action), string(b.action))
+	})
+
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+
+	executions := r.namespace + "_statement_executions_total"
+	fmt.Fprintf(buf, "# HELP %s The number of the executions of the statements.\n# TYPE %s counter\n", executions, executions)
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s{%s} %d\n", executions, key.labels(), snapshot[key].executions)
+	}
+
+	failures := r.namespace + "_statement_errors_total"
+	fmt.Fprintf(buf, "# HELP %s The number of the failed executions of the statements.\n# TYPE %s counter\n", failures, failures)
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s{%s} %d\n", failures, key.labels(), snapshot[key].errors)
+	}
+
+	duration := r.namespace + "_statement_duration_seconds"
+	fmt.Fprintf(buf, "# HELP %s The latency of the executions of the statements.\n# TYPE %s histogram\n", duration, duration)
+	for _, key := range keys {
+		s, labels := snapshot[key], key.labels()
+		var cumulative uint64
+		for i, bound := range r.buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", duration, labels, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", duration, labels, s.executions)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", duration, labels, formatFloat(s.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", duration, labels, s.executions)
+	}
+	err := buf.Flush()
+	return counter.n, err
+}
+
+// ServeHTTP implements http.Handler, it serves the recorded metrics.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// labels returns the labels of the series in the exposition format.
+func (k seriesKey) labels() string {
+	return `statement="` + escapeLabelValue(k.statement) + `",action="` + escapeLabelValue(string(k.action)) + `"`
+}
+
+// labelValueReplacer escapes the label values as the exposition format requires.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promtext
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-juicedev/juice"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(WithBuckets(0.1, 0.01, 1))
+	ctx := context.Background()
+	recorder.RecordStatement(ctx, "main.list", juice.Select, 5*time.Millisecond, nil)
+	recorder.RecordStatement(ctx, "main.list", juice.Select, 50*time.Millisecond, errors.New("failed"))
+	recorder.RecordStatement(ctx, "main.list", juice.Select, 2*time.Second, nil)
+	recorder.RecordStatement(ctx, `main."quoted"`, juice.Delete, time.Millisecond, nil)
+
+	response := httptest.NewRecorder()
+	recorder.ServeHTTP(response, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type: %s", contentType)
+	}
+	body := response.Body.String()
+	for _, line := range []string{
+		"# TYPE juice_statement_executions_total counter",
+		`juice_statement_executions_total{statement="main.list",action="select"} 3`,
+		`juice_statement_errors_total{statement="main.list",action="select"} 1`,
+		`juice_statement_duration_seconds_bucket{statement="main.list",action="select",le="0.01"} 1`,
+		`juice_statement_duration_seconds_bucket{statement="main.list",action="select",le="0.1"} 2`,
+		`juice_statement_duration_seconds_bucket{statement="main.list",action="select",le="1"} 2`,
+		`juice_statement_duration_seconds_bucket{statement="main.list",action="select",le="+Inf"} 3`,
+		`juice_statement_duration_seconds_sum{statement="main.list",action="select"} 2.055`,
+		`juice_statement_duration_seconds_count{statement="main.list",action="select"} 3`,
+		`juice_statement_executions_total{statement="main.\"quoted\"",action="delete"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	recorder := NewRecorder(WithNamespace("app"))
+	recorder.RecordStatement(context.Background(), "main.list", juice.Select, time.Millisecond, nil)
+	var builder strings.Builder
+	n, err := recorder.WriteTo(&builder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != builder.Len() {
+		t.Errorf("expected %d bytes written, got %d", builder.Len(), n)
+	}
+	if !strings.Contains(builder.String(), `app_statement_executions_total{statement="main.list",action="select"} 1`) {
+		t.Errorf("unexpected metrics:\n%s", builder.String())
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type metricsRecord struct {
+	statement string
+	action    Action
+	failed    bool
+}
+
+type testMetricsRecorder struct {
+	mu      sync.Mutex
+	records []metricsRecord
+}
+
+func (r *testMetricsRecorder) RecordStatement(_ context.Context, statement string, action Action, elapsed time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elapsed < 0 {
+		panic("negative elapsed time")
+	}
+	r.records = append(r.records, metricsRecord{statement: statement, action: action, failed: err != nil})
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	db := newFakeDB(t)
+	db.exec = func(query string, args []any) (sqldriver.Result, error) {
+		return nil, errors.New("exec failed")
+	}
+	engine := db.Engine(t, "main", `<select id="list">select id from users</select>
+<delete id="remove">delete from users where id = #{id}</delete>`)
+	recorder := &testMetricsRecorder{}
+	engine.Use(&MetricsMiddleware{Recorder: recorder})
+
+	ctx := context.Background()
+	rows, err := engine.Object("main.list").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if _, err = engine.Object("main.remove").ExecContext(ctx, H{"id": 1}); err == nil {
+		t.Fatal("expected the exec error")
+	}
+	rows, err = engine.Raw("select id from users where id = "+"1").Select(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	expected := []metricsRecord{
+		{statement: "main.list", action: Select},
+		{statement: "main.remove", action: Delete, failed: true},
+		{statement: rawStatementMetricName, action: Select},
+	}
+	if len(recorder.records) != len(expected) {
+		t.Fatalf("expected %d records, got %v", len(expected), recorder.records)
+	}
+	for i, record := range recorder.records {
+		if record != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], record)
+		}
+	}
+
+	// the middleware without recorder records nothing.
+	recorder.records = nil
+	engine.Use(&MetricsMiddleware{})
+	rows, err = engine.Object("main.list").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if len(recorder.records) != 1 {
+		t.Errorf("expected 1 record, got %v", recorder.records)
+	}
+}
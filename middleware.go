@@ -21,13 +21,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/go-juicedev/juice/driver"
 	"github.com/go-juicedev/juice/internal/reflectlite"
 	"github.com/go-juicedev/juice/session"
 	"log"
 	"math/rand"
 	"reflect"
+	"regexp"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -390,3 +393,155 @@ func (t *TxSensitiveDataSourceSwitchMiddleware) QueryContext(stmt Statement, nex
 func (t *TxSensitiveDataSourceSwitchMiddleware) ExecContext(_ Statement, next ExecHandler) ExecHandler {
 	return next
 }
+
+// limitedQueryRegexp matches the queries which already limit or lock their rows.
+var limitedQueryRegexp = regexp.MustCompile(`(?i)\b(limit|fetch\s+(first|next)|for\s+update)\b`)
+
+// ensure SingleResultLimitMiddleware implements Middleware
+var _ Middleware = (*SingleResultLimitMiddleware)(nil) // compile time check
+
+// SingleResultLimitMiddleware is a middleware that limits the select queries of the single result
+// executions to one row, like NewGenericManager[*User], so the database does not compute the rows
+// which are never scanned. Note that ErrTooManyRows is no longer returned for these queries.
+// The limit clause is given by the driver.Limiter of the driver which executes the query,
+// the middleware does nothing if the driver does not implement it.
+// The clause is put before the trailing semicolons and comments of the query, and the queries which
+// already contain a limit or a for update clause outside their comments and literals are kept as they are.
+//
+// It changes the emitted queries, so it must be enabled explicitly:
+//
+//	engine.Use(&juice.SingleResultLimitMiddleware{})
+type SingleResultLimitMiddleware struct{}
+
+// QueryContext implements Middleware.
+func (m *SingleResultLimitMiddleware) QueryContext(stmt Statement, next QueryHandler) QueryHandler {
+	if stmt.Action() != Select {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		if !isSingleResultQuery(ctx) {
+			return next(ctx, query, args...)
+		}
+		drv, err := driver.FromContext(ctx)
+		if err != nil {
+			return next(ctx, query, args...)
+		}
+		if limiter, ok := drv.(driver.Limiter); ok {
+			body, tail, code := splitQueryTail(query)
+			if !limitedQueryRegexp.MatchString(code) {
+				query = limiter.Limit(body, 1) + tail
+			}
+		}
+		return next(ctx, query, args...)
+	}
+}
+
+// splitQueryTail splits the query into the body and the tail of the trailing semicolons, spaces and comments.
+// The code is the query whose comments are replaced by spaces and whose quoted literals are emptied,
+// so the clauses can be looked for without being fooled by them.
+func splitQueryTail(query string) (body, tail, code string) {
+	var builder strings.Builder
+	end := 0
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case strings.HasPrefix(query[i:], "--"):
+			next := strings.IndexByte(query[i:], '\n')
+			if next < 0 {
+				next = len(query) - i
+			}
+			i += next
+			builder.WriteByte(' ')
+		case strings.HasPrefix(query[i:], "/*"):
+			next := strings.Index(query[i+2:], "*/")
+			if next < 0 {
+				next = len(query) - i - 4
+			}
+			i += next + 4
+			builder.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			next := strings.IndexByte(query[i+1:], c)
+			if next < 0 {
+				next = len(query) - i - 2
+			}
+			i += next + 2
+			end = i
+			builder.WriteByte(c)
+			builder.WriteByte(c)
+		default:
+			if c != ';' && c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+				end = i + 1
+			}
+			builder.WriteByte(c)
+			i++
+		}
+	}
+	return query[:end], query[end:], builder.String()
+}
+
+// ExecContext implements Middleware.
+func (m *SingleResultLimitMiddleware) ExecContext(_ Statement, next ExecHandler) ExecHandler {
+	return next
+}
+
+// ErrInvalidSQL is an error that is returned when the rendered query of a statement is rejected
+// by the database with the validateSQL setting.
+var ErrInvalidSQL = errors.New("invalid sql")
+
+// ensure sqlValidationMiddleware implements Middleware
+var _ Middleware = (*sqlValidationMiddleware)(nil) // compile time check
+
+// sqlValidationMiddleware is a middleware that validates the rendered queries before executing them,
+// which is enabled by the validateSQL setting:
+//
+//	<settings>
+//	    <setting name="validateSQL" value="true"/>
+//	</settings>
+//
+// The query is prepared on the session and closed immediately, so the database checks its syntax
+// without executing it. It costs a round trip per execution, so it is meant for the tests and development.
+type sqlValidationMiddleware struct{}
+
+// QueryContext implements Middleware.
+func (m *sqlValidationMiddleware) QueryContext(stmt Statement, next QueryHandler) QueryHandler {
+	if !m.enabled(stmt) {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		if err := m.validate(ctx, stmt, query); err != nil {
+			return nil, err
+		}
+		return next(ctx, query, args...)
+	}
+}
+
+// ExecContext implements Middleware.
+func (m *sqlValidationMiddleware) ExecContext(stmt Statement, next ExecHandler) ExecHandler {
+	if !m.enabled(stmt) {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+		if err := m.validate(ctx, stmt, query); err != nil {
+			return nil, err
+		}
+		return next(ctx, query, args...)
+	}
+}
+
+// enabled reports whether the validateSQL setting is enabled.
+func (m *sqlValidationMiddleware) enabled(stmt Statement) bool {
+	cfg := stmt.Configuration()
+	return cfg != nil && cfg.Settings().Get("validateSQL").Bool()
+}
+
+// validate prepares the query on the session of the context and closes it.
+func (m *sqlValidationMiddleware) validate(ctx context.Context, stmt Statement, query string) error {
+	sess, err := session.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	prepared, err := sess.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("%w: statement %s: %w", ErrInvalidSQL, stmt.Name(), err)
+	}
+	return prepared.Close()
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+func TestSingleResultLimitMiddleware(t *testing.T) {
+	type User struct {
+		ID int64 `column:"id"`
+	}
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id"}, [][]sqldriver.Value{{int64(1)}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="all">select id from users</select>
+<select id="limited">select id from users limit 10</select>
+<select id="locked">select id from users for update</select>
+<select id="terminated">select id from users where name = 'limit' /* no limit */; -- first user</select>`)
+	engine.Use(&SingleResultLimitMiddleware{})
+
+	ctx := context.Background()
+	if _, err := NewGenericManager[*User](engine).Object("main.all").QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGenericManager[[]User](engine).Object("main.all").QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"main.limited", "main.locked", "main.terminated"} {
+		if _, err := NewGenericManager[User](engine).Object(id).QueryContext(ctx, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := engine.Object("main.all").QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"select id from users LIMIT 1",
+		"select id from users",
+		"select id from users limit 10",
+		"select id from users for update",
+		"select id from users where name = 'limit' LIMIT 1 /* no limit */; -- first user",
+		"select id from users",
+	}
+	calls := db.Calls()
+	if len(calls) != len(expected) {
+		t.Fatalf("expected %d calls, got %d", len(expected), len(calls))
+	}
+	for i, call := range calls {
+		if call.query != expected[i] {
+			t.Errorf("expected query %q, got %q", expected[i], call.query)
+		}
+	}
+
+	// the limit is given by the driver which executes the query.
+	driver.Register(fakeDriverName, driver.OracleDriver{})
+	t.Cleanup(func() { driver.Register(fakeDriverName, driver.MySQLDriver{}) })
+	engine = db.Engine(t, "main", `<select id="all">select id from users;</select>`)
+	engine.Use(&SingleResultLimitMiddleware{})
+	if _, err := NewGenericManager[User](engine).Object("main.all").QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls = db.Calls(); calls[len(calls)-1].query != "select id from users FETCH FIRST 1 ROWS ONLY;" {
+		t.Errorf("unexpected query %q", calls[len(calls)-1].query)
+	}
+}
+
+func TestSQLValidationMiddleware(t *testing.T) {
+	db := newFakeDB(t)
+	var prepared []string
+	db.prepare = func(query string) error {
+		prepared = append(prepared, query)
+		if strings.Contains(query, "frm") {
+			return errors.New("syntax error near frm")
+		}
+		return nil
+	}
+	engine, err := New(newTestConfiguration(t, `<configuration>
+    <environments default="test">
+        <environment id="test">
+            <dataSource>`+db.dsn+`</dataSource>
+            <driver>`+fakeDriverName+`</driver>
+        </environment>
+    </environments>
+    <settings>
+        <setting name="validateSQL" value="true"/>
+    </settings>
+    <mappers>
+        <mapper namespace="main">
+            <select id="valid">select id from users</select>
+            <delete id="invalid">delete frm users</delete>
+        </mapper>
+    </mappers>
+</configuration>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+
+	ctx := context.Background()
+	rows, err := engine.Object("main.valid").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+
+	_, err = engine.Object("main.invalid").ExecContext(ctx, nil)
+	if !errors.Is(err, ErrInvalidSQL) || !strings.Contains(err.Error(), "main.invalid") {
+		t.Errorf("expected ErrInvalidSQL with the statement id, got %v", err)
+		return
+	}
+	if len(prepared) != 2 || len(db.Calls()) != 1 {
+		t.Errorf("expected the invalid query to be prepared but not executed, got %v and %v", prepared, db.Calls())
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNamingStrategyNotFound is an error that is returned when the namingStrategy of a statement is not registered.
+var ErrNamingStrategyNotFound = errors.New("naming strategy not found")
+
+// NamingStrategy converts the names of the Go fields and types into the names of the columns and tables,
+// which centralizes the naming conventions of the reflection based features, like matching the untagged
+// fields to the result columns and generating the assignments of the set nodes.
+// An explicit column tag always takes precedence over the name derived by the strategy.
+type NamingStrategy interface {
+	// ColumnName returns the column name of the struct field name.
+	ColumnName(field string) string
+
+	// TableName returns the table name of the Go type name.
+	TableName(typ string) string
+}
+
+// DefaultNamingStrategy keeps the names as is, or converts them to snake_case if SnakeCase is true,
+// like CreatedAt to created_at and UserID to user_id.
+type DefaultNamingStrategy struct {
+	SnakeCase bool
+}
+
+// ColumnName implements NamingStrategy.
+func (d DefaultNamingStrategy) ColumnName(field string) string {
+	return d.convert(field)
+}
+
+// TableName implements NamingStrategy.
+func (d DefaultNamingStrategy) TableName(typ string) string {
+	return d.convert(typ)
+}
+
+func (d DefaultNamingStrategy) convert(name string) string {
+	if d.SnakeCase {
+		return toSnakeCase(name)
+	}
+	return name
+}
+
+// namingStrategies is a map of the registered naming strategies by their names.
+var namingStrategies = map[string]NamingStrategy{}
+
+// RegisterNamingStrategy registers the naming strategy with the name, which is chosen by the namingStrategy
+// attribute of the statements or the namingStrategy setting. Registering a strategy with the same name again overrides it.
+// By default, identity keeps the names as is, and snakeCase converts them to snake_case:
+//
+//	<setting name="namingStrategy" value="snakeCase"/>
+//
+// It is not safe for concurrent use, strategies should be registered at init time.
+func RegisterNamingStrategy(name string, strategy NamingStrategy) {
+	if name == "" {
+		panic("juice: naming strategy name is empty")
+	}
+	if strategy == nil {
+		panic("juice: naming strategy is nil")
+	}
+	namingStrategies[name] = strategy
+}
+
+func init() {
+	RegisterNamingStrategy("identity", DefaultNamingStrategy{})
+	RegisterNamingStrategy("snakeCase", DefaultNamingStrategy{SnakeCase: true})
+}
+
+// namingStrategyKey is the name of the attribute and the setting which chooses the naming strategy of a statement.
+const namingStrategyKey = "namingStrategy"
+
+// statementNamingStrategy returns the naming strategy chosen by the namingStrategy option of the statement,
+// or nil if it is not set.
+func statementNamingStrategy(statement Statement) (NamingStrategy, error) {
+	strategy, err := lookupNamingStrategy(statementOption(statement, namingStrategyKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w of statement %s", err, statement.Name())
+	}
+	return strategy, nil
+}
+
+// lookupNamingStrategy returns the naming strategy registered by the given name, or nil if the name is empty.
+func lookupNamingStrategy(name StringValue) (NamingStrategy, error) {
+	if name == "" {
+		return nil, nil
+	}
+	strategy, ok := namingStrategies[string(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNamingStrategyNotFound, name)
+	}
+	return strategy, nil
+}
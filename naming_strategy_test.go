@@ -0,0 +1,102 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+func TestDefaultNamingStrategy(t *testing.T) {
+	if name := (DefaultNamingStrategy{}).ColumnName("CreatedAt"); name != "CreatedAt" {
+		t.Errorf("expected CreatedAt, got %s", name)
+	}
+	strategy := DefaultNamingStrategy{SnakeCase: true}
+	if name := strategy.ColumnName("UserID"); name != "user_id" {
+		t.Errorf("expected user_id, got %s", name)
+	}
+	if name := strategy.TableName("OrderItem"); name != "order_item" {
+		t.Errorf("expected order_item, got %s", name)
+	}
+}
+
+// upperNamingStrategy names the columns and tables in upper case.
+type upperNamingStrategy struct{}
+
+func (upperNamingStrategy) ColumnName(field string) string { return strings.ToUpper(field) }
+
+func (upperNamingStrategy) TableName(typ string) string { return strings.ToUpper(typ) }
+
+func TestNamingStrategy(t *testing.T) {
+	RegisterNamingStrategy("upper", upperNamingStrategy{})
+	t.Cleanup(func() { delete(namingStrategies, "upper") })
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id", "USERNAME", "created_at"}, [][]sqldriver.Value{{int64(1), "a", "today"}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="snake" namingStrategy="snakeCase">select * from users</select>
+<select id="upper" namingStrategy="upper">select * from users</select>
+<select id="missing" namingStrategy="kebab">select * from users</select>
+<update id="patch" namingStrategy="snakeCase">
+    update users <set param="user"/> where id = #{user.ID}
+</update>`)
+
+	type User struct {
+		ID        int64 `column:"id"`
+		UserName  string
+		CreatedAt string
+	}
+	ctx := context.Background()
+	user, err := NewGenericManager[User](engine).Object("main.snake").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{ID: 1, CreatedAt: "today"}) {
+		t.Errorf("unexpected user: %+v", user)
+		return
+	}
+	if user, err = NewGenericManager[User](engine).Object("main.upper").QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if user != (User{ID: 1, UserName: "a"}) {
+		t.Errorf("unexpected user: %+v", user)
+		return
+	}
+	if _, err = NewGenericManager[User](engine).Object("main.missing").QueryContext(ctx, nil); !errors.Is(err, ErrNamingStrategyNotFound) {
+		t.Errorf("expected ErrNamingStrategyNotFound, got %v", err)
+		return
+	}
+
+	// the set node derives the columns of the untagged fields by the strategy too.
+	statement, err := engine.GetConfiguration().GetStatement("main.patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := statement.Build(driver.MySQLDriver{}.Translator(), H{"user": User{ID: 1, UserName: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "update users SET id = ?, user_name = ? where id = ?" || len(args) != 3 || args[1] != "b" {
+		t.Errorf("unexpected statement: %s %v", query, args)
+	}
+}
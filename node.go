@@ -17,12 +17,17 @@ limitations under the License.
 package juice
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"github.com/go-juicedev/juice/internal/reflectlite"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-juicedev/juice/eval"
 
@@ -37,7 +42,8 @@ var (
 	//   - #{  age  }    -> matches (whitespace is ignored)
 	//   - #{}           -> doesn't match (requires identifier)
 	//   - #{123}        -> matches
-	paramRegex = regexp.MustCompile(`#{\s*(\w+(?:\.\w+)*)\s*}`)
+	//   - #{settings,typeHandler=json} -> matches with the type handler "json"
+	paramRegex = regexp.MustCompile(`#{\s*(\w+(?:\.\w+)*)\s*(?:,\s*typeHandler\s*=\s*(\w+)\s*)?}`)
 
 	// formatRegexp matches string interpolation placeholders using ${...} syntax.
 	// Unlike paramRegex, these are replaced directly in the SQL string.
@@ -48,7 +54,8 @@ var (
 	//   - ${  field  }  -> matches (whitespace is ignored)
 	//   - ${}           -> doesn't match (requires identifier)
 	//   - ${123}        -> matches
-	formatRegexp = regexp.MustCompile(`\${\s*(\w+(?:\.\w+)*)\s*}`)
+	//   - ${date:20060102} -> matches with the format spec "20060102"
+	formatRegexp = regexp.MustCompile(`\${\s*(\w+(?:\.\w+)*)\s*(?::([^}]*))?}`)
 )
 
 // Node is the fundamental interface for all SQL generation components.
@@ -93,20 +100,118 @@ type Node interface {
 	Accept(translator driver.Translator, p Parameter) (query string, args []any, err error)
 }
 
+// AcceptResult is the result of a node accepting the parameters.
+// It carries the generated SQL fragment and its arguments,
+// and leaves room for the metadata which rides along with them.
+type AcceptResult struct {
+	// Query is the generated SQL fragment.
+	Query string
+
+	// Args is the slice of arguments for the prepared statement.
+	Args []any
+
+	// Names is the slice of the parameter names which produced the arguments,
+	// parallel to Args, like "id" for #{id}. It lets named translators and
+	// checkers correlate the placeholders with the parameters.
+	// The name is empty if it is unknown, like for the arguments of custom nodes.
+	Names []string
+}
+
+// append appends the arguments and their names of the other result to the result.
+// The query is not touched, since how queries are joined depends on the node.
+func (r *AcceptResult) append(other AcceptResult) {
+	if len(other.Args) == 0 {
+		return
+	}
+	r.Args = append(r.Args, other.Args...)
+	r.Names = append(r.Names, other.Names...)
+	// keep the names parallel to the args.
+	for len(r.Names) < len(r.Args) {
+		r.Names = append(r.Names, "")
+	}
+}
+
+// ResultAcceptor is a Node which returns its result as an AcceptResult.
+// All the builtin nodes implement it, and custom nodes may implement it
+// to provide the metadata of their results.
+type ResultAcceptor interface {
+	Node
+	// AcceptResult processes the node with given translator and parameters
+	// and returns the result.
+	AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error)
+}
+
+// AcceptNode accepts the node and returns its result.
+// It calls AcceptResult if the node implements ResultAcceptor,
+// otherwise it wraps the return values of Accept.
+func AcceptNode(node Node, translator driver.Translator, p Parameter) (AcceptResult, error) {
+	if acceptor, ok := node.(ResultAcceptor); ok {
+		return acceptor.AcceptResult(translator, p)
+	}
+	query, args, err := node.Accept(translator, p)
+	if err != nil {
+		return AcceptResult{}, err
+	}
+	return AcceptResult{Query: query, Args: args, Names: make([]string, len(args))}, nil
+}
+
+// ensure the builtin nodes implement ResultAcceptor
+var (
+	_ ResultAcceptor = (NodeGroup)(nil)
+	_ ResultAcceptor = pureTextNode("")
+	_ ResultAcceptor = (*TextNode)(nil)
+	_ ResultAcceptor = (*ConditionNode)(nil)
+	_ ResultAcceptor = (*WhereNode)(nil)
+	_ ResultAcceptor = (*TrimNode)(nil)
+	_ ResultAcceptor = (*ForeachNode)(nil)
+	_ ResultAcceptor = (*SetNode)(nil)
+	_ ResultAcceptor = (*SQLNode)(nil)
+	_ ResultAcceptor = (*IncludeNode)(nil)
+	_ ResultAcceptor = (*ChooseNode)(nil)
+	_ ResultAcceptor = (*OtherwiseNode)(nil)
+	_ ResultAcceptor = (ValuesNode)(nil)
+	_ ResultAcceptor = (*ParamValuesNode)(nil)
+	_ ResultAcceptor = (*CaseNode)(nil)
+	_ ResultAcceptor = (*ColumnsNode)(nil)
+	_ ResultAcceptor = (*AggregateNode)(nil)
+	_ ResultAcceptor = (*BlockNode)(nil)
+	_ ResultAcceptor = (*BindNode)(nil)
+	_ ResultAcceptor = (SelectFieldAliasNode)(nil)
+)
+
+// unpackAcceptResult converts the result of AcceptResult to the return values of Accept.
+func unpackAcceptResult(result AcceptResult, err error) (query string, args []any, _ error) {
+	if err != nil {
+		return "", nil, err
+	}
+	return result.Query, result.Args, nil
+}
+
 // NodeGroup wraps multiple nodes into a single node.
 type NodeGroup []Node
 
 // Accept processes all nodes in the group and combines their results.
+func (g NodeGroup) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(g.AcceptResult(translator, p))
+}
+
+// AcceptResult processes all nodes in the group and combines their results.
 // The method ensures proper spacing between node outputs and trims any extra whitespace.
 // If the group is empty or no nodes produce output, it returns empty results.
-func (g NodeGroup) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+func (g NodeGroup) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	return g.acceptResult(translator, p, false)
+}
+
+// acceptResult processes all nodes in the group and combines their results.
+// If compact is true, no space is inserted between the node outputs.
+func (g NodeGroup) acceptResult(translator driver.Translator, p Parameter, compact bool) (AcceptResult, error) {
 	// Return early if group is empty
 	nodeLength := len(g)
 	switch nodeLength {
 	case 0:
-		return "", nil, nil
+		return AcceptResult{}, nil
 	case 1:
-		return g[0].Accept(translator, p)
+		return AcceptNode(g[0], translator, p)
 	}
 
 	var builder = getStringBuilder()
@@ -116,35 +221,109 @@ func (g NodeGroup) Accept(translator driver.Translator, p Parameter) (query stri
 	builder.Grow(nodeLength * 4)
 
 	// Pre-allocate args slice to avoid reallocations
-	args = make([]any, 0, nodeLength)
+	result := AcceptResult{Args: make([]any, 0, nodeLength)}
 
 	lastIdx := nodeLength - 1
 
 	// Process each node in the group
 	for i, node := range g {
-		q, a, err := node.Accept(translator, p)
+		r, err := AcceptNode(node, translator, p)
 		if err != nil {
-			return "", nil, err
+			return AcceptResult{}, err
 		}
-		if len(q) > 0 {
+		if q := r.Query; len(q) > 0 {
 			builder.WriteString(q)
 
 			// Add space between nodes, but not after the last one
-			if i < lastIdx && !strings.HasSuffix(q, " ") {
+			if !compact && i < lastIdx && !strings.HasSuffix(q, " ") {
 				builder.WriteString(" ")
 			}
 		}
-		if len(a) > 0 {
-			args = append(args, a...)
-		}
+		result.append(r)
 	}
 
 	// Return empty results if no content was generated
 	if builder.Len() == 0 {
-		return "", nil, nil
+		return AcceptResult{}, nil
+	}
+
+	result.Query = strings.TrimSpace(builder.String())
+	return result, nil
+}
+
+// compactNodeGroup collapses the runs of adjacent pureTextNodes in the group and in its
+// children into single pureTextNodes, which saves the node traversal and the builder writes
+// of the static text every time the statement is built.
+// The texts are joined with a space like NodeGroup.Accept does, so the output is not changed.
+func compactNodeGroup(group NodeGroup) NodeGroup {
+	compacted := make(NodeGroup, 0, len(group))
+	for _, node := range group {
+		compactNode(node)
+		if text, ok := node.(pureTextNode); ok && len(compacted) > 0 {
+			if last, ok := compacted[len(compacted)-1].(pureTextNode); ok {
+				compacted[len(compacted)-1] = joinPureTextNode(last, text)
+				continue
+			}
+		}
+		compacted = append(compacted, node)
+	}
+	return scopeBindNodes(compacted, false)
+}
+
+// joinPureTextNode joins two adjacent pureTextNodes as NodeGroup.Accept does.
+func joinPureTextNode(left, right pureTextNode) pureTextNode {
+	switch {
+	case len(right) == 0:
+		return left
+	case len(left) == 0:
+		return right
+	case strings.HasSuffix(string(left), " "):
+		return left + right
+	default:
+		return left + " " + right
 	}
+}
 
-	return strings.TrimSpace(builder.String()), args, nil
+// compactNode compacts the children of the given node.
+// ForeachNode joins its children without spaces, so only the children of them are compacted.
+// IncludeNode is skipped, since the SQLNode it refers to is compacted by itself.
+func compactNode(node Node) {
+	switch n := node.(type) {
+	case *ConditionNode:
+		n.Nodes = compactNodeGroup(n.Nodes)
+	case *WhereNode:
+		n.Nodes = compactNodeGroup(n.Nodes)
+	case *TrimNode:
+		// the texts of a compact TrimNode must not be joined with spaces.
+		if n.Compact {
+			for _, child := range n.Nodes {
+				compactNode(child)
+			}
+			n.Nodes = scopeBindNodes(n.Nodes, true)
+		} else {
+			n.Nodes = compactNodeGroup(n.Nodes)
+		}
+	case *SetNode:
+		n.Nodes = compactNodeGroup(n.Nodes)
+	case *OtherwiseNode:
+		n.Nodes = compactNodeGroup(n.Nodes)
+	case *BlockNode:
+		n.Nodes = compactNodeGroup(n.Nodes)
+	case *SQLNode:
+		n.nodes = compactNodeGroup(n.nodes)
+	case *ForeachNode:
+		for _, child := range n.Nodes {
+			compactNode(child)
+		}
+		n.Nodes = scopeBindNodes(n.Nodes, true)
+	case *ChooseNode:
+		for _, child := range n.WhenNodes {
+			compactNode(child)
+		}
+		if n.OtherwiseNode != nil {
+			compactNode(n.OtherwiseNode)
+		}
+	}
 }
 
 // pureTextNode is a node of pure text.
@@ -158,6 +337,11 @@ func (p pureTextNode) Accept(_ driver.Translator, _ Parameter) (query string, ar
 	return string(p), nil, nil
 }
 
+// AcceptResult implements ResultAcceptor.
+func (p pureTextNode) AcceptResult(_ driver.Translator, _ Parameter) (AcceptResult, error) {
+	return AcceptResult{Query: string(p)}, nil
+}
+
 var _ Node = (*TextNode)(nil)
 
 // TextNode is a node of text.
@@ -166,32 +350,39 @@ var _ Node = (*TextNode)(nil)
 // pureTextNode is used to avoid unnecessary parameter replacement.
 type TextNode struct {
 	value            string
-	placeholder      [][]string // for example, #{id}
+	placeholder      [][]string // for example, #{id} or #{settings,typeHandler=json}
 	textSubstitution [][]string // for example, ${id}
 }
 
 // Accept accepts parameters and returns query and arguments.
 // Accept implements Node interface.
 func (c *TextNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(c.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (c *TextNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
 	// If there is no parameter, return the value as it is.
 	if len(c.placeholder) == 0 && len(c.textSubstitution) == 0 {
-		return c.value, nil, nil
+		return AcceptResult{Query: c.value}, nil
 	}
 	// Otherwise, replace the parameter with a placeholder.
-	query, args, err = c.replaceHolder(c.value, args, translator, p)
+	result, err := c.replaceHolder(c.value, translator, p)
 	if err != nil {
-		return "", nil, err
+		return AcceptResult{}, err
 	}
-	query, err = c.replaceTextSubstitution(query, p)
+	result.Query, err = c.replaceTextSubstitution(result.Query, p)
 	if err != nil {
-		return "", nil, err
+		return AcceptResult{}, err
 	}
-	return query, args, nil
+	return result, nil
 }
 
-func (c *TextNode) replaceHolder(query string, args []any, translator driver.Translator, p Parameter) (string, []any, error) {
+// replaceHolder replaces the placeholders with the translated ones,
+// and records the arguments together with the names which produced them.
+func (c *TextNode) replaceHolder(query string, translator driver.Translator, p Parameter) (AcceptResult, error) {
 	if len(c.placeholder) == 0 {
-		return query, args, nil
+		return AcceptResult{Query: query}, nil
 	}
 
 	builder := getStringBuilder()
@@ -199,18 +390,20 @@ func (c *TextNode) replaceHolder(query string, args []any, translator driver.Tra
 	builder.Grow(len(query))
 
 	lastIndex := 0
-	newArgs := make([]any, 0, len(args)+len(c.placeholder))
-	newArgs = append(newArgs, args...)
+	result := AcceptResult{
+		Args:  make([]any, 0, len(c.placeholder)),
+		Names: make([]string, 0, len(c.placeholder)),
+	}
 
 	for _, param := range c.placeholder {
-		if len(param) != 2 {
-			return "", nil, fmt.Errorf("invalid parameter %v", param)
+		if len(param) != 3 {
+			return AcceptResult{}, fmt.Errorf("invalid parameter %v", param)
 		}
-		matched, name := param[0], param[1]
+		matched, name, typeHandler := param[0], param[1], param[2]
 
 		value, exists := p.Get(name)
 		if !exists {
-			return "", nil, fmt.Errorf("parameter %s not found", name)
+			return AcceptResult{}, fmt.Errorf("%w: %s", ErrParamNotFound, name)
 		}
 
 		pos := strings.Index(query[lastIndex:], matched)
@@ -223,11 +416,17 @@ func (c *TextNode) replaceHolder(query string, args []any, translator driver.Tra
 		builder.WriteString(translator.Translate(name))
 		lastIndex = pos + len(matched)
 
-		newArgs = append(newArgs, value.Interface())
+		arg, err := bindArg(p, typeHandler, name, value)
+		if err != nil {
+			return AcceptResult{}, err
+		}
+		result.Args = append(result.Args, arg)
+		result.Names = append(result.Names, name)
 	}
 
 	builder.WriteString(query[lastIndex:])
-	return builder.String(), newArgs, nil
+	result.Query = builder.String()
+	return result, nil
 }
 
 // replaceTextSubstitution replaces text substitution.
@@ -242,14 +441,22 @@ func (c *TextNode) replaceTextSubstitution(query string, p Parameter) (string, e
 
 	lastIndex := 0
 	for _, sub := range c.textSubstitution {
-		if len(sub) != 2 {
+		if len(sub) != 3 {
 			return "", fmt.Errorf("invalid text substitution %v", sub)
 		}
-		matched, name := sub[0], sub[1]
+		matched, name, spec := sub[0], sub[1], sub[2]
 
-		value, exists := p.Get(name)
-		if !exists {
-			return "", fmt.Errorf("parameter %s not found", name)
+		value, err := textSubstitutionValue(p, name)
+		if err != nil {
+			return "", err
+		}
+
+		text, err := formatTextSubstitution(value, spec)
+		if err != nil {
+			return "", fmt.Errorf("text substitution %s: %w", matched, err)
+		}
+		if err = validateTextSubstitution(name, text); err != nil {
+			return "", err
 		}
 
 		pos := strings.Index(query[lastIndex:], matched)
@@ -259,7 +466,7 @@ func (c *TextNode) replaceTextSubstitution(query string, p Parameter) (string, e
 		pos += lastIndex
 
 		builder.WriteString(query[lastIndex:pos])
-		builder.WriteString(reflectValueToString(value))
+		builder.WriteString(text)
 		lastIndex = pos + len(matched)
 	}
 
@@ -309,14 +516,19 @@ func (c *ConditionNode) Parse(test string) (err error) {
 // Accept accepts parameters and returns query and arguments.
 // Accept implements Node interface.
 func (c *ConditionNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(c.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (c *ConditionNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
 	matched, err := c.Match(p)
 	if err != nil {
-		return "", nil, err
+		return AcceptResult{}, err
 	}
 	if !matched {
-		return "", nil, nil
+		return AcceptResult{}, nil
 	}
-	return c.Nodes.Accept(translator, p)
+	return c.Nodes.AcceptResult(translator, p)
 }
 
 // Match evaluates if the condition is true based on the provided parameter.
@@ -325,11 +537,16 @@ func (c *ConditionNode) Accept(translator driver.Translator, p Parameter) (query
 //   - Integers (signed/unsigned): returns true if non-zero
 //   - Floats: returns true if non-zero
 //   - String: returns true if non-empty
+//
+// The values held by interfaces, like the values of H, are unwrapped first.
 func (c *ConditionNode) Match(p Parameter) (bool, error) {
 	value, err := c.expr.Execute(p)
 	if err != nil {
 		return false, err
 	}
+	for value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
 	switch value.Kind() {
 	case reflect.Bool:
 		return value.Bool(), nil
@@ -342,7 +559,7 @@ func (c *ConditionNode) Match(p Parameter) (bool, error) {
 	case reflect.String:
 		return value.String() != "", nil
 	default:
-		return false, fmt.Errorf("unsupported type %s", value.Kind())
+		return false, fmt.Errorf("%w: %s", ErrUnsupportedType, value.Kind())
 	}
 }
 
@@ -367,46 +584,114 @@ type IfNode = ConditionNode
 
 var _ Node = (*IfNode)(nil)
 
+// DistinctNode is an alias for ConditionNode, which renders the DISTINCT keyword if its condition is true,
+// so the query shape can be toggled by a parameter without a text substitution.
+//
+// Example usage:
+//
+//	SELECT <distinct test="unique == true"/> city FROM users
+//
+// Output: "SELECT DISTINCT city FROM users" if unique is true, otherwise "SELECT city FROM users".
+type DistinctNode = ConditionNode
+
 // WhereNode represents a SQL WHERE clause and its conditions.
 // It manages a group of condition nodes that form the complete WHERE clause.
 type WhereNode struct {
 	Nodes NodeGroup
+
+	// PrefixOverrides is the set of the leading tokens stripped from the conditions,
+	// AND and OR are stripped if it is nil.
+	PrefixOverrides []string
+
+	// Default is the fragment used when all the conditions are empty, it is prefixed by WHERE as well.
+	// Nothing is output if it is empty too.
+	Default string
 }
 
+// defaultWherePrefixOverrides is the leading tokens stripped by the WhereNode by default.
+var defaultWherePrefixOverrides = []string{"AND", "OR"}
+
 // Accept processes the WHERE clause and its conditions.
+func (w WhereNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(w.AcceptResult(translator, p))
+}
+
+// AcceptResult processes the WHERE clause and its conditions.
 // It handles several special cases:
-//  1. Removes leading "AND" or "OR" from the first condition
+//  1. Removes the leading token of the PrefixOverrides from the first condition
 //  2. Ensures the clause starts with "WHERE" if not already present
 //  3. Properly handles spacing between conditions
 //
+// At most one leading token is stripped, and the tokens are matched case-insensitively.
+// A token of letters, like AND, must be followed by a whitespace, so "ORDER" or "android" are kept,
+// while the other tokens, like the comma, are stripped as they are.
+// The whitespace after the stripped token is removed too.
+//
 // Examples:
 //
 //	Input:  "AND id = ?"        -> Output: "WHERE id = ?"
-//	Input:  "OR name = ?"       -> Output: "WHERE name = ?"
+//	Input:  "or name = ?"       -> Output: "WHERE name = ?"
 //	Input:  "WHERE age > ?"     -> Output: "WHERE age > ?"
 //	Input:  "status = ?"        -> Output: "WHERE status = ?"
-func (w WhereNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
-	query, args, err = w.Nodes.Accept(translator, p)
+//	Input:  ", status = ?"      -> Output: "WHERE status = ?" (with "," in the PrefixOverrides)
+//
+// The tokens can be configured by the prefixOverrides attribute, which replaces the default ones:
+//
+//	<where prefixOverrides="AND|OR|,">
+//
+// If all the conditions are empty, the default attribute is output instead, like the otherwise of the choose:
+//
+//	<where default="deleted_at IS NULL">
+func (w WhereNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	result, err := w.Nodes.AcceptResult(translator, p)
 	if err != nil {
-		return "", nil, err
+		return AcceptResult{}, err
 	}
 
+	query := result.Query
 	if query == "" {
-		return "", args, nil
+		if w.Default == "" {
+			return result, nil
+		}
+		query = w.Default
 	}
-	// A space is required at the end; otherwise, it is meaningless.
-	switch {
-	case strings.HasPrefix(query, "and ") || strings.HasPrefix(query, "AND "):
-		query = query[4:]
-	case strings.HasPrefix(query, "or ") || strings.HasPrefix(query, "OR "):
-		query = query[3:]
+	prefixOverrides := w.PrefixOverrides
+	if prefixOverrides == nil {
+		prefixOverrides = defaultWherePrefixOverrides
 	}
+	query = trimWherePrefix(query, prefixOverrides)
 
 	// A space is required at the end; otherwise, it is meaningless.
 	if !(strings.HasPrefix(query, "where ") || strings.HasPrefix(query, "WHERE ")) {
 		query = "WHERE " + query
 	}
-	return
+	result.Query = query
+	return result, nil
+}
+
+// trimWherePrefix strips the first matched token of the prefixOverrides from the query.
+func trimWherePrefix(query string, prefixOverrides []string) string {
+	for _, prefix := range prefixOverrides {
+		if prefix == "" || len(query) < len(prefix) || !strings.EqualFold(query[:len(prefix)], prefix) {
+			continue
+		}
+		rest := query[len(prefix):]
+		if isWordToken(prefix) && (rest == "" || !unicode.IsSpace(rune(rest[0]))) {
+			continue
+		}
+		return strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	return query
+}
+
+// isWordToken reports whether the token consists of letters only.
+func isWordToken(token string) bool {
+	for _, r := range token {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
 }
 
 var _ Node = (*WhereNode)(nil)
@@ -421,6 +706,7 @@ var _ Node = (*WhereNode)(nil)
 //   - PrefixOverrides: Strings to remove if found at the start
 //   - Suffix: String to append to the result if content exists
 //   - SuffixOverrides: Strings to remove if found at the end
+//   - Compact: Do not insert spaces between the child nodes
 //
 // Common use cases:
 //  1. Removing leading AND/OR from WHERE clauses
@@ -442,23 +728,41 @@ var _ Node = (*WhereNode)(nil)
 //
 //	Input:  "AND id = ? AND name = ?"
 //	Output: "WHERE id = ? AND name = ?"
+//
+// By default a space is inserted between the outputs of the child nodes.
+// When Compact is set, the outputs are concatenated as they are, which gives
+// precise control over the whitespace, like for function call arguments:
+//
+//	<trim prefix="CONCAT(" suffix=")" suffixOverrides="," compact="true">
+//	  <if test='first != ""'>#{first},</if>
+//	  <if test='last != ""'>#{last},</if>
+//	</trim>
+//
+// Output: "CONCAT(?,?)"
 type TrimNode struct {
 	Nodes           NodeGroup
 	Prefix          string
 	PrefixOverrides []string
 	Suffix          string
 	SuffixOverrides []string
+	Compact         bool
 }
 
 // Accept accepts parameters and returns query and arguments.
 func (t TrimNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
-	query, args, err = t.Nodes.Accept(translator, p)
+	return unpackAcceptResult(t.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (t TrimNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	result, err := t.Nodes.acceptResult(translator, p, t.Compact)
 	if err != nil {
-		return "", nil, err
+		return AcceptResult{}, err
 	}
 
+	query := result.Query
 	if len(query) == 0 {
-		return "", nil, nil
+		return AcceptResult{}, nil
 	}
 
 	// Handle prefix overrides before adding prefix
@@ -495,7 +799,8 @@ func (t TrimNode) Accept(translator driver.Translator, p Parameter) (query strin
 		builder.WriteString(t.Suffix)
 	}
 
-	return builder.String(), args, nil
+	result.Query = builder.String()
+	return result, nil
 }
 
 var _ Node = (*TrimNode)(nil)
@@ -512,6 +817,13 @@ var _ Node = (*TrimNode)(nil)
 //   - Open: String to prepend before the iteration results
 //   - Close: String to append after the iteration results
 //   - Separator: String to insert between iterations
+//   - SplitSize: Maximum number of placeholders in one group (optional)
+//   - Collection2: Expression to get the second collection to zip with (optional)
+//   - Item2: Variable name for the current item of the second collection
+//   - Nullable: Whether a missing or nil collection outputs nothing (optional)
+//
+// The collection can be a dotted path into the parameter, like user.Roles, and the collections
+// held by interfaces or pointers are iterated as they are.
 //
 // Example XML:
 //
@@ -519,6 +831,37 @@ var _ Node = (*TrimNode)(nil)
 //	  #{item}
 //	</foreach>
 //
+// Some databases limit the number of parameters of a statement, like the 2100 of SQL Server.
+// When the items emit more placeholders than SplitSize, the items are split into groups emitting
+// at most SplitSize placeholders each, counted from the rendered items.
+// Each group is wrapped by open and close, and the groups are joined with OR in parentheses,
+// or with AND if open is negated, like "id NOT IN (", so the query keeps its meaning.
+// So open must contain the column to keep the query semantically equivalent, which is validated
+// by the parser, an open without any text before its parentheses, like "(", is rejected:
+//
+//	<foreach collection="ids" item="id" open="id IN (" separator="," close=")" splitSize="1000">
+//	  #{id}
+//	</foreach>
+//
+// Output for 2500 ids: "(id IN (?,...) OR id IN (?,...) OR id IN (?,...))"
+//
+// Two slices of the same length can be iterated in lockstep by Collection2 and Item2,
+// which is useful to build the CASE expressions of bulk conditional updates:
+//
+//	UPDATE user SET name = CASE
+//	<foreach collection="ids" item="id" collection2="names" item2="name" separator=" ">
+//	  WHEN id = #{id} THEN #{name}
+//	</foreach>
+//	END
+//
+// The inner nodes can tell the first and the last items by the ForeachFirstKey and ForeachLastKey
+// variables, which are reserved and can not be used as the item or the index.
+// When the items are split by SplitSize, they refer to the first and the last items of each group.
+//
+//	<foreach collection="columns" item="column">
+//	  #{column}<if test="!__last__">,</if>
+//	</foreach>
+//
 // Usage scenarios:
 //
 //  1. IN clauses:
@@ -548,54 +891,262 @@ type ForeachNode struct {
 	Open       string
 	Close      string
 	Separator  string
+	SplitSize  int
+
+	Collection2 string
+	Item2       string
+
+	// Nullable makes a missing or nil collection output nothing like an empty one,
+	// instead of returning ErrCollectionNotFound and ErrUnsupportedType.
+	Nullable bool
+}
+
+const (
+	// ForeachFirstKey is the name of the variable which is true for the first item of a foreach.
+	ForeachFirstKey = "__first__"
+	// ForeachLastKey is the name of the variable which is true for the last item of a foreach.
+	ForeachLastKey = "__last__"
+)
+
+// check checks the open, close and separator of the foreach node, which catches the authoring mistakes
+// that produce malformed SQL, like an open "(" without a close ")".
+// The parentheses opened by open must be closed by close, and the separator must be balanced itself
+// and must not contain any placeholder, which would be bound to nothing.
+// The item and the index must not be the reserved names of ForeachFirstKey and ForeachLastKey.
+func (f ForeachNode) check() error {
+	for _, name := range []string{f.Item, f.Index, f.Item2} {
+		if name == ForeachFirstKey || name == ForeachLastKey {
+			return fmt.Errorf("foreach: %s is a reserved name", name)
+		}
+	}
+	if depth := parenthesesDepth(f.Open) + parenthesesDepth(f.Close); depth != 0 {
+		return fmt.Errorf("foreach: unbalanced parentheses in open %q and close %q", f.Open, f.Close)
+	}
+	if parenthesesDepth(f.Separator) != 0 {
+		return fmt.Errorf("foreach: unbalanced parentheses in separator %q", f.Separator)
+	}
+	// the groups split by SplitSize are joined with OR or AND, which only keeps the meaning if each of them names the column.
+	if f.SplitSize > 0 && strings.TrimSpace(strings.TrimRight(f.Open, "( \t\r\n")) == "" {
+		return fmt.Errorf("foreach: splitSize requires open to contain the column, like open=\"id IN (\", got %q", f.Open)
+	}
+	if strings.Contains(f.Separator, "?") || strings.Contains(f.Separator, "#{") || strings.Contains(f.Separator, "${") {
+		return fmt.Errorf("foreach: separator %q must not contain placeholders", f.Separator)
+	}
+	return nil
+}
+
+// parenthesesDepth returns the number of the opening parentheses minus the closing ones of the text.
+func parenthesesDepth(text string) int {
+	return strings.Count(text, "(") - strings.Count(text, ")")
 }
 
 // Accept accepts parameters and returns query and arguments.
 func (f ForeachNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(f.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (f ForeachNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
 
 	// if item already exists
 	if _, exists := p.Get(f.Item); exists {
-		return "", nil, fmt.Errorf("item %s already exists", f.Item)
+		return AcceptResult{}, fmt.Errorf("item %s already exists", f.Item)
 	}
 
 	// one collection from parameter
 	value, exists := p.Get(f.Collection)
 	if !exists {
-		return "", nil, fmt.Errorf("collection %s not found", f.Collection)
+		if f.Nullable {
+			return AcceptResult{}, nil
+		}
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrCollectionNotFound, f.Collection)
 	}
 
 	// if valueItem can not be iterated
 	if !value.CanInterface() {
-		return "", nil, fmt.Errorf("collection %s can not be iterated", f.Collection)
+		return AcceptResult{}, fmt.Errorf("%w: collection %s can not be iterated", ErrUnsupportedType, f.Collection)
 	}
 
-	// if valueItem is not a slice
-	for value.Kind() == reflect.Interface {
-		value = value.Elem()
+	// the collection may be held by interfaces or pointers, like a field of any type
+	// or a pointer to a slice, which are resolved to the collection itself.
+	value = reflectlite.Unwrap(value)
+
+	if f.Nullable && !value.IsValid() {
+		return AcceptResult{}, nil
+	}
+
+	if f.Collection2 != "" {
+		return f.acceptZip(value, translator, p)
 	}
 
 	switch value.Kind() {
 	case reflect.Array, reflect.Slice:
-		return f.acceptSlice(value, translator, p)
+		return f.acceptSlice(value, reflect.Value{}, translator, p)
 	case reflect.Map:
 		return f.acceptMap(value, translator, p)
 	default:
-		return "", nil, fmt.Errorf("collection %s is not a slice or map", f.Collection)
+		return AcceptResult{}, fmt.Errorf("%w: collection %s is not a slice or map", ErrUnsupportedType, f.Collection)
 	}
 }
 
-func (f ForeachNode) acceptSlice(value reflect.Value, translator driver.Translator, p Parameter) (query string, args []any, err error) {
+// acceptZip accepts the items of the collection and the second collection in lockstep.
+func (f ForeachNode) acceptZip(value reflect.Value, translator driver.Translator, p Parameter) (AcceptResult, error) {
+	if _, exists := p.Get(f.Item2); exists {
+		return AcceptResult{}, fmt.Errorf("item %s already exists", f.Item2)
+	}
+	value2, exists := p.Get(f.Collection2)
+	if !exists {
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrCollectionNotFound, f.Collection2)
+	}
+	if !value2.CanInterface() {
+		return AcceptResult{}, fmt.Errorf("%w: collection %s can not be iterated", ErrUnsupportedType, f.Collection2)
+	}
+	value2 = reflectlite.Unwrap(value2)
+	for _, collection := range []struct {
+		name  string
+		value reflect.Value
+	}{{f.Collection, value}, {f.Collection2, value2}} {
+		switch collection.value.Kind() {
+		case reflect.Array, reflect.Slice:
+		default:
+			return AcceptResult{}, fmt.Errorf("%w: collection %s is not a slice", ErrUnsupportedType, collection.name)
+		}
+	}
+	if value.Len() != value2.Len() {
+		return AcceptResult{}, fmt.Errorf("collection %s and %s have different lengths: %d != %d",
+			f.Collection, f.Collection2, value.Len(), value2.Len())
+	}
+	return f.acceptSlice(value, value2, translator, p)
+}
+
+// acceptSlice accepts the items of the slice.
+// If value2 is valid, its items are zipped with the items of the slice.
+func (f ForeachNode) acceptSlice(value, value2 reflect.Value, translator driver.Translator, p Parameter) (AcceptResult, error) {
 	sliceLength := value.Len()
 
 	if sliceLength == 0 {
-		return "", nil, nil
+		return AcceptResult{}, nil
+	}
+
+	if f.SplitSize <= 0 {
+		return f.acceptRange(value, value2, 0, sliceLength, translator, p, nil)
+	}
+
+	// the items are rendered as one group with the placeholders recorded, which is the real output
+	// if they are not split, and the placeholders of each item are counted from it.
+	recorder := &recordingTranslator{}
+	counts := make([]int, 0, sliceLength)
+	whole, err := f.acceptRange(value, value2, 0, sliceLength, recorder, p, &counts)
+	if err != nil {
+		return AcceptResult{}, err
+	}
+	bounds, err := f.splitBounds(counts)
+	if err != nil {
+		return AcceptResult{}, err
 	}
+	if len(bounds) == 1 {
+		whole.Query = recorder.replay(whole.Query, translator)
+		return whole, nil
+	}
+
+	joiner := " OR "
+	if negatedOpenRegexp.MatchString(f.Open) {
+		joiner = " AND "
+	}
+
+	var builder = getStringBuilder()
+	defer putStringBuilder(builder)
+
+	builder.WriteString("(")
+
+	var result AcceptResult
+
+	start := 0
+	for i, end := range bounds {
+		r, err := f.acceptRange(value, value2, start, end, translator, p, nil)
+		if err != nil {
+			return AcceptResult{}, err
+		}
+		// the items may render differently in their groups, like by __first__ and __last__.
+		if len(r.Args) > f.SplitSize {
+			return AcceptResult{}, fmt.Errorf("foreach: group %d of %s emits %d placeholders, more than the splitSize %d", i, f.Collection, len(r.Args), f.SplitSize)
+		}
+		if i > 0 {
+			builder.WriteString(joiner)
+		}
+		builder.WriteString(r.Query)
+		result.append(r)
+		start = end
+	}
+
+	builder.WriteString(")")
+
+	result.Query = builder.String()
+	return result, nil
+}
+
+// negatedOpenRegexp matches the negated open of the foreach, whose split groups must all hold.
+var negatedOpenRegexp = regexp.MustCompile(`(?i)\bnot\b|<>|!=`)
+
+// placeholderMarker is the marker of the placeholders recorded by recordingTranslator.
+const placeholderMarker = "\x00"
+
+// recordingTranslator records the placeholders and emits a marker for each of them,
+// which are translated by replay in their order.
+type recordingTranslator struct {
+	names []string
+}
+
+// Translate implements driver.Translator.
+func (r *recordingTranslator) Translate(name string) string {
+	r.names = append(r.names, name)
+	return placeholderMarker
+}
+
+// replay translates the recorded placeholders of the query by the translator.
+func (r *recordingTranslator) replay(query string, translator driver.Translator) string {
+	var builder strings.Builder
+	for _, name := range r.names {
+		index := strings.Index(query, placeholderMarker)
+		if index < 0 {
+			break
+		}
+		builder.WriteString(query[:index])
+		builder.WriteString(translator.Translate(name))
+		query = query[index+len(placeholderMarker):]
+	}
+	builder.WriteString(query)
+	return builder.String()
+}
+
+// splitBounds returns the exclusive ends of the groups of the items by the placeholders of each item,
+// each group emitting at most SplitSize placeholders.
+func (f ForeachNode) splitBounds(counts []int) ([]int, error) {
+	var bounds []int
+	var placeholders int
+	for i, count := range counts {
+		if count > f.SplitSize {
+			return nil, fmt.Errorf("foreach: item %d of %s emits %d placeholders, more than the splitSize %d", i, f.Collection, count, f.SplitSize)
+		}
+		if placeholders+count > f.SplitSize {
+			bounds = append(bounds, i)
+			placeholders = 0
+		}
+		placeholders += count
+	}
+	return append(bounds, len(counts)), nil
+}
+
+// acceptRange accepts the items of the slice in the range [start, end).
+// If counts is not nil, the number of the placeholders of each item is appended to it.
+func (f ForeachNode) acceptRange(value, value2 reflect.Value, start, end int, translator driver.Translator, p Parameter, counts *[]int) (AcceptResult, error) {
+	sliceLength := end - start
 
 	// Pre-allocate args slice capacity to avoid multiple growths
 	// Estimate: number of slice elements * number of nodes
 	estimatedArgsLen := sliceLength * len(f.Nodes)
 
-	args = make([]any, 0, estimatedArgsLen)
+	result := AcceptResult{Args: make([]any, 0, estimatedArgsLen)}
 
 	// Pre-allocate string builder capacity to minimize buffer reallocations
 	// Capacity = open + items + separators + close
@@ -608,36 +1159,43 @@ func (f ForeachNode) acceptSlice(value reflect.Value, translator driver.Translat
 
 	builder.WriteString(f.Open)
 
-	end := sliceLength - 1
+	last := end - 1
 
-	h := make(eval.H, 2)
+	h := make(eval.H, 5)
 
 	// Create and reuse GenericParameter outside the loop to avoid allocations per iteration
 	genericParameter := &eval.GenericParameter{Value: reflect.ValueOf(h)}
 
-	group := eval.ParamGroup{genericParameter, p}
+	group := scopeParameter(p, genericParameter)
 
-	for i := 0; i < sliceLength; i++ {
+	for i := start; i < end; i++ {
 
 		item := value.Index(i).Interface()
 
 		h[f.Item] = item
 		h[f.Index] = i
+		h[ForeachFirstKey] = i == start
+		h[ForeachLastKey] = i == last
+		if value2.IsValid() {
+			h[f.Item2] = value2.Index(i).Interface()
+		}
 
+		placeholders := len(result.Args)
 		for _, node := range f.Nodes {
-			q, a, err := node.Accept(translator, group)
+			r, err := AcceptNode(node, translator, group)
 			if err != nil {
-				return "", nil, err
-			}
-			if len(q) > 0 {
-				builder.WriteString(q)
+				return AcceptResult{}, err
 			}
-			if len(a) > 0 {
-				args = append(args, a...)
+			if len(r.Query) > 0 {
+				builder.WriteString(r.Query)
 			}
+			result.append(r)
+		}
+		if counts != nil {
+			*counts = append(*counts, len(result.Args)-placeholders)
 		}
 
-		if i < end {
+		if i < last {
 			builder.WriteString(f.Separator)
 		}
 		genericParameter.Clear()
@@ -646,21 +1204,25 @@ func (f ForeachNode) acceptSlice(value reflect.Value, translator driver.Translat
 	// if sliceLength is not zero, add close
 	builder.WriteString(f.Close)
 
-	return builder.String(), args, nil
+	result.Query = builder.String()
+	return result, nil
 }
 
-func (f ForeachNode) acceptMap(value reflect.Value, translator driver.Translator, p Parameter) (query string, args []any, err error) {
+// acceptMap accepts the entries of the map in the order of the keys if they are ordered,
+// see sortMapKeys.
+func (f ForeachNode) acceptMap(value reflect.Value, translator driver.Translator, p Parameter) (AcceptResult, error) {
 	keys := value.MapKeys()
+	sortMapKeys(keys)
 
 	if len(keys) == 0 {
-		return "", nil, nil
+		return AcceptResult{}, nil
 	}
 
 	// Pre-allocate args slice capacity to avoid multiple growths
 	// Estimate: number of slice elements * number of nodes
 	estimatedArgsLen := len(keys) * len(f.Nodes)
 
-	args = make([]any, 0, estimatedArgsLen)
+	result := AcceptResult{Args: make([]any, 0, estimatedArgsLen)}
 
 	// Pre-allocate string builder capacity to minimize buffer reallocations
 	// Capacity = open + items + separators + close
@@ -677,12 +1239,12 @@ func (f ForeachNode) acceptMap(value reflect.Value, translator driver.Translator
 
 	var index int
 
-	h := make(eval.H, 2)
+	h := make(eval.H, 4)
 
 	// Create and reuse GenericParameter outside the loop to avoid allocations per iteration
 	genericParameter := &eval.GenericParameter{Value: reflect.ValueOf(h)}
 
-	group := eval.ParamGroup{genericParameter, p}
+	group := scopeParameter(p, genericParameter)
 
 	for _, key := range keys {
 
@@ -690,18 +1252,18 @@ func (f ForeachNode) acceptMap(value reflect.Value, translator driver.Translator
 
 		h[f.Item] = item
 		h[f.Index] = key.Interface()
+		h[ForeachFirstKey] = index == 0
+		h[ForeachLastKey] = index == end
 
 		for _, node := range f.Nodes {
-			q, a, err := node.Accept(translator, group)
+			r, err := AcceptNode(node, translator, group)
 			if err != nil {
-				return "", nil, err
-			}
-			if len(q) > 0 {
-				builder.WriteString(q)
+				return AcceptResult{}, err
 			}
-			if len(a) > 0 {
-				args = append(args, a...)
+			if len(r.Query) > 0 {
+				builder.WriteString(r.Query)
 			}
+			result.append(r)
 		}
 
 		if index < end {
@@ -715,7 +1277,8 @@ func (f ForeachNode) acceptMap(value reflect.Value, translator driver.Translator
 
 	builder.WriteString(f.Close)
 
-	return builder.String(), args, nil
+	result.Query = builder.String()
+	return result, nil
 }
 
 var _ Node = (*ForeachNode)(nil)
@@ -758,28 +1321,171 @@ var _ Node = (*ForeachNode)(nil)
 // Note: The node automatically handles trailing commas and ensures
 // proper formatting of the SET clause regardless of which fields
 // are included dynamically.
+//
+// For the PATCH-style updates, the assignments can be generated from a struct parameter,
+// only the fields tagged with column and holding non-zero values are set:
+//
+//	<update id="patchUser">
+//	  UPDATE users
+//	  <set param="user" presence="fields"/>
+//	  WHERE id = #{user.id}
+//	</update>
+//
+// With the namingStrategy of the statement, the untagged exported fields are set too,
+// by the column names the strategy derives from their names.
+// Presence optionally names a map[string]bool parameter of the column names,
+// the columns marked true are set even if their fields are zero, which sets the columns to zero explicitly.
+// The generated assignments come before the children of the node.
+// ErrNothingToUpdate is returned if nothing is generated at all.
 type SetNode struct {
 	Nodes NodeGroup
+
+	// Param is the name of the struct parameter to generate the assignments from.
+	Param string
+
+	// Presence is the name of the parameter of the columns which are set even if they are zero.
+	Presence string
 }
 
 // Accept accepts parameters and returns query and arguments.
 func (s SetNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
-	query, args, err = s.Nodes.Accept(translator, p)
+	return unpackAcceptResult(s.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (s SetNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	// the generated assignments come first, so they are translated before the children,
+	// which keeps the stateful translators numbering the placeholders in the order of the query.
+	var assignments AcceptResult
+	var err error
+	if s.Param != "" {
+		if assignments, err = s.assignments(translator, p); err != nil {
+			return AcceptResult{}, err
+		}
+	}
+	result, err := s.Nodes.AcceptResult(translator, p)
 	if err != nil {
-		return "", nil, err
+		return AcceptResult{}, err
+	}
+	if s.Param != "" {
+		if result, err = s.withAssignments(assignments, result); err != nil {
+			return AcceptResult{}, err
+		}
 	}
+	query := result.Query
 	if len(query) == 0 {
-		return "", args, nil
+		return result, nil
+	}
+	// Remove the trailing run of commas and whitespace in any order, which is left by the indentation
+	// and the empty nodes when the last conditional assignment is omitted.
+	query = strings.TrimRightFunc(query, isSetTrailingRune)
+	if len(query) == 0 {
+		result.Query = query
+		return result, nil
 	}
-	// Remove trailing comma
-	query = strings.TrimSuffix(query, ",")
 
 	// Ensure SET prefix if not present
 	if !(strings.HasPrefix(query, "set ") || strings.HasPrefix(query, "SET ")) {
 		query = "SET " + query
 	}
 
-	return query, args, nil
+	result.Query = query
+	return result, nil
+}
+
+// assignments generates the assignments from the struct parameter.
+func (s SetNode) assignments(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	value, exists := p.Get(s.Param)
+	if !exists {
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrParamNotFound, s.Param)
+	}
+	value = reflectlite.Unwrap(value)
+	if value.Kind() != reflect.Struct {
+		return AcceptResult{}, fmt.Errorf("%w: set param %s must be a struct, got %s", ErrUnsupportedType, s.Param, value.Kind())
+	}
+	var presence map[string]bool
+	if s.Presence != "" {
+		present, exists := p.Get(s.Presence)
+		if !exists {
+			return AcceptResult{}, fmt.Errorf("%w: %s", ErrParamNotFound, s.Presence)
+		}
+		if presence, exists = reflectlite.Unwrap(present).Interface().(map[string]bool); !exists {
+			return AcceptResult{}, fmt.Errorf("%w: set presence %s must be a map[string]bool", ErrUnsupportedType, s.Presence)
+		}
+	}
+
+	builder := getStringBuilder()
+	defer putStringBuilder(builder)
+
+	// the untagged fields are set by the column names derived by the naming strategy of the statement if any.
+	strategy := contextOf(p).namingStrategy
+
+	var result AcceptResult
+	var walk func(value reflect.Value) error
+	walk = func(value reflect.Value) error {
+		tp := value.Type()
+		for i := 0; i < tp.NumField(); i++ {
+			field := tp.Field(i)
+			tag := field.Tag.Get("column")
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+				if err := walk(value.Field(i)); err != nil {
+					return err
+				}
+				continue
+			}
+			if tag == "" && !field.Anonymous && strategy != nil {
+				tag = strategy.ColumnName(field.Name)
+			}
+			if tag == "" || tag == "-" || !field.IsExported() {
+				continue
+			}
+			fieldValue := value.Field(i)
+			if fieldValue.IsZero() && !presence[tag] {
+				continue
+			}
+			name := s.Param + "." + field.Name
+			if builder.Len() > 0 {
+				builder.WriteString(", ")
+			}
+			builder.WriteString(tag)
+			builder.WriteString(" = ")
+			builder.WriteString(translator.Translate(name))
+			arg, err := bindArg(p, "", name, fieldValue)
+			if err != nil {
+				return err
+			}
+			result.Args = append(result.Args, arg)
+			result.Names = append(result.Names, name)
+		}
+		return nil
+	}
+	if err := walk(value); err != nil {
+		return AcceptResult{}, err
+	}
+	result.Query = builder.String()
+	return result, nil
+}
+
+// withAssignments prepends the generated assignments to the result of the children.
+func (s SetNode) withAssignments(assignments, children AcceptResult) (AcceptResult, error) {
+	if len(assignments.Query) == 0 && len(children.Query) == 0 {
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrNothingToUpdate, s.Param)
+	}
+	query := assignments.Query
+	if len(children.Query) > 0 {
+		if len(query) > 0 {
+			query += ", "
+		}
+		query += strings.TrimPrefix(strings.TrimPrefix(children.Query, "set "), "SET ")
+	}
+	assignments.Query = query
+	assignments.append(children)
+	return assignments, nil
+}
+
+// isSetTrailingRune reports whether the rune is stripped from the end of the SetNode.
+func isSetTrailingRune(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
 }
 
 var _ Node = (*SetNode)(nil)
@@ -835,6 +1541,11 @@ func (s SQLNode) Accept(translator driver.Translator, p Parameter) (query string
 	return s.nodes.Accept(translator, p)
 }
 
+// AcceptResult implements ResultAcceptor.
+func (s SQLNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	return s.nodes.AcceptResult(translator, p)
+}
+
 var _ Node = (*SQLNode)(nil)
 
 // IncludeNode represents a reference to another SQL fragment, enabling SQL reuse.
@@ -884,16 +1595,30 @@ type IncludeNode struct {
 
 // Accept accepts parameters and returns query and arguments.
 func (i *IncludeNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(i.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (i *IncludeNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	sqlNode, err := i.resolve()
+	if err != nil {
+		return AcceptResult{}, err
+	}
+	return AcceptNode(sqlNode, translator, p)
+}
+
+// resolve returns the referenced SQL fragment node.
+func (i *IncludeNode) resolve() (Node, error) {
 	if i.sqlNode == nil {
 		// lazy loading
 		// does it need to be thread safe?
 		sqlNode, err := i.mapper.GetSQLNodeByID(i.refId)
 		if err != nil {
-			return "", nil, err
+			return nil, err
 		}
 		i.sqlNode = sqlNode
 	}
-	return i.sqlNode.Accept(translator, p)
+	return i.sqlNode, nil
 }
 
 var _ Node = (*IncludeNode)(nil)
@@ -950,21 +1675,26 @@ type ChooseNode struct {
 
 // Accept accepts parameters and returns query and arguments.
 func (c ChooseNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(c.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (c ChooseNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
 	for _, node := range c.WhenNodes {
-		q, a, err := node.Accept(translator, p)
+		result, err := AcceptNode(node, translator, p)
 		if err != nil {
-			return "", nil, err
+			return AcceptResult{}, err
 		}
 		// if one of when nodes is true, return query and arguments
-		if len(q) > 0 {
-			return q, a, nil
+		if len(result.Query) > 0 {
+			return result, nil
 		}
 	}
 	// if all when nodes are false, return otherwise node
 	if c.OtherwiseNode != nil {
-		return c.OtherwiseNode.Accept(translator, p)
+		return AcceptNode(c.OtherwiseNode, translator, p)
 	}
-	return "", nil, nil
+	return AcceptResult{}, nil
 }
 
 var _ Node = (*ChooseNode)(nil)
@@ -1056,6 +1786,11 @@ func (o OtherwiseNode) Accept(translator driver.Translator, p Parameter) (query
 	return o.Nodes.Accept(translator, p)
 }
 
+// AcceptResult implements ResultAcceptor.
+func (o OtherwiseNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	return o.Nodes.AcceptResult(translator, p)
+}
+
 var _ Node = (*OtherwiseNode)(nil)
 
 // valueItem is a element of ValuesNode.
@@ -1070,8 +1805,13 @@ type ValuesNode []*valueItem
 
 // Accept accepts parameters and returns query and arguments.
 func (v ValuesNode) Accept(translator driver.Translator, param Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(v.AcceptResult(translator, param))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (v ValuesNode) AcceptResult(translator driver.Translator, param Parameter) (AcceptResult, error) {
 	if len(v) == 0 {
-		return "", nil, nil
+		return AcceptResult{}, nil
 	}
 	builder := getStringBuilder()
 	defer putStringBuilder(builder)
@@ -1081,7 +1821,7 @@ func (v ValuesNode) Accept(translator driver.Translator, param Parameter) (query
 	builder.WriteString(v.values())
 	builder.WriteString(")")
 	node := NewTextNode(builder.String())
-	return node.Accept(translator, param)
+	return AcceptNode(node, translator, param)
 }
 
 // columns returns columns of values.
@@ -1102,19 +1842,386 @@ func (v ValuesNode) values() string {
 	return strings.Join(values, ", ")
 }
 
+// ParamValuesNode is a node of values generated from the exported fields of a struct parameter,
+// which saves listing every column of a simple insert by hand.
+// The columns are named by the column tags of the fields, or by the names the namingStrategy of the
+// statement derives from the names of the untagged fields, which are skipped without it, like the SetNode.
+// The fields tagged with "-" are skipped, and the untagged embedded structs are walked into.
+// A field shadowed by a shallower one of the same column is skipped, like the field promotion of Go.
+//
+//	<insert id="CreateUser">
+//	  INSERT INTO users <values param="user" keyColumn="id"/>
+//	</insert>
+//
+// Output: "INSERT INTO users (name, age) VALUES (?, ?)" for a user whose ID is zero.
+//
+// KeyColumn names the column of the auto-increment key, which is skipped when its field is zero,
+// so the database generates it. With Tagged, only the fields with column tags are used even with a namingStrategy.
+// The explicit values of the node follow the generated ones.
+// ErrNothingToInsert is returned if no column is generated at all.
+type ParamValuesNode struct {
+	// Param is the name of the struct parameter to generate the values from.
+	Param string
+
+	// KeyColumn is the column of the auto-increment key, which is skipped when its field is zero.
+	KeyColumn string
+
+	// Tagged makes only the fields with column tags used even with a namingStrategy.
+	Tagged bool
+
+	// Values is the explicit values following the generated ones.
+	Values ValuesNode
+}
+
+// Accept accepts parameters and returns query and arguments.
+func (v ParamValuesNode) Accept(translator driver.Translator, param Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(v.AcceptResult(translator, param))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (v ParamValuesNode) AcceptResult(translator driver.Translator, param Parameter) (AcceptResult, error) {
+	value, exists := param.Get(v.Param)
+	if !exists {
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrParamNotFound, v.Param)
+	}
+	value = reflectlite.Unwrap(value)
+	if value.Kind() != reflect.Struct {
+		return AcceptResult{}, fmt.Errorf("%w: values param %s must be a struct, got %s", ErrUnsupportedType, v.Param, value.Kind())
+	}
+	strategy := contextOf(param).namingStrategy
+	var values ValuesNode
+	// depths are the depths of the fields of the values, which decide the shadowed ones.
+	var depths []int
+	// the path is the field names through the embedded structs, which binds the shadowed fields too.
+	var walk func(value reflect.Value, path string, depth int)
+	walk = func(value reflect.Value, path string, depth int) {
+		tp := value.Type()
+		for i := 0; i < tp.NumField(); i++ {
+			field := tp.Field(i)
+			column := field.Tag.Get("column")
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && column == "" {
+				walk(value.Field(i), path+"."+field.Name, depth+1)
+				continue
+			}
+			if column == "" && !v.Tagged && !field.Anonymous && strategy != nil {
+				column = strategy.ColumnName(field.Name)
+			}
+			if column == "" || column == "-" || !field.IsExported() {
+				continue
+			}
+			if column == v.KeyColumn && value.Field(i).IsZero() {
+				continue
+			}
+			item := &valueItem{column: column, value: "#{" + path + "." + field.Name + "}"}
+			if index := slices.IndexFunc(values, func(other *valueItem) bool { return other.column == column }); index >= 0 {
+				if depth < depths[index] {
+					values[index], depths[index] = item, depth
+				}
+				continue
+			}
+			values = append(values, item)
+			depths = append(depths, depth)
+		}
+	}
+	walk(value, v.Param, 0)
+	values = append(values, v.Values...)
+	if len(values) == 0 {
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrNothingToInsert, v.Param)
+	}
+	return values.AcceptResult(translator, param)
+}
+
+// CaseColumn is a column set by the CaseNode.
+type CaseColumn struct {
+	// Column is the name of the column.
+	Column string
+
+	// Property is the property of the item which the column is set to.
+	Property string
+}
+
+// CaseNode is a node of the CASE expressions which set the columns of many rows to the different values
+// of the items of a collection with one statement, instead of an update per row.
+//
+//	<update id="UpdateScores">
+//	  UPDATE users SET
+//	  <case collection="users" item="user" key="id" keyProperty="ID" columns="score:Score, level:Level"/>
+//	  WHERE id IN <foreach collection="users" item="user" open="(" separator=", " close=")">#{user.ID}</foreach>
+//	</update>
+//
+// Output: "UPDATE users SET score = CASE id WHEN ? THEN ? WHEN ? THEN ? ELSE score END,
+// level = CASE id WHEN ? THEN ? WHEN ? THEN ? ELSE level END WHERE id IN (?, ?)" for two users.
+//
+// Each column is given as column:Property, or as column if the property has the same name.
+// The rows whose keys are not in the collection keep their values.
+// ErrNothingToUpdate is returned if the collection is empty.
+type CaseNode struct {
+	// Collection is the name of the collection parameter.
+	Collection string
+
+	// Item is the name of the item in the collection.
+	Item string
+
+	// Key is the column which identifies the rows.
+	Key string
+
+	// KeyProperty is the property of the item which holds the key.
+	KeyProperty string
+
+	// Columns is the columns to set.
+	Columns []CaseColumn
+}
+
+// Accept accepts parameters and returns query and arguments.
+func (c CaseNode) Accept(translator driver.Translator, param Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(c.AcceptResult(translator, param))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (c CaseNode) AcceptResult(translator driver.Translator, param Parameter) (AcceptResult, error) {
+	value, exists := param.Get(c.Collection)
+	if !exists {
+		return AcceptResult{}, fmt.Errorf("%w: %s", ErrCollectionNotFound, c.Collection)
+	}
+	for value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if kind := value.Kind(); kind != reflect.Slice && kind != reflect.Array {
+		return AcceptResult{}, fmt.Errorf("%w: collection %s is not a slice", ErrUnsupportedType, c.Collection)
+	}
+	if value.Len() == 0 {
+		return AcceptResult{}, fmt.Errorf("%w: collection %s is empty", ErrNothingToUpdate, c.Collection)
+	}
+	var result AcceptResult
+	var builder = getStringBuilder()
+	defer putStringBuilder(builder)
+
+	for i, column := range c.Columns {
+		// each column is a foreach of the WHEN clauses over the collection.
+		foreach := ForeachNode{
+			Collection: c.Collection,
+			Item:       c.Item,
+			Nodes:      []Node{NewTextNode("WHEN #{" + c.Item + "." + c.KeyProperty + "} THEN #{" + c.Item + "." + column.Property + "}")},
+			Open:       column.Column + " = CASE " + c.Key + " ",
+			Separator:  " ",
+			Close:      " ELSE " + column.Column + " END",
+		}
+		r, err := foreach.AcceptResult(translator, param)
+		if err != nil {
+			return AcceptResult{}, err
+		}
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(r.Query)
+		result.append(r)
+	}
+	result.Query = builder.String()
+	return result, nil
+}
+
+// ErrColumnNotAllowed is an error that is returned when a column requested from a columns node
+// is not in its allowed columns.
+var ErrColumnNotAllowed = errors.New("column not allowed")
+
+// ColumnsNode is a node of the columns requested by a parameter, which are checked against the
+// allowed columns of the node, so the clients can select a subset of the columns safely.
+//
+//	<select id="ListUsers">
+//	  SELECT <columns param="fields" allowed="id, name, email"/> FROM users
+//	</select>
+//
+// Output: "SELECT name, id FROM users" for the fields ["name", "id"], and
+// "SELECT id, name, email FROM users" if the fields are not given or empty.
+//
+// The parameter is a slice of the column names, or a string of the column names separated by commas.
+// The columns are emitted in the requested order and the duplicates are dropped.
+// ErrColumnNotAllowed is returned if a requested column is not allowed.
+type ColumnsNode struct {
+	// Param is the name of the parameter of the requested columns.
+	Param string
+
+	// Allowed is the columns which can be requested.
+	Allowed []string
+}
+
+// Accept accepts parameters and returns query and arguments.
+func (c ColumnsNode) Accept(translator driver.Translator, param Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(c.AcceptResult(translator, param))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (c ColumnsNode) AcceptResult(_ driver.Translator, param Parameter) (AcceptResult, error) {
+	requested, err := c.requested(param)
+	if err != nil {
+		return AcceptResult{}, err
+	}
+	if len(requested) == 0 {
+		return AcceptResult{Query: strings.Join(c.Allowed, ", ")}, nil
+	}
+	columns := make([]string, 0, len(requested))
+	for _, column := range requested {
+		if !slices.Contains(c.Allowed, column) {
+			return AcceptResult{}, fmt.Errorf("%w: %q", ErrColumnNotAllowed, column)
+		}
+		if !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+	return AcceptResult{Query: strings.Join(columns, ", ")}, nil
+}
+
+// requested returns the names of the columns requested by the parameter.
+func (c ColumnsNode) requested(param Parameter) ([]string, error) {
+	value, exists := param.Get(c.Param)
+	if !exists {
+		return nil, nil
+	}
+	value = reflectlite.Unwrap(value)
+	var columns []string
+	switch value.Kind() {
+	case reflect.Invalid:
+	case reflect.String:
+		for _, column := range strings.Split(value.String(), ",") {
+			if column = strings.TrimSpace(column); column != "" {
+				columns = append(columns, column)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			item := reflectlite.Unwrap(value.Index(i))
+			if item.Kind() != reflect.String {
+				return nil, fmt.Errorf("%w: columns param %s must contain strings, got %s", ErrUnsupportedType, c.Param, item.Kind())
+			}
+			columns = append(columns, item.String())
+		}
+	default:
+		return nil, fmt.Errorf("%w: columns param %s must be a string or a slice, got %s", ErrUnsupportedType, c.Param, value.Kind())
+	}
+	return columns, nil
+}
+
+// ErrAggregateNotAllowed is an error that is returned when an aggregate function requested from
+// an aggregate node is not in its allowed functions.
+var ErrAggregateNotAllowed = errors.New("aggregate function not allowed")
+
+// aggregateFunctions is the aggregate functions which can be rendered by the AggregateNode.
+var aggregateFunctions = []string{"COUNT", "SUM", "AVG", "MIN", "MAX"}
+
+// AggregateNode is a node of the column wrapped in the aggregate function requested by a parameter,
+// the function is checked against an enumerated set, so it can not inject anything.
+//
+//	<select id="Stats">
+//	  SELECT <aggregate param="fn" column="amount" allowed="sum, avg" default="sum" alias="value"/> FROM orders
+//	</select>
+//
+// Output: "SELECT AVG(amount) AS value FROM orders" for the fn "avg".
+//
+// The functions are COUNT, SUM, AVG, MIN and MAX, and they are matched case-insensitively.
+// Allowed restricts the functions further. The default function is used if the parameter
+// is not given or empty, and the column is rendered bare if there is no default either.
+// ErrAggregateNotAllowed is returned if the requested function is not allowed.
+type AggregateNode struct {
+	// Param is the name of the parameter of the requested function.
+	Param string
+
+	// Column is the column to aggregate.
+	Column string
+
+	// Allowed is the functions which can be requested, all the functions are allowed if empty.
+	Allowed []string
+
+	// Default is the function used if the parameter is not given.
+	Default string
+
+	// Alias is the name which the aggregated column is selected as.
+	Alias string
+}
+
+// Accept accepts parameters and returns query and arguments.
+func (a AggregateNode) Accept(translator driver.Translator, param Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(a.AcceptResult(translator, param))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (a AggregateNode) AcceptResult(_ driver.Translator, param Parameter) (AcceptResult, error) {
+	function := a.Default
+	if value, exists := param.Get(a.Param); exists {
+		value = reflectlite.Unwrap(value)
+		switch value.Kind() {
+		case reflect.Invalid:
+		case reflect.String:
+			if value.String() != "" {
+				function = value.String()
+			}
+		default:
+			return AcceptResult{}, fmt.Errorf("%w: aggregate param %s must be a string, got %s", ErrUnsupportedType, a.Param, value.Kind())
+		}
+	}
+	query := a.Column
+	if function != "" {
+		function = strings.ToUpper(strings.TrimSpace(function))
+		if !a.allows(function) {
+			return AcceptResult{}, fmt.Errorf("%w: %q", ErrAggregateNotAllowed, function)
+		}
+		query = function + "(" + a.Column + ")"
+	}
+	if a.Alias != "" {
+		query += " AS " + a.Alias
+	}
+	return AcceptResult{Query: query}, nil
+}
+
+// allows reports whether the given upper case function can be rendered.
+func (a AggregateNode) allows(function string) bool {
+	if !slices.Contains(aggregateFunctions, function) {
+		return false
+	}
+	return len(a.Allowed) == 0 || slices.ContainsFunc(a.Allowed, func(allowed string) bool {
+		return strings.EqualFold(allowed, function)
+	})
+}
+
 // selectFieldAliasItem is a element of SelectFieldAliasNode.
 type selectFieldAliasItem struct {
 	column string
 	alias  string
 }
 
+// columnName returns the name of the column which the item is selected as.
+func (s *selectFieldAliasItem) columnName() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.column
+}
+
 // SelectFieldAliasNode is a node of select field alias.
 type SelectFieldAliasNode []*selectFieldAliasItem
 
+// check checks whether the columns selected by the fields are unique,
+// otherwise it is ambiguous which field is bound to the destination.
+func (s SelectFieldAliasNode) check() error {
+	columns := make(map[string]struct{}, len(s))
+	for _, item := range s {
+		name := item.columnName()
+		if _, exists := columns[name]; exists {
+			return fmt.Errorf("%w: %s", ErrAmbiguousColumn, name)
+		}
+		columns[name] = struct{}{}
+	}
+	return nil
+}
+
 // Accept accepts parameters and returns query and arguments.
-func (s SelectFieldAliasNode) Accept(_ driver.Translator, _ Parameter) (query string, args []any, err error) {
+func (s SelectFieldAliasNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(s.AcceptResult(translator, p))
+}
+
+// AcceptResult implements ResultAcceptor.
+func (s SelectFieldAliasNode) AcceptResult(_ driver.Translator, _ Parameter) (AcceptResult, error) {
 	if len(s) == 0 {
-		return "", nil, nil
+		return AcceptResult{}, nil
 	}
 	fields := make([]string, 0, len(s))
 	for _, item := range s {
@@ -1124,12 +2231,278 @@ func (s SelectFieldAliasNode) Accept(_ driver.Translator, _ Parameter) (query st
 		}
 		fields = append(fields, field)
 	}
-	return strings.Join(fields, ", "), nil, nil
+	return AcceptResult{Query: strings.Join(fields, ", ")}, nil
 }
 
-// reflectValueToString converts reflect.Value to string
-func reflectValueToString(v reflect.Value) string {
+// BlockNode is a named region of a statement, which the statements extending
+// the statement can override by the name.
+//
+//	<select id="base">
+//	    select * from users
+//	    <block name="where">where status = 1</block>
+//	</select>
+//
+//	<select id="active" extends="base">
+//	    <block name="where" mode="append">and role = #{role}</block>
+//	</select>
+//
+// Mode is only used by the overriding blocks, see the BlockMode constants.
+type BlockNode struct {
+	Name  string
+	Mode  BlockMode
+	Nodes NodeGroup
+}
+
+// Accept accepts parameters and returns query and arguments.
+func (b *BlockNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(b.AcceptResult(translator, p))
+}
+
+// AcceptResult accepts parameters and returns the result of the nodes of the block.
+func (b *BlockNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	return b.Nodes.AcceptResult(translator, p)
+}
+
+// BlockMode is the way an overriding block merges into the block of the base statement.
+type BlockMode string
+
+const (
+	// BlockModeReplace replaces the nodes of the base block, which is the default mode.
+	BlockModeReplace BlockMode = "replace"
+	// BlockModeAppend appends the nodes after the nodes of the base block.
+	BlockModeAppend BlockMode = "append"
+	// BlockModePrepend prepends the nodes before the nodes of the base block.
+	BlockModePrepend BlockMode = "prepend"
+)
+
+// BindNode binds the value of an expression to a name, which is visible to the nodes after it
+// in the same element, like the bind element of MyBatis.
+//
+//	<bind name="pattern" value="'%' + title + '%'"/>
+//	select * from posts where title like #{pattern}
+//
+// The bound name shadows the parameter and the outer bindings with the same name.
+// Nodes is the nodes after the bind element, which the parser collects by scopeBindNodes.
+type BindNode struct {
+	Name  string
+	expr  eval.Expression
+	Nodes NodeGroup
+
+	// compact reports whether the nodes are joined without spaces, like the children of a foreach.
+	compact bool
+}
+
+// Parse compiles the expression of the value.
+func (b *BindNode) Parse(value string) (err error) {
+	b.expr, err = eval.Compile(value)
+	return err
+}
+
+// Accept accepts parameters and returns query and arguments.
+func (b *BindNode) Accept(translator driver.Translator, p Parameter) (query string, args []any, err error) {
+	return unpackAcceptResult(b.AcceptResult(translator, p))
+}
+
+// AcceptResult evaluates the expression and accepts the nodes with the bound value.
+func (b *BindNode) AcceptResult(translator driver.Translator, p Parameter) (AcceptResult, error) {
+	value, err := b.expr.Execute(p)
+	if err != nil {
+		return AcceptResult{}, fmt.Errorf("bind %s: %w", b.Name, err)
+	}
+	var bound any
+	if value.IsValid() && value.CanInterface() {
+		bound = value.Interface()
+	}
+	group := scopeParameter(p, eval.H{b.Name: bound}.AsParam())
+	return b.Nodes.acceptResult(translator, group, b.compact)
+}
+
+// scopeBindNodes moves the nodes after each BindNode into it, so the binding is visible to them only.
+// The BindNodes which already have their nodes are kept as they are.
+func scopeBindNodes(nodes []Node, compact bool) []Node {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if bind, ok := nodes[i].(*BindNode); ok && bind.Nodes == nil {
+			bind.Nodes = slices.Clone(nodes[i+1:])
+			bind.compact = compact
+			nodes = nodes[:i+1]
+		}
+	}
+	return nodes
+}
+
+// sortMapKeys sorts the keys of the string, integer and float kinds, so the query and the order
+// of its arguments are the same every time, which the prepared statement caches rely on.
+// The keys of the other kinds are kept in the random order of the map.
+func sortMapKeys(keys []reflect.Value) {
+	if len(keys) < 2 {
+		return
+	}
+	switch keys[0].Kind() {
+	case reflect.String:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.Int(), b.Int()) })
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.Uint(), b.Uint()) })
+	case reflect.Float32, reflect.Float64:
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.Float(), b.Float()) })
+	}
+}
+
+// ErrUntrustedTextSubstitution is an error that is returned when a text substitution
+// resolves from the parameters of the request in the strictTextSubstitution mode.
+var ErrUntrustedTextSubstitution = errors.New("untrusted text substitution")
+
+// textSubstitutionValue returns the value of the text substitution with the given name.
+// In the strictTextSubstitution mode, the value must come from the trusted values,
+// otherwise ErrUntrustedTextSubstitution is returned.
+func textSubstitutionValue(p Parameter, name string) (reflect.Value, error) {
+	trusted := contextOf(p).trusted
+	if trusted == nil {
+		value, exists := p.Get(name)
+		if !exists {
+			return reflect.Value{}, fmt.Errorf("%w: %s", ErrParamNotFound, name)
+		}
+		return value, nil
+	}
+	if value, exists := trusted.Get(name); exists {
+		return value, nil
+	}
+	if _, exists := p.Get(name); exists {
+		return reflect.Value{}, fmt.Errorf("%w: %s comes from the parameters, use #{%s} instead", ErrUntrustedTextSubstitution, name, name)
+	}
+	return reflect.Value{}, fmt.Errorf("%w: %s", ErrParamNotFound, name)
+}
+
+// ErrInvalidTextSubstitution is an error that is returned when the text of a ${} substitution is rejected by its validator.
+var ErrInvalidTextSubstitution = errors.New("invalid text substitution")
+
+// textSubstitutionValidators is a map of the registered text substitution validators by the names of the substitutions.
+var textSubstitutionValidators = map[string]func(text string) error{}
+
+// RegisterTextSubstitutionValidator registers a validator of the ${} substitutions of the given name,
+// which checks the rendered text before it is spliced into the query, like the computed table names.
+//
+//	juice.RegisterTextSubstitutionValidator("table", juice.PatternValidator(`events_[0-9]{6}`))
+//
+// It is not safe for concurrent use, validators should be registered at init time.
+func RegisterTextSubstitutionValidator(name string, validator func(text string) error) {
+	if len(name) == 0 {
+		panic("name is empty")
+	}
+	if validator == nil {
+		panic("juice: text substitution validator is nil")
+	}
+	textSubstitutionValidators[name] = validator
+}
+
+// PatternValidator returns a text substitution validator which accepts the texts fully matching the given pattern.
+// It panics if the pattern is invalid.
+func PatternValidator(pattern string) func(text string) error {
+	re := regexp.MustCompile(`^(?:` + pattern + `)$`)
+	return func(text string) error {
+		if !re.MatchString(text) {
+			return fmt.Errorf("%q does not match %s", text, pattern)
+		}
+		return nil
+	}
+}
+
+// validateTextSubstitution validates the text of the ${} substitution of the given name by its validator if any.
+func validateTextSubstitution(name, text string) error {
+	validator, ok := textSubstitutionValidators[name]
+	if !ok {
+		return nil
+	}
+	if err := validator(text); err != nil {
+		return fmt.Errorf("%w: ${%s}: %w", ErrInvalidTextSubstitution, name, err)
+	}
+	return nil
+}
+
+var (
+	// integerFormatSpecRegexp matches the format specs of integers, like "03d" or "x".
+	integerFormatSpecRegexp = regexp.MustCompile(`^0?[0-9]*[dxXob]$`)
+
+	// floatFormatSpecRegexp matches the format specs of floats, like ".2f".
+	floatFormatSpecRegexp = regexp.MustCompile(`^0?[0-9]*(?:\.[0-9]+)?[fFeEg]$`)
+)
+
+// formatTextSubstitution formats the value of a text substitution by the given format spec.
+// If the spec is empty, the value is converted by reflectValueToString.
+// The spec depends on the type of the value:
+//   - time.Time: a layout of time.Format, like "20060102"
+//   - integers: a fmt verb with an optional zero padding width, like "03d" or "x"
+//   - floats: a fmt verb with an optional width and precision, like ".2f"
+//   - strings: "upper" or "lower"
+//
+// An error is returned if the spec does not match the type of the value.
+func formatTextSubstitution(v reflect.Value, spec string) (string, error) {
+	if spec == "" {
+		return reflectValueToString(v), nil
+	}
 	v = reflectlite.Unwrap(v)
+	if !v.IsValid() {
+		return "", fmt.Errorf("can not format nil value with %q", spec)
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(spec), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integerFormatSpecRegexp.MatchString(spec) {
+			return fmt.Sprintf("%"+spec, v.Int()), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if integerFormatSpecRegexp.MatchString(spec) {
+			return fmt.Sprintf("%"+spec, v.Uint()), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if floatFormatSpecRegexp.MatchString(spec) {
+			return fmt.Sprintf("%"+spec, v.Float()), nil
+		}
+	case reflect.String:
+		switch spec {
+		case "upper":
+			return strings.ToUpper(v.String()), nil
+		case "lower":
+			return strings.ToLower(v.String()), nil
+		}
+	}
+	return "", fmt.Errorf("%w: invalid format spec %q for type %s", ErrUnsupportedType, spec, v.Type())
+}
+
+// textFormatters is a map of the registered text formatters by the types of the values.
+var textFormatters = map[reflect.Type]func(v reflect.Value) string{}
+
+// RegisterTextFormatter registers a formatter which renders the values of type T in the ${} text substitutions.
+// It takes precedence over the fmt.Stringer and the default formatting of the type.
+//
+//	juice.RegisterTextFormatter(func(s Status) string { return strings.ToLower(s.String()) })
+func RegisterTextFormatter[T any](formatter func(T) string) {
+	if formatter == nil {
+		panic("juice: text formatter is nil")
+	}
+	textFormatters[reflect.TypeFor[T]()] = func(v reflect.Value) string {
+		return formatter(v.Interface().(T))
+	}
+}
+
+// reflectValueToString converts reflect.Value to string.
+// The registered text formatter of the type is used first, then the fmt.Stringer,
+// and the default formatting of the type at last.
+func reflectValueToString(v reflect.Value) string {
+	unwrapped := reflectlite.Unwrap(v)
+	if !unwrapped.IsValid() {
+		return ""
+	}
+	if formatter, ok := textFormatters[unwrapped.Type()]; ok {
+		return formatter(unwrapped)
+	}
+	if stringer, ok := lookupStringer(v, unwrapped); ok {
+		return stringer.String()
+	}
+	v = unwrapped
 	switch t := v.Interface().(type) {
 	case nil:
 		return ""
@@ -1137,8 +2510,6 @@ func reflectValueToString(v reflect.Value) string {
 		return t
 	case []byte:
 		return string(v.Bytes())
-	case fmt.Stringer:
-		return t.String()
 	case int, int8, int16, int32, int64:
 		return strconv.FormatInt(v.Int(), 10)
 	case uint, uint8, uint16, uint32, uint64:
@@ -1153,3 +2524,29 @@ func reflectValueToString(v reflect.Value) string {
 		return fmt.Sprintf("%v", t)
 	}
 }
+
+// lookupStringer returns the fmt.Stringer of the value before it is unwrapped,
+// or of the pointer to the unwrapped value, so the String methods with a pointer receiver are found too.
+func lookupStringer(v, unwrapped reflect.Value) (fmt.Stringer, bool) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.CanInterface() {
+		if stringer, ok := v.Interface().(fmt.Stringer); ok {
+			return stringer, true
+		}
+	}
+	if !unwrapped.CanInterface() {
+		return nil, false
+	}
+	ptr := reflect.PointerTo(unwrapped.Type())
+	if !ptr.Implements(reflect.TypeFor[fmt.Stringer]()) {
+		return nil, false
+	}
+	if unwrapped.CanAddr() {
+		return unwrapped.Addr().Interface().(fmt.Stringer), true
+	}
+	copied := reflect.New(unwrapped.Type())
+	copied.Elem().Set(unwrapped)
+	return copied.Interface().(fmt.Stringer), true
+}
@@ -17,7 +17,15 @@ limitations under the License.
 package juice
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/go-juicedev/juice/driver"
 )
@@ -54,6 +62,141 @@ func TestForeachNode_Accept(t *testing.T) {
 	}
 }
 
+func TestForeachNode_SplitSize(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	node := ForeachNode{
+		Nodes:      []Node{NewTextNode("#{id}")},
+		Item:       "id",
+		Index:      "i",
+		Collection: "ids",
+		Open:       "id IN (",
+		Close:      ")",
+		Separator:  ",",
+		SplitSize:  2,
+	}
+	query, args, err := node.Accept(drv.Translator(), H{"ids": []int{1, 2, 3, 4, 5}}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "(id IN (?,?) OR id IN (?,?) OR id IN (?))" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if len(args) != 5 || args[0] != 1 || args[4] != 5 {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// the index keeps counting across the groups.
+	node.Nodes = []Node{NewTextNode("#{i}")}
+	_, args, err = node.Accept(drv.Translator(), H{"ids": [3]int{7, 8, 9}}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(args) != 3 || args[2] != 2 {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// no split within the limit.
+	query, _, err = node.Accept(drv.Translator(), H{"ids": []int{1, 2}}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "id IN (?,?)" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+
+	// the placeholders are numbered once whether the items are split or not.
+	query, _, err = node.Accept(driver.PostgresDriver{}.Translator(), H{"ids": []int{1, 2}}.AsParam())
+	if err != nil || query != "id IN ($1,$2)" {
+		t.Errorf("unexpected query: %s %v", query, err)
+		return
+	}
+	query, _, err = node.Accept(driver.PostgresDriver{}.Translator(), H{"ids": []int{1, 2, 3}}.AsParam())
+	if err != nil || query != "(id IN ($1,$2) OR id IN ($3))" {
+		t.Errorf("unexpected query: %s %v", query, err)
+		return
+	}
+
+	// the negated groups must all hold.
+	node.Open = "id NOT IN ("
+	query, _, err = node.Accept(drv.Translator(), H{"ids": []int{1, 2, 3, 4}}.AsParam())
+	if err != nil || query != "(id NOT IN (?,?) AND id NOT IN (?,?))" {
+		t.Errorf("unexpected query: %s %v", query, err)
+		return
+	}
+	node.Open = "id IN ("
+
+	// the groups are split by the placeholders of the items.
+	node.Open = "(id, name) IN ("
+	node.Nodes = []Node{NewTextNode("(#{id.ID}, #{id.Name})")}
+	node.SplitSize = 5
+	type key struct {
+		ID   int
+		Name string
+	}
+	query, args, err = node.Accept(drv.Translator(), H{"ids": []key{{1, "a"}, {2, "b"}, {3, "c"}}}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "((id, name) IN ((?, ?),(?, ?)) OR (id, name) IN ((?, ?)))" || len(args) != 6 {
+		t.Errorf("unexpected query: %s %v", query, args)
+		return
+	}
+	node.SplitSize = 1
+	if _, _, err = node.Accept(drv.Translator(), H{"ids": []key{{1, "a"}}}.AsParam()); err == nil {
+		t.Error("expected error for an item exceeding the splitSize")
+		return
+	}
+
+	// the open must name the column.
+	files := fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(`<configuration><mappers><mapper namespace="users">
+    <select id="list">select * from users where <foreach collection="ids" item="id" open="(" separator="," close=")" splitSize="2">#{id}</foreach></select>
+</mapper></mappers></configuration>`)}}
+	if _, err = NewXMLConfigurationWithFS(files, "juice.xml"); err == nil || !strings.Contains(err.Error(), "splitSize requires open") {
+		t.Errorf("expected error for the open without column, got %v", err)
+	}
+}
+
+func TestForeachNode_Zip(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	node := ForeachNode{
+		Nodes:       []Node{NewTextNode("WHEN id = #{id} THEN #{name}")},
+		Item:        "id",
+		Collection:  "ids",
+		Item2:       "name",
+		Collection2: "names",
+		Separator:   " ",
+	}
+	query, args, err := node.Accept(drv.Translator(), H{"ids": []int{1, 2}, "names": []string{"a", "b"}}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "WHEN id = ? THEN ? WHEN id = ? THEN ?" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if len(args) != 4 || args[0] != 1 || args[1] != "a" || args[2] != 2 || args[3] != "b" {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	if _, _, err = node.Accept(drv.Translator(), H{"ids": []int{1, 2}, "names": []string{"a"}}.AsParam()); err == nil {
+		t.Error("expected error for length mismatch")
+		return
+	}
+	if _, _, err = node.Accept(drv.Translator(), H{"ids": []int{1}, "names": map[string]int{"a": 1}}.AsParam()); err == nil {
+		t.Error("expected error for non slice collection")
+	}
+}
+
 func TestForeachMapNode_Accept(t *testing.T) {
 	drv := driver.MySQLDriver{}
 	textNode := NewTextNode("(#{item}, #{index})")
@@ -99,6 +242,39 @@ func TestForeachMapNode_Accept(t *testing.T) {
 	}
 }
 
+func TestForeachMapNode_Order(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	node := ForeachNode{
+		Nodes:      []Node{NewTextNode("#{index} = #{item}")},
+		Item:       "item",
+		Index:      "index",
+		Collection: "map",
+		Separator:  ", ",
+	}
+	type key string
+	cases := []struct {
+		collection any
+		args       []any
+	}{
+		{collection: map[string]int{"c": 3, "a": 1, "b": 2, "e": 5, "d": 4}, args: []any{"a", 1, "b", 2, "c", 3, "d", 4, "e", 5}},
+		{collection: map[key]int{"y": 2, "x": 1, "z": 3}, args: []any{"x", 1, "y", 2, "z", 3}},
+		{collection: map[int]string{10: "c", -1: "a", 3: "b"}, args: []any{-1, "a", 3, "b", 10, "c"}},
+		{collection: map[uint8]string{2: "b", 1: "a"}, args: []any{uint8(1), "a", uint8(2), "b"}},
+		{collection: map[float64]string{2.5: "b", 0.5: "a"}, args: []any{0.5, "a", 2.5, "b"}},
+	}
+	for _, c := range cases {
+		for range 20 {
+			_, args, err := node.Accept(drv.Translator(), H{"map": c.collection}.AsParam())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, c.args) {
+				t.Fatalf("%T: expected %v, got %v", c.collection, c.args, args)
+			}
+		}
+	}
+}
+
 func TestIfNode_Accept(t *testing.T) {
 	drv := driver.MySQLDriver{}
 	node1 := NewTextNode("select * from user where id = #{id}")
@@ -202,6 +378,113 @@ func TestWhereNode_Accept(t *testing.T) {
 
 }
 
+func TestWhereNode_PrefixOverrides(t *testing.T) {
+	translator := driver.MySQLDriver{}.Translator()
+	cases := []struct {
+		text            string
+		prefixOverrides []string
+		expected        string
+	}{
+		{"AND id = 1", nil, "WHERE id = 1"},
+		{"and id = 1", nil, "WHERE id = 1"},
+		{"Or id = 1", nil, "WHERE id = 1"},
+		{"AND\tid = 1", nil, "WHERE id = 1"},
+		{"ORDER = 1", nil, "WHERE ORDER = 1"},
+		{"android = 1", nil, "WHERE android = 1"},
+		{"AND OR id = 1", nil, "WHERE OR id = 1"},
+		{", id = 1", nil, "WHERE , id = 1"},
+		{"WHERE id = 1", nil, "WHERE id = 1"},
+		{", id = 1", []string{"AND", "OR", ","}, "WHERE id = 1"},
+		{",id = 1", []string{"AND", "OR", ","}, "WHERE id = 1"},
+		{"AND id = 1", []string{","}, "WHERE AND id = 1"},
+		{"AND id = 1", []string{}, "WHERE AND id = 1"},
+	}
+	for _, c := range cases {
+		node := WhereNode{Nodes: NodeGroup{NewTextNode(c.text)}, PrefixOverrides: c.prefixOverrides}
+		query, _, err := node.Accept(translator, newGenericParam(H{}, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected {
+			t.Errorf("%q with %v: expected %q, got %q", c.text, c.prefixOverrides, c.expected, query)
+		}
+	}
+
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <update id="update">
+                update users set name = 'a' <where prefixOverrides="AND | OR | ,"><if test="true">, id = 1</if></where>
+            </update>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _, err := statement.Build(translator, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "update users set name = 'a' WHERE id = 1" {
+		t.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestWhereNode_Default(t *testing.T) {
+	translator := driver.MySQLDriver{}.Translator()
+	cases := []struct {
+		text         string
+		defaultValue string
+		expected     string
+	}{
+		{"", "deleted_at IS NULL", "WHERE deleted_at IS NULL"},
+		{"", "AND deleted_at IS NULL", "WHERE deleted_at IS NULL"},
+		{"", "WHERE deleted_at IS NULL", "WHERE deleted_at IS NULL"},
+		{"", "", ""},
+		{"AND id = 1", "deleted_at IS NULL", "WHERE id = 1"},
+	}
+	for _, c := range cases {
+		node := WhereNode{Nodes: NodeGroup{NewTextNode(c.text)}, Default: c.defaultValue}
+		query, _, err := node.Accept(translator, newGenericParam(H{}, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected {
+			t.Errorf("%q with default %q: expected %q, got %q", c.text, c.defaultValue, c.expected, query)
+		}
+	}
+
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users <where default="AND deleted_at IS NULL"><if test="id > 0">AND id = #{id}</if></where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := statement.Build(translator, H{"id": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from users WHERE deleted_at IS NULL" || len(args) != 0 {
+		t.Errorf("unexpected query: %s %v", query, args)
+	}
+	query, args, err = statement.Build(translator, H{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from users WHERE id = ?" || len(args) != 1 {
+		t.Errorf("unexpected query: %s %v", query, args)
+	}
+}
+
 func TestTrimNode_Accept(t *testing.T) {
 	drv := driver.MySQLDriver{}
 	node1 := NewTextNode("name,")
@@ -269,3 +552,938 @@ func TestSetNode_Accept(t *testing.T) {
 		return
 	}
 }
+
+func TestSetNode_TrailingComma(t *testing.T) {
+	translator := driver.MySQLDriver{}.Translator()
+	condition := func(test string, text string) Node {
+		node := &IfNode{Nodes: NodeGroup{NewTextNode(text)}}
+		if err := node.Parse(test); err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+	params := newGenericParam(H{"id": 1, "name": "a", "age": 2}, "")
+	cases := []struct {
+		nodes    NodeGroup
+		expected string
+	}{
+		{NodeGroup{condition("true", "id = #{id},"), condition("false", "name = #{name},")}, "SET id = ?"},
+		{NodeGroup{condition("true", "id = #{id},\n    "), condition("false", "name = #{name}")}, "SET id = ?"},
+		{NodeGroup{condition("true", "id = #{id},"), condition("true", "name = #{name} ,\n\t"), condition("false", "age = #{age}")}, "SET id = ?, name = ?"},
+		{NodeGroup{condition("true", "id = #{id}"), condition("false", "name = #{name},")}, "SET id = ?"},
+		{NodeGroup{condition("true", "id = #{id},"), condition("true", "name = #{name}, \n ,"), NewTextNode("  "), condition("false", "age = #{age}")}, "SET id = ?, name = ?"},
+		{NodeGroup{condition("true", "id = #{id} , ,\t"), NewTextNode(" , "), condition("false", "age = #{age}")}, "SET id = ?"},
+		{NodeGroup{condition("true", ", \n"), condition("false", "age = #{age}")}, ""},
+	}
+	for _, c := range cases {
+		query, _, err := SetNode{Nodes: c.nodes}.Accept(translator, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, query)
+		}
+	}
+
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <update id="update">
+                update users
+                <set>
+                    <if test="name != ''">name = #{name},</if>
+                    <if test="age > 0">age = #{age},</if>
+                    <if test="age > 0">
+                    </if>
+                </set>
+                where id = #{id}
+            </update>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := statement.Build(translator, H{"id": 1, "name": "a", "age": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "update users SET name = ? where id = ?" || len(args) != 2 {
+		t.Errorf("unexpected query: %s %v", query, args)
+	}
+}
+
+func TestSetNode_Param(t *testing.T) {
+	type Base struct {
+		Status int `column:"status"`
+	}
+	type User struct {
+		Base
+		ID    int64  `column:"id"`
+		Name  string `column:"name"`
+		Age   int    `column:"age"`
+		Email string
+	}
+	drv := driver.MySQLDriver{}
+	node := SetNode{Param: "user", Presence: "fields", Nodes: NodeGroup{NewTextNode("updated_at = #{now},")}}
+
+	params := H{"user": User{Name: "a", Email: "ignored"}, "fields": map[string]bool{"age": true}, "now": 1}
+	query, args, err := node.Accept(drv.Translator(), newGenericParam(params, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SET name = ?, age = ?, updated_at = ?" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if len(args) != 3 || args[0] != "a" || args[1] != 0 || args[2] != 1 {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	node = SetNode{Param: "user"}
+	query, args, err = node.Accept(drv.Translator(), newGenericParam(H{"user": &User{Base: Base{Status: 1}, ID: 2}}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SET status = ?, id = ?" || len(args) != 2 {
+		t.Errorf("unexpected query: %s %v", query, args)
+		return
+	}
+
+	_, _, err = node.Accept(drv.Translator(), newGenericParam(H{"user": User{Email: "ignored"}}, ""))
+	if !errors.Is(err, ErrNothingToUpdate) {
+		t.Errorf("expected ErrNothingToUpdate, got %v", err)
+	}
+}
+
+func TestSetNode_ParamPlaceholderOrder(t *testing.T) {
+	type User struct {
+		Name string `column:"name"`
+	}
+	node := SetNode{Param: "user", Nodes: NodeGroup{NewTextNode("updated_at = #{now},")}}
+	params := newGenericParam(H{"user": User{Name: "a"}, "now": 1}, "")
+
+	// the generated assignments are translated before the children.
+	query, args, err := node.Accept(driver.PostgresDriver{}.Translator(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SET name = $1, updated_at = $2" || len(args) != 2 || args[0] != "a" || args[1] != 1 {
+		t.Errorf("unexpected statement: %s %v", query, args)
+		return
+	}
+
+	translator := driver.NewNamedTranslator()
+	result, err := node.AcceptResult(translator, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Query != "SET name = :user_Name, updated_at = :now" {
+		t.Errorf("unexpected query: %s", result.Query)
+		return
+	}
+	if names := translator.Names(); !slices.Equal(names, []string{"user_Name", "now"}) || !slices.Equal(result.Names, []string{"user.Name", "now"}) {
+		t.Errorf("unexpected names: %v %v", names, result.Names)
+		return
+	}
+	if result.Args[0] != "a" || result.Args[1] != 1 {
+		t.Errorf("unexpected args: %v", result.Args)
+	}
+}
+
+func TestParamValuesNode(t *testing.T) {
+	type Base struct {
+		Status int    `column:"status"`
+		Name   string `column:"name"`
+		Note   string `column:"base_note"`
+	}
+	type User struct {
+		ID int64 `column:"id"`
+		Base
+		Name     string `column:"name"`
+		Note     string `column:"note"`
+		Email    string
+		Password string `column:"-"`
+		secret   string
+	}
+	translator := driver.MySQLDriver{}.Translator()
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <insert id="create">
+                insert into users <values param="user" keyColumn="id"/>
+            </insert>
+            <insert id="createSnake" namingStrategy="snakeCase">
+                insert into users <values param="user"/>
+            </insert>
+            <insert id="createTagged">
+                insert into users <values param="user" tagged="true"><value column="created_at" value="#{now}"/></values>
+            </insert>
+        </mapper>
+    </mappers>
+</configuration>`)
+	build := func(id string, param Param) (string, []any, error) {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statement.Build(translator, param)
+	}
+
+	// the untagged fields are skipped without the naming strategy, and the shadowed columns are deduplicated.
+	user := User{Base: Base{Status: 1, Name: "shadowed", Note: "b"}, Name: "a", Note: "n", Email: "a@b.c", Password: "p", secret: "s"}
+	query, args, err := build("users.create", H{"user": user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "insert into users (status, name, base_note, note) VALUES (?, ?, ?, ?)" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if len(args) != 4 || args[0] != 1 || args[1] != "a" || args[2] != "b" || args[3] != "n" {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// the untagged fields are named by the naming strategy of the statement.
+	query, _, err = build("users.createSnake", H{"user": struct {
+		UserName string
+		Age      int `column:"user_age"`
+	}{UserName: "a", Age: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "insert into users (user_name, user_age) VALUES (?, ?)" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+
+	// the key is inserted when it is set.
+	user.ID = 7
+	query, args, err = build("users.createTagged", H{"user": &user, "now": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "insert into users (id, status, name, base_note, note, created_at) VALUES (?, ?, ?, ?, ?, ?)" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if len(args) != 6 || args[0] != int64(7) || args[5] != 2 {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	type Empty struct {
+		ID int64 `column:"id"`
+	}
+	if _, _, err = build("users.create", H{"user": Empty{}}); !errors.Is(err, ErrNothingToInsert) {
+		t.Errorf("expected ErrNothingToInsert, got %v", err)
+	}
+	if _, _, err = build("users.create", H{"user": 1}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestCompactNodeGroup(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	ifNode := &IfNode{Nodes: NodeGroup{pureTextNode("AND"), pureTextNode("status = 1")}}
+	_ = ifNode.Parse("true")
+	group := NodeGroup{
+		pureTextNode("SELECT id,"),
+		pureTextNode("name "),
+		pureTextNode("FROM user"),
+		NewTextNode("WHERE id = #{id}"),
+		ifNode,
+		pureTextNode("ORDER BY id"),
+		pureTextNode("LIMIT 1"),
+	}
+	param := H{"id": 1}.AsParam()
+	expected, _, err := group.Accept(drv.Translator(), param)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compacted := compactNodeGroup(group)
+	if len(compacted) != 4 {
+		t.Errorf("expected 4 nodes, got %d", len(compacted))
+		return
+	}
+	if len(ifNode.Nodes) != 1 {
+		t.Errorf("expected the children to be compacted, got %d nodes", len(ifNode.Nodes))
+		return
+	}
+	query, args, err := compacted.Accept(drv.Translator(), param)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != expected {
+		t.Errorf("expected %q, got %q", expected, query)
+		return
+	}
+	if len(args) != 1 || args[0] != 1 {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func BenchmarkNodeGroup_Accept(b *testing.B) {
+	drv := driver.MySQLDriver{}
+	group := make(NodeGroup, 0, 50)
+	for i := 0; i < 49; i++ {
+		group = append(group, pureTextNode("column_name,"))
+	}
+	group = append(group, NewTextNode("id = #{id}"))
+	param := H{"id": 1}.AsParam()
+
+	benchmarks := map[string]NodeGroup{
+		"raw":       group,
+		"compacted": compactNodeGroup(group),
+	}
+	for name, nodes := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := nodes.Accept(drv.Translator(), param); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// acceptOnlyNode is a custom node which only implements Node.
+type acceptOnlyNode struct{}
+
+func (acceptOnlyNode) Accept(_ driver.Translator, _ Parameter) (string, []any, error) {
+	return "id = ?", []any{1}, nil
+}
+
+func TestAcceptNode(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	group := NodeGroup{pureTextNode("WHERE"), acceptOnlyNode{}, NewTextNode("AND name = #{name}")}
+	result, err := AcceptNode(group, drv.Translator(), H{"name": "a"}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Query != "WHERE id = ? AND name = ?" {
+		t.Errorf("unexpected query: %s", result.Query)
+		return
+	}
+	if len(result.Args) != 2 || result.Args[0] != 1 || result.Args[1] != "a" {
+		t.Errorf("unexpected args: %v", result.Args)
+		return
+	}
+
+	// the shim wraps the return values of Accept.
+	result, err = AcceptNode(acceptOnlyNode{}, drv.Translator(), nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Query != "id = ?" || len(result.Args) != 1 {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+func TestAcceptResult_Names(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	foreachNode := &ForeachNode{
+		Nodes:      []Node{NewTextNode("#{id}")},
+		Item:       "id",
+		Collection: "ids",
+		Open:       "(",
+		Close:      ")",
+		Separator:  ",",
+	}
+	group := NodeGroup{
+		NewTextNode("SELECT * FROM user WHERE name = #{name} AND"),
+		acceptOnlyNode{},
+		pureTextNode("AND id IN"),
+		foreachNode,
+	}
+	result, err := group.AcceptResult(drv.Translator(), H{"name": "a", "ids": []int{1, 2}}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []string{"name", "", "id", "id"}
+	if len(result.Names) != len(result.Args) || len(result.Names) != len(expected) {
+		t.Errorf("expected names %v, got %v", expected, result.Names)
+		return
+	}
+	for i, name := range expected {
+		if result.Names[i] != name {
+			t.Errorf("expected names %v, got %v", expected, result.Names)
+			return
+		}
+	}
+}
+
+func TestTextNode_FormatSpec(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	param := H{
+		"date":  time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		"shard": 7,
+		"rate":  1.5,
+		"name":  "Users",
+	}.AsParam()
+	cases := map[string]string{
+		"select * from orders_${date:20060102}": "select * from orders_20240305",
+		"select * from t_${shard:03d}":          "select * from t_007",
+		"select * from t_${shard:x}":            "select * from t_7",
+		"select ${rate:.2f}":                    "select 1.50",
+		"select * from ${name:lower}":           "select * from users",
+		"select * from ${name}":                 "select * from Users",
+	}
+	for text, expected := range cases {
+		query, _, err := NewTextNode(text).Accept(drv.Translator(), param)
+		if err != nil {
+			t.Errorf("%s: %v", text, err)
+			return
+		}
+		if query != expected {
+			t.Errorf("expected %q, got %q", expected, query)
+			return
+		}
+	}
+
+	for _, text := range []string{"${name:03d}", "${shard:lower}", "${shard:s}", "${rate:%v}"} {
+		if _, _, err := NewTextNode(text).Accept(drv.Translator(), param); err == nil {
+			t.Errorf("%s: expected error for mismatched format spec", text)
+		}
+	}
+}
+
+func TestNode_Errors(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	cases := []struct {
+		name     string
+		node     Node
+		param    H
+		expected error
+	}{
+		{"placeholder", NewTextNode("id = #{id}"), H{}, ErrParamNotFound},
+		{"substitution", NewTextNode("order by ${column}"), H{}, ErrParamNotFound},
+		{"collection", &ForeachNode{Nodes: []Node{NewTextNode("#{item}")}, Item: "item", Collection: "ids"}, H{}, ErrCollectionNotFound},
+		{"collection type", &ForeachNode{Nodes: []Node{NewTextNode("#{item}")}, Item: "item", Collection: "ids"}, H{"ids": 1}, ErrUnsupportedType},
+		{"condition type", &IfNode{Nodes: []Node{NewTextNode("1")}}, H{"a": []int{1}}, ErrUnsupportedType},
+	}
+	if err := cases[4].node.(*IfNode).Parse("a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		_, _, err := c.node.Accept(drv.Translator(), c.param.AsParam())
+		if !errors.Is(err, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, err)
+		}
+	}
+
+	node := SelectFieldAliasNode{{column: "id"}, {column: "user_id", alias: "id"}}
+	if err := node.check(); !errors.Is(err, ErrAmbiguousColumn) {
+		t.Errorf("expected ErrAmbiguousColumn, got %v", err)
+	}
+}
+
+func TestForeachNode_Check(t *testing.T) {
+	cases := []struct {
+		node  ForeachNode
+		valid bool
+	}{
+		{ForeachNode{Open: "(", Close: ")", Separator: ","}, true},
+		{ForeachNode{Open: "id IN (", Close: ")", Separator: ","}, true},
+		{ForeachNode{Open: "((", Close: "))", Separator: "),("}, true},
+		{ForeachNode{Open: "(", Separator: ","}, false},
+		{ForeachNode{Close: ")", Separator: ","}, false},
+		{ForeachNode{Separator: ", ("}, false},
+		{ForeachNode{Separator: ", ?"}, false},
+		{ForeachNode{Separator: "#{sep}"}, false},
+		{ForeachNode{Item: ForeachFirstKey}, false},
+		{ForeachNode{Item: "item", Index: ForeachLastKey}, false},
+	}
+	for _, c := range cases {
+		if err := c.node.check(); (err == nil) != c.valid {
+			t.Errorf("open %q, close %q, separator %q: expected valid %v, got %v",
+				c.node.Open, c.node.Close, c.node.Separator, c.valid, err)
+		}
+	}
+}
+
+func TestForeachNode_FirstLast(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	condition := func(test, text string) Node {
+		node := &ConditionNode{Nodes: NodeGroup{pureTextNode(text)}}
+		if err := node.Parse(test); err != nil {
+			t.Fatal(err)
+		}
+		return node
+	}
+	node := ForeachNode{
+		Nodes:      []Node{condition(ForeachFirstKey, "["), NewTextNode("#{id}"), condition("!"+ForeachLastKey, ","), condition(ForeachLastKey, "]")},
+		Item:       "id",
+		Collection: "ids",
+	}
+	cases := []struct {
+		collection any
+		expected   string
+	}{
+		{collection: []int{1}, expected: "[?]"},
+		{collection: []int{1, 2, 3}, expected: "[?,?,?]"},
+		{collection: map[string]int{"a": 1}, expected: "[?]"},
+		{collection: map[string]int{"a": 1, "b": 2, "c": 3}, expected: "[?,?,?]"},
+	}
+	for _, c := range cases {
+		query, _, err := node.Accept(drv.Translator(), H{"ids": c.collection}.AsParam())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected {
+			t.Errorf("%v: expected %q, got %q", c.collection, c.expected, query)
+		}
+	}
+
+	// the flags refer to the items of each group when the items are split.
+	node.Open, node.Close, node.SplitSize = "id IN (", ")", 2
+	query, _, err := node.Accept(drv.Translator(), H{"ids": []int{1, 2, 3}}.AsParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "(id IN ([?,?]) OR id IN ([?]))"; query != expected {
+		t.Errorf("expected %q, got %q", expected, query)
+	}
+}
+
+type testStatus int
+
+func (s testStatus) String() string {
+	if s == 1 {
+		return "active"
+	}
+	return "inactive"
+}
+
+type testUserID int64
+
+type testTableName struct{ name string }
+
+func (n *testTableName) String() string { return "t_" + n.name }
+
+func TestReflectValueToString_PointerStringer(t *testing.T) {
+	name := testTableName{name: "users"}
+	for _, value := range []any{name, &name, H{"table": name}["table"]} {
+		if text := reflectValueToString(reflect.ValueOf(value)); text != "t_users" {
+			t.Errorf("%T: expected %q, got %q", value, "t_users", text)
+		}
+	}
+	type holder struct{ Table testTableName }
+	if text := reflectValueToString(reflect.ValueOf(&holder{Table: name}).Elem().Field(0)); text != "t_users" {
+		t.Errorf("expected %q for the addressable field, got %q", "t_users", text)
+	}
+}
+
+func TestTextNode_TextFormatter(t *testing.T) {
+	RegisterTextFormatter(func(id testUserID) string { return "u" + strconv.FormatInt(int64(id), 10) })
+	t.Cleanup(func() { delete(textFormatters, reflect.TypeFor[testUserID]()) })
+
+	drv := driver.MySQLDriver{}
+	node := NewTextNode("select * from ${table} where status = '${status}'")
+	query, _, err := node.Accept(drv.Translator(), H{"table": testUserID(42), "status": testStatus(1)}.AsParam())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if query != "select * from u42 where status = 'active'" {
+		t.Errorf("unexpected query: %s", query)
+	}
+	var nilID *testUserID
+	if text := reflectValueToString(reflect.ValueOf(nilID)); text != "" {
+		t.Errorf("expected empty text for nil pointer, got %q", text)
+	}
+}
+
+func TestTextNode_TextSubstitutionValidator(t *testing.T) {
+	RegisterTextSubstitutionValidator("table", PatternValidator(`events_[0-9]{6}`))
+	t.Cleanup(func() { delete(textSubstitutionValidators, "table") })
+
+	drv := driver.MySQLDriver{}
+	node := NewTextNode("select * from ${table}")
+	query, _, err := node.Accept(drv.Translator(), H{"table": "events_202401"}.AsParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from events_202401" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	for _, table := range []string{"events_2024011", "users; drop table events_202401", "events_202401 --"} {
+		if _, _, err = node.Accept(drv.Translator(), H{"table": table}.AsParam()); !errors.Is(err, ErrInvalidTextSubstitution) {
+			t.Errorf("%s: expected ErrInvalidTextSubstitution, got %v", table, err)
+		}
+	}
+}
+
+func TestCaseNode(t *testing.T) {
+	type User struct {
+		ID    int64
+		Score int
+		Level string
+	}
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <update id="updateScores">
+                update users set
+                <case collection="users" item="user" key="id" keyProperty="ID" columns="score:Score, level:Level"/>
+                where id in <foreach collection="users" item="user" open="(" separator=", " close=")">#{user.ID}</foreach>
+            </update>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.updateScores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := []User{{ID: 1, Score: 10, Level: "a"}, {ID: 2, Score: 20, Level: "b"}}
+	query, args, err := statement.Build(driver.PostgresDriver{}.Translator(), H{"users": users})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "update users set score = CASE id WHEN $1 THEN $2 WHEN $3 THEN $4 ELSE score END, " +
+		"level = CASE id WHEN $5 THEN $6 WHEN $7 THEN $8 ELSE level END where id in ($9, $10)"
+	if query != expected {
+		t.Errorf("expected query %q, got %q", expected, query)
+		return
+	}
+	if !reflect.DeepEqual(args, []any{int64(1), 10, int64(2), 20, int64(1), "a", int64(2), "b", int64(1), int64(2)}) {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	if _, _, err = statement.Build(driver.MySQLDriver{}.Translator(), H{"users": []User{}}); !errors.Is(err, ErrNothingToUpdate) {
+		t.Errorf("expected ErrNothingToUpdate, got %v", err)
+	}
+
+	_, err = NewXMLConfigurationWithFS(fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <update id="missing">update users set <case collection="users" item="user" key="id" keyProperty="ID"/></update>
+        </mapper>
+    </mappers>
+</configuration>`)}}, "juice.xml")
+	var requiredErr *nodeAttributeRequiredError
+	if !errors.As(err, &requiredErr) || requiredErr.attrName != "columns" {
+		t.Errorf("expected columns to be required, got %v", err)
+	}
+}
+
+func TestColumnsNode(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">select <columns param="fields" allowed="id, name, email"/> from users</select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		param    H
+		expected string
+	}{
+		{param: H{}, expected: "select id, name, email from users"},
+		{param: H{"fields": []string{}}, expected: "select id, name, email from users"},
+		{param: H{"fields": []string{"name", "id", "name"}}, expected: "select name, id from users"},
+		{param: H{"fields": []any{"email"}}, expected: "select email from users"},
+		{param: H{"fields": "email, id"}, expected: "select email, id from users"},
+	}
+	for _, tt := range tests {
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), tt.param)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.param, err)
+			continue
+		}
+		if query != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.param, tt.expected, query)
+		}
+	}
+
+	for _, fields := range []any{[]string{"id", "password"}, "id; drop table users", []int{1}} {
+		if _, _, err = statement.Build(driver.MySQLDriver{}.Translator(), H{"fields": fields}); err == nil {
+			t.Errorf("%v: expected error", fields)
+		}
+	}
+	if _, _, err = statement.Build(driver.MySQLDriver{}.Translator(), H{"fields": []string{"password"}}); !errors.Is(err, ErrColumnNotAllowed) {
+		t.Errorf("expected ErrColumnNotAllowed, got %v", err)
+	}
+}
+
+func TestDistinctAndAggregateNode(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="orders">
+            <select id="cities">select <distinct test="unique == true"/> city from orders</select>
+            <select id="stats">
+                select <aggregate param="fn" column="amount" allowed="sum, avg" default="sum" alias="value"/> from orders
+            </select>
+            <select id="bare">select <aggregate param="fn" column="amount"/> from orders</select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	build := func(id string, param H) (string, error) {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), param)
+		return query, err
+	}
+	tests := []struct {
+		id       string
+		param    H
+		expected string
+	}{
+		{id: "orders.cities", param: H{"unique": true}, expected: "select DISTINCT city from orders"},
+		{id: "orders.cities", param: H{"unique": false}, expected: "select city from orders"},
+		{id: "orders.stats", param: H{"fn": "avg"}, expected: "select AVG(amount) AS value from orders"},
+		{id: "orders.stats", param: H{}, expected: "select SUM(amount) AS value from orders"},
+		{id: "orders.bare", param: H{"fn": "Max"}, expected: "select MAX(amount) from orders"},
+		{id: "orders.bare", param: H{"fn": ""}, expected: "select amount from orders"},
+	}
+	for _, tt := range tests {
+		query, err := build(tt.id, tt.param)
+		if err != nil {
+			t.Errorf("%s %v: unexpected error: %v", tt.id, tt.param, err)
+			continue
+		}
+		if query != tt.expected {
+			t.Errorf("%s %v: expected %q, got %q", tt.id, tt.param, tt.expected, query)
+		}
+	}
+
+	for _, fn := range []string{"max", "sum(amount)); drop table orders; --"} {
+		if _, err := build("orders.stats", H{"fn": fn}); !errors.Is(err, ErrAggregateNotAllowed) {
+			t.Errorf("%q: expected ErrAggregateNotAllowed, got %v", fn, err)
+		}
+	}
+
+	_, err := NewXMLConfigurationWithFS(fstest.MapFS{"juice.xml": &fstest.MapFile{Data: []byte(`<configuration>
+    <mappers>
+        <mapper namespace="orders">
+            <select id="stats">select <aggregate param="fn" column="amount" allowed="median"/> from orders</select>
+        </mapper>
+    </mappers>
+</configuration>`)}}, "juice.xml")
+	if err == nil {
+		t.Error("expected error for unknown aggregate function")
+	}
+}
+
+func TestBindNode(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="posts">
+            <select id="search">
+                <bind name="pattern" value="'%' + title + '%'"/>
+                select * from posts where title like #{pattern}
+            </select>
+            <select id="nested">
+                select * from posts
+                <bind name="name" value="'outer'"/>
+                <where>
+                    <if test="title != ''">
+                        <bind name="name" value="title"/>
+                        title = #{name}
+                    </if>
+                    and author = #{name}
+                </where>
+            </select>
+            <select id="foreach">
+                select * from posts where id in
+                <foreach collection="ids" item="id" open="(" separator="," close=")"><bind name="next" value="id + 1"/>#{next}</foreach>
+            </select>
+            <select id="scoped">
+                select * from posts <if test="title != ''"><bind name="pattern" value="title"/></if> where title = #{pattern}
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	build := func(id string, param H) (string, []any, error) {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statement.Build(driver.MySQLDriver{}.Translator(), param)
+	}
+	tests := []struct {
+		id       string
+		param    H
+		expected string
+		args     []any
+	}{
+		{
+			id:       "posts.search",
+			param:    H{"title": "go"},
+			expected: "select * from posts where title like ?",
+			args:     []any{"%go%"},
+		},
+		{
+			// the inner binding shadows the outer one, which shadows the parameter.
+			id:       "posts.nested",
+			param:    H{"title": "go", "name": "param"},
+			expected: "select * from posts WHERE title = ? and author = ?",
+			args:     []any{"go", "outer"},
+		},
+		{
+			id:       "posts.foreach",
+			param:    H{"ids": []int{1, 2}},
+			expected: "select * from posts where id in (?,?)",
+			args:     []any{2, 3},
+		},
+	}
+	for _, tt := range tests {
+		query, args, err := build(tt.id, tt.param)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.id, err)
+			continue
+		}
+		if query != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.id, tt.expected, query)
+		}
+		if fmt.Sprint(args) != fmt.Sprint(tt.args) {
+			t.Errorf("%s: expected args %v, got %v", tt.id, tt.args, args)
+		}
+	}
+
+	// the binding is not visible outside of its element.
+	if _, _, err := build("posts.scoped", H{"title": "go"}); !errors.Is(err, ErrParamNotFound) {
+		t.Errorf("expected ErrParamNotFound, got %v", err)
+	}
+}
+
+func TestForeachNode_Nullable(t *testing.T) {
+	drv := driver.MySQLDriver{}
+	node := ForeachNode{
+		Nodes:      []Node{NewTextNode("#{id}")},
+		Item:       "id",
+		Collection: "ids",
+		Open:       "(",
+		Separator:  ",",
+		Close:      ")",
+	}
+	if _, _, err := node.Accept(drv.Translator(), H{}.AsParam()); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("expected ErrCollectionNotFound, got %v", err)
+	}
+	if _, _, err := node.Accept(drv.Translator(), H{"ids": nil}.AsParam()); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+
+	node.Nullable = true
+	cases := map[string]H{
+		"missing":   {},
+		"nil":       {"ids": nil},
+		"nil slice": {"ids": []int(nil)},
+		"empty map": {"ids": map[string]int{}},
+	}
+	for name, param := range cases {
+		query, args, err := node.Accept(drv.Translator(), param.AsParam())
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if query != "" || len(args) != 0 {
+			t.Errorf("%s: expected nothing, got %q %v", name, query, args)
+		}
+	}
+	query, args, err := node.Accept(drv.Translator(), H{"ids": []int{1, 2}}.AsParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "(?,?)" || len(args) != 2 {
+		t.Errorf("unexpected query: %q %v", query, args)
+	}
+
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users
+                <where>
+                    <foreach collection="ids" item="id" open="id in (" separator="," close=")" nullable="true">#{id}</foreach>
+                </where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, _, err = statement.Build(drv.Translator(), H{}); err != nil || query != "select * from users" {
+		t.Errorf("unexpected query: %q %v", query, err)
+	}
+}
+
+func TestForeachNode_NestedCollection(t *testing.T) {
+	type Profile struct {
+		Roles []string
+		Tags  map[string]int
+		Extra any
+	}
+	type User struct {
+		Profile Profile
+	}
+	drv := driver.MySQLDriver{}
+	node := ForeachNode{Nodes: []Node{NewTextNode("#{item}")}, Item: "item", Separator: ","}
+	cases := []struct {
+		collection string
+		param      any
+		expected   string
+		args       []any
+	}{
+		{
+			collection: "user.Profile.Roles",
+			param:      H{"user": User{Profile: Profile{Roles: []string{"admin", "dev"}}}},
+			expected:   "?,?",
+			args:       []any{"admin", "dev"},
+		},
+		{
+			collection: "user.Profile.Tags",
+			param:      H{"user": &User{Profile: Profile{Tags: map[string]int{"go": 1}}}},
+			expected:   "?",
+			args:       []any{1},
+		},
+		{
+			collection: "user.roles",
+			param:      H{"user": map[string][]int{"roles": {1, 2}}},
+			expected:   "?,?",
+			args:       []any{1, 2},
+		},
+		{
+			collection: "user.tags",
+			param:      H{"user": H{"tags": map[string]string{"k": "v"}}},
+			expected:   "?",
+			args:       []any{"v"},
+		},
+		{
+			collection: "user.Profile.Extra",
+			param:      H{"user": User{Profile: Profile{Extra: &[]int{3}}}},
+			expected:   "?",
+			args:       []any{3},
+		},
+	}
+	for _, c := range cases {
+		node.Collection = c.collection
+		query, args, err := node.Accept(drv.Translator(), newGenericParam(c.param, ""))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.collection, err)
+			continue
+		}
+		if query != c.expected || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: expected %q %v, got %q %v", c.collection, c.expected, c.args, query, args)
+		}
+	}
+}
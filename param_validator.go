@@ -0,0 +1,137 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-juicedev/juice/eval"
+)
+
+// ErrParamValidation is returned when the parameter of a statement is rejected by a ParamValidator.
+var ErrParamValidation = errors.New("parameter validation failed")
+
+// ParamValidationError is an error that the parameter of a statement breaks a rule,
+// it matches ErrParamValidation by errors.Is.
+type ParamValidationError struct {
+	// Statement is the name of the statement, which is set by the caller of the validator if empty.
+	Statement string
+	// Field is the name of the rejected parameter.
+	Field string
+	// Rule is the description of the broken rule.
+	Rule string
+}
+
+// Error implements error.
+func (e *ParamValidationError) Error() string {
+	return fmt.Sprintf("%s: statement %s: parameter %s breaks rule %s", ErrParamValidation, e.Statement, e.Field, e.Rule)
+}
+
+// Is implements errors.Is.
+func (e *ParamValidationError) Is(target error) bool {
+	return target == ErrParamValidation
+}
+
+// ParamValidator validates the parameter of a statement after it is bound and before the statement is built,
+// so the invalid parameters never reach the database.
+type ParamValidator interface {
+	// Validate returns an error if the parameter is invalid for the statement,
+	// which should be a *ParamValidationError for the broken rules.
+	Validate(statement Statement, param Parameter) error
+}
+
+// ParamValidatorFunc is a function which implements ParamValidator.
+type ParamValidatorFunc func(statement Statement, param Parameter) error
+
+// Validate implements ParamValidator.
+func (f ParamValidatorFunc) Validate(statement Statement, param Parameter) error {
+	return f(statement, param)
+}
+
+// ParamRule returns a ParamValidator which rejects the parameter when the expression evaluates to false,
+// the field is reported as the rejected parameter and the expression as the broken rule.
+// It panics if the expression can not be compiled.
+//
+//	juice.RegisterStatementParamValidator("main.GetUser", juice.ParamRule("id", "id > 0"))
+func ParamRule(field, expr string) ParamValidator {
+	expression, err := eval.Compile(expr)
+	if err != nil {
+		panic(fmt.Sprintf("juice: invalid param rule %q: %v", expr, err))
+	}
+	condition := &ConditionNode{expr: expression}
+	return ParamValidatorFunc(func(statement Statement, param Parameter) error {
+		matched, err := condition.Match(param)
+		if err != nil {
+			return fmt.Errorf("%w: statement %s: parameter %s: %w", ErrParamValidation, statement.Name(), field, err)
+		}
+		if !matched {
+			return &ParamValidationError{Statement: statement.Name(), Field: field, Rule: expr}
+		}
+		return nil
+	})
+}
+
+var (
+	// paramValidators is the validators of all the statements.
+	paramValidators []ParamValidator
+
+	// statementParamValidators is the validators by the names of the statements.
+	statementParamValidators = map[string][]ParamValidator{}
+)
+
+// RegisterParamValidator registers a validator which validates the parameters of all the xml statements.
+// It is not safe for concurrent use, validators should be registered at init time.
+func RegisterParamValidator(validator ParamValidator) {
+	if validator == nil {
+		panic("juice: param validator is nil")
+	}
+	paramValidators = append(paramValidators, validator)
+}
+
+// RegisterStatementParamValidator registers a validator which validates the parameters of the statement
+// with the given name, like "main.GetUser".
+// It is not safe for concurrent use, validators should be registered at init time.
+func RegisterStatementParamValidator(statement string, validator ParamValidator) {
+	if len(statement) == 0 {
+		panic("juice: statement is empty")
+	}
+	if validator == nil {
+		panic("juice: param validator is nil")
+	}
+	statementParamValidators[statement] = append(statementParamValidators[statement], validator)
+}
+
+// validateParam validates the parameter of the statement by the global validators
+// and then the validators of the statement.
+func validateParam(statement Statement, param Parameter) error {
+	if len(paramValidators) == 0 && len(statementParamValidators) == 0 {
+		return nil
+	}
+	for _, validators := range [][]ParamValidator{paramValidators, statementParamValidators[statement.Name()]} {
+		for _, validator := range validators {
+			if err := validator.Validate(statement, param); err != nil {
+				var validationErr *ParamValidationError
+				if errors.As(err, &validationErr) && validationErr.Statement == "" {
+					validationErr.Statement = statement.Name()
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
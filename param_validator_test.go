@@ -0,0 +1,74 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParamValidator(t *testing.T) {
+	RegisterStatementParamValidator("main.get", ParamRule("id", "id > 0"))
+	RegisterParamValidator(ParamValidatorFunc(func(statement Statement, param Parameter) error {
+		if _, exists := param.Get("tenant"); !exists {
+			return &ParamValidationError{Field: "tenant", Rule: "required"}
+		}
+		return nil
+	}))
+	t.Cleanup(func() {
+		delete(statementParamValidators, "main.get")
+		paramValidators = nil
+	})
+
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="get">select * from users where id = #{id} and tenant = #{tenant}</select>
+<select id="list">select * from users where tenant = #{tenant}</select>`)
+
+	ctx := context.Background()
+	_, err := engine.Object("main.get").QueryContext(ctx, H{"id": 0, "tenant": "a"})
+	var validationErr *ParamValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, ErrParamValidation) {
+		t.Fatalf("expected ParamValidationError, got %v", err)
+	}
+	if validationErr.Statement != "main.get" || validationErr.Field != "id" || validationErr.Rule != "id > 0" {
+		t.Errorf("unexpected error: %+v", validationErr)
+	}
+
+	// the global validators apply to all the statements, the statement is filled in.
+	_, err = engine.Object("main.list").QueryContext(ctx, H{})
+	if !errors.As(err, &validationErr) || validationErr.Statement != "main.list" || validationErr.Field != "tenant" {
+		t.Errorf("expected the tenant to be required, got %v", err)
+	}
+
+	// a missing parameter of a rule is reported as the validation failure.
+	if _, err = engine.Object("main.get").QueryContext(ctx, H{"tenant": "a"}); !errors.Is(err, ErrParamValidation) {
+		t.Errorf("expected ErrParamValidation, got %v", err)
+	}
+	if calls := db.Calls(); len(calls) != 0 {
+		t.Fatalf("expected the invalid parameters not to reach the database, got %v", calls)
+	}
+
+	rows, err := engine.Object("main.get").QueryContext(ctx, H{"id": 1, "tenant": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if calls := db.Calls(); len(calls) != 1 {
+		t.Errorf("expected one call, got %v", calls)
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"io/fs"
 	"net/http"
 	"net/url"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -41,8 +43,14 @@ type ConfigurationParser interface {
 type XMLParser struct {
 	configuration Configuration
 	FS            fs.FS
-	ignoreEnv     bool
-	parsers       []XMLElementParser
+
+	// MapperRoot is the directory of the FS which the mapper resources, the file urls
+	// and the patterns are relative to, it is joined with them. The root attribute of
+	// the mappers element is joined with it.
+	MapperRoot string
+
+	ignoreEnv bool
+	parsers   []XMLElementParser
 }
 
 // Parse implements ConfigurationParser.
@@ -67,6 +75,9 @@ func (p *XMLParser) Parse(reader io.Reader) (IConfiguration, error) {
 			}
 		}
 	}
+	if p.configuration.typeHandlers == nil {
+		p.configuration.typeHandlers = &TypeHandlers{}
+	}
 	return &p.configuration, nil
 }
 
@@ -267,8 +278,111 @@ func (p *XMLSettingsElementParser) parseSettings(decoder *xml.Decoder) (keyValue
 	return settings, nil
 }
 
+// XMLDatabaseIDProviderElementParser parses the databaseIdProvider element,
+// which maps the driver names of the environments to the databaseIds of the statements.
+//
+//	<databaseIdProvider>
+//	    <property name="mysql" value="mysql"/>
+//	    <property name="postgres" value="pg"/>
+//	</databaseIdProvider>
+type XMLDatabaseIDProviderElementParser struct{}
+
+func (p *XMLDatabaseIDProviderElementParser) MatchElement(token xml.StartElement) bool {
+	return token.Name.Local == "databaseIdProvider"
+}
+
+func (p *XMLDatabaseIDProviderElementParser) ParseElement(parser *XMLParser, decoder *xml.Decoder, _ xml.StartElement) error {
+	var databaseIDs = make(map[string]string)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name.Local == "databaseIdProvider" {
+				parser.configuration.databaseIDs = databaseIDs
+				return nil
+			}
+		case xml.StartElement:
+			if t.Name.Local != "property" {
+				continue
+			}
+			var item settingItem
+			if err := decoder.DecodeElement(&item, &t); err != nil {
+				return err
+			}
+			if _, ok := databaseIDs[item.Name]; ok {
+				return fmt.Errorf("duplicate databaseIdProvider property: %s", item.Name)
+			}
+			databaseIDs[item.Name] = item.Value.String()
+		}
+	}
+	return &nodeUnclosedError{nodeName: "databaseIdProvider"}
+}
+
 type XMLMappersElementParser struct {
 	parser *XMLParser
+
+	// strict is true if the unknown attributes of the tags are rejected,
+	// which is enabled by the strict attribute of the mappers element.
+	strict bool
+
+	// root is the directory which the mapper resources are relative to,
+	// which is the MapperRoot of the parser joined with the root attribute of the mappers element.
+	root string
+}
+
+// knownTagAttributes is the attributes of the tags in the statements,
+// which are the only allowed attributes in the strict mode.
+var knownTagAttributes = map[string][]string{
+	"if":        {"test"},
+	"when":      {"test"},
+	"otherwise": nil,
+	"choose":    nil,
+	"where":     {"prefixOverrides", "default"},
+	"set":       {"param", "presence"},
+	"trim":      {"prefix", "prefixOverrides", "suffix", "suffixOverrides", "compact"},
+	"foreach":   {"collection", "item", "index", "open", "separator", "close", "splitSize", "collection2", "item2", "nullable"},
+	"include":   {"refid"},
+	"sql":       {"id"},
+	"values":    {"param", "keyColumn", "tagged"},
+	"value":     {"value", "column"},
+	"alias":     nil,
+	"field":     {"name", "alias"},
+	"defaults":  nil,
+	"default":   {"name", "value"},
+	"case":      {"collection", "item", "key", "keyProperty", "columns"},
+	"columns":   {"param", "allowed"},
+	"distinct":  {"test"},
+	"aggregate": {"param", "column", "allowed", "default", "alias"},
+	"block":     {"name", "mode"},
+	"bind":      {"name", "value"},
+}
+
+// checkAttributes checks whether the attributes of the given tag are all known in the strict mode.
+// It does nothing if the strict mode is disabled.
+func (p *XMLMappersElementParser) checkAttributes(token xml.StartElement) error {
+	if !p.strict {
+		return nil
+	}
+	known, ok := knownTagAttributes[token.Name.Local]
+	if !ok {
+		return nil
+	}
+	var unknown []string
+	for _, attr := range token.Attr {
+		if !slices.Contains(known, attr.Name.Local) {
+			unknown = append(unknown, attr.Name.Local)
+		}
+	}
+	if len(unknown) > 0 {
+		return &nodeAttributeUnknownError{nodeName: token.Name.Local, attrNames: unknown}
+	}
+	return nil
 }
 
 func (p *XMLMappersElementParser) MatchElement(token xml.StartElement) bool {
@@ -281,7 +395,9 @@ func (p *XMLMappersElementParser) ParseElement(parser *XMLParser, decoder *xml.D
 	if err != nil {
 		return err
 	}
-	mappers.cfg = parser.configuration
+	// keep a reference to the configuration, so that the elements parsed later,
+	// like settings, are visible to the statements.
+	mappers.cfg = &parser.configuration
 	parser.configuration.mappers = mappers
 	return nil
 }
@@ -292,6 +408,8 @@ func (p *XMLMappersElementParser) parseMappers(start xml.StartElement, decoder *
 	for _, attr := range start.Attr {
 		mappers.setAttribute(attr.Name.Local, attr.Value)
 	}
+	p.strict = mappers.Attribute("strict") == "true"
+	p.root = path.Join(p.parser.MapperRoot, mappers.Attribute("root"))
 
 	// parse mappers by pattern
 	if pattern := mappers.Attribute("pattern"); pattern != "" {
@@ -350,13 +468,13 @@ func (p *XMLMappersElementParser) parseMapper(decoder *xml.Decoder, token xml.St
 	// namespace is required if resource and url are not set
 	switch {
 	case resource != "" && _url != "":
-		return nil, &nodeAttributeConflictError{nodeName: "mapper", attrName: "resource|url"}
+		return nil, newXMLParseError(decoder, mapper, "mapper", &nodeAttributeConflictError{nodeName: "mapper", attrName: "resource|url"})
 	case resource != "" && namespace != "":
-		return nil, &nodeAttributeConflictError{nodeName: "mapper", attrName: "resource|namespace"}
+		return nil, newXMLParseError(decoder, mapper, "mapper", &nodeAttributeConflictError{nodeName: "mapper", attrName: "resource|namespace"})
 	case _url != "" && namespace != "":
-		return nil, &nodeAttributeConflictError{nodeName: "mapper", attrName: "url|namespace"}
+		return nil, newXMLParseError(decoder, mapper, "mapper", &nodeAttributeConflictError{nodeName: "mapper", attrName: "url|namespace"})
 	case resource == "" && _url == "" && namespace == "":
-		return nil, &nodeAttributeRequiredError{nodeName: "mapper", attrName: "resource|url|namespace"}
+		return nil, newXMLParseError(decoder, mapper, "mapper", &nodeAttributeRequiredError{nodeName: "mapper", attrName: "resource|url|namespace"})
 	}
 	if resource != "" {
 		return p.parseMapperByResource(resource)
@@ -365,7 +483,7 @@ func (p *XMLMappersElementParser) parseMapper(decoder *xml.Decoder, token xml.St
 		return p.parseMapperByURL(_url)
 	}
 	if namespace == "" {
-		return nil, &nodeAttributeRequiredError{nodeName: "mapper", attrName: "namespace"}
+		return nil, newXMLParseError(decoder, mapper, "mapper", &nodeAttributeRequiredError{nodeName: "mapper", attrName: "namespace"})
 	}
 
 	mapper.namespace = namespace
@@ -377,42 +495,53 @@ func (p *XMLMappersElementParser) parseMapper(decoder *xml.Decoder, token xml.St
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			return nil, newXMLParseError(decoder, mapper, "", err)
 		}
 		switch token := token.(type) {
 		case xml.StartElement:
 			action := Action(token.Name.Local)
 			switch action {
 			case Select, Insert, Update, Delete:
+				element := elementWithID(token)
 				stmt := &xmlSQLStatement{action: action, mapper: mapper}
 				if err = p.parseStatement(stmt, decoder, token); err != nil {
-					return nil, err
+					return nil, newXMLParseError(decoder, mapper, element, err)
 				}
-				key := stmt.ID()
-				if _, exists := mapper.statements[key]; exists {
-					return nil, fmt.Errorf("duplicate xmlSQLStatement id: %s", key)
+				if err = mapper.setStatement(stmt); err != nil {
+					return nil, newXMLParseError(decoder, mapper, element, err)
 				}
-				mapper.statements[key] = stmt
 			case "sql":
+				element := elementWithID(token)
+				if err = p.checkAttributes(token); err != nil {
+					return nil, newXMLParseError(decoder, mapper, element, err)
+				}
 				// parse sql node
 				sqlNode, err := p.parseSQLNode(mapper, decoder, token)
 				if err != nil {
-					return nil, err
+					return nil, newXMLParseError(decoder, mapper, element, err)
 				}
 				if err = mapper.setSqlNode(sqlNode); err != nil {
-					return nil, err
+					return nil, newXMLParseError(decoder, mapper, element, err)
 				}
 			}
 		case xml.EndElement:
 			if token.Name.Local == "mapper" {
+				if err = mapper.resolveExtends(); err != nil {
+					return nil, newXMLParseError(decoder, mapper, "mapper", err)
+				}
 				return mapper, nil
 			}
 		}
 	}
+	if err := mapper.resolveExtends(); err != nil {
+		return nil, newXMLParseError(decoder, mapper, "mapper", err)
+	}
 	return mapper, nil
 }
 
-func (p *XMLMappersElementParser) parseMapperByReader(reader io.Reader) (mapper *Mapper, err error) {
+// parseMapperByReader parses the mapper file of the given resource from the reader,
+// the resource is the name of the file reported by the parse errors.
+func (p *XMLMappersElementParser) parseMapperByReader(reader io.Reader, resource string) (mapper *Mapper, err error) {
 	decoder := xml.NewDecoder(reader)
 	for {
 		token, err := decoder.Token()
@@ -420,13 +549,13 @@ func (p *XMLMappersElementParser) parseMapperByReader(reader io.Reader) (mapper
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			return nil, withParseResource(newXMLParseError(decoder, nil, "", err), resource)
 		}
 		switch token := token.(type) {
 		case xml.StartElement:
 			if token.Name.Local == "mapper" {
 				if mapper, err = p.parseMapper(decoder, token); err != nil {
-					return nil, err
+					return nil, withParseResource(err, resource)
 				}
 				break
 			}
@@ -440,12 +569,12 @@ func (p *XMLMappersElementParser) parseMapperByResource(resource string) (*Mappe
 		reader io.ReadCloser
 		err    error
 	)
-	reader, err = p.parser.FS.Open(resource)
+	reader, err = p.parser.FS.Open(p.resolve(resource))
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = reader.Close() }()
-	return p.parseMapperByReader(reader)
+	return p.parseMapperByReader(reader, resource)
 }
 
 func (p *XMLMappersElementParser) parseMapperByHttpResponse(url string) (*Mapper, error) {
@@ -454,7 +583,7 @@ func (p *XMLMappersElementParser) parseMapperByHttpResponse(url string) (*Mapper
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
-	return p.parseMapperByReader(resp.Body)
+	return p.parseMapperByReader(resp.Body, url)
 }
 
 func (p *XMLMappersElementParser) parseMapperByURL(path string) (*Mapper, error) {
@@ -466,7 +595,12 @@ func (p *XMLMappersElementParser) parseMapperByURL(path string) (*Mapper, error)
 	schema := u.Scheme
 	switch schema {
 	case "file":
-		return p.parseMapperByResource(u.Path)
+		// file://mappers/users.xml, file:///mappers/users.xml and file:mappers/users.xml
+		// are all relative to the root, the same as the resources.
+		if u.Opaque != "" {
+			return p.parseMapperByResource(u.Opaque)
+		}
+		return p.parseMapperByResource(strings.TrimPrefix(u.Host+u.Path, "/"))
 	case "http", "https":
 		return p.parseMapperByHttpResponse(path)
 	default:
@@ -474,10 +608,18 @@ func (p *XMLMappersElementParser) parseMapperByURL(path string) (*Mapper, error)
 	}
 }
 
+// resolve returns the path of the given resource in the FS, which is relative to the root.
+func (p *XMLMappersElementParser) resolve(resource string) string {
+	if p.root == "" {
+		return resource
+	}
+	return path.Join(p.root, resource)
+}
+
 func (p *XMLMappersElementParser) parseMapperByPattern(pattern string) ([]*Mapper, error) {
 	fsys := p.parser.FS
 	// Find files matching the pattern using fs.Glob
-	matches, err := fs.Glob(fsys, pattern)
+	matches, err := fs.Glob(fsys, p.resolve(pattern))
 	if err != nil {
 		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
 	}
@@ -494,13 +636,8 @@ func (p *XMLMappersElementParser) parseMapperByPattern(pattern string) ([]*Mappe
 		}
 		defer func() { _ = file.Close() }()
 
-		// Parse mapper from file content
-		mapper, err := p.parseMapperByReader(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse mapper %q: %w", match, err)
-		}
-
-		return mapper, nil
+		// Parse mapper from file content, the error tells the file already.
+		return p.parseMapperByReader(file, match)
 	}
 
 	// Pre-allocate slice with capacity matching number of files
@@ -520,6 +657,41 @@ func (p *XMLMappersElementParser) parseMapperByPattern(pattern string) ([]*Mappe
 	return mappers, nil
 }
 
+// newXMLParseError returns an XMLParseError which locates the given error at the current position of the decoder,
+// the errors which are located already are returned as they are.
+func newXMLParseError(decoder *xml.Decoder, mapper *Mapper, element string, err error) error {
+	var parseErr *XMLParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+	parseErr = &XMLParseError{Element: element, Err: err}
+	if mapper != nil {
+		parseErr.Namespace = mapper.namespace
+	}
+	parseErr.Line, parseErr.Column = decoder.InputPos()
+	return parseErr
+}
+
+// withParseResource sets the resource of the XMLParseError if it is not set,
+// since the errors of the mappers included by their resources are reported by the resources.
+func withParseResource(err error, resource string) error {
+	var parseErr *XMLParseError
+	if errors.As(err, &parseErr) && parseErr.Resource == "" {
+		parseErr.Resource = resource
+	}
+	return err
+}
+
+// elementWithID returns the name of the element with its id, like select id="GetUser".
+func elementWithID(token xml.StartElement) string {
+	for _, attr := range token.Attr {
+		if attr.Name.Local == "id" {
+			return fmt.Sprintf("%s id=%q", token.Name.Local, attr.Value)
+		}
+	}
+	return token.Name.Local
+}
+
 func (p *XMLMappersElementParser) parseStatement(stmt *xmlSQLStatement, decoder *xml.Decoder, token xml.StartElement) error {
 	for _, attr := range token.Attr {
 		stmt.setAttribute(attr.Name.Local, attr.Value)
@@ -529,6 +701,14 @@ func (p *XMLMappersElementParser) parseStatement(stmt *xmlSQLStatement, decoder
 	} else {
 		stmt.id = id
 	}
+	if value := stmt.Attribute("affectedRows"); value != "" {
+		if stmt.action != Update && stmt.action != Delete {
+			return fmt.Errorf("affectedRows only support update and delete xmlSQLStatement")
+		}
+		if _, _, err := parseAffectedRows(value); err != nil {
+			return err
+		}
+	}
 	for {
 		token, err := decoder.Token()
 		if err != nil {
@@ -540,11 +720,24 @@ func (p *XMLMappersElementParser) parseStatement(stmt *xmlSQLStatement, decoder
 		switch token := token.(type) {
 		case xml.StartElement:
 			switch token.Name.Local {
+			case "values", "alias", "defaults":
+				if err = p.checkAttributes(token); err != nil {
+					return err
+				}
+			}
+			switch token.Name.Local {
+			case "defaults":
+				if stmt.defaults != nil {
+					return fmt.Errorf("multiple defaults elements in %s", stmt.id)
+				}
+				if stmt.defaults, err = p.parseDefaults(decoder); err != nil {
+					return err
+				}
 			case "values":
 				if stmt.action != Insert {
 					return fmt.Errorf("values node only support insert xmlSQLStatement")
 				}
-				node, err := p.parseValuesNode(decoder)
+				node, err := p.parseValuesNode(decoder, token)
 				if err != nil {
 					return err
 				}
@@ -574,6 +767,7 @@ func (p *XMLMappersElementParser) parseStatement(stmt *xmlSQLStatement, decoder
 		case xml.EndElement:
 			switch token.Name.Local {
 			case stmt.action.String():
+				stmt.Nodes = compactNodeGroup(stmt.Nodes)
 				return nil
 			default:
 				return fmt.Errorf("unexpected end element: %s", token.Name.Local)
@@ -584,21 +778,38 @@ func (p *XMLMappersElementParser) parseStatement(stmt *xmlSQLStatement, decoder
 }
 
 func (p *XMLMappersElementParser) parseTags(mapper *Mapper, decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	if err := p.checkAttributes(token); err != nil {
+		return nil, err
+	}
 	switch token.Name.Local {
 	case "if":
 		return p.parseIf(mapper, decoder, token)
 	case "where":
-		return p.parseWhere(mapper, decoder)
+		return p.parseWhere(mapper, decoder, token)
 	case "trim":
 		return p.parseTrim(mapper, decoder, token)
 	case "foreach":
 		return p.parseForeach(mapper, decoder, token)
 	case "set":
-		return p.parseSet(mapper, decoder)
+		return p.parseSet(mapper, decoder, token)
 	case "include":
 		return p.parseInclude(mapper, decoder, token)
 	case "choose":
 		return p.parseChoose(mapper, decoder)
+	case "case":
+		return p.parseCase(decoder, token)
+	case "columns":
+		return p.parseColumns(decoder, token)
+	case "distinct":
+		return p.parseDistinct(decoder, token)
+	case "aggregate":
+		return p.parseAggregate(decoder, token)
+	case "block":
+		return p.parseBlock(mapper, decoder, token)
+	case "bind":
+		return p.parseBind(decoder, token)
+	case "when", "otherwise":
+		return nil, fmt.Errorf("%s element outside choose", token.Name.Local)
 	}
 	return nil, fmt.Errorf("unknown tag: %s", token.Name.Local)
 }
@@ -641,8 +852,19 @@ func (p *XMLMappersElementParser) parseInclude(mapper *Mapper, decoder *xml.Deco
 	return nil, &nodeUnclosedError{nodeName: "include"}
 }
 
-func (p *XMLMappersElementParser) parseSet(mapper *Mapper, decoder *xml.Decoder) (Node, error) {
+func (p *XMLMappersElementParser) parseSet(mapper *Mapper, decoder *xml.Decoder, token xml.StartElement) (Node, error) {
 	setNode := &SetNode{}
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "param":
+			setNode.Param = attr.Value
+		case "presence":
+			setNode.Presence = attr.Value
+		}
+	}
+	if setNode.Presence != "" && setNode.Param == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "set", attrName: "param"}
+	}
 	for {
 		token, err := decoder.Token()
 		if err != nil {
@@ -720,8 +942,20 @@ func (p *XMLMappersElementParser) parseIf(mapper *Mapper, decoder *xml.Decoder,
 	return nil, &nodeUnclosedError{nodeName: "if"}
 }
 
-func (p *XMLMappersElementParser) parseWhere(mapper *Mapper, decoder *xml.Decoder) (Node, error) {
+func (p *XMLMappersElementParser) parseWhere(mapper *Mapper, decoder *xml.Decoder, token xml.StartElement) (Node, error) {
 	whereNode := &WhereNode{}
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "prefixOverrides":
+			prefixOverrides := strings.Split(attr.Value, "|")
+			for i := range prefixOverrides {
+				prefixOverrides[i] = strings.TrimSpace(prefixOverrides[i])
+			}
+			whereNode.PrefixOverrides = prefixOverrides
+		case "default":
+			whereNode.Default = strings.TrimSpace(attr.Value)
+		}
+	}
 	for {
 		token, err := decoder.Token()
 		if err != nil {
@@ -772,6 +1006,12 @@ func (p *XMLMappersElementParser) parseTrim(mapper *Mapper, decoder *xml.Decoder
 				suffixOverrides[i] = strings.TrimSpace(suffixOverrides[i])
 			}
 			trimNode.SuffixOverrides = suffixOverrides
+		case "compact":
+			compact, err := strconv.ParseBool(attr.Value)
+			if err != nil {
+				return nil, fmt.Errorf("trim: invalid compact %q: %w", attr.Value, err)
+			}
+			trimNode.Compact = compact
 		}
 	}
 	for {
@@ -789,6 +1029,12 @@ func (p *XMLMappersElementParser) parseTrim(mapper *Mapper, decoder *xml.Decoder
 				return nil, err
 			}
 			trimNode.Nodes = append(trimNode.Nodes, node)
+		case xml.CharData:
+			text := string(token)
+			if char := strings.TrimSpace(text); char != "" {
+				node := NewTextNode(char)
+				trimNode.Nodes = append(trimNode.Nodes, node)
+			}
 		case xml.EndElement:
 			if token.Name.Local == "trim" {
 				return trimNode, nil
@@ -814,6 +1060,22 @@ func (p *XMLMappersElementParser) parseForeach(mapper *Mapper, decoder *xml.Deco
 			foreachNode.Separator = attr.Value
 		case "close":
 			foreachNode.Close = attr.Value
+		case "splitSize":
+			splitSize, err := strconv.Atoi(attr.Value)
+			if err != nil || splitSize <= 0 {
+				return nil, fmt.Errorf("foreach: invalid splitSize %q: must be a positive integer", attr.Value)
+			}
+			foreachNode.SplitSize = splitSize
+		case "collection2":
+			foreachNode.Collection2 = attr.Value
+		case "item2":
+			foreachNode.Item2 = attr.Value
+		case "nullable":
+			nullable, err := strconv.ParseBool(attr.Value)
+			if err != nil {
+				return nil, fmt.Errorf("foreach: invalid nullable %q: %w", attr.Value, err)
+			}
+			foreachNode.Nullable = nullable
 		}
 	}
 
@@ -824,6 +1086,12 @@ func (p *XMLMappersElementParser) parseForeach(mapper *Mapper, decoder *xml.Deco
 	if foreachNode.Item == "" {
 		return nil, &nodeAttributeRequiredError{nodeName: "foreach", attrName: "item"}
 	}
+	if foreachNode.Collection2 != "" && foreachNode.Item2 == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "foreach", attrName: "item2"}
+	}
+	if err := foreachNode.check(); err != nil {
+		return nil, err
+	}
 	for {
 		token, err := decoder.Token()
 		if err != nil {
@@ -854,6 +1122,259 @@ func (p *XMLMappersElementParser) parseForeach(mapper *Mapper, decoder *xml.Deco
 	return nil, &nodeUnclosedError{nodeName: "foreach"}
 }
 
+func (p *XMLMappersElementParser) parseCase(decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	caseNode := &CaseNode{}
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "collection":
+			caseNode.Collection = attr.Value
+		case "item":
+			caseNode.Item = attr.Value
+		case "key":
+			caseNode.Key = attr.Value
+		case "keyProperty":
+			caseNode.KeyProperty = attr.Value
+		case "columns":
+			for _, column := range strings.Split(attr.Value, ",") {
+				name, property, _ := strings.Cut(strings.TrimSpace(column), ":")
+				if name = strings.TrimSpace(name); name == "" {
+					continue
+				}
+				if property = strings.TrimSpace(property); property == "" {
+					property = name
+				}
+				caseNode.Columns = append(caseNode.Columns, CaseColumn{Column: name, Property: property})
+			}
+		}
+	}
+	// if collection is empty, use default param key instead.
+	if caseNode.Collection == "" {
+		caseNode.Collection = eval.DefaultParamKey()
+	}
+	for _, required := range []struct{ name, value string }{
+		{"item", caseNode.Item},
+		{"key", caseNode.Key},
+		{"keyProperty", caseNode.KeyProperty},
+	} {
+		if required.value == "" {
+			return nil, &nodeAttributeRequiredError{nodeName: "case", attrName: required.name}
+		}
+	}
+	if len(caseNode.Columns) == 0 {
+		return nil, &nodeAttributeRequiredError{nodeName: "case", attrName: "columns"}
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if end, ok := token.(xml.EndElement); ok && end.Name.Local == "case" {
+			return caseNode, nil
+		}
+	}
+	return nil, &nodeUnclosedError{nodeName: "case"}
+}
+
+func (p *XMLMappersElementParser) parseColumns(decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	columnsNode := &ColumnsNode{}
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "param":
+			columnsNode.Param = attr.Value
+		case "allowed":
+			for _, column := range strings.Split(attr.Value, ",") {
+				if column = strings.TrimSpace(column); column != "" && !slices.Contains(columnsNode.Allowed, column) {
+					columnsNode.Allowed = append(columnsNode.Allowed, column)
+				}
+			}
+		}
+	}
+	if columnsNode.Param == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "columns", attrName: "param"}
+	}
+	if len(columnsNode.Allowed) == 0 {
+		return nil, &nodeAttributeRequiredError{nodeName: "columns", attrName: "allowed"}
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if end, ok := token.(xml.EndElement); ok && end.Name.Local == "columns" {
+			return columnsNode, nil
+		}
+	}
+	return nil, &nodeUnclosedError{nodeName: "columns"}
+}
+
+func (p *XMLMappersElementParser) parseDistinct(decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	distinctNode := &DistinctNode{Nodes: NodeGroup{pureTextNode("DISTINCT")}}
+	var test string
+	for _, attr := range token.Attr {
+		if attr.Name.Local == "test" {
+			test = attr.Value
+			break
+		}
+	}
+	if test == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "distinct", attrName: "test"}
+	}
+	if err := distinctNode.Parse(test); err != nil {
+		return nil, err
+	}
+	if err := skipEmptyElement(decoder, "distinct"); err != nil {
+		return nil, err
+	}
+	return distinctNode, nil
+}
+
+func (p *XMLMappersElementParser) parseAggregate(decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	aggregateNode := &AggregateNode{}
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "param":
+			aggregateNode.Param = attr.Value
+		case "column":
+			aggregateNode.Column = attr.Value
+		case "allowed":
+			for _, function := range strings.Split(attr.Value, ",") {
+				if function = strings.ToUpper(strings.TrimSpace(function)); function != "" {
+					aggregateNode.Allowed = append(aggregateNode.Allowed, function)
+				}
+			}
+		case "default":
+			aggregateNode.Default = attr.Value
+		case "alias":
+			aggregateNode.Alias = attr.Value
+		}
+	}
+	if aggregateNode.Param == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "aggregate", attrName: "param"}
+	}
+	if aggregateNode.Column == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "aggregate", attrName: "column"}
+	}
+	for _, function := range aggregateNode.Allowed {
+		if !slices.Contains(aggregateFunctions, function) {
+			return nil, fmt.Errorf("aggregate: unknown function %q in allowed", function)
+		}
+	}
+	if aggregateNode.Default != "" && !aggregateNode.allows(strings.ToUpper(aggregateNode.Default)) {
+		return nil, fmt.Errorf("aggregate: default function %q is not allowed", aggregateNode.Default)
+	}
+	if err := skipEmptyElement(decoder, "aggregate"); err != nil {
+		return nil, err
+	}
+	return aggregateNode, nil
+}
+
+func (p *XMLMappersElementParser) parseBlock(mapper *Mapper, decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	blockNode := &BlockNode{}
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "name":
+			blockNode.Name = attr.Value
+		case "mode":
+			blockNode.Mode = BlockMode(attr.Value)
+		}
+	}
+	if blockNode.Name == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "block", attrName: "name"}
+	}
+	switch blockNode.Mode {
+	case "", BlockModeReplace, BlockModeAppend, BlockModePrepend:
+	default:
+		return nil, fmt.Errorf("block %s: unknown mode %q", blockNode.Name, blockNode.Mode)
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			node, err := p.parseTags(mapper, decoder, token)
+			if err != nil {
+				return nil, err
+			}
+			blockNode.Nodes = append(blockNode.Nodes, node)
+		case xml.CharData:
+			text := string(token)
+			if char := strings.TrimSpace(text); char != "" {
+				node := NewTextNode(char)
+				blockNode.Nodes = append(blockNode.Nodes, node)
+			}
+		case xml.EndElement:
+			if token.Name.Local == "block" {
+				return blockNode, nil
+			}
+		}
+	}
+	return nil, &nodeUnclosedError{nodeName: "block"}
+}
+
+func (p *XMLMappersElementParser) parseBind(decoder *xml.Decoder, token xml.StartElement) (Node, error) {
+	bindNode := &BindNode{}
+	var value string
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "name":
+			bindNode.Name = attr.Value
+		case "value":
+			value = attr.Value
+		}
+	}
+	if bindNode.Name == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "bind", attrName: "name"}
+	}
+	if value == "" {
+		return nil, &nodeAttributeRequiredError{nodeName: "bind", attrName: "value"}
+	}
+	if err := bindNode.Parse(value); err != nil {
+		return nil, fmt.Errorf("bind %s: %w", bindNode.Name, err)
+	}
+	if err := skipEmptyElement(decoder, "bind"); err != nil {
+		return nil, err
+	}
+	return bindNode, nil
+}
+
+// skipEmptyElement reads the tokens until the end of the element with the given name,
+// which must have no content.
+func skipEmptyElement(decoder *xml.Decoder, name string) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		switch token := token.(type) {
+		case xml.EndElement:
+			if token.Name.Local == name {
+				return nil
+			}
+		case xml.StartElement:
+			return fmt.Errorf("%s element must be empty", name)
+		case xml.CharData:
+			if strings.TrimSpace(string(token)) != "" {
+				return fmt.Errorf("%s element must be empty", name)
+			}
+		}
+	}
+	return &nodeUnclosedError{nodeName: name}
+}
+
 func (p *XMLMappersElementParser) parseChoose(mapper *Mapper, decoder *xml.Decoder) (Node, error) {
 	chooseNode := &ChooseNode{}
 	for {
@@ -866,8 +1387,15 @@ func (p *XMLMappersElementParser) parseChoose(mapper *Mapper, decoder *xml.Decod
 		}
 		switch token := token.(type) {
 		case xml.StartElement:
+			if err = p.checkAttributes(token); err != nil {
+				return nil, err
+			}
 			switch token.Name.Local {
 			case "when":
+				// the otherwise is the fallback of all the whens, a when after it would never be reached.
+				if chooseNode.OtherwiseNode != nil {
+					return nil, errors.New("when element after otherwise in choose")
+				}
 				node, err := p.parseWhen(mapper, decoder, token)
 				if err != nil {
 					return nil, err
@@ -875,13 +1403,16 @@ func (p *XMLMappersElementParser) parseChoose(mapper *Mapper, decoder *xml.Decod
 				chooseNode.WhenNodes = append(chooseNode.WhenNodes, node)
 			case "otherwise":
 				if chooseNode.OtherwiseNode != nil {
-					return nil, errors.New("otherwise is only once")
+					return nil, errors.New("multiple otherwise elements in choose")
 				}
 				node, err := p.parseOtherwise(mapper, decoder)
 				if err != nil {
 					return nil, err
 				}
 				chooseNode.OtherwiseNode = node
+			default:
+				// the other elements would be dropped silently, only the whens and the otherwise are evaluated.
+				return nil, fmt.Errorf("unknown element %s in choose", token.Name.Local)
 			}
 
 		case xml.EndElement:
@@ -930,6 +1461,7 @@ func (p *XMLMappersElementParser) parseSQLNode(mapper *Mapper, decoder *xml.Deco
 			}
 		case xml.EndElement:
 			if token.Name.Local == "sql" {
+				sqlNode.nodes = compactNodeGroup(sqlNode.nodes)
 				return sqlNode, nil
 			}
 		}
@@ -1016,8 +1548,26 @@ func (p *XMLMappersElementParser) parseOtherwise(mapper *Mapper, decoder *xml.De
 	return nil, &nodeUnclosedError{nodeName: "otherwise"}
 }
 
-func (p *XMLMappersElementParser) parseValuesNode(decoder *xml.Decoder) (Node, error) {
+func (p *XMLMappersElementParser) parseValuesNode(decoder *xml.Decoder, token xml.StartElement) (Node, error) {
 	var node = make(ValuesNode, 0)
+	var paramValues ParamValuesNode
+	for _, attr := range token.Attr {
+		switch attr.Name.Local {
+		case "param":
+			paramValues.Param = attr.Value
+		case "keyColumn":
+			paramValues.KeyColumn = attr.Value
+		case "tagged":
+			tagged, err := strconv.ParseBool(attr.Value)
+			if err != nil {
+				return nil, fmt.Errorf("values: invalid tagged %q: %w", attr.Value, err)
+			}
+			paramValues.Tagged = tagged
+		}
+	}
+	if paramValues.Param == "" && (paramValues.KeyColumn != "" || paramValues.Tagged) {
+		return nil, &nodeAttributeRequiredError{nodeName: "values", attrName: "param"}
+	}
 	for {
 		token, err := decoder.Token()
 		if err != nil {
@@ -1030,6 +1580,9 @@ func (p *XMLMappersElementParser) parseValuesNode(decoder *xml.Decoder) (Node, e
 		case xml.StartElement:
 			switch token.Name.Local {
 			case "value":
+				if err = p.checkAttributes(token); err != nil {
+					return nil, err
+				}
 				value, err := p.parseValueNode(token, decoder)
 				if err != nil {
 					return nil, err
@@ -1038,6 +1591,10 @@ func (p *XMLMappersElementParser) parseValuesNode(decoder *xml.Decoder) (Node, e
 			}
 		case xml.EndElement:
 			if token.Name.Local == "values" {
+				if paramValues.Param != "" {
+					paramValues.Values = node
+					return &paramValues, nil
+				}
 				return node, nil
 			}
 		}
@@ -1081,6 +1638,50 @@ func (p *XMLMappersElementParser) parseValueNode(token xml.StartElement, decoder
 	return nil, errors.New("value node requires value attribute to close")
 }
 
+// parseDefaults parses the defaults element of a statement.
+func (p *XMLMappersElementParser) parseDefaults(decoder *xml.Decoder) (H, error) {
+	defaults := make(H)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if token.Name.Local != "default" {
+				return nil, fmt.Errorf("unexpected element %s in defaults", token.Name.Local)
+			}
+			if err = p.checkAttributes(token); err != nil {
+				return nil, err
+			}
+			var name, value string
+			for _, attr := range token.Attr {
+				switch attr.Name.Local {
+				case "name":
+					name = attr.Value
+				case "value":
+					value = attr.Value
+				}
+			}
+			if name == "" {
+				return nil, &nodeAttributeRequiredError{nodeName: "default", attrName: "name"}
+			}
+			if _, exists := defaults[name]; exists {
+				return nil, fmt.Errorf("duplicate default %s", name)
+			}
+			defaults[name] = value
+		case xml.EndElement:
+			if token.Name.Local == "defaults" {
+				return defaults, nil
+			}
+		}
+	}
+	return nil, &nodeUnclosedError{nodeName: "defaults"}
+}
+
 // parseAliasNode parses the alias node
 func (p *XMLMappersElementParser) parseAliasNode(decoder *xml.Decoder) (Node, error) {
 	var node = make(SelectFieldAliasNode, 0)
@@ -1096,6 +1697,9 @@ func (p *XMLMappersElementParser) parseAliasNode(decoder *xml.Decoder) (Node, er
 		case xml.StartElement:
 			switch token.Name.Local {
 			case "field":
+				if err = p.checkAttributes(token); err != nil {
+					return nil, err
+				}
 				item, err := p.parseFieldAlias(token, decoder)
 				if err != nil {
 					return nil, err
@@ -1104,6 +1708,11 @@ func (p *XMLMappersElementParser) parseAliasNode(decoder *xml.Decoder) (Node, er
 			}
 		case xml.EndElement:
 			if token.Name.Local == "alias" {
+				if p.strict {
+					if err = node.check(); err != nil {
+						return nil, err
+					}
+				}
 				return node, nil
 			}
 		}
@@ -0,0 +1,262 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+// ErrDynamicStatement is an error that is returned when a statement with dynamic nodes is prepared.
+var ErrDynamicStatement = errors.New("statement is dynamic")
+
+// PreparedStatement is a statement with a fixed shape, which is built only once.
+// Its query is prepared on the database, and the arguments are extracted from the
+// parameters by the names of the placeholders, so executing it does not build the statement again.
+// It is safe for concurrent use.
+type PreparedStatement struct {
+	statement   Statement
+	query       string
+	names       []string
+	argNames    []string
+	handlers    []string
+	stmt        *sql.Stmt
+	engine      *Engine
+	middlewares MiddlewareGroup
+}
+
+// Prepare prepares the statement of the given value, which must have a fixed shape.
+// The statement must contain no dynamic nodes, like if, where, foreach and ${} substitutions,
+// otherwise ErrDynamicStatement is returned. The placeholders with type handlers are allowed,
+// their values are encoded by the handlers on every execution.
+// The middlewares still run on every execution, if any of them rewrites the query, like the
+// QueryRewriteMiddleware, the rewritten query is executed on the database directly instead of
+// the prepared one, since the prepared one does not match it anymore.
+// The returned PreparedStatement must be closed when it is no longer used.
+func (e *Engine) Prepare(ctx context.Context, v any) (*PreparedStatement, error) {
+	if e.manager.draining() {
+		return nil, ErrDBManagerClosed
+	}
+	statement, err := e.getStatement(v)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := statementNodes(statement)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a xml statement", ErrDynamicStatement, statement.Name())
+	}
+	if err = checkStaticNode(nodes); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, statement.Name())
+	}
+	// build the statement once with the names of the placeholders as their values,
+	// which gives the query and the order of the arguments.
+	translator := e.Driver().Translator()
+	result, err := nodes.AcceptResult(translator, withBuildContext(placeholderNameParameter{}, &buildContext{prepare: true}))
+	if err != nil {
+		return nil, err
+	}
+	// the arguments of the build are the type handlers of the placeholders.
+	handlers := make([]string, len(result.Args))
+	for i, arg := range result.Args {
+		if placeholder, ok := arg.(preparedPlaceholder); ok {
+			handlers[i] = placeholder.typeHandler
+		}
+	}
+	if len(result.Query) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	// the names of the named placeholders, whose arguments are passed as the sql.NamedArg.
+	var argNames []string
+	if named, ok := translator.(driver.NamedArgTranslator); ok {
+		if argNames = named.Names(); len(argNames) != len(result.Names) {
+			return nil, fmt.Errorf("named translator translated %d placeholders for %d arguments", len(argNames), len(result.Names))
+		}
+	}
+	stmt, err := e.DB().PrepareContext(ctx, result.Query)
+	if err != nil {
+		return nil, fmt.Errorf("prepare statement failed: %w", err)
+	}
+	return &PreparedStatement{
+		statement:   statement,
+		query:       result.Query,
+		names:       result.Names,
+		argNames:    argNames,
+		handlers:    handlers,
+		stmt:        stmt,
+		engine:      e,
+		middlewares: e.middlewares,
+	}, nil
+}
+
+// Query returns the prepared query.
+func (p *PreparedStatement) Query() string {
+	return p.query
+}
+
+// args extracts the arguments from the given parameter in the order of the placeholders.
+func (p *PreparedStatement) args(param Param) ([]any, error) {
+	if err := checkStatementFlag(p.statement); err != nil {
+		return nil, err
+	}
+	value := newGenericParam(param, p.statement.Attribute("paramName"))
+	switch statement := p.statement.(type) {
+	case *xmlSQLStatement:
+		value = statement.withDefaults(value)
+	case *databaseIDStatement:
+		value = statement.withDefaults(value)
+	}
+	if err := validateParam(p.statement, value); err != nil {
+		return nil, err
+	}
+	value = withBuildContext(value, &buildContext{typeHandlers: typeHandlersOf(p.statement.Configuration())})
+	args := make([]any, len(p.names))
+	var err error
+	for i, name := range p.names {
+		arg, exists := value.Get(name)
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrParamNotFound, name)
+		}
+		if args[i], err = bindArg(value, p.handlers[i], name, arg); err != nil {
+			return nil, err
+		}
+		if p.argNames != nil {
+			args[i] = sql.Named(p.argNames[i], args[i])
+		}
+	}
+	return args, nil
+}
+
+// handler returns the statement handler which executes the prepared statement through the middlewares.
+// The query rewritten by the middlewares is executed on the database directly.
+func (p *PreparedStatement) handler(args []any) *CompiledStatementHandler {
+	session := p.engine.DB()
+	return &CompiledStatementHandler{
+		query:       p.query,
+		args:        args,
+		middlewares: p.middlewares,
+		driver:      p.engine.Driver(),
+		session:     session,
+		queryHandler: func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+			if query != p.query {
+				return session.QueryContext(ctx, query, args...)
+			}
+			return p.stmt.QueryContext(ctx, args...)
+		},
+		execHandler: func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+			if query != p.query {
+				return session.ExecContext(ctx, query, args...)
+			}
+			return p.stmt.ExecContext(ctx, args...)
+		},
+	}
+}
+
+// QueryContext executes the prepared statement with the given parameter and returns the rows.
+func (p *PreparedStatement) QueryContext(ctx context.Context, param Param) (*sql.Rows, error) {
+	args, err := p.args(param)
+	if err != nil {
+		return nil, err
+	}
+	return p.engine.trackStatementHandler(p.handler(args)).QueryContext(ctx, p.statement, param)
+}
+
+// ExecContext executes the prepared statement with the given parameter.
+func (p *PreparedStatement) ExecContext(ctx context.Context, param Param) (sql.Result, error) {
+	if err := generateKeys(p.statement, param); err != nil {
+		return nil, err
+	}
+	args, err := p.args(param)
+	if err != nil {
+		return nil, err
+	}
+	return p.engine.trackStatementHandler(p.handler(args)).ExecContext(ctx, p.statement, param)
+}
+
+// Close closes the prepared statement.
+func (p *PreparedStatement) Close() error {
+	return p.stmt.Close()
+}
+
+// placeholderNameParameter is a Parameter which returns the name of each parameter as its value.
+type placeholderNameParameter struct{}
+
+// Get implements Parameter.
+func (placeholderNameParameter) Get(name string) (reflect.Value, bool) {
+	return reflect.ValueOf(name), true
+}
+
+// preparedPlaceholder is the argument of a placeholder built by Engine.Prepare,
+// which records the type handler of the placeholder instead of binding a value.
+type preparedPlaceholder struct {
+	typeHandler string
+}
+
+// statementNodes returns the nodes of the xml statements.
+func statementNodes(statement Statement) (NodeGroup, bool) {
+	switch s := statement.(type) {
+	case *xmlSQLStatement:
+		return s.Nodes, true
+	case *databaseIDStatement:
+		return s.Nodes, true
+	default:
+		return nil, false
+	}
+}
+
+// checkStaticNode checks whether the output of the node depends on nothing but the values of its placeholders.
+func checkStaticNode(node Node) error {
+	switch n := node.(type) {
+	case pureTextNode, SelectFieldAliasNode:
+		return nil
+	case *TextNode:
+		if len(n.textSubstitution) > 0 {
+			return fmt.Errorf("%w: text substitution %s", ErrDynamicStatement, n.textSubstitution[0][0])
+		}
+		return nil
+	case ValuesNode:
+		for _, item := range n {
+			if formatRegexp.MatchString(item.value) {
+				return fmt.Errorf("%w: text substitution %s", ErrDynamicStatement, item.value)
+			}
+		}
+		return nil
+	case NodeGroup:
+		for _, child := range n {
+			if err := checkStaticNode(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *SQLNode:
+		return checkStaticNode(n.nodes)
+	case *BlockNode:
+		return checkStaticNode(n.Nodes)
+	case *IncludeNode:
+		sqlNode, err := n.resolve()
+		if err != nil {
+			return err
+		}
+		return checkStaticNode(sqlNode)
+	default:
+		return fmt.Errorf("%w: %T", ErrDynamicStatement, node)
+	}
+}
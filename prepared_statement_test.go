@@ -0,0 +1,102 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEngine_Prepare(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<sql id="columns">name, age</sql>
+<insert id="create">
+    insert into users (<include refid="columns"/>) values (#{name}, #{age})
+</insert>
+<select id="dynamic">
+    select * from users <where><if test="id > 0">id = #{id}</if></where>
+</select>
+<select id="substitution">select * from ${table}</select>
+<update id="settings">update users set settings = #{settings,typeHandler=json} where id = #{id}</update>`)
+
+	stmt, err := engine.Prepare(context.Background(), "main.create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	if stmt.Query() != "insert into users ( name, age ) values (?, ?)" {
+		t.Errorf("unexpected query: %s", stmt.Query())
+		return
+	}
+	for _, param := range []H{{"name": "a", "age": 1}, {"name": "b", "age": 2}} {
+		if _, err = stmt.ExecContext(context.Background(), param); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	calls := db.Calls()
+	if len(calls) != 2 {
+		t.Errorf("expected 2 calls, got %d", len(calls))
+		return
+	}
+	if calls[1].args[0] != "b" || calls[1].args[1] != 2 {
+		t.Errorf("unexpected args: %v", calls[1].args)
+		return
+	}
+
+	if _, err = stmt.ExecContext(context.Background(), H{"name": "c"}); err == nil {
+		t.Error("expected error for missing parameter")
+		return
+	}
+
+	for _, id := range []string{"main.dynamic", "main.substitution"} {
+		if _, err = engine.Prepare(context.Background(), id); !errors.Is(err, ErrDynamicStatement) {
+			t.Errorf("%s: expected ErrDynamicStatement, got %v", id, err)
+			return
+		}
+	}
+
+	// the placeholders with type handlers are encoded on every execution.
+	settings, err := engine.Prepare(context.Background(), "main.settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = settings.Close() }()
+	if _, err = settings.ExecContext(context.Background(), H{"settings": H{"theme": "dark"}, "id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if calls = db.Calls(); string(calls[len(calls)-1].args[0].([]byte)) != `{"theme":"dark"}` {
+		t.Errorf("unexpected args: %v", calls[len(calls)-1].args)
+		return
+	}
+
+	// the query rewritten by the middlewares is executed instead of the prepared one.
+	engine.UseQueryRewriter(StatementIDCommentRewriter)
+	rewritten, err := engine.Prepare(context.Background(), "main.create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rewritten.Close() }()
+	if _, err = rewritten.ExecContext(context.Background(), H{"name": "d", "age": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if calls = db.Calls(); calls[len(calls)-1].query != "/* main.create */ insert into users ( name, age ) values (?, ?)" {
+		t.Errorf("unexpected query: %s", calls[len(calls)-1].query)
+	}
+}
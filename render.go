@@ -0,0 +1,82 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+// Render builds the statement of the given value, like a statement id "main.GetUser", with the parameter,
+// and returns the query and its arguments without executing it.
+// The query is translated by the driver of the default environment and the databaseId variants
+// of the statement are chosen by it, the same as the Engine does, but no connection is opened.
+// It allows running the query by the other runners, like a *sql.DB or sqlx:
+//
+//	query, args, err := juice.Render(cfg, "main.GetUser", juice.H{"id": 1})
+//	row := db.QueryRowContext(ctx, query, args...)
+//
+// The middlewares of the Engine are not involved, so the queries are not rewritten by them.
+func Render(cfg IConfiguration, v any, param Param) (query string, args []any, err error) {
+	envID := cfg.Environments().Attribute("default")
+	env, err := cfg.Environments().Use(envID)
+	if err != nil {
+		return "", nil, err
+	}
+	drv, err := driver.Get(env.Driver)
+	if err != nil {
+		return "", nil, fmt.Errorf("render: environment %s: %w", envID, err)
+	}
+	statement, err := statementOfDatabaseID(cfg, v, databaseIDOf(cfg, envID))
+	if err != nil {
+		return "", nil, err
+	}
+	return statement.Build(drv.Translator(), param)
+}
+
+// fingerprintMarker is the canonical marker which all the placeholders are rendered as by Fingerprint.
+const fingerprintMarker = "?"
+
+// Fingerprint returns a stable fingerprint of the shape of the statement of the given value built with the parameter,
+// which is independent of the values of the arguments, so the metrics or the plan caches can be grouped by it.
+// The dynamic nodes are rendered, so the different branches produce the different fingerprints,
+// while all the placeholders are rendered as the same marker regardless of the driver, and the whitespace is collapsed.
+// The number of the placeholders is a part of the shape, like the ones of a foreach over the different number of items.
+// The databaseId variants of the statement are chosen by the default environment like Render.
+//
+//	fingerprint, err := juice.Fingerprint(cfg, "main.GetUser", juice.H{"id": 1})
+func Fingerprint(cfg IConfiguration, v any, param Param) (string, error) {
+	envID := cfg.Environments().Attribute("default")
+	statement, err := statementOfDatabaseID(cfg, v, databaseIDOf(cfg, envID))
+	if err != nil {
+		return "", err
+	}
+	query, _, err := statement.Build(fingerprintTranslator, param)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.Join(strings.Fields(query), " ")))
+	return strconv.FormatUint(h.Sum64(), 16), nil
+}
+
+// fingerprintTranslator translates all the placeholders to the fingerprintMarker.
+var fingerprintTranslator = driver.TranslateFunc(func(string) string { return fingerprintMarker })
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <environments default="test">
+        <environment id="test">
+            <dataSource>unused</dataSource>
+            <driver>`+fakeDriverName+`</driver>
+        </environment>
+    </environments>
+    <mappers>
+        <mapper namespace="main">
+            <select id="get">
+                select * from users
+                <where>
+                    <if test="id > 0">id = #{id}</if>
+                    <foreach collection="names" item="name" open="and name in (" separator="," close=")" nullable="true">#{name}</foreach>
+                </where>
+            </select>
+            <select id="now" databaseId="mysql">select current_timestamp()</select>
+            <select id="now">select current_timestamp</select>
+        </mapper>
+    </mappers>
+    <databaseIdProvider>
+        <property name="`+fakeDriverName+`" value="mysql"/>
+    </databaseIdProvider>
+</configuration>`)
+
+	query, args, err := Render(cfg, "main.get", H{"id": 1, "names": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from users WHERE id = ? and name in (?,?)" || len(args) != 3 || args[0] != 1 || args[2] != "b" {
+		t.Errorf("unexpected render: %q %v", query, args)
+	}
+
+	// the variant of the databaseId of the default environment is chosen.
+	if query, _, err = Render(cfg, "main.now", nil); err != nil || query != "select current_timestamp()" {
+		t.Errorf("unexpected render: %q %v", query, err)
+	}
+
+	if _, _, err = Render(cfg, "main.missing", nil); err == nil {
+		t.Error("expected error for the unknown statement")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <environments default="test">
+        <environment id="test">
+            <dataSource>unused</dataSource>
+            <driver>postgres</driver>
+        </environment>
+    </environments>
+    <mappers>
+        <mapper namespace="main">
+            <select id="get">
+                select * from users
+                <where>
+                    <if test="id > 0">id = #{id}</if>
+                    <if test='name != ""'>and name = #{name}</if>
+                </where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	fingerprint := func(param H) string {
+		t.Helper()
+		value, err := Fingerprint(cfg, "main.get", param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	byID := fingerprint(H{"id": 1, "name": ""})
+	if byID == "" || byID != fingerprint(H{"id": 2, "name": ""}) {
+		t.Error("expected the same fingerprint for the different values")
+	}
+	if byID == fingerprint(H{"id": 1, "name": "a"}) || byID == fingerprint(H{"id": 0, "name": ""}) {
+		t.Error("expected the different fingerprints for the different branches")
+	}
+	if _, err := Fingerprint(cfg, "main.missing", nil); err == nil {
+		t.Error("expected error for the unknown statement")
+	}
+}
@@ -22,24 +22,78 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
+	"unicode"
 )
 
 // ErrTooManyRows is returned when the result set has too many rows but excepted only one row.
 var ErrTooManyRows = errors.New("juice: too many rows in result set")
 
+// ErrMaxRowsExceeded is returned when the result set has more rows than the MaxRows of MultiRowsResultMap.
+var ErrMaxRowsExceeded = errors.New("juice: max rows exceeded")
+
 // ResultMap is an interface that defines a method for mapping database query results to Go data structures.
 type ResultMap interface {
 	// MapTo maps the data from the SQL row to the provided reflect.Value.
 	MapTo(rv reflect.Value, row *sql.Rows) error
 }
 
+// ColumnMapping configures how the result columns are mapped to the struct fields.
+//
+// The columns are matched to the column tags of the fields case-sensitively by default,
+// which is the strict mode. With CaseInsensitive, both sides are lowercased before comparing,
+// which helps with the databases returning the names in their own case, like Oracle uppercases them.
+// MapUnderscoreToCamelCase layers on top of it, the untagged exported fields are matched by the
+// snake_case of their names, like CreatedAt is matched to created_at, and to CREATED_AT if CaseInsensitive.
+// A NamingStrategy derives the names of the untagged fields in its own way instead.
+// An explicit column tag always takes precedence over a name derived from a field.
+type ColumnMapping struct {
+	// NullAsZero makes the NULL columns scanned as the zero values of the struct fields,
+	// instead of returning an error for the fields which can not hold NULL.
+	// The pointers and sql.Null* fields still reflect the NULL explicitly.
+	NullAsZero bool
+
+	// CaseInsensitive makes the columns matched to the fields case-insensitively.
+	CaseInsensitive bool
+
+	// MapUnderscoreToCamelCase makes the untagged fields matched by the snake_case of their names.
+	// It is a shorthand of the snake_case DefaultNamingStrategy, which is used if NamingStrategy is nil.
+	MapUnderscoreToCamelCase bool
+
+	// NamingStrategy makes the untagged exported fields matched by the column names it derives from their names.
+	NamingStrategy NamingStrategy
+
+	// TypeHandlers decodes the columns scanned into the destinations of the types registered in it.
+	TypeHandlers *TypeHandlers
+}
+
+// namingStrategy returns the naming strategy which derives the column names of the untagged fields,
+// or nil if the untagged fields are not matched.
+func (m ColumnMapping) namingStrategy() NamingStrategy {
+	if m.NamingStrategy != nil {
+		return m.NamingStrategy
+	}
+	if m.MapUnderscoreToCamelCase {
+		return DefaultNamingStrategy{SnakeCase: true}
+	}
+	return nil
+}
+
+// isDefault reports whether the mapping is the default one, which configures nothing.
+func (m ColumnMapping) isDefault() bool {
+	return !m.NullAsZero && !m.CaseInsensitive && !m.MapUnderscoreToCamelCase && m.NamingStrategy == nil && m.TypeHandlers == nil
+}
+
 // SingleRowResultMap is a ResultMap that maps a rowDestination to a non-slice type.
-type SingleRowResultMap struct{}
+type SingleRowResultMap struct {
+	ColumnMapping
+}
 
 // MapTo implements ResultMapper interface.
 // It maps the data from the SQL row to the provided reflect.Value.
 // If more than one row is returned from the query, it returns an ErrTooManyRows error.
-func (SingleRowResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
+func (m SingleRowResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
 	// Validate input is a pointer
 	if rv.Kind() != reflect.Ptr {
 		return ErrPointerRequired
@@ -63,7 +117,7 @@ func (SingleRowResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
 	targetValue := reflect.Indirect(rv)
 
 	// Create destination mapper
-	columnDest := &rowDestination{}
+	columnDest := &rowDestination{ColumnMapping: m.ColumnMapping}
 
 	// Map columns to struct fields and create scan destinations
 	dest, err := columnDest.Destination(targetValue, columns)
@@ -75,6 +129,9 @@ func (SingleRowResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
 	if err = rows.Scan(dest...); err != nil {
 		return fmt.Errorf("failed to scan row: %w", err)
 	}
+	if err = columnDest.scanProbes(rows, targetValue); err != nil {
+		return fmt.Errorf("failed to scan row: %w", err)
+	}
 
 	// Check for any errors that occurred during row scanning
 	if err = rows.Err(); err != nil {
@@ -92,6 +149,20 @@ func (SingleRowResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
 // MultiRowsResultMap is a ResultMap that maps a rowDestination to a slice type.
 type MultiRowsResultMap struct {
 	New func() reflect.Value
+
+	ColumnMapping
+
+	// MaxRows is the maximum number of rows to map, which protects the memory from the runaway queries.
+	// If the result set has more rows, ErrMaxRowsExceeded is returned, or the rows are truncated to MaxRows
+	// if TruncateRows is true. Zero means no limit.
+	MaxRows int
+
+	// TruncateRows makes the rows beyond MaxRows dropped instead of returning an error.
+	TruncateRows bool
+
+	// Truncated is set to true if the rows are truncated to MaxRows, so the caller can tell
+	// a truncated result from a complete one.
+	Truncated *bool
 }
 
 // MapTo implements ResultMapper interface.
@@ -151,6 +222,47 @@ func (m MultiRowsResultMap) resolveTypes(elementType reflect.Type) (bool, bool)
 	return isPointer, pointerType.Implements(rowScannerType)
 }
 
+// exceeded reports whether the next row exceeds MaxRows, the mapped is the number of the rows mapped.
+// It returns ErrMaxRowsExceeded if the rows are not truncated.
+func (m MultiRowsResultMap) exceeded(mapped int) (bool, error) {
+	if m.MaxRows <= 0 || mapped < m.MaxRows {
+		return false, nil
+	}
+	if m.TruncateRows {
+		if m.Truncated != nil {
+			*m.Truncated = true
+		}
+		return true, nil
+	}
+	return true, fmt.Errorf("%w: more than %d rows", ErrMaxRowsExceeded, m.MaxRows)
+}
+
+// maxRowsResultMap limits the rows of the slice mapped by the ResultMap to MaxRows after the mapping,
+// it guards the result maps which do not stop at MaxRows by themselves.
+type maxRowsResultMap struct {
+	ResultMap
+	MaxRows      int
+	TruncateRows bool
+	Truncated    *bool
+}
+
+// MapTo implements ResultMap.
+func (m maxRowsResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
+	if err := m.ResultMap.MapTo(rv, rows); err != nil {
+		return err
+	}
+	target := reflect.Indirect(rv)
+	if target.Kind() != reflect.Slice || target.Len() <= m.MaxRows {
+		return nil
+	}
+	limit := MultiRowsResultMap{MaxRows: m.MaxRows, TruncateRows: m.TruncateRows, Truncated: m.Truncated}
+	if _, err := limit.exceeded(target.Len()); err != nil {
+		return err
+	}
+	target.SetLen(m.MaxRows)
+	return nil
+}
+
 // mapRows maps the rows to a slice of reflect.Values
 func (m MultiRowsResultMap) mapRows(rows *sql.Rows, isPointer bool, useScanner bool) ([]reflect.Value, error) {
 	if useScanner {
@@ -165,6 +277,12 @@ func (m MultiRowsResultMap) mapWithRowScanner(rows *sql.Rows, isPointer bool) ([
 	values := make([]reflect.Value, 0, 8)
 
 	for rows.Next() {
+		if exceeded, err := m.exceeded(len(values)); exceeded {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
 		// Create a new instance. Since RowScanner is implemented with pointer receiver,
 		// we always create a pointer type and use it directly for scanning
 		newValue := m.New()
@@ -192,11 +310,17 @@ func (m MultiRowsResultMap) mapWithColumnDestination(rows *sql.Rows, isPointer b
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
 	}
-	columnDest := &rowDestination{}
+	columnDest := &rowDestination{ColumnMapping: m.ColumnMapping}
 	// Pre-allocate slice with an initial capacity
 	values := make([]reflect.Value, 0, 8)
 
 	for rows.Next() {
+		if exceeded, err := m.exceeded(len(values)); exceeded {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
 		// Create a new instance and get its underlying value for column mapping
 		newValue := m.New()
 		elementValue := newValue.Elem()
@@ -211,6 +335,9 @@ func (m MultiRowsResultMap) mapWithColumnDestination(rows *sql.Rows, isPointer b
 		if err = rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
+		if err = columnDest.scanProbes(rows, elementValue); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
 
 		// Append either the pointer or the value based on the target type
 		if isPointer {
@@ -244,6 +371,20 @@ type rowDestination struct {
 	// - Multiple integers represent nested struct field access
 	indexes [][]int
 
+	// pointers stores the index of the innermost embedded struct pointer field which the column is
+	// mapped through, nil means the column is not mapped through any embedded struct pointer.
+	// These columns are scanned into the probes first, each pointer along the path is only allocated
+	// when any of the columns under it is not NULL, otherwise it is left nil.
+	pointers [][]int
+
+	// probes are the destinations of the columns which are scanned into the probes first,
+	// like the columns mapped through the embedded struct pointers.
+	probes []any
+
+	// ColumnMapping configures how the columns are mapped to the fields.
+	// All the mapped columns are scanned into the probes first in the NullAsZero mode.
+	ColumnMapping
+
 	// checked indicates whether the destination has been validated for sql.RawBytes.
 	// This flag helps avoid redundant checks for the same rowDestination instance.
 	checked bool
@@ -252,6 +393,11 @@ type rowDestination struct {
 	// corresponding struct fields. Each rowDestination instance maintains its
 	// own discard variable to ensure thread safety during concurrent scans.
 	discard any
+
+	// columns and values are the columns and their scanned values of the current row
+	// when the destination is a map, they are put into the map by scanProbes.
+	columns []string
+	values  []reflect.Value
 }
 
 // Destination returns the destination for the given reflect value and column.
@@ -270,6 +416,9 @@ func (s *rowDestination) Destination(rv reflect.Value, columns []string) ([]any,
 }
 
 func (s *rowDestination) destinationForOneColumn(rv reflect.Value, columns []string) ([]any, error) {
+	if _, ok := s.TypeHandlers.lookupDecoder(rv.Type()); ok {
+		return []any{s.addr(rv)}, nil
+	}
 	// if type is time.Time or implements sql.Scanner, we can scan it directly
 	if rv.Type() == timeType || rv.Type().Implements(scannerType) {
 		return []any{rv.Addr().Interface()}, nil
@@ -277,6 +426,9 @@ func (s *rowDestination) destinationForOneColumn(rv reflect.Value, columns []str
 	if rv.Kind() == reflect.Struct {
 		return s.destinationForStruct(rv, columns)
 	}
+	if isStringKeyMap(rv.Type()) {
+		return s.destinationForMap(rv, columns)
+	}
 	// default behavior
 	return []any{rv.Addr().Interface()}, nil
 }
@@ -288,46 +440,166 @@ func (s *rowDestination) destination(rv reflect.Value, columns []string) ([]any,
 	if rv.Kind() == reflect.Struct {
 		return s.destinationForStruct(rv, columns)
 	}
+	if isStringKeyMap(rv.Type()) {
+		return s.destinationForMap(rv, columns)
+	}
 	return nil, fmt.Errorf("expected struct, but got %s", rv.Kind())
 }
 
+// destinationForMap returns the destinations of the values of the columns,
+// which are put into the map by their column names in scanProbes.
+func (s *rowDestination) destinationForMap(rv reflect.Value, columns []string) ([]any, error) {
+	s.columns = columns
+	s.values = make([]reflect.Value, len(columns))
+	dest := make([]any, len(columns))
+	for i := range columns {
+		s.values[i] = reflect.New(rv.Type().Elem())
+		dest[i] = s.values[i].Interface()
+	}
+	return dest, nil
+}
+
+// isStringKeyMap reports whether the given type is a map with string keys.
+func isStringKeyMap(tp reflect.Type) bool {
+	return tp.Kind() == reflect.Map && tp.Key().Kind() == reflect.String
+}
+
 func (s *rowDestination) destinationForStruct(rv reflect.Value, columns []string) ([]any, error) {
 	if len(s.indexes) == 0 {
 		s.setIndexes(rv, columns)
 	}
 	dest := make([]any, len(columns))
 	for i, indexes := range s.indexes {
-		if len(indexes) == 0 {
+		switch {
+		case len(indexes) == 0:
 			dest[i] = &s.discard
-		} else {
-			dest[i] = rv.FieldByIndex(indexes).Addr().Interface()
+		case s.NullAsZero || s.pointers[i] != nil:
+			dest[i] = &s.probes[i]
+		default:
+			dest[i] = s.addr(rv.FieldByIndex(indexes))
 		}
 	}
 	return dest, nil
 }
 
+// scanProbes scans the columns which are scanned into the probes of the given struct value,
+// which must be called after the row is scanned into the destinations.
+// The embedded struct pointers whose columns are all NULL are left nil, like the absent rows of a LEFT JOIN,
+// the others are allocated and their fields are filled by scanning the row again. The nested pointers
+// are checked at each level, so a pointer inside an allocated one is still left nil if its columns are all NULL.
+// The fields of the NULL columns are left zero, except the fields which can hold NULL, like pointers
+// and sql.Scanner implementations, which are still scanned and reflect the NULL explicitly.
+// If the destination is a map, the scanned values are put into it by their column names instead.
+func (s *rowDestination) scanProbes(rows *sql.Rows, rv reflect.Value) error {
+	if s.values != nil {
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(s.values)))
+		}
+		for i, value := range s.values {
+			rv.SetMapIndex(reflect.ValueOf(s.columns[i]).Convert(rv.Type().Key()), value.Elem())
+		}
+		return nil
+	}
+	for i, pointer := range s.pointers {
+		if pointer == nil || s.probes[i] == nil {
+			continue
+		}
+		// the outer pointers along the path are allocated as well.
+		if field := fieldByIndexAlloc(rv, pointer); field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+	}
+	var rescan bool
+	dest := make([]any, len(s.indexes))
+	for i, indexes := range s.indexes {
+		dest[i] = &s.discard
+		if len(indexes) == 0 || !s.NullAsZero && s.pointers[i] == nil {
+			continue
+		}
+		if pointer := s.pointers[i]; pointer != nil {
+			// an outer pointer along the path may be left nil as well.
+			if field, err := rv.FieldByIndexErr(pointer); err != nil || field.IsNil() {
+				continue
+			}
+		}
+		field := fieldByIndexAlloc(rv, indexes)
+		if s.probes[i] == nil && !canHoldNull(field.Type()) {
+			field.SetZero()
+			continue
+		}
+		dest[i] = s.addr(field)
+		rescan = true
+	}
+	if !rescan {
+		return nil
+	}
+	return rows.Scan(dest...)
+}
+
+// addr returns the scan destination of the given addressable value,
+// which is decoded by the type handler of its type if any.
+func (s *rowDestination) addr(rv reflect.Value) any {
+	if handler, ok := s.TypeHandlers.lookupDecoder(rv.Type()); ok {
+		return &typeHandlerScanner{handler: handler, dest: rv.Addr().Interface()}
+	}
+	return rv.Addr().Interface()
+}
+
+// canHoldNull reports whether the field of the given type can be scanned from NULL.
+func canHoldNull(tp reflect.Type) bool {
+	switch tp.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	default:
+		return reflect.PointerTo(tp).Implements(scannerType)
+	}
+}
+
+// fieldByIndexAlloc returns the nested field of the given struct value like reflect.Value.FieldByIndex,
+// but allocates the nil struct pointers along the path instead of panicking.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
 // setIndexes sets the indexes for the given reflect value and columns.
 func (s *rowDestination) setIndexes(rv reflect.Value, columns []string) {
 	tp := rv.Type()
 	s.indexes = make([][]int, len(columns))
+	s.pointers = make([][]int, len(columns))
+	s.probes = make([]any, len(columns))
 
-	// columnIndex is a map to store the index of the column.
+	// columnIndex is a map to store the index of the normalized column.
 	columnIndex := func() map[string]int {
 		m := make(map[string]int)
 		for i, column := range columns {
-			m[column] = i
+			m[s.normalize(column)] = i
 		}
 		return m
 	}()
 
-	s.findFromStruct(tp, columns, columnIndex, nil)
+	s.findFromStruct(tp, columns, columnIndex, nil, nil)
 }
 
 // findFromStruct finds the index from the given struct type.
-func (s *rowDestination) findFromStruct(tp reflect.Type, columns []string, columnIndex map[string]int, walk []int) {
+// The pointer is the index of the embedded struct pointer field which the walk goes through.
+func (s *rowDestination) findFromStruct(tp reflect.Type, columns []string, columnIndex map[string]int, walk []int, pointer []int) {
+	strategy := s.namingStrategy()
 
 	// finished is a helper function to check if the indexes completed or not.
 	finished := func() bool {
+		// a column matched by a field name may still be taken by a tagged field later.
+		if strategy != nil {
+			return false
+		}
 		for i := range columns {
 			if len(s.indexes[i]) == 0 {
 				return false
@@ -344,23 +616,67 @@ func (s *rowDestination) findFromStruct(tp reflect.Type, columns []string, colum
 		}
 		field := tp.Field(i)
 		tag := field.Tag.Get("column")
+		// the untagged fields are matched by the names derived by the naming strategy.
+		if derive := tag == "" && !field.Anonymous && strategy != nil && field.IsExported(); derive {
+			// the column tagged explicitly takes precedence.
+			if index, ok := columnIndex[s.normalize(strategy.ColumnName(field.Name))]; ok && len(s.indexes[index]) == 0 {
+				s.indexes[index] = append(walk, field.Index...)
+				s.pointers[index] = pointer
+			}
+			continue
+		}
 		// if the tag is empty or "-", we can skip it.
 		if skip := tag == "" && !field.Anonymous || tag == "-"; skip {
 			continue
 		}
 		// if the field is anonymous and the type is struct, we can walk into it.
 		if deepScan := field.Anonymous && field.Type.Kind() == reflect.Struct && len(tag) == 0; deepScan {
-			s.findFromStruct(field.Type, columns, columnIndex, append(walk, i))
+			s.findFromStruct(field.Type, columns, columnIndex, append(walk, i), pointer)
+			continue
+		}
+		// if the field is an exported embedded struct pointer, walk into it and mark its columns,
+		// so that the pointer is only allocated when it has any value.
+		if field.Anonymous && field.IsExported() && len(tag) == 0 &&
+			field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
+			next := slices.Concat(walk, []int{i})
+			s.findFromStruct(field.Type.Elem(), columns, columnIndex, next, next)
 			continue
 		}
 		// find the index of the column
-		index, ok := columnIndex[tag]
+		index, ok := columnIndex[s.normalize(tag)]
 		if !ok {
 			continue
 		}
 		// set the index
 		s.indexes[index] = append(walk, field.Index...)
+		s.pointers[index] = pointer
+	}
+}
+
+// normalize normalizes the column name for matching.
+func (s *rowDestination) normalize(name string) string {
+	if s.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// toSnakeCase converts the camel case name to snake_case, like UserID to user_id and HTTPServer to http_server.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	builder := getStringBuilder()
+	defer putStringBuilder(builder)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])) {
+				builder.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		builder.WriteRune(r)
 	}
+	return builder.String()
 }
 
 var errRawBytesScan = errors.New("sql: RawBytes isn't allowed on scan")
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrResultMapNotFound is an error that is returned when the result map chosen by WithResultMap is not registered.
+var ErrResultMapNotFound = errors.New("result map not found")
+
+// ErrMissingColumns is an error that is returned when the result set does not have the columns
+// required by a ColumnsResultMap.
+var ErrMissingColumns = errors.New("missing columns")
+
+// resultMaps is a map of the registered result maps by their ids.
+var resultMaps = map[string]ResultMap{}
+
+// RegisterResultMap registers a result map by the given id, which can be chosen by WithResultMap at call time.
+// The id can be scoped to a mapper by prefixing it with the namespace of the mapper, like "users.summary".
+func RegisterResultMap(id string, resultMap ResultMap) {
+	if len(id) == 0 {
+		panic("id is empty")
+	}
+	if resultMap == nil {
+		panic("juice: result map is nil")
+	}
+	resultMaps[id] = resultMap
+}
+
+// WithResultMap returns a copy of the executor which binds the result with the result map registered
+// by the given id, overriding the default binding of the statement, so one statement can serve
+// multiple projections. The choice belongs to the returned executor only, unlike a context value
+// it does not reach the other queries running with the same context.
+// The id is resolved against the mapper of the statement first, like "users.summary" for the
+// statement "users.list", then as is. ErrResultMapNotFound is returned if none is registered.
+// The executor must be a GenericExecutor, the others are invalid.
+func WithResultMap[T any](executor Executor[T], id string) Executor[T] {
+	exe, ok := executor.(*GenericExecutor[T])
+	if !ok {
+		return &GenericExecutor[T]{SQLRowsExecutor: inValidExecutor(fmt.Errorf("WithResultMap requires a GenericExecutor, got %T", executor))}
+	}
+	chosen := *exe
+	chosen.resultMap = id
+	return &chosen
+}
+
+// lookupResultMap returns the result map registered by the id for the given statement.
+func lookupResultMap(id string, statement Statement) (ResultMap, error) {
+	if index := strings.LastIndex(statement.Name(), "."); index > 0 {
+		if resultMap, exists := resultMaps[statement.Name()[:index+1]+id]; exists {
+			return resultMap, nil
+		}
+	}
+	if resultMap, exists := resultMaps[id]; exists {
+		return resultMap, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrResultMapNotFound, id)
+}
+
+// ColumnsResultMap is a ResultMap which requires the result set to have all the given columns,
+// then maps the rows with the ResultMap, or the default one of the destination if it is nil.
+// It makes sure the query satisfies the projection it is bound to.
+type ColumnsResultMap struct {
+	Columns []string
+
+	// Optional are the columns of Columns which may be absent from the result set,
+	// their fields are left zero then, so the queries can evolve without breaking the map.
+	// The other columns are still required.
+	Optional []string
+
+	ResultMap ResultMap
+}
+
+// MapTo implements ResultMap.
+func (m ColumnsResultMap) MapTo(rv reflect.Value, rows *sql.Rows) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to get columns: %w", err)
+	}
+	var missing []string
+	for _, column := range m.Columns {
+		if !slices.Contains(columns, column) && !slices.Contains(m.Optional, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+	resultMap := m.ResultMap
+	if resultMap == nil {
+		if reflect.Indirect(rv).Kind() == reflect.Slice {
+			resultMap = MultiRowsResultMap{}
+		} else {
+			resultMap = SingleRowResultMap{}
+		}
+	}
+	return resultMap.MapTo(rv, rows)
+}
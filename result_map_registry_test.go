@@ -0,0 +1,94 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithResultMap(t *testing.T) {
+	RegisterResultMap("main.summary", ColumnsResultMap{Columns: []string{"id", "name"}})
+	RegisterResultMap("detail", ColumnsResultMap{Columns: []string{"id", "name", "email"}})
+	RegisterResultMap("optional", ColumnsResultMap{Columns: []string{"id", "name", "email"}, Optional: []string{"email"}})
+	RegisterResultMap("required", ColumnsResultMap{Columns: []string{"id", "age", "email"}, Optional: []string{"email"}})
+	t.Cleanup(func() {
+		delete(resultMaps, "main.summary")
+		delete(resultMaps, "detail")
+		delete(resultMaps, "optional")
+		delete(resultMaps, "required")
+	})
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id", "name"}, [][]sqldriver.Value{{int64(1), "a"}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="users">select id, name from users</select>`)
+
+	type Summary struct {
+		ID   int64  `column:"id"`
+		Name string `column:"name"`
+	}
+	executor := NewGenericManager[[]Summary](engine).Object("main.users")
+
+	// the id is resolved against the mapper of the statement.
+	users, err := WithResultMap(executor, "summary").QueryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0] != (Summary{ID: 1, Name: "a"}) {
+		t.Errorf("unexpected users: %+v", users)
+		return
+	}
+	if _, err = WithResultMap(executor, "detail").QueryContext(context.Background(), nil); !errors.Is(err, ErrMissingColumns) {
+		t.Errorf("expected ErrMissingColumns, got %v", err)
+		return
+	}
+	// the optional columns may be absent, but the others are still required.
+	if users, err = WithResultMap(executor, "optional").QueryContext(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0] != (Summary{ID: 1, Name: "a"}) {
+		t.Errorf("unexpected users: %+v", users)
+		return
+	}
+	if _, err = WithResultMap(executor, "required").QueryContext(context.Background(), nil); !errors.Is(err, ErrMissingColumns) || !strings.HasSuffix(err.Error(), ": age") {
+		t.Errorf("expected ErrMissingColumns of age, got %v", err)
+		return
+	}
+	if _, err = WithResultMap(executor, "missing").QueryContext(context.Background(), nil); !errors.Is(err, ErrResultMapNotFound) {
+		t.Errorf("expected ErrResultMapNotFound, got %v", err)
+		return
+	}
+
+	// the chosen result map belongs to the returned executor only.
+	if _, err = executor.QueryContext(context.Background(), nil); err != nil {
+		t.Errorf("expected the default binding, got %v", err)
+		return
+	}
+	if _, err = WithResultMap[[]Summary](otherExecutor[[]Summary]{}, "summary").QueryContext(context.Background(), nil); !errors.Is(err, ErrInvalidExecutor) {
+		t.Errorf("expected ErrInvalidExecutor, got %v", err)
+	}
+}
+
+// otherExecutor is an Executor which is not a GenericExecutor.
+type otherExecutor[T any] struct {
+	Executor[T]
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// QueryRewriter rewrites the rendered query of a statement before it is executed.
+// The statementID is the full name of the statement, like "main.UserRepository.GetUser".
+// A QueryRewriter must be deterministic and must not change the placeholders of the query,
+// since the arguments have already been bound to them.
+type QueryRewriter func(ctx context.Context, statementID string, query string) string
+
+// StatementIDCommentRewriter prepends the statement id as an SQL comment to the query,
+// which makes the statement identifiable by database side monitoring tools.
+//
+//	/* main.UserRepository.GetUser */ SELECT * FROM user WHERE id = ?
+func StatementIDCommentRewriter(_ context.Context, statementID string, query string) string {
+	return "/* " + escapeSQLComment(statementID) + " */ " + query
+}
+
+// escapeSQLComment makes sure the given text can not terminate the comment it is put in.
+func escapeSQLComment(text string) string {
+	return strings.ReplaceAll(text, "*/", "* /")
+}
+
+// ensure QueryRewriteMiddleware implements Middleware
+var _ Middleware = (*QueryRewriteMiddleware)(nil) // compile time check
+
+// QueryRewriteMiddleware is a middleware that rewrites the query with the given QueryRewriter.
+// Since the middlewares added later are executed earlier, the middlewares added before it,
+// like DebugMiddleware, will see the rewritten query.
+type QueryRewriteMiddleware struct {
+	Rewriter QueryRewriter
+}
+
+// QueryContext implements Middleware.
+func (m *QueryRewriteMiddleware) QueryContext(stmt Statement, next QueryHandler) QueryHandler {
+	if m.Rewriter == nil {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+		return next(ctx, m.Rewriter(ctx, stmt.Name(), query), args...)
+	}
+}
+
+// ExecContext implements Middleware.
+func (m *QueryRewriteMiddleware) ExecContext(stmt Statement, next ExecHandler) ExecHandler {
+	if m.Rewriter == nil {
+		return next
+	}
+	return func(ctx context.Context, query string, args ...any) (sql.Result, error) {
+		return next(ctx, m.Rewriter(ctx, stmt.Name(), query), args...)
+	}
+}
+
+// sqlCommentTagsKey is the context key of the sql comment tags.
+type sqlCommentTagsKey struct{}
+
+// ContextWithSQLCommentTag returns a new context which carries the given sql comment tag.
+// The tags of the parent context are kept.
+func ContextWithSQLCommentTag(ctx context.Context, key, value string) context.Context {
+	parent, _ := ctx.Value(sqlCommentTagsKey{}).(map[string]string)
+	tags := make(map[string]string, len(parent)+1)
+	maps.Copy(tags, parent)
+	tags[key] = value
+	return context.WithValue(ctx, sqlCommentTagsKey{}, tags)
+}
+
+// ContextWithTraceparent returns a new context which carries the given W3C traceparent,
+// like "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ContextWithTraceparent(ctx context.Context, traceparent string) context.Context {
+	return ContextWithSQLCommentTag(ctx, "traceparent", traceparent)
+}
+
+// SQLCommentTagsFromContext returns the sql comment tags carried by the context.
+func SQLCommentTagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(sqlCommentTagsKey{}).(map[string]string)
+	return tags
+}
+
+// SQLCommenter appends the tags of the context to the query as a comment
+// in the sqlcommenter format, so that database monitoring tools can correlate
+// the queries with the traces.
+//
+//	SELECT * FROM user /*application='api',traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/
+//
+// Use its Rewrite method as a QueryRewriter:
+//
+//	engine.UseQueryRewriter(juice.SQLCommenter{Application: "api"}.Rewrite)
+type SQLCommenter struct {
+	// Application is the value of the application tag.
+	// It is only appended when the context carries tags.
+	Application string
+
+	// Keys is the set of the tag keys which are allowed to be appended.
+	// If empty, all the tags are appended.
+	Keys []string
+
+	// Tags returns the tags of the context.
+	// If nil, SQLCommentTagsFromContext is used, which allows tracing integrations
+	// to be plugged in by replacing it.
+	Tags func(ctx context.Context) map[string]string
+}
+
+// Rewrite implements QueryRewriter.
+// It returns the query as it is when the context carries no tags.
+func (c SQLCommenter) Rewrite(ctx context.Context, _ string, query string) string {
+	tagsFunc := c.Tags
+	if tagsFunc == nil {
+		tagsFunc = SQLCommentTagsFromContext
+	}
+	tags := tagsFunc(ctx)
+	if len(c.Keys) > 0 {
+		tags = maps.Clone(tags)
+		maps.DeleteFunc(tags, func(key, _ string) bool { return !slices.Contains(c.Keys, key) })
+	}
+	if len(tags) == 0 {
+		return query
+	}
+	if c.Application != "" {
+		if _, exists := tags["application"]; !exists {
+			tags = maps.Clone(tags)
+			tags["application"] = c.Application
+		}
+	}
+
+	var builder = getStringBuilder()
+	defer putStringBuilder(builder)
+
+	builder.WriteString(query)
+	builder.WriteString(" /*")
+	// serialize the tags in lexicographic order of their keys as the sqlcommenter does.
+	for i, key := range slices.Sorted(maps.Keys(tags)) {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+		builder.WriteString(encodeSQLCommentValue(key))
+		builder.WriteString("='")
+		builder.WriteString(encodeSQLCommentValue(tags[key]))
+		builder.WriteString("'")
+	}
+	builder.WriteString("*/")
+	return builder.String()
+}
+
+// encodeSQLCommentValue url-encodes the given text, which escapes the single quotes and the slashes,
+// so the text can neither terminate the comment nor the quoted value.
+func encodeSQLCommentValue(text string) string {
+	return strings.ReplaceAll(url.QueryEscape(text), "+", "%20")
+}
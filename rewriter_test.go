@@ -0,0 +1,84 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEngine_UseQueryRewriter(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main.UserRepository", `
+    <select id="GetUser">select * from user where id = #{id}</select>
+    <delete id="DeleteUser">delete from user where id = #{id}</delete>`)
+	engine.UseQueryRewriter(StatementIDCommentRewriter)
+
+	rows, err := engine.Object("main.UserRepository.GetUser").QueryContext(context.Background(), H{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if _, err = engine.Object("main.UserRepository.DeleteUser").ExecContext(context.Background(), H{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := db.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if calls[0].query != "/* main.UserRepository.GetUser */ select * from user where id = ?" {
+		t.Errorf("unexpected query: %s", calls[0].query)
+		return
+	}
+	if calls[1].query != "/* main.UserRepository.DeleteUser */ delete from user where id = ?" {
+		t.Errorf("unexpected query: %s", calls[1].query)
+	}
+}
+
+func TestStatementIDCommentRewriter_Escape(t *testing.T) {
+	query := StatementIDCommentRewriter(context.Background(), "a*/b", "select 1")
+	if query != "/* a* /b */ select 1" {
+		t.Errorf("unexpected query: %s", query)
+	}
+}
+
+func TestSQLCommenter_Rewrite(t *testing.T) {
+	commenter := SQLCommenter{Application: "api", Keys: []string{"traceparent"}}
+
+	query := commenter.Rewrite(context.Background(), "main.GetUser", "select 1")
+	if query != "select 1" {
+		t.Errorf("expected no-op without trace, got %s", query)
+		return
+	}
+
+	ctx := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx = ContextWithSQLCommentTag(ctx, "route", "/users")
+	query = commenter.Rewrite(ctx, "main.GetUser", "select 1")
+	expected := "select 1 /*application='api',traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/"
+	if query != expected {
+		t.Errorf("expected %s, got %s", expected, query)
+		return
+	}
+
+	ctx = ContextWithSQLCommentTag(context.Background(), "route", "/users/*/it's")
+	query = SQLCommenter{}.Rewrite(ctx, "main.GetUser", "select 1")
+	expected = "select 1 /*route='%2Fusers%2F%2A%2Fit%27s'*/"
+	if query != expected {
+		t.Errorf("expected %s, got %s", expected, query)
+	}
+}
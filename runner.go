@@ -19,6 +19,7 @@ package juice
 import (
 	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/go-juicedev/juice/session"
 )
@@ -88,6 +89,10 @@ type SQLRunner struct {
 	query   string
 	engine  *Engine
 	session session.Session
+
+	// readOnly reports whether the session is a read-only transaction,
+	// which rejects the Insert, Update and Delete with ErrReadOnlyTransaction.
+	readOnly bool
 }
 
 // BuildExecutor creates a new SQL executor based on the given action.
@@ -96,6 +101,10 @@ func (r *SQLRunner) BuildExecutor(action Action) Executor[*sql.Rows] {
 	driver := r.engine.Driver()
 	statement := NewRawSQLStatement(r.query, r.engine.GetConfiguration(), action)
 	statementHandler := NewQueryBuildStatementHandler(driver, r.session, r.engine.middlewares...)
+	// the statements of a transaction are tracked by the transaction itself.
+	if _, ok := r.session.(*sql.Tx); !ok {
+		statementHandler = r.engine.trackStatementHandler(statementHandler)
+	}
 	return &sqlRowsExecutor{
 		statement:        statement,
 		statementHandler: statementHandler,
@@ -113,6 +122,9 @@ func (r *SQLRunner) queryContext(ctx context.Context, param Param) (*sql.Rows, e
 // execContext executes a non-query SQL operation (INSERT, UPDATE, DELETE)
 // with the given context and parameters.
 func (r *SQLRunner) execContext(action Action, ctx context.Context, param Param) (sql.Result, error) {
+	if r.readOnly && action.ForWrite() {
+		return nil, fmt.Errorf("%w: raw %s", ErrReadOnlyTransaction, action)
+	}
 	executor := r.BuildExecutor(action)
 	return executor.ExecContext(ctx, param)
 }
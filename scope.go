@@ -28,6 +28,9 @@ var ErrInvalidManager = errors.New("juice: invalid manager")
 // ErrCommitOnSpecific is an error for commit on specific transaction.
 var ErrCommitOnSpecific = errors.New("juice: commit on specific transaction")
 
+// ErrReadOnlyTransaction is an error for executing a write statement in a read-only transaction.
+var ErrReadOnlyTransaction = errors.New("juice: write statement in read-only transaction")
+
 // TransactionOptionFunc is a function to set the transaction options.
 // It is used to set the transaction options for the transaction.
 type TransactionOptionFunc func(options *sql.TxOptions)
@@ -46,6 +49,19 @@ func WithReadOnly(readOnly bool) TransactionOptionFunc {
 	}
 }
 
+// newTxOptions returns the transaction options set by the given functions,
+// or nil if there is no function, which uses the default options of the database.
+func newTxOptions(opts []TransactionOptionFunc) *sql.TxOptions {
+	if len(opts) == 0 {
+		return nil
+	}
+	options := new(sql.TxOptions)
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
 // Transaction executes a transaction with the given handler.
 // If the manager is not an instance of Engine, it will return ErrInvalidManager.
 // If the handler returns an error, the transaction will be rolled back.
@@ -69,15 +85,8 @@ func Transaction(ctx context.Context, handler func(ctx context.Context) error, o
 		return ErrInvalidManager
 	}
 
-	var options *sql.TxOptions
-	if len(opts) > 0 {
-		options = new(sql.TxOptions)
-		for _, opt := range opts {
-			opt(options)
-		}
-	}
 	// create a new transaction
-	tx := engine.ContextTx(ctx, options)
+	tx := engine.ContextTx(ctx, newTxOptions(opts))
 
 	if err = tx.Begin(); err != nil {
 		return err
@@ -116,3 +125,45 @@ func NestedTransaction(ctx context.Context, handler func(ctx context.Context) er
 	}
 	return Transaction(ctx, handler, opts...)
 }
+
+// InTx executes fn within a new transaction of the engine, and fn receives the manager scoped to the transaction.
+// The transaction is committed if fn returns nil, otherwise it is rolled back.
+// If fn panics, the transaction is rolled back and the panic is propagated.
+// Unlike Transaction, it does not require a context created by ContextWithManager.
+// The options set the isolation level and the read-only mode of the transaction,
+// the write statements in a read-only transaction fail with ErrReadOnlyTransaction before reaching the database.
+// For example:
+//
+//	err := engine.InTx(ctx, func(tx juice.Manager) error {
+//		if _, err := tx.Object("account.withdraw").ExecContext(ctx, from); err != nil {
+//			return err
+//		}
+//		_, err := tx.Object("account.deposit").ExecContext(ctx, to)
+//		return err
+//	})
+//
+//	err := engine.InTx(ctx, func(tx juice.Manager) error {
+//		// ... run the reporting queries
+//	}, juice.WithReadOnly(true), juice.WithIsolationLevel(sql.LevelRepeatableRead))
+func (e *Engine) InTx(ctx context.Context, fn func(tx Manager) error, opts ...TransactionOptionFunc) (err error) {
+	tx := e.ContextTx(ctx, newTxOptions(opts))
+	if err = tx.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err == nil {
+			return
+		}
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			err = errors.Join(err, rollbackErr)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
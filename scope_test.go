@@ -0,0 +1,165 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestEngine_InTx(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<update id="withdraw">update accounts set balance = balance - 1 where id = #{id}</update>
+<update id="deposit">update accounts set balance = balance + 1 where id = #{id}</update>`)
+	ctx := context.Background()
+
+	err := engine.InTx(ctx, func(tx Manager) error {
+		if _, err := tx.Object("main.withdraw").ExecContext(ctx, H{"id": 1}); err != nil {
+			return err
+		}
+		_, err := tx.Object("main.deposit").ExecContext(ctx, H{"id": 2})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.commits != 1 || db.rollbacks != 0 || len(db.Calls()) != 2 {
+		t.Errorf("expected the transaction to be committed, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+		return
+	}
+
+	errFailed := errors.New("failed")
+	err = engine.InTx(ctx, func(tx Manager) error {
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Errorf("expected errFailed, got %v", err)
+		return
+	}
+	if db.commits != 1 || db.rollbacks != 1 {
+		t.Errorf("expected the transaction to be rolled back, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+		return
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be propagated")
+			}
+		}()
+		_ = engine.InTx(ctx, func(tx Manager) error {
+			panic("boom")
+		})
+	}()
+	if db.commits != 1 || db.rollbacks != 2 {
+		t.Errorf("expected the transaction to be rolled back on panic, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+	}
+}
+
+func TestEngine_InTxReadOnly(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="count">select count(*) from accounts</select>
+<update id="deposit">update accounts set balance = balance + 1 where id = #{id}</update>`)
+	ctx := context.Background()
+
+	err := engine.InTx(ctx, func(tx Manager) error {
+		rows, err := tx.Object("main.count").QueryContext(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err = rows.Close(); err != nil {
+			return err
+		}
+		_, err = tx.Object("main.deposit").ExecContext(ctx, H{"id": 1})
+		return err
+	}, WithReadOnly(true), WithIsolationLevel(sql.LevelRepeatableRead))
+	if !errors.Is(err, ErrReadOnlyTransaction) {
+		t.Errorf("expected ErrReadOnlyTransaction, got %v", err)
+		return
+	}
+	if len(db.txOptions) != 1 || !db.txOptions[0].ReadOnly || sql.IsolationLevel(db.txOptions[0].Isolation) != sql.LevelRepeatableRead {
+		t.Errorf("unexpected transaction options: %+v", db.txOptions)
+		return
+	}
+	// the write statement never reaches the database.
+	if calls := db.Calls(); len(calls) != 1 {
+		t.Errorf("expected only the read query, got %v", calls)
+	}
+}
+
+func TestBasicTxManager_RawReadOnly(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<select id="count">select count(*) from accounts</select>`)
+	ctx := context.Background()
+
+	tx := engine.ContextTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Raw("select count(*) from accounts").Select(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rows.Close()
+	if _, err = tx.Raw("update accounts set balance = 0").Update(ctx, nil); !errors.Is(err, ErrReadOnlyTransaction) {
+		t.Errorf("expected ErrReadOnlyTransaction, got %v", err)
+		return
+	}
+	if calls := db.Calls(); len(calls) != 1 {
+		t.Errorf("expected only the read query, got %v", calls)
+	}
+}
+
+func TestTransaction_IsolationLevel(t *testing.T) {
+	db := newFakeDB(t)
+	engine := db.Engine(t, "main", `<update id="transfer">update accounts set balance = balance - #{amount} where id = #{id}</update>`)
+	ctx := ContextWithManager(context.Background(), engine)
+
+	handler := func(ctx context.Context) error {
+		_, err := ManagerFromContext(ctx).Object("main.transfer").ExecContext(ctx, H{"id": 1, "amount": 10})
+		return err
+	}
+	if err := Transaction(ctx, handler, WithIsolationLevel(sql.LevelSerializable)); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.InTx(ctx, func(tx Manager) error {
+		return handler(ContextWithManager(ctx, tx))
+	}, WithIsolationLevel(sql.LevelReadCommitted)); err != nil {
+		t.Fatal(err)
+	}
+	// the default options of the database are used without any option.
+	if err := NestedTransaction(ctx, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []sql.IsolationLevel{sql.LevelSerializable, sql.LevelReadCommitted, sql.LevelDefault}
+	if len(db.txOptions) != len(expected) {
+		t.Fatalf("expected %d transactions, got %d", len(expected), len(db.txOptions))
+	}
+	for i, level := range expected {
+		if got := sql.IsolationLevel(db.txOptions[i].Isolation); got != level {
+			t.Errorf("transaction %d: expected %s, got %s", i, level, got)
+		}
+	}
+	if db.commits != 3 {
+		t.Errorf("expected 3 commits, got %d", db.commits)
+	}
+}
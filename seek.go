@@ -0,0 +1,272 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-juicedev/juice/driver"
+	"github.com/go-juicedev/juice/internal/reflectlite"
+)
+
+// ErrInvalidSeek is an error that is returned when the keys, the size or the cursor of a seek pagination is invalid.
+var ErrInvalidSeek = errors.New("invalid seek pagination")
+
+// SeekKey is a sort key of the seek pagination.
+type SeekKey struct {
+	// Column is the name of the column selected by the statement.
+	Column string
+
+	// Desc sorts the column in descending order.
+	Desc bool
+}
+
+// SeekPage is a page returned by the SeekPaginator.
+type SeekPage[T any] struct {
+	// Items are the rows of the page.
+	Items []T
+
+	// Next is the cursor of the next page, which holds the values of the keys of the last item.
+	// It is nil if there are no more pages.
+	Next []any
+}
+
+// SeekPaginator pages the rows of a select statement with the keyset pagination, which
+// seeks the rows after the last seen sort keys instead of skipping them with an OFFSET.
+// The query of the statement is wrapped as a derived table, like:
+//
+//	SELECT * FROM (SELECT ...) seek_page WHERE (created_at, id) > (?, ?) ORDER BY created_at, id LIMIT 21
+//
+// If the database does not support the row value comparisons or the keys are sorted in the
+// mixed directions, the condition is expanded, like:
+//
+//	(created_at < ?) OR (created_at = ? AND id > ?)
+//
+// The last key must be unique, like the primary key, and the key columns must not be NULL,
+// otherwise the rows may be skipped or repeated between the pages.
+// The driver must implement driver.Limiter.
+type SeekPaginator[T any] struct {
+	engine *Engine
+	v      any
+	size   int
+	keys   []SeekKey
+}
+
+// NewSeekPaginator returns a new SeekPaginator of the statement of the given value,
+// which returns at most size rows per page ordered by the given keys.
+func NewSeekPaginator[T any](engine *Engine, v any, size int, keys ...SeekKey) *SeekPaginator[T] {
+	return &SeekPaginator[T]{engine: engine, v: v, size: size, keys: keys}
+}
+
+// Page returns the page after the given cursor, an empty cursor returns the first page.
+// The cursor of the next page is read from the fields of the last item tagged with the key
+// columns by the column tag, or from the keys of the map items.
+func (p *SeekPaginator[T]) Page(ctx context.Context, param Param, cursor []any) (*SeekPage[T], error) {
+	if err := p.check(cursor); err != nil {
+		return nil, err
+	}
+	limiter, ok := p.engine.Driver().(driver.Limiter)
+	if !ok {
+		return nil, fmt.Errorf("%w: driver %T does not implement driver.Limiter", ErrInvalidSeek, p.engine.Driver())
+	}
+	if p.engine.manager.draining() {
+		return nil, ErrDBManagerClosed
+	}
+	statement, err := p.engine.getStatement(p.v)
+	if err != nil {
+		return nil, err
+	}
+	if statement.Action() != Select {
+		return nil, fmt.Errorf("%w: %s is not a select statement", ErrInvalidSeek, statement.Name())
+	}
+	// the placeholders of the condition are translated after the ones of the statement.
+	translator := p.engine.Driver().Translator()
+	query, args, err := statement.Build(translator, param)
+	if err != nil {
+		return nil, err
+	}
+	// the cursor values are bound like the values of the placeholders of the statement.
+	binding := withBuildContext(newGenericParam(param, ""), &buildContext{typeHandlers: typeHandlersOf(statement.Configuration())})
+	query, args, err = p.seekQuery(translator, binding, query, args, cursor, p.rowValueComparison(p.engine.Driver()))
+	if err != nil {
+		return nil, err
+	}
+	// fetch one more row to know whether there is a next page.
+	query = limiter.Limit(query, p.size+1)
+
+	handler := p.engine.trackStatementHandler(&CompiledStatementHandler{
+		query:       query,
+		args:        args,
+		middlewares: p.engine.middlewares,
+		driver:      p.engine.Driver(),
+		session:     p.engine.DB(),
+	})
+	rows, err := handler.QueryContext(ctx, statement, param)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	items, err := List[T](rows)
+	if err != nil {
+		return nil, err
+	}
+	page := &SeekPage[T]{Items: items}
+	if len(items) > p.size {
+		page.Items = items[:p.size]
+		if page.Next, err = p.cursorOf(page.Items[p.size-1]); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// check checks the keys, the size and the given cursor.
+func (p *SeekPaginator[T]) check(cursor []any) error {
+	if len(p.keys) == 0 {
+		return fmt.Errorf("%w: keys are required", ErrInvalidSeek)
+	}
+	if p.size <= 0 {
+		return fmt.Errorf("%w: size must be positive, got %d", ErrInvalidSeek, p.size)
+	}
+	for _, key := range p.keys {
+		if key.Column == "" {
+			return fmt.Errorf("%w: key column is empty", ErrInvalidSeek)
+		}
+	}
+	if len(cursor) > 0 && len(cursor) != len(p.keys) {
+		return fmt.Errorf("%w: cursor has %d values, expected %d", ErrInvalidSeek, len(cursor), len(p.keys))
+	}
+	return nil
+}
+
+// seekQuery wraps the query with the seek condition after the given cursor and the order of the keys,
+// the condition is a row value comparison if rowValue is true, otherwise it is expanded.
+// The placeholder of each cursor value is translated by the name seek.<column>, and the value is bound
+// by bindArg with the type handlers of the binding, under the same name if the placeholders are named.
+func (p *SeekPaginator[T]) seekQuery(translator driver.Translator, binding Parameter, query string, args []any, cursor []any, rowValue bool) (string, []any, error) {
+	var cursorArgs []any
+	var err error
+	bind := func(i int) string {
+		name := "seek." + p.keys[i].Column
+		var arg any
+		if err == nil {
+			arg, err = bindArg(binding, "", name, reflect.ValueOf(cursor[i]))
+		}
+		cursorArgs = append(cursorArgs, arg)
+		return translator.Translate(name)
+	}
+	var builder strings.Builder
+	builder.WriteString("SELECT * FROM (")
+	builder.WriteString(query)
+	builder.WriteString(") seek_page")
+	if len(cursor) > 0 {
+		builder.WriteString(" WHERE ")
+		if rowValue {
+			columns := make([]string, len(p.keys))
+			placeholders := make([]string, len(p.keys))
+			for i, key := range p.keys {
+				columns[i] = key.Column
+				placeholders[i] = bind(i)
+			}
+			builder.WriteString("(" + strings.Join(columns, ", ") + ") " + seekOperator(p.keys[0]) + " (" + strings.Join(placeholders, ", ") + ")")
+		} else {
+			// (k1 > ?) OR (k1 = ? AND k2 > ?) OR ...
+			for i, key := range p.keys {
+				if i > 0 {
+					builder.WriteString(" OR ")
+				}
+				builder.WriteString("(")
+				for j := 0; j < i; j++ {
+					builder.WriteString(p.keys[j].Column + " = " + bind(j) + " AND ")
+				}
+				builder.WriteString(key.Column + " " + seekOperator(key) + " " + bind(i) + ")")
+			}
+		}
+	}
+	builder.WriteString(" ORDER BY ")
+	for i, key := range p.keys {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(key.Column)
+		if key.Desc {
+			builder.WriteString(" DESC")
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	// the arguments of the statement are already named by its build, the names of the cursor follow them.
+	if named, ok := translator.(driver.NamedArgTranslator); ok {
+		names := named.Names()
+		if len(names) != len(args)+len(cursorArgs) {
+			return "", nil, fmt.Errorf("named translator translated %d placeholders for %d arguments", len(names), len(args)+len(cursorArgs))
+		}
+		for i, arg := range cursorArgs {
+			cursorArgs[i] = sql.Named(names[len(args)+i], arg)
+		}
+	}
+	return builder.String(), append(args, cursorArgs...), nil
+}
+
+// rowValueComparison reports whether the seek condition can be a row value comparison,
+// which requires the support of the database and the keys sorted in the same direction.
+func (p *SeekPaginator[T]) rowValueComparison(drv driver.Driver) bool {
+	comparer, ok := drv.(driver.RowValueComparer)
+	if !ok || !comparer.RowValueComparison() {
+		return false
+	}
+	for _, key := range p.keys[1:] {
+		if key.Desc != p.keys[0].Desc {
+			return false
+		}
+	}
+	return true
+}
+
+// seekOperator returns the operator which seeks the values after the cursor in the direction of the key.
+func seekOperator(key SeekKey) string {
+	if key.Desc {
+		return "<"
+	}
+	return ">"
+}
+
+// cursorOf returns the values of the keys of the given item.
+func (p *SeekPaginator[T]) cursorOf(item T) ([]any, error) {
+	value := reflectlite.Unwrap(reflect.ValueOf(item))
+	cursor := make([]any, len(p.keys))
+	for i, key := range p.keys {
+		var field reflect.Value
+		switch {
+		case value.Kind() == reflect.Struct:
+			field = reflectlite.ValueFrom(value).FindFieldFromTag("column", key.Column).Value
+		case value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String:
+			field = value.MapIndex(reflect.ValueOf(key.Column).Convert(value.Type().Key()))
+		}
+		if !field.IsValid() {
+			return nil, fmt.Errorf("%w: key %s not found in %T", ErrInvalidSeek, key.Column, item)
+		}
+		cursor[i] = field.Interface()
+	}
+	return cursor, nil
+}
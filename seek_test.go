@@ -0,0 +1,166 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+func TestSeekPaginator_Page(t *testing.T) {
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id", "name"}, [][]sqldriver.Value{{int64(3), "c"}, {int64(4), "d"}, {int64(5), "e"}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="users">select id, name from users where status = #{status}</select>
+<delete id="delete">delete from users</delete>`)
+
+	type User struct {
+		ID   int64  `column:"id"`
+		Name string `column:"name"`
+	}
+	ctx := context.Background()
+	paginator := NewSeekPaginator[User](engine, "main.users", 2, SeekKey{Column: "id"})
+	page, err := paginator.Page(ctx, H{"status": int64(1)}, []any{int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Items[1].ID != 4 {
+		t.Errorf("unexpected items: %v", page.Items)
+		return
+	}
+	if !reflect.DeepEqual(page.Next, []any{int64(4)}) {
+		t.Errorf("unexpected next cursor: %v", page.Next)
+		return
+	}
+	calls := db.Calls()
+	expected := "SELECT * FROM (select id, name from users where status = ?) seek_page WHERE (id) > (?) ORDER BY id LIMIT 3"
+	if calls[0].query != expected {
+		t.Errorf("expected query %q, got %q", expected, calls[0].query)
+		return
+	}
+	if !reflect.DeepEqual(calls[0].args, []any{int64(1), int64(2)}) {
+		t.Errorf("unexpected args: %v", calls[0].args)
+		return
+	}
+
+	// the last page has no next cursor.
+	paginator = NewSeekPaginator[User](engine, "main.users", 3, SeekKey{Column: "id"})
+	if page, err = paginator.Page(ctx, H{"status": 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 3 || page.Next != nil {
+		t.Errorf("unexpected last page: %v, %v", page.Items, page.Next)
+		return
+	}
+	if query := db.Calls()[1].query; query != "SELECT * FROM (select id, name from users where status = ?) seek_page ORDER BY id LIMIT 4" {
+		t.Errorf("unexpected first page query: %s", query)
+		return
+	}
+
+	// the cursor must hold a value for each key.
+	if _, err = paginator.Page(ctx, H{"status": 1}, []any{1, 2}); !errors.Is(err, ErrInvalidSeek) {
+		t.Errorf("expected ErrInvalidSeek, got %v", err)
+		return
+	}
+	if _, err = NewSeekPaginator[User](engine, "main.delete", 2, SeekKey{Column: "id"}).Page(ctx, nil, nil); !errors.Is(err, ErrInvalidSeek) {
+		t.Errorf("expected ErrInvalidSeek for a delete statement, got %v", err)
+	}
+}
+
+func TestSeekPaginator_seekQuery(t *testing.T) {
+	paginator := &SeekPaginator[H]{keys: []SeekKey{{Column: "created_at", Desc: true}, {Column: "id", Desc: true}}}
+
+	translator := driver.PostgresDriver{}.Translator()
+	translator.Translate("?")
+	binding := H{}.AsParam()
+	query, args, err := paginator.seekQuery(translator, binding, "select * from users where status = $1", []any{1}, []any{"2024-01-01", 7}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SELECT * FROM (select * from users where status = $1) seek_page WHERE (created_at, id) < ($2, $3) ORDER BY created_at DESC, id DESC"
+	if query != expected {
+		t.Errorf("expected query %q, got %q", expected, query)
+		return
+	}
+	if !reflect.DeepEqual(args, []any{1, "2024-01-01", 7}) {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// the mixed directions are expanded.
+	paginator.keys[1].Desc = false
+	if paginator.rowValueComparison(driver.MySQLDriver{}) {
+		t.Error("expected no row value comparison for mixed directions")
+		return
+	}
+	query, args, err = paginator.seekQuery(driver.MySQLDriver{}.Translator(), binding, "select * from users", nil, []any{"2024-01-01", 7}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "SELECT * FROM (select * from users) seek_page WHERE (created_at < ?) OR (created_at = ? AND id > ?) ORDER BY created_at DESC, id"
+	if query != expected {
+		t.Errorf("expected query %q, got %q", expected, query)
+		return
+	}
+	if !reflect.DeepEqual(args, []any{"2024-01-01", "2024-01-01", 7}) {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+	if paginator.rowValueComparison(driver.OracleDriver{}) {
+		t.Error("expected no row value comparison for oracle")
+	}
+
+	// the cursor placeholders are named by their columns, and the values are converted like the other arguments.
+	named := driver.NewNamedTranslator()
+	named.Translate("status")
+	query, args, err = paginator.seekQuery(named, binding, "select * from users where status = :status", []any{sql.Named("status", 1)}, []any{"2024-01-01", testStatus(1)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "SELECT * FROM (select * from users where status = :status) seek_page WHERE (created_at < :seek_created_at) OR " +
+		"(created_at = :seek_created_at_2 AND id > :seek_id) ORDER BY created_at DESC, id"
+	if query != expected {
+		t.Errorf("expected query %q, got %q", expected, query)
+		return
+	}
+	expectedArgs := []any{sql.Named("status", 1), sql.Named("seek_created_at", "2024-01-01"), sql.Named("seek_created_at_2", "2024-01-01"), sql.Named("seek_id", 1)}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestSeekPaginator_cursorOf(t *testing.T) {
+	paginator := &SeekPaginator[map[string]any]{keys: []SeekKey{{Column: "id"}}}
+	cursor, err := paginator.cursorOf(map[string]any{"id": 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cursor, []any{9}) {
+		t.Errorf("unexpected cursor: %v", cursor)
+		return
+	}
+	if _, err = paginator.cursorOf(map[string]any{"name": "a"}); !errors.Is(err, ErrInvalidSeek) {
+		t.Errorf("expected ErrInvalidSeek, got %v", err)
+	}
+}
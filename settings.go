@@ -18,6 +18,7 @@ package juice
 
 import (
 	"encoding"
+	"reflect"
 	"strconv"
 )
 
@@ -73,6 +74,21 @@ func (s keyValueSettingProvider) Get(name string) StringValue {
 // ensure keyValueSettingProvider implements SettingProvider.
 var _ SettingProvider = (*keyValueSettingProvider)(nil)
 
+// settingParameter is a Parameter which reads the values from the settings.
+// A setting which is not set or empty is treated as not found.
+type settingParameter struct {
+	SettingProvider
+}
+
+// Get implements Parameter.
+func (s settingParameter) Get(name string) (reflect.Value, bool) {
+	value := s.SettingProvider.Get(name)
+	if value == "" {
+		return reflect.Value{}, false
+	}
+	return reflect.ValueOf(value.String()), true
+}
+
 // settingItem is a setting element.
 type settingItem struct {
 	// The name of the setting.
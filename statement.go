@@ -17,10 +17,13 @@ limitations under the License.
 package juice
 
 import (
+	"database/sql"
+	"fmt"
 	"hash/fnv"
 	"strconv"
 
 	"github.com/go-juicedev/juice/driver"
+	"github.com/go-juicedev/juice/eval"
 )
 
 type Statement interface {
@@ -31,6 +34,10 @@ type Statement interface {
 	Configuration() IConfiguration
 	ResultMap() (ResultMap, error)
 	Build(translator driver.Translator, param Param) (query string, args []any, err error)
+
+	// Clone returns a deep copy of the statement, whose nodes can be modified
+	// without affecting the original, like rewriting the query.
+	Clone() Statement
 }
 
 // xmlSQLStatement defines a sql xmlSQLStatement.
@@ -41,6 +48,10 @@ type xmlSQLStatement struct {
 	attrs  map[string]string
 	name   string
 	id     string
+
+	// defaults is the default parameter values declared by the defaults element,
+	// which have a lower priority than the parameter.
+	defaults H
 }
 
 // Attribute returns the value of the attribute with the given key.
@@ -65,6 +76,12 @@ func (s *xmlSQLStatement) ID() string {
 	return s.id
 }
 
+// DatabaseID returns the databaseId declared by the xmlSQLStatement.
+// A statement with a databaseId is only used by the environments of that database.
+func (s *xmlSQLStatement) DatabaseID() string {
+	return s.attrs["databaseId"]
+}
+
 func (s *xmlSQLStatement) lazyName() string {
 	var builder = getStringBuilder()
 	defer putStringBuilder(builder)
@@ -107,6 +124,93 @@ func (s *xmlSQLStatement) ResultMap() (ResultMap, error) {
 // Build builds the xmlSQLStatement with the given parameter.
 func (s *xmlSQLStatement) Build(translator driver.Translator, param Param) (query string, args []any, err error) {
 	value := newGenericParam(param, s.Attribute("paramName"))
+	return s.build(translator, value)
+}
+
+// strictTextSubstitutionKey is the name of the setting which enables the strictTextSubstitution mode.
+// In this mode, the ${} text substitutions are only resolved from the settings of the configuration,
+// and an ErrUntrustedTextSubstitution is returned if the value comes from the parameters,
+// which should be bound by #{} instead.
+//
+//	<setting name="strictTextSubstitution" value="true"/>
+//	<setting name="tablePrefix" value="t_"/>
+//
+//	select * from ${tablePrefix}users where id = #{id}
+const strictTextSubstitutionKey = "strictTextSubstitution"
+
+const (
+	// maxExpressionDepthKey is the name of the setting which limits the depth of the nested sub expressions
+	// evaluated by the expressions of the nodes, like the test of the if.
+	maxExpressionDepthKey = "maxExpressionDepth"
+
+	// maxExpressionStepsKey is the name of the setting which limits the number of the sub expressions
+	// evaluated by an execution of the expressions of the nodes.
+	maxExpressionStepsKey = "maxExpressionSteps"
+)
+
+// expressionLimits returns the limits of the evaluation of the expressions configured by the settings.
+// The eval.DefaultLimits are used if they are not set, and a negative value disables the limit.
+//
+//	<setting name="maxExpressionDepth" value="128"/>
+//	<setting name="maxExpressionSteps" value="10000"/>
+func expressionLimits(settings SettingProvider) eval.Limits {
+	limit := func(key string, defaultValue int) int {
+		value := int(settings.Get(key).Int64())
+		switch {
+		case value == 0:
+			return defaultValue
+		case value < 0:
+			return 0
+		default:
+			return value
+		}
+	}
+	return eval.Limits{
+		MaxDepth: limit(maxExpressionDepthKey, eval.DefaultLimits.MaxDepth),
+		MaxSteps: limit(maxExpressionStepsKey, eval.DefaultLimits.MaxSteps),
+	}
+}
+
+// withDefaults returns the Parameter which falls back to the defaults of the xmlSQLStatement.
+//
+//	<select id="ListEvents">
+//	    <defaults>
+//	        <default name="source" value="api"/>
+//	    </defaults>
+//	    SELECT * FROM events WHERE source = #{source}
+//	</select>
+//
+// The default values are strings, and the values of the parameter take precedence over them.
+func (s *xmlSQLStatement) withDefaults(value Parameter) Parameter {
+	if len(s.defaults) == 0 {
+		return value
+	}
+	return eval.ParamGroup{value, s.defaults.AsParam()}
+}
+
+// build builds the xmlSQLStatement with the given Parameter.
+func (s *xmlSQLStatement) build(translator driver.Translator, value Parameter) (query string, args []any, err error) {
+	if err = checkStatementFlag(s); err != nil {
+		return "", nil, err
+	}
+	value = s.withDefaults(value)
+	if err = validateParam(s, value); err != nil {
+		return "", nil, err
+	}
+	settings := s.Configuration().Settings()
+	strategy, err := statementNamingStrategy(s)
+	if err != nil {
+		return "", nil, err
+	}
+	ctx := &buildContext{
+		budget:         eval.NewBudget(expressionLimits(settings)),
+		typeHandlers:   typeHandlersOf(s.Configuration()),
+		namingStrategy: strategy,
+	}
+	if settings.Get(strictTextSubstitutionKey).Bool() {
+		ctx.trusted = settingParameter{settings}
+	}
+	value = withBuildContext(value, ctx)
 	query, args, err = s.Nodes.Accept(translator, value)
 	if err != nil {
 		return "", nil, err
@@ -114,9 +218,49 @@ func (s *xmlSQLStatement) Build(translator driver.Translator, param Param) (quer
 	if len(query) == 0 {
 		return "", nil, ErrEmptyQuery
 	}
+	return namedArgs(translator, query, args)
+}
+
+// namedArgs passes the arguments as the sql.NamedArg by the names of their placeholders
+// if the query is translated by a driver.NamedArgTranslator, like the driver.NamedTranslator.
+func namedArgs(translator driver.Translator, query string, args []any) (string, []any, error) {
+	named, ok := translator.(driver.NamedArgTranslator)
+	if !ok {
+		return query, args, nil
+	}
+	names := named.Names()
+	if len(names) != len(args) {
+		return "", nil, fmt.Errorf("named translator translated %d placeholders for %d arguments", len(names), len(args))
+	}
+	for i, arg := range args {
+		args[i] = sql.Named(names[i], arg)
+	}
 	return query, args, nil
 }
 
+// databaseIDParamKey is the name of the variable which holds the databaseId of the environment.
+const databaseIDParamKey = "_databaseId"
+
+// databaseIDStatement wraps a xmlSQLStatement to expose the databaseId of the current
+// environment to its nodes as the _databaseId variable, which makes vendor specific
+// branches possible inside a single statement.
+//
+//	<if test="_databaseId == 'postgres'">RETURNING id</if>
+type databaseIDStatement struct {
+	*xmlSQLStatement
+	databaseID string
+}
+
+// Build builds the xmlSQLStatement with the given parameter and the _databaseId variable.
+// The variable has a lower priority than the parameter.
+func (s *databaseIDStatement) Build(translator driver.Translator, param Param) (query string, args []any, err error) {
+	value := eval.ParamGroup{
+		newGenericParam(param, s.Attribute("paramName")),
+		H{databaseIDParamKey: s.databaseID}.AsParam(),
+	}
+	return s.build(translator, value)
+}
+
 // rawSQLStatement represents a raw SQL query with its parameters and action type.
 // It implements the Statement interface and provides methods for query execution.
 type rawSQLStatement struct {
@@ -168,7 +312,7 @@ func (s rawSQLStatement) ResultMap() (ResultMap, error) {
 
 // Build builds the rawSQLStatement with the given parameter.
 func (s rawSQLStatement) Build(translator driver.Translator, param Param) (query string, args []any, err error) {
-	value := newGenericParam(param, "")
+	value := withBuildContext(newGenericParam(param, ""), &buildContext{typeHandlers: typeHandlersOf(s.cfg)})
 	query, args, err = NewTextNode(s.query).Accept(translator, value)
 	if err != nil {
 		return "", nil, err
@@ -176,7 +320,7 @@ func (s rawSQLStatement) Build(translator driver.Translator, param Param) (query
 	if len(query) == 0 {
 		return "", nil, ErrEmptyQuery
 	}
-	return query, args, nil
+	return namedArgs(translator, query, args)
 }
 
 // NewRawSQLStatement creates a new raw SQL statement with the given query, configuration, and action.
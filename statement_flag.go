@@ -0,0 +1,54 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-juicedev/juice/eval"
+)
+
+// ErrStatementDisabled is an error that is returned when the statement is disabled by its feature flag.
+var ErrStatementDisabled = errors.New("statement disabled")
+
+// flagKey is the attribute which includes the whole statement by a feature flag of the eval.FlagProvider,
+// the statement is enabled if the flag is, or if the flag is not when its name is prefixed with "!".
+// So the old and the new statements can be switched at runtime without editing SQL.
+//
+//	<select id="ListByScore" flag="new_ranking">...</select>
+//	<select id="ListByDate" flag="!new_ranking">...</select>
+const flagKey = "flag"
+
+// checkStatementFlag returns ErrStatementDisabled if the statement is disabled by its feature flag.
+// The unknown flags are disabled, or ErrUnknownFlag of eval is returned in the strict mode.
+func checkStatementFlag(statement Statement) error {
+	value := statement.Attribute(flagKey)
+	if value == "" {
+		return nil
+	}
+	name, negated := strings.CutPrefix(value, "!")
+	enabled, err := eval.Flag(name)
+	if err != nil {
+		return fmt.Errorf("%w of statement %s", err, statement.Name())
+	}
+	if enabled == negated {
+		return fmt.Errorf("%w: %s by flag %s", ErrStatementDisabled, statement.Name(), value)
+	}
+	return nil
+}
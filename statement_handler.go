@@ -60,12 +60,13 @@ type CompiledStatementHandler struct {
 }
 
 // QueryContext executes a query that returns rows. It enriches the context with
-// session and parameter information, then executes the pre-built query through
+// session, parameter and driver information, then executes the pre-built query through
 // the middleware chain using SessionQueryHandler.
 func (s *CompiledStatementHandler) QueryContext(ctx context.Context, statement Statement, param Param) (*sql.Rows, error) {
 	contextReducer := ctxreducer.G{
 		ctxreducer.NewSessionContextReducer(s.session),
 		ctxreducer.NewParamContextReducer(param),
+		ctxreducer.NewDriverContextReducer(s.driver),
 	}
 	ctx = contextReducer.Reduce(ctx)
 	if s.queryHandler == nil {
@@ -81,6 +82,7 @@ func (s *CompiledStatementHandler) ExecContext(ctx context.Context, statement St
 	contextReducer := ctxreducer.G{
 		ctxreducer.NewSessionContextReducer(s.session),
 		ctxreducer.NewParamContextReducer(param),
+		ctxreducer.NewDriverContextReducer(s.driver),
 	}
 	ctx = contextReducer.Reduce(ctx)
 	if s.execHandler == nil {
@@ -288,6 +290,8 @@ func (s *sliceBatchStatementHandler) ExecContext(ctx context.Context, statement
 	// Ensure all prepared statements are properly closed after use
 	defer func() { _ = preparedStatementHandler.Close() }()
 
+	results := make(batchResult, 0, times)
+
 	// execute the statement in batches.
 	for i := 0; i < times; i++ {
 		start := i * int(s.batchSize)
@@ -300,8 +304,9 @@ func (s *sliceBatchStatementHandler) ExecContext(ctx context.Context, statement
 		if err != nil {
 			return nil, err
 		}
+		results = append(results, result)
 	}
-	return result, nil
+	return results, nil
 }
 
 type mapBatchStatementHandler struct {
@@ -368,6 +373,8 @@ func (s *mapBatchStatementHandler) ExecContext(ctx context.Context, statement St
 	// Ensure all prepared statements are properly closed after use
 	defer func() { _ = preparedStatementHandler.Close() }()
 
+	results := make(batchResult, 0, times)
+
 	batchParam := reflect.MakeMap(s.value.Type())
 
 	executionParam := batchParam.Interface()
@@ -384,8 +391,9 @@ func (s *mapBatchStatementHandler) ExecContext(ctx context.Context, statement St
 		if err != nil {
 			return nil, err
 		}
+		results = append(results, result)
 	}
-	return result, nil
+	return results, nil
 }
 
 // BatchStatementHandler is a specialized SQL statement executor that provides optimized handling
@@ -414,15 +422,21 @@ func (b *BatchStatementHandler) QueryContext(ctx context.Context, statement Stat
 
 // ExecContext executes a batch of SQL statements within a context. It handles
 // the execution of SQL statements in batches if the action is an Insert and a
-// batch size is specified. If the action is not an Insert or no batch size is
-// specified, it delegates to the execContext method.
+// batch size is specified. If the action is not an Insert, it delegates to the execContext method.
+// An Insert without a batch size is split into batches only if its placeholders exceed the limit,
+// see execWithinPlaceholderLimit. The result of the batches reports the rows affected by all of them.
+// For an Insert, the keys are generated by the keyGenerator of the statement first.
 func (b *BatchStatementHandler) ExecContext(ctx context.Context, statement Statement, param Param) (result sql.Result, err error) {
 	if statement.Action() != Insert {
 		return b.execContext(ctx, statement, param)
 	}
+	// the keys must be generated before the statement is built.
+	if err = generateKeys(statement, param); err != nil {
+		return nil, err
+	}
 	batchSizeValue := statement.Attribute("batchSize")
 	if len(batchSizeValue) == 0 {
-		return b.execContext(ctx, statement, param)
+		return b.execWithinPlaceholderLimit(ctx, statement, param)
 	}
 	batchSize, err := strconv.ParseInt(batchSizeValue, 10, 64)
 	if err != nil {
@@ -432,32 +446,157 @@ func (b *BatchStatementHandler) ExecContext(ctx context.Context, statement State
 		return nil, errors.New("batch size must be greater than 0")
 	}
 
-	var statementHandler StatementHandler
+	statementHandler, err := b.batchStatementHandler(b.session, param, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	return statementHandler.ExecContext(ctx, statement, param)
+}
 
+// batchStatementHandler returns the handler which executes the statement with the given param in batches.
+func (b *BatchStatementHandler) batchStatementHandler(sess session.Session, param Param, batchSize int64) (StatementHandler, error) {
 	// ensure the param is a slice or array
 	value := reflectlite.ValueOf(param)
 
 	switch value.IndirectType().Kind() {
 	case reflect.Slice, reflect.Array:
-		statementHandler = &sliceBatchStatementHandler{
+		return &sliceBatchStatementHandler{
 			driver:      b.driver,
 			middlewares: b.middlewares,
-			session:     b.session,
+			session:     sess,
 			batchSize:   batchSize,
 			value:       value.Unwrap().Value,
-		}
+		}, nil
 	case reflect.Map:
-		statementHandler = &mapBatchStatementHandler{
+		return &mapBatchStatementHandler{
 			driver:      b.driver,
 			middlewares: b.middlewares,
-			session:     b.session,
+			session:     sess,
 			batchSize:   batchSize,
 			value:       value.Unwrap().Value,
-		}
+		}, nil
 	default:
 		return nil, errSliceOrArrayRequired
 	}
-	return statementHandler.ExecContext(ctx, statement, param)
+}
+
+// execWithinPlaceholderLimit executes the insert without a batch size, which is split into the batches
+// if its placeholders exceed the limit, like the bind parameter maximum of the database.
+// The limit is configured by the maxPlaceholders attribute of the statement, or defaults to the one
+// of the driver if it implements driver.PlaceholderLimiter, zero means no limit.
+// Only the param of a slice of the rows, or a map of one key to them, can be split,
+// assuming that each row has the same number of the placeholders.
+// The batches are executed within a transaction unless the session is one already.
+func (b *BatchStatementHandler) execWithinPlaceholderLimit(ctx context.Context, statement Statement, param Param) (sql.Result, error) {
+	limit, err := maxPlaceholders(statement, b.driver)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return b.execContext(ctx, statement, param)
+	}
+	query, args, err := statement.Build(b.driver.Translator(), param)
+	if err != nil {
+		return nil, err
+	}
+	rows := batchRows(param)
+	if len(args) <= limit || rows < 2 {
+		statementHandler := CompiledStatementHandler{
+			query:       query,
+			args:        args,
+			middlewares: b.middlewares,
+			driver:      b.driver,
+			session:     b.session,
+		}
+		return statementHandler.ExecContext(ctx, statement, param)
+	}
+	placeholdersPerRow := (len(args) + rows - 1) / rows
+	batchSize := int64(max(1, limit/placeholdersPerRow))
+	return execInTransaction(ctx, b.session, func(sess session.Session) (sql.Result, error) {
+		statementHandler, err := b.batchStatementHandler(sess, param, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		return statementHandler.ExecContext(ctx, statement, param)
+	})
+}
+
+// maxPlaceholders returns the maximum number of the placeholders in the statement, zero means no limit.
+func maxPlaceholders(statement Statement, drv driver.Driver) (int, error) {
+	if value := statement.Attribute("maxPlaceholders"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return 0, fmt.Errorf("invalid max placeholders: %s", value)
+		}
+		return limit, nil
+	}
+	if limiter, ok := drv.(driver.PlaceholderLimiter); ok {
+		return limiter.MaxPlaceholders(), nil
+	}
+	return 0, nil
+}
+
+// batchRows returns the number of the rows of the param which can be split into batches,
+// the param must be a slice of the rows or a map of one string key to them, otherwise zero is returned.
+func batchRows(param Param) int {
+	value := reflectlite.ValueOf(param).Unwrap().Value
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		return value.Len()
+	case reflect.Map:
+		if value.Len() != 1 || value.Type().Key().Kind() != reflect.String {
+			return 0
+		}
+		value = reflectlite.Unpack(value.MapIndex(value.MapKeys()[0]))
+		if kind := value.Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return value.Len()
+		}
+	}
+	return 0
+}
+
+// execInTransaction executes the given function within a transaction begun on the session,
+// the function is executed on the session directly if the session is not a *sql.DB, like a transaction already.
+func execInTransaction(ctx context.Context, sess session.Session, fn func(sess session.Session) (sql.Result, error)) (sql.Result, error) {
+	db, ok := sess.(*sql.DB)
+	if !ok {
+		return fn(sess)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	result, err := fn(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// batchResult is the result of the statement executed in batches,
+// it reports the rows affected by all the batches and the last insert id of the last batch.
+type batchResult []sql.Result
+
+// LastInsertId implements sql.Result.
+func (r batchResult) LastInsertId() (int64, error) {
+	return r[len(r)-1].LastInsertId()
+}
+
+// RowsAffected implements sql.Result.
+func (r batchResult) RowsAffected() (int64, error) {
+	var affected int64
+	for _, result := range r {
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		affected += n
+	}
+	return affected, nil
 }
 
 func (b *BatchStatementHandler) execContext(ctx context.Context, statement Statement, param Param) (sql.Result, error) {
@@ -473,3 +612,42 @@ func NewBatchStatementHandler(driver driver.Driver, session session.Session, mid
 		session:     session,
 	}
 }
+
+// trackedStatementHandler is a StatementHandler which counts its executions as the
+// in-flight ones of a source of the DBManager, so that DBManager.Close waits for them.
+// It returns ErrDBManagerClosed instead of executing once the manager is draining.
+type trackedStatementHandler struct {
+	StatementHandler
+	manager *DBManager
+	source  string
+}
+
+// QueryContext implements StatementHandler.
+func (s *trackedStatementHandler) QueryContext(ctx context.Context, statement Statement, param Param) (*sql.Rows, error) {
+	end, err := s.manager.begin(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return s.StatementHandler.QueryContext(ctx, statement, param)
+}
+
+// ExecContext implements StatementHandler.
+func (s *trackedStatementHandler) ExecContext(ctx context.Context, statement Statement, param Param) (sql.Result, error) {
+	end, err := s.manager.begin(s.source)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return s.StatementHandler.ExecContext(ctx, statement, param)
+}
+
+// trackStatementHandler returns the StatementHandler tracking the executions of the
+// given one on the current environment of the engine.
+func (e *Engine) trackStatementHandler(statementHandler StatementHandler) StatementHandler {
+	return &trackedStatementHandler{
+		StatementHandler: statementHandler,
+		manager:          e.manager,
+		source:           e.using,
+	}
+}
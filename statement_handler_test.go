@@ -0,0 +1,96 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBatchStatementHandler_PlaceholderLimit(t *testing.T) {
+	db := newFakeDB(t)
+	db.exec = func(query string, args []any) (sqldriver.Result, error) {
+		if len(args) > 4 {
+			return nil, errors.New("too many placeholders")
+		}
+		if args[0] == "fail" {
+			return nil, errors.New("exec failed")
+		}
+		return sqldriver.RowsAffected(len(args) / 2), nil
+	}
+	engine := db.Engine(t, "main", `<insert id="create" maxPlaceholders="4">
+    insert into users (name, age) values
+    <foreach collection="users" item="user" separator=",">(#{user.Name}, #{user.Age})</foreach>
+</insert>
+<insert id="createAll" maxPlaceholders="4">
+    insert into users (name, age) values
+    <foreach collection="param" item="user" separator=",">(#{user.Name}, #{user.Age})</foreach>
+</insert>`)
+
+	type User struct {
+		Name string
+		Age  int
+	}
+	users := []User{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}, {"e", 5}}
+	ctx := context.Background()
+
+	// the rows within the limit are inserted by one statement without a transaction.
+	result, err := engine.Object("main.create").ExecContext(ctx, H{"users": users[:2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 2 || len(db.Calls()) != 1 || db.commits != 0 {
+		t.Errorf("expected one statement, got %d affected, %d calls and %d commits", affected, len(db.Calls()), db.commits)
+		return
+	}
+
+	for i, id := range []string{"main.create", "main.createAll"} {
+		var param Param = H{"users": users}
+		if id == "main.createAll" {
+			param = users
+		}
+		result, err = engine.Object(id).ExecContext(ctx, param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if affected, _ := result.RowsAffected(); affected != 5 {
+			t.Errorf("%s: expected 5 rows affected, got %d", id, affected)
+			return
+		}
+		calls := db.Calls()[1+i*3:]
+		if len(calls) != 3 || strings.Count(calls[2].query, "(?, ?)") != 1 || calls[2].args[0] != "e" {
+			t.Errorf("%s: expected three batches, got %v", id, calls)
+			return
+		}
+		if db.commits != i+1 {
+			t.Errorf("%s: expected the batches to be committed, got %d commits", id, db.commits)
+			return
+		}
+	}
+
+	// the batches are rolled back together.
+	failed := append(append([]User(nil), users[:4]...), User{"fail", 0})
+	if _, err = engine.Object("main.create").ExecContext(ctx, H{"users": failed}); err == nil {
+		t.Fatal("expected the failed batch to return an error")
+	}
+	if db.rollbacks != 1 || db.commits != 2 {
+		t.Errorf("expected the batches to be rolled back, got %d commits and %d rollbacks", db.commits, db.rollbacks)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+	"github.com/go-juicedev/juice/eval"
+)
+
+func TestStatement_Build(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users
+                <where>
+                    <if test='name != ""'>and name = #{name}</if>
+                    <if test="len(ids) > 0">
+                        and id in <foreach collection="ids" item="id" open="(" separator="," close=")">#{id}</foreach>
+                    </if>
+                </where>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		translator driver.Translator
+		param      H
+		expected   string
+		args       []any
+	}{
+		{driver.MySQLDriver{}.Translator(), H{"name": "a", "ids": []int{1, 2}}, "select * from users WHERE name = ? and id in (?,?)", []any{"a", 1, 2}},
+		{driver.PostgresDriver{}.Translator(), H{"name": "a", "ids": []int{1, 2}}, "select * from users WHERE name = $1 and id in ($2,$3)", []any{"a", 1, 2}},
+		{driver.PostgresDriver{}.Translator(), H{"name": "", "ids": []int{3}}, "select * from users WHERE id in ($1)", []any{3}},
+		{driver.MySQLDriver{}.Translator(), H{"name": "", "ids": []int{}}, "select * from users", nil},
+	}
+	for _, c := range cases {
+		query, args, err := statement.Build(c.translator, c.param)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, query)
+		}
+		if len(args) != len(c.args) || len(args) > 0 && !reflect.DeepEqual(args, c.args) {
+			t.Errorf("expected args %v, got %v", c.args, args)
+		}
+	}
+}
+
+func TestStatement_BuildNumberedPlaceholders(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="tree">
+                select * from users where (id = #{id} or parent_id = #{id}) and name = #{name}
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drv := driver.PostgresDriver{}
+	// each build gets a new translator, which numbers the placeholders from $1 again.
+	for range 2 {
+		query, args, err := statement.Build(drv.Translator(), H{"id": 1, "name": "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != "select * from users where (id = $1 or parent_id = $2) and name = $3" {
+			t.Errorf("unexpected query: %s", query)
+		}
+		if !reflect.DeepEqual(args, []any{1, 1, "a"}) {
+			t.Errorf("unexpected args: %v", args)
+		}
+	}
+}
+
+func TestStatement_BuildNamedArgs(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users where (id = #{id} or parent_id = #{id}) and name in
+                <foreach collection="users" item="user" open="(" separator="," close=")">#{user.Name}</foreach>
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type User struct {
+		Name string
+	}
+	query, args, err := statement.Build(driver.NewNamedTranslator(), H{"id": 1, "users": []User{{"a"}, {"b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from users where (id = :id or parent_id = :id_2) and name in (:user_Name,:user_Name_2)" {
+		t.Errorf("unexpected query: %s", query)
+	}
+	expected := []any{sql.Named("id", 1), sql.Named("id_2", 1), sql.Named("user_Name", "a"), sql.Named("user_Name_2", "b")}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	raw := NewRawSQLStatement("select * from users where id = #{id}", cfg, Select)
+	query, args, err = raw.Build(driver.NewNamedTranslator(), H{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from users where id = :id" || !reflect.DeepEqual(args, []any{sql.Named("id", 1)}) {
+		t.Errorf("unexpected raw statement: %s %v", query, args)
+	}
+}
+
+func TestStatement_ExpressionLimits(t *testing.T) {
+	mappers := `<mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users
+                <where>
+                    <foreach collection="ids" item="id" separator=" or ">
+                        <if test="id > 0 and id != 10">id = #{id}</if>
+                    </foreach>
+                </where>
+            </select>
+        </mapper>
+    </mappers>`
+	cases := []struct {
+		settings string
+		failed   bool
+	}{
+		{``, false},
+		{`<setting name="maxExpressionDepth" value="2"/>`, true},
+		{`<setting name="maxExpressionDepth" value="-1"/><setting name="maxExpressionSteps" value="4"/>`, true},
+		{`<setting name="maxExpressionSteps" value="-1"/>`, false},
+	}
+	for _, c := range cases {
+		cfg := newTestConfiguration(t, `<configuration><settings>`+c.settings+`</settings>`+mappers+`</configuration>`)
+		statement, err := cfg.GetStatement("users.list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, _, err := statement.Build(driver.MySQLDriver{}.Translator(), H{"ids": []int{1, 2}})
+		if failed := errors.Is(err, eval.ErrLimitExceeded); failed != c.failed {
+			t.Errorf("%s: expected failed %v, got %v", c.settings, c.failed, err)
+			continue
+		}
+		if !c.failed && query != "select * from users WHERE id = ? or id = ?" {
+			t.Errorf("%s: unexpected query: %s", c.settings, query)
+		}
+	}
+}
+
+func TestStatement_Flag(t *testing.T) {
+	eval.SetFlagProvider(eval.FlagMap{"new_ranking": true})
+	t.Cleanup(func() {
+		eval.SetFlagProvider(nil)
+		eval.SetStrictFlag(false)
+	})
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="byScore" flag="new_ranking">select * from users order by score desc</select>
+            <select id="byDate" flag="!new_ranking">select * from users order by created_at desc</select>
+            <select id="beta" flag="beta">select * from users</select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	build := func(id string) error {
+		statement, err := cfg.GetStatement(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = statement.Build(driver.MySQLDriver{}.Translator(), nil)
+		return err
+	}
+	if err := build("users.byScore"); err != nil {
+		t.Errorf("expected the statement to be enabled, got %v", err)
+		return
+	}
+	if err := build("users.byDate"); !errors.Is(err, ErrStatementDisabled) {
+		t.Errorf("expected ErrStatementDisabled, got %v", err)
+		return
+	}
+	if err := build("users.beta"); !errors.Is(err, ErrStatementDisabled) {
+		t.Errorf("expected ErrStatementDisabled, got %v", err)
+		return
+	}
+	eval.SetStrictFlag(true)
+	if err := build("users.beta"); !errors.Is(err, eval.ErrUnknownFlag) {
+		t.Errorf("expected ErrUnknownFlag, got %v", err)
+		return
+	}
+
+	// the flags are read at runtime.
+	eval.SetFlagProvider(eval.FlagMap{"new_ranking": false})
+	if err := build("users.byDate"); err != nil {
+		t.Errorf("expected the statement to be enabled, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-juicedev/juice/internal/reflectlite"
+)
+
+// ErrTypeAliasNotFound is an error that is returned when the resultType of a statement is not registered.
+var ErrTypeAliasNotFound = errors.New("type alias not found")
+
+// ErrResultTypeMismatch is an error that is returned when the result of a query
+// does not match the resultType declared by the statement.
+var ErrResultTypeMismatch = errors.New("result type mismatch")
+
+// typeAliases is a map of the registered type aliases.
+var typeAliases = map[string]reflect.Type{}
+
+// RegisterTypeAlias registers the type of the given value with the given name,
+// which can be used by the resultType attribute of the select statements.
+// The pointers are dereferenced, so User{} and (*User)(nil) register the same type.
+//
+//	juice.RegisterTypeAlias("main.User", User{})
+//
+//	<select id="GetUserByID" resultType="main.User">
+//	    SELECT * FROM user WHERE id = #{id}
+//	</select>
+func RegisterTypeAlias(name string, v any) {
+	if len(name) == 0 {
+		panic("name is empty")
+	}
+	if v == nil {
+		panic("juice: type alias value is nil")
+	}
+	typeAliases[name] = reflectlite.IndirectType(reflect.TypeOf(v))
+}
+
+// GetTypeAlias returns the type registered by the given name.
+func GetTypeAlias(name string) (reflect.Type, error) {
+	tp, exists := typeAliases[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrTypeAliasNotFound, name)
+	}
+	return tp, nil
+}
+
+// resolveResultType checks whether the given result type matches the resultType of the statement,
+// and returns the type of the destination which the result is bound to.
+// The result type may be the declared type, a pointer of it, or a slice or array of them.
+// The result of an interface type, like any, is bound to a slice of the declared type, or to the declared
+// type itself if the executor binds a single result, see WithSingleResult, so the binder and the result map
+// options are chosen by the declared type instead of the interface.
+// The result type is returned as is if the resultType of the statement is not set.
+func resolveResultType(statement Statement, resultType reflect.Type, single bool) (reflect.Type, error) {
+	name := statement.Attribute("resultType")
+	if len(name) == 0 {
+		return resultType, nil
+	}
+	expected, err := GetTypeAlias(name)
+	if err != nil {
+		return nil, err
+	}
+	if resultType.Kind() == reflect.Interface {
+		dest := reflect.SliceOf(expected)
+		if single {
+			dest = expected
+		}
+		if !dest.Implements(resultType) {
+			return nil, fmt.Errorf("%w: %s declares %s, but got %s", ErrResultTypeMismatch, statement.Name(), expected, resultType)
+		}
+		return dest, nil
+	}
+	elem := reflectlite.IndirectType(resultType)
+	if kind := elem.Kind(); kind == reflect.Slice || kind == reflect.Array {
+		elem = reflectlite.IndirectType(elem.Elem())
+	}
+	if elem != expected {
+		return nil, fmt.Errorf("%w: %s declares %s, but got %s", ErrResultTypeMismatch, statement.Name(), expected, resultType)
+	}
+	return resultType, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGenericExecutor_ResultType(t *testing.T) {
+	type User struct {
+		ID int64 `column:"id"`
+	}
+	type Order struct {
+		ID int64 `column:"id"`
+	}
+	RegisterTypeAlias("test.User", (*User)(nil))
+	t.Cleanup(func() { delete(typeAliases, "test.User") })
+
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id"}, [][]sqldriver.Value{{int64(1)}}, nil
+	}
+	engine := db.Engine(t, "main", `<select id="user" resultType="test.User">select id from users</select>
+<select id="missing" resultType="test.Missing">select id from users</select>`)
+
+	ctx := context.Background()
+	user, err := NewGenericManager[User](engine).Object("main.user").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 {
+		t.Errorf("expected id 1, got %d", user.ID)
+		return
+	}
+	if _, err = NewGenericManager[[]*User](engine).Object("main.user").QueryContext(ctx, nil); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = NewGenericManager[Order](engine).Object("main.user").QueryContext(ctx, nil); !errors.Is(err, ErrResultTypeMismatch) {
+		t.Errorf("expected ErrResultTypeMismatch, got %v", err)
+		return
+	}
+	if _, err = NewGenericManager[User](engine).Object("main.missing").QueryContext(ctx, nil); !errors.Is(err, ErrTypeAliasNotFound) {
+		t.Errorf("expected ErrTypeAliasNotFound, got %v", err)
+		return
+	}
+
+	// the interface results are bound to the slices of the declared type.
+	result, err := NewGenericManager[any](engine).Object("main.user").QueryContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users, ok := result.([]User); !ok || len(users) != 1 || users[0].ID != 1 {
+		t.Errorf("expected the users, got %#v", result)
+		return
+	}
+	// or to the declared type itself for the single results.
+	if result, err = WithSingleResult(NewGenericManager[any](engine).Object("main.user")).QueryContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if user, ok := result.(User); !ok || user.ID != 1 {
+		t.Errorf("expected the user, got %#v", result)
+		return
+	}
+	if _, err = WithSingleResult[any](otherExecutor[any]{}).QueryContext(ctx, nil); !errors.Is(err, ErrInvalidExecutor) {
+		t.Errorf("expected ErrInvalidExecutor, got %v", err)
+		return
+	}
+	if _, err = NewGenericManager[fmt.Stringer](engine).Object("main.user").QueryContext(ctx, nil); !errors.Is(err, ErrResultTypeMismatch) {
+		t.Errorf("expected ErrResultTypeMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrTypeHandlerExists is an error that is returned when a type handler is registered twice with the same name or type.
+var ErrTypeHandlerExists = errors.New("type handler already registered")
+
+// TypeHandler converts the values between Go and SQL in both directions.
+// It encodes the values bound by the placeholders into the arguments like the ArgTypeHandler,
+// and decodes the scanned values of the columns into the fields of the results.
+type TypeHandler interface {
+	ArgTypeHandler
+
+	// DecodeResult decodes the value scanned from a column into dst, which is a pointer to the destination.
+	// The src is nil if the column is NULL.
+	DecodeResult(src any, dst any) error
+}
+
+// TypeHandlers is the registry of the type handlers of a Configuration, keyed by their names or Go types.
+//
+// The handlers registered by names are referenced by the typeHandler of the placeholders,
+// like #{amount,typeHandler=money}, and ErrTypeHandlerNotFound is returned if the name is not registered.
+// The handlers registered by types encode the values of their types bound by the placeholders without
+// typeHandler, and decode the columns scanned into the fields of their types.
+// The names and the types are looked up here first, then in the default TypeHandlers shared by all the
+// configurations, which holds the builtin json, jsonb, bool, boolInt and boolString handlers and the ones
+// registered by the deprecated RegisterArgTypeHandler and RegisterArgConverter, so a configuration can
+// override the builtin json handler for its mappers.
+// It is not safe for concurrent use, handlers should be registered before the configuration is used.
+type TypeHandlers struct {
+	byName map[string]TypeHandler
+	byType map[reflect.Type]TypeHandler
+}
+
+// defaultTypeHandlers is the default TypeHandlers, which the TypeHandlers of the configurations fall back to.
+var defaultTypeHandlers = &TypeHandlers{
+	byName: make(map[string]TypeHandler),
+	byType: make(map[reflect.Type]TypeHandler),
+}
+
+// Register registers the handler by the name, ErrTypeHandlerExists is returned if the name is already taken.
+func (t *TypeHandlers) Register(name string, handler TypeHandler) error {
+	if name == "" {
+		return errors.New("type handler name is empty")
+	}
+	if handler == nil {
+		return errors.New("type handler is nil")
+	}
+	if _, exists := t.byName[name]; exists {
+		return fmt.Errorf("%w: %s", ErrTypeHandlerExists, name)
+	}
+	if t.byName == nil {
+		t.byName = make(map[string]TypeHandler)
+	}
+	t.byName[name] = handler
+	return nil
+}
+
+// RegisterType registers the handler by the Go type, ErrTypeHandlerExists is returned if the type is already taken.
+func (t *TypeHandlers) RegisterType(tp reflect.Type, handler TypeHandler) error {
+	if tp == nil {
+		return errors.New("type handler type is nil")
+	}
+	if handler == nil {
+		return errors.New("type handler is nil")
+	}
+	if _, exists := t.byType[tp]; exists {
+		return fmt.Errorf("%w: %s", ErrTypeHandlerExists, tp)
+	}
+	if t.byType == nil {
+		t.byType = make(map[reflect.Type]TypeHandler)
+	}
+	t.byType[tp] = handler
+	return nil
+}
+
+// Lookup returns the handler registered by the name, or the default one of the name.
+func (t *TypeHandlers) Lookup(name string) (TypeHandler, bool) {
+	if t != nil {
+		if handler, ok := t.byName[name]; ok {
+			return handler, true
+		}
+	}
+	if t != defaultTypeHandlers {
+		return defaultTypeHandlers.Lookup(name)
+	}
+	return nil, false
+}
+
+// LookupType returns the handler registered by the Go type, or the default one of the type.
+func (t *TypeHandlers) LookupType(tp reflect.Type) (TypeHandler, bool) {
+	if t != nil {
+		if handler, ok := t.byType[tp]; ok {
+			return handler, true
+		}
+	}
+	if t != defaultTypeHandlers {
+		return defaultTypeHandlers.LookupType(tp)
+	}
+	return nil, false
+}
+
+// lookupDecoder returns the handler registered by the Go type which decodes the results,
+// the ones which only encode the arguments are skipped.
+func (t *TypeHandlers) lookupDecoder(tp reflect.Type) (TypeHandler, bool) {
+	handler, ok := t.LookupType(tp)
+	if _, argOnly := handler.(argOnlyTypeHandler); argOnly {
+		return nil, false
+	}
+	return handler, ok
+}
+
+// typeHandlersOf returns the type handlers of the configuration, or nil if it has none.
+func typeHandlersOf(cfg IConfiguration) *TypeHandlers {
+	if cfg == nil {
+		return nil
+	}
+	handlers := cfg.TypeHandlers()
+	if handlers == nil || len(handlers.byName) == 0 && len(handlers.byType) == 0 {
+		return nil
+	}
+	return handlers
+}
+
+// bindArg converts the value bound by the placeholder of the name into the argument passed to the driver.
+// The placeholder flagged with a typeHandler is encoded by the handler of the name, and the others are
+// converted by convertArg, which prefers the handlers of their types.
+func bindArg(p Parameter, typeHandler, name string, value reflect.Value) (any, error) {
+	ctx := contextOf(p)
+	if ctx.prepare {
+		return preparedPlaceholder{typeHandler: typeHandler}, nil
+	}
+	if typeHandler == "" {
+		return convertArg(ctx.typeHandlers, name, value)
+	}
+	handler, ok := ctx.typeHandlers.Lookup(typeHandler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s of parameter %s", ErrTypeHandlerNotFound, typeHandler, name)
+	}
+	return encodeArg(handler, typeHandler, name, value)
+}
+
+// typeHandlerScanner is a sql.Scanner which decodes the scanned value into the destination by the TypeHandler.
+type typeHandlerScanner struct {
+	handler TypeHandler
+	dest    any
+}
+
+// Scan implements sql.Scanner.
+func (s *typeHandlerScanner) Scan(src any) error {
+	return s.handler.DecodeResult(src, s.dest)
+}
+
+// ensure typeHandlerScanner implements sql.Scanner.
+var _ sql.Scanner = (*typeHandlerScanner)(nil)
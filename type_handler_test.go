@@ -0,0 +1,241 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testUserStatus int
+
+const (
+	testUserActive testUserStatus = iota + 1
+	testUserBanned
+)
+
+// testUserStatusHandler maps the testUserStatus to its int value.
+type testUserStatusHandler struct{}
+
+func (testUserStatusHandler) EncodeArg(value any) (any, error) {
+	status, ok := value.(testUserStatus)
+	if !ok {
+		return nil, fmt.Errorf("expected testUserStatus, got %T", value)
+	}
+	return int64(status) * 10, nil
+}
+
+func (testUserStatusHandler) DecodeResult(src any, dst any) error {
+	value, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("expected int64, got %T", src)
+	}
+	*dst.(*testUserStatus) = testUserStatus(value / 10)
+	return nil
+}
+
+type testProfile struct {
+	Bio string `json:"bio"`
+}
+
+// testJSONHandler serializes the values to JSON for the jsonb columns.
+type testJSONHandler struct{}
+
+func (testJSONHandler) EncodeArg(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (testJSONHandler) DecodeResult(src any, dst any) error {
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(src), dst)
+	case []byte:
+		return json.Unmarshal(src, dst)
+	default:
+		return fmt.Errorf("unexpected json value %T", src)
+	}
+}
+
+func TestTypeHandlers_Register(t *testing.T) {
+	var handlers TypeHandlers
+	if err := handlers.Register("status", testUserStatusHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handlers.Register("status", testJSONHandler{}); !errors.Is(err, ErrTypeHandlerExists) {
+		t.Errorf("expected ErrTypeHandlerExists, got %v", err)
+	}
+	if err := handlers.RegisterType(reflect.TypeFor[testUserStatus](), testUserStatusHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handlers.RegisterType(reflect.TypeFor[testUserStatus](), testUserStatusHandler{}); !errors.Is(err, ErrTypeHandlerExists) {
+		t.Errorf("expected ErrTypeHandlerExists, got %v", err)
+	}
+	if err := handlers.Register("", testUserStatusHandler{}); err == nil {
+		t.Error("expected error for empty name")
+	}
+	if err := handlers.Register("nil", nil); err == nil {
+		t.Error("expected error for nil handler")
+	}
+	if _, ok := handlers.Lookup("status"); !ok {
+		t.Error("expected status handler")
+	}
+	if _, ok := handlers.Lookup("missing"); ok {
+		t.Error("unexpected missing handler")
+	}
+	if _, ok := handlers.LookupType(reflect.TypeFor[int]()); ok {
+		t.Error("unexpected int handler")
+	}
+}
+
+func TestConfiguration_TypeHandlers(t *testing.T) {
+	db := newFakeDB(t)
+	db.query = func(string, []any) ([]string, [][]sqldriver.Value, error) {
+		return []string{"id", "status", "profile"}, [][]sqldriver.Value{
+			{int64(1), int64(10), `{"bio":"a"}`},
+			{int64(2), int64(20), nil},
+		}, nil
+	}
+	cfg := db.Configuration(t, "main", `<insert id="create">
+    insert into users (status, profile) values (#{status}, #{profile,typeHandler=profile})
+</insert>
+<insert id="missing">
+    insert into users (profile) values (#{profile,typeHandler=yaml})
+</insert>
+<select id="list">select id, status, profile from users</select>`)
+	if err := cfg.TypeHandlers().RegisterType(reflect.TypeFor[testUserStatus](), testUserStatusHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.TypeHandlers().Register("profile", testJSONHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+
+	// the values are encoded by the handlers of their types and of the names of the placeholders.
+	param := H{"status": testUserBanned, "profile": testProfile{Bio: "b"}}
+	if _, err = engine.Object("main.create").ExecContext(context.Background(), param); err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if len(calls) != 1 || calls[0].args[0] != int64(20) || calls[0].args[1] != `{"bio":"b"}` {
+		t.Errorf("unexpected calls: %v", calls)
+		return
+	}
+	if _, err = engine.Object("main.missing").ExecContext(context.Background(), param); !errors.Is(err, ErrTypeHandlerNotFound) {
+		t.Errorf("expected ErrTypeHandlerNotFound, got %v", err)
+		return
+	}
+
+	// the columns are decoded into the fields of the registered types.
+	type User struct {
+		ID      int64          `column:"id"`
+		Status  testUserStatus `column:"status"`
+		Profile testProfile    `column:"profile"`
+	}
+	if err = cfg.TypeHandlers().RegisterType(reflect.TypeFor[testProfile](), testJSONHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewGenericManager[[]User](engine).Object("main.list").QueryContext(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []User{{ID: 1, Status: testUserActive, Profile: testProfile{Bio: "a"}}, {ID: 2, Status: testUserBanned}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("unexpected users: %+v", users)
+	}
+}
+
+func TestConfiguration_TypeHandlersPrecedence(t *testing.T) {
+	db := newFakeDB(t)
+	cfg := db.Configuration(t, "main", `<insert id="create">
+    insert into users (profile, active) values (#{profile,typeHandler=json}, #{active,typeHandler=boolInt})
+</insert>`)
+	engine, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = engine.Close() })
+	param := H{"profile": testProfile{Bio: "b"}, "active": true}
+
+	// the global handlers are used if the configuration has none of the names.
+	if _, err = engine.Object("main.create").ExecContext(context.Background(), param); err != nil {
+		t.Fatal(err)
+	}
+	calls := db.Calls()
+	if data, ok := calls[0].args[0].([]byte); !ok || string(data) != `{"bio":"b"}` || calls[0].args[1] != 1 {
+		t.Errorf("unexpected args: %v", calls[0].args)
+		return
+	}
+
+	// the handler of the configuration overrides the global one of the same name.
+	if err = cfg.TypeHandlers().Register("json", testJSONHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.Object("main.create").ExecContext(context.Background(), param); err != nil {
+		t.Fatal(err)
+	}
+	if calls = db.Calls(); calls[1].args[0] != `{"bio":"b"}` || calls[1].args[1] != 1 {
+		t.Errorf("unexpected args: %v", calls[1].args)
+	}
+}
+
+func TestTypeHandlers_Defaults(t *testing.T) {
+	RegisterArgConverter(func(status testUserStatus) (any, error) { return int64(status), nil })
+	t.Cleanup(func() { delete(defaultTypeHandlers.byType, reflect.TypeFor[testUserStatus]()) })
+
+	// the configurations without the handler of the type fall back to the default ones.
+	handlers := &TypeHandlers{}
+	arg, err := convertArg(handlers, "status", reflect.ValueOf(testUserBanned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arg != int64(2) {
+		t.Errorf("expected the default converter to be used, got %v", arg)
+		return
+	}
+	// the converters only encode the arguments.
+	if _, ok := handlers.lookupDecoder(reflect.TypeFor[testUserStatus]()); ok {
+		t.Error("expected the default converter not to decode the results")
+		return
+	}
+
+	// the handler of the configuration takes precedence over the default one of the same type.
+	if err = handlers.RegisterType(reflect.TypeFor[testUserStatus](), testUserStatusHandler{}); err != nil {
+		t.Fatal(err)
+	}
+	if arg, err = convertArg(handlers, "status", reflect.ValueOf(testUserBanned)); err != nil || arg != int64(20) {
+		t.Errorf("expected the handler of the configuration to be used, got %v %v", arg, err)
+		return
+	}
+	if _, ok := handlers.lookupDecoder(reflect.TypeFor[testUserStatus]()); !ok {
+		t.Error("expected the handler of the configuration to decode the results")
+	}
+}
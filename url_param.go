@@ -0,0 +1,138 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// URLValueKind is the kind of the value which a url value is coerced to.
+type URLValueKind int
+
+const (
+	// URLValueString keeps the value as a string, which is the default.
+	URLValueString URLValueKind = iota
+
+	// URLValueInt coerces the value to an int64.
+	URLValueInt
+
+	// URLValueFloat coerces the value to a float64.
+	URLValueFloat
+
+	// URLValueBool coerces the value to a bool.
+	URLValueBool
+)
+
+// coerce coerces the given url value to the kind.
+func (k URLValueKind) coerce(value string) (any, error) {
+	switch k {
+	case URLValueInt:
+		return strconv.ParseInt(value, 10, 64)
+	case URLValueFloat:
+		return strconv.ParseFloat(value, 64)
+	case URLValueBool:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+// urlValuesOption is the option of URLValuesParam.
+type urlValuesOption struct {
+	kinds map[string]URLValueKind
+	lists map[string]bool
+}
+
+// URLValuesOptionFunc is the function to set the option of URLValuesParam.
+type URLValuesOptionFunc func(*urlValuesOption)
+
+// URLValuesAs coerces the values of the given keys to the kind, the keys not given are kept as strings.
+func URLValuesAs(kind URLValueKind, keys ...string) URLValuesOptionFunc {
+	return func(o *urlValuesOption) {
+		for _, key := range keys {
+			o.kinds[key] = kind
+		}
+	}
+}
+
+// URLValuesList binds all the values of the given keys as a slice, even if there is only one value,
+// so they can always be iterated by foreach.
+func URLValuesList(keys ...string) URLValuesOptionFunc {
+	return func(o *urlValuesOption) {
+		for _, key := range keys {
+			o.lists[key] = true
+		}
+	}
+}
+
+// URLValuesParam returns the parameter of the given url values, which saves writing a binding struct
+// for the simple endpoints. Each key is bound to its first value, even if it is repeated, so a client
+// can not turn a scalar into a slice, and only the keys given by URLValuesList are bound to all their
+// values as a slice, which can be iterated by foreach:
+//
+//	// GET /users?status=active&limit=20&id=1&id=2
+//	param, err := juice.URLValuesParam(r.URL.Query(),
+//	    juice.URLValuesAs(juice.URLValueInt, "limit", "id"),
+//	    juice.URLValuesList("id"),
+//	)
+//
+//	<select id="ListUsers">
+//	    SELECT * FROM users WHERE status = #{status} AND id IN
+//	    <foreach collection="id" item="item" open="(" separator="," close=")">#{item}</foreach>
+//	    LIMIT #{limit}
+//	</select>
+//
+// The values are strings unless they are coerced by URLValuesAs, an error is returned if the coercion fails.
+func URLValuesParam(values url.Values, opts ...URLValuesOptionFunc) (H, error) {
+	option := urlValuesOption{kinds: map[string]URLValueKind{}, lists: map[string]bool{}}
+	for _, opt := range opts {
+		opt(&option)
+	}
+	param := make(H, len(values))
+	for key, items := range values {
+		kind := option.kinds[key]
+		if !option.lists[key] {
+			if len(items) == 0 {
+				continue
+			}
+			value, err := kind.coerce(items[0])
+			if err != nil {
+				return nil, fmt.Errorf("url value %s: %w", key, err)
+			}
+			param[key] = value
+			continue
+		}
+		list := make([]any, 0, len(items))
+		for _, item := range items {
+			value, err := kind.coerce(item)
+			if err != nil {
+				return nil, fmt.Errorf("url value %s: %w", key, err)
+			}
+			list = append(list, value)
+		}
+		param[key] = list
+	}
+	return param, nil
+}
+
+// RequestQueryParam returns the parameter of the query values of the request, see URLValuesParam.
+func RequestQueryParam(r *http.Request, opts ...URLValuesOptionFunc) (H, error) {
+	return URLValuesParam(r.URL.Query(), opts...)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/go-juicedev/juice/driver"
+)
+
+func TestURLValuesParam(t *testing.T) {
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="list">
+                select * from users where status = #{status} and id in
+                <foreach collection="id" item="item" open="(" separator=", " close=")">#{item}</foreach>
+                limit #{limit}
+            </select>
+        </mapper>
+    </mappers>
+</configuration>`)
+	statement, err := cfg.GetStatement("users.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/users?status=active&limit=20&id=1&id=2", nil)
+	param, err := RequestQueryParam(r, URLValuesAs(URLValueInt, "limit", "id"), URLValuesList("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := statement.Build(driver.MySQLDriver{}.Translator(), param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "select * from users where status = ? and id in (?, ?) limit ?" {
+		t.Errorf("unexpected query: %s", query)
+		return
+	}
+	if !reflect.DeepEqual(args, []any{"active", int64(1), int64(2), int64(20)}) {
+		t.Errorf("unexpected args: %v", args)
+		return
+	}
+
+	// a single value is bound as a slice by URLValuesList.
+	param, err = URLValuesParam(url.Values{"id": {"1"}, "status": {"active"}, "limit": {"1"}}, URLValuesList("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(param["id"], []any{"1"}) || param["limit"] != "1" {
+		t.Errorf("unexpected param: %v", param)
+		return
+	}
+
+	// the repeated keys not given by URLValuesList are bound to their first values.
+	param, err = URLValuesParam(url.Values{"id": {"1"}, "status": {"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if param["status"] != "a" {
+		t.Errorf("unexpected param: %v", param)
+		return
+	}
+
+	if _, err = URLValuesParam(url.Values{"limit": {"ten"}}, URLValuesAs(URLValueInt, "limit")); err == nil {
+		t.Error("expected error for invalid int")
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-juicedev/juice/eval"
+	"github.com/go-juicedev/juice/internal/reflectlite"
+)
+
+// PlaceholderError is an error that is returned by ValidateConfiguration when a #{} placeholder
+// or a foreach collection of a statement is not reachable from the parameterType of the statement.
+type PlaceholderError struct {
+	// Statement is the name of the statement.
+	Statement string
+
+	// Placeholder is the placeholder which is not reachable, like #{usrId}.
+	Placeholder string
+
+	// Type is the parameterType the placeholder is checked against.
+	Type reflect.Type
+}
+
+// Error implements error.
+func (e *PlaceholderError) Error() string {
+	return fmt.Sprintf("statement %s: %s is not bindable from %s", e.Statement, e.Placeholder, e.Type)
+}
+
+// ValidateConfiguration checks the statements of the configuration statically, so the mistakes are caught
+// before runtime. Each #{} placeholder and foreach collection of the statements which declare a parameterType
+// must be a reachable field or path of the type, which is resolved by the type alias registry:
+//
+//	juice.RegisterTypeAlias("main.User", User{})
+//
+//	<select id="GetUser" parameterType="main.User">
+//	    SELECT * FROM user WHERE id = #{usrId}
+//	</select>
+//
+// The struct fields are matched by their names, or by their param tags for the lowercase names, the same as
+// binding at runtime. The paths into maps and interfaces are not checked, since they are only known at runtime,
+// and the names introduced by foreach are skipped.
+// All the errors are joined, each of them is a *PlaceholderError or an error of resolving the parameterType.
+func ValidateConfiguration(cfg IConfiguration) error {
+	configuration, ok := cfg.(*Configuration)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, cfg)
+	}
+	if configuration.mappers == nil || configuration.mappers.mappers == nil {
+		return nil
+	}
+	var statements []*xmlSQLStatement
+	for _, item := range configuration.mappers.mappers.All() {
+		for _, statement := range item.Value.statements {
+			statements = append(statements, statement)
+		}
+		for _, byDatabaseID := range item.Value.databaseIDStatements {
+			for _, statement := range byDatabaseID {
+				statements = append(statements, statement)
+			}
+		}
+	}
+	slices.SortStableFunc(statements, func(a, b *xmlSQLStatement) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	var errs []error
+	for _, statement := range statements {
+		errs = append(errs, validateStatementPlaceholders(statement)...)
+	}
+	return errors.Join(errs...)
+}
+
+// validateStatementPlaceholders checks the placeholders of the statement against its parameterType.
+func validateStatementPlaceholders(statement *xmlSQLStatement) []error {
+	name := statement.Attribute("parameterType")
+	if len(name) == 0 {
+		return nil
+	}
+	parameterType, err := GetTypeAlias(name)
+	if err != nil {
+		return []error{fmt.Errorf("statement %s: %w", statement.Name(), err)}
+	}
+	// the parameters which are neither structs nor maps are wrapped by the paramName.
+	var wrapKey string
+	if kind := parameterType.Kind(); kind != reflect.Struct && kind != reflect.Map {
+		if wrapKey = statement.Attribute("paramName"); wrapKey == "" {
+			wrapKey = eval.DefaultParamKey()
+		}
+	}
+	var errs []error
+	check := func(placeholder, path string) {
+		if wrapKey != "" {
+			root, rest, _ := strings.Cut(path, ".")
+			if root != wrapKey {
+				errs = append(errs, &PlaceholderError{Statement: statement.Name(), Placeholder: placeholder, Type: parameterType})
+				return
+			}
+			path = rest
+		}
+		if !reachableFrom(parameterType, path) {
+			errs = append(errs, &PlaceholderError{Statement: statement.Name(), Placeholder: placeholder, Type: parameterType})
+		}
+	}
+	// the defaults are always resolved.
+	walkPlaceholders(statement.Nodes, slices.Collect(maps.Keys(statement.defaults)), check)
+	return errs
+}
+
+// walkPlaceholders calls the visit with the placeholders and the foreach collections of the node,
+// except the ones starting with the names in the scope, which are introduced by foreach.
+func walkPlaceholders(node Node, scope []string, visit func(placeholder, path string)) {
+	visitPath := func(placeholder, path string) {
+		root, _, _ := strings.Cut(path, ".")
+		if !slices.Contains(scope, root) {
+			visit(placeholder, path)
+		}
+	}
+	visitText := func(text string) {
+		for _, matched := range paramRegex.FindAllStringSubmatch(text, -1) {
+			visitPath(matched[0], matched[1])
+		}
+	}
+	switch n := node.(type) {
+	case *TextNode:
+		for _, matched := range n.placeholder {
+			visitPath(matched[0], matched[1])
+		}
+	case ValuesNode:
+		for _, item := range n {
+			visitText(item.value)
+		}
+	case *ParamValuesNode:
+		visitPath(`param="`+n.Param+`"`, n.Param)
+		walkPlaceholders(n.Values, scope, visit)
+	case *CaseNode:
+		visitPath(`collection="`+n.Collection+`"`, n.Collection)
+	case NodeGroup:
+		for _, child := range n {
+			walkPlaceholders(child, scope, visit)
+		}
+	case *ConditionNode:
+		walkPlaceholders(n.Nodes, scope, visit)
+	case *WhereNode:
+		walkPlaceholders(n.Nodes, scope, visit)
+	case *TrimNode:
+		walkPlaceholders(n.Nodes, scope, visit)
+	case *SetNode:
+		if n.Param != "" {
+			visitPath(`param="`+n.Param+`"`, n.Param)
+		}
+		walkPlaceholders(n.Nodes, scope, visit)
+	case *OtherwiseNode:
+		walkPlaceholders(n.Nodes, scope, visit)
+	case *BlockNode:
+		walkPlaceholders(n.Nodes, scope, visit)
+	case *BindNode:
+		walkPlaceholders(n.Nodes, slices.Concat(scope, []string{n.Name}), visit)
+	case *ChooseNode:
+		for _, child := range n.WhenNodes {
+			walkPlaceholders(child, scope, visit)
+		}
+		if n.OtherwiseNode != nil {
+			walkPlaceholders(n.OtherwiseNode, scope, visit)
+		}
+	case *ForeachNode:
+		visitPath(`collection="`+n.Collection+`"`, n.Collection)
+		if n.Collection2 != "" {
+			visitPath(`collection2="`+n.Collection2+`"`, n.Collection2)
+		}
+		inner := slices.Concat(scope, []string{n.Item, n.Index, n.Item2, ForeachFirstKey, ForeachLastKey})
+		walkPlaceholders(NodeGroup(n.Nodes), inner, visit)
+	case *SQLNode:
+		walkPlaceholders(n.nodes, scope, visit)
+	case *IncludeNode:
+		if sqlNode, err := n.resolve(); err == nil {
+			walkPlaceholders(sqlNode, scope, visit)
+		}
+	}
+}
+
+// reachableFrom reports whether the dotted path is reachable from the type, the same as binding at runtime.
+// The paths into maps and interfaces are always reachable, since they are only known at runtime.
+func reachableFrom(tp reflect.Type, path string) bool {
+	for path != "" {
+		var segment string
+		segment, path, _ = strings.Cut(path, ".")
+		tp = reflectlite.IndirectType(tp)
+		switch tp.Kind() {
+		case reflect.Interface:
+			return true
+		case reflect.Map:
+			tp = tp.Elem()
+		case reflect.Struct:
+			var indexes []int
+			if token := segment[0]; token >= 'A' && token <= 'Z' {
+				field, ok := tp.FieldByName(segment)
+				if !ok {
+					return false
+				}
+				indexes = field.Index
+			} else {
+				var ok bool
+				if indexes, ok = reflectlite.TypeFrom(tp).GetFieldIndexesFromTag(eval.DefaultParamKey(), segment); !ok {
+					return false
+				}
+			}
+			tp = tp.FieldByIndex(indexes).Type
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(segment); err != nil {
+				return false
+			}
+			tp = tp.Elem()
+		default:
+			return false
+		}
+	}
+	return true
+}
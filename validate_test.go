@@ -0,0 +1,82 @@
+/*
+Copyright 2023 eatmoreapple
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package juice
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateConfiguration(t *testing.T) {
+	type Address struct {
+		City string `param:"city"`
+	}
+	type User struct {
+		ID      int64 `param:"id"`
+		Name    string
+		Address Address
+		Tags    []string
+		Extra   map[string]any
+	}
+	RegisterTypeAlias("test.User", User{})
+	RegisterTypeAlias("test.ID", int64(0))
+	t.Cleanup(func() {
+		delete(typeAliases, "test.User")
+		delete(typeAliases, "test.ID")
+	})
+
+	cfg := newTestConfiguration(t, `<configuration>
+    <mappers>
+        <mapper namespace="users">
+            <select id="valid" parameterType="test.User">
+                select * from users where id = #{id} and name = #{Name} and city = #{Address.city}
+                and tag = #{Tags.0} and extra = #{Extra.anything.nested}
+                <foreach collection="Tags" item="tag" separator=",">#{tag}</foreach>
+            </select>
+            <select id="typo" parameterType="test.User">
+                select * from users where id = #{usrId} and city = #{Address.town}
+            </select>
+            <select id="scalar" parameterType="test.ID">
+                select * from users where id = #{param} or id = #{id}
+            </select>
+            <select id="untyped">select * from users where id = #{whatever}</select>
+        </mapper>
+    </mappers>
+</configuration>`)
+
+	err := ValidateConfiguration(cfg)
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	var got []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var placeholderErr *PlaceholderError
+		if !errors.As(e, &placeholderErr) {
+			t.Fatalf("unexpected error: %v", e)
+		}
+		got = append(got, placeholderErr.Statement+" "+placeholderErr.Placeholder)
+	}
+	expected := []string{"users.scalar #{id}", "users.typo #{usrId}", "users.typo #{Address.town}"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], got[i])
+		}
+	}
+}